@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Keys under which the token middleware stores the caller identity in the gin context
+const (
+	CTX_CLAIMS = "auth.claims"
+)
+
+// SetClaims stores the verified claims of the request
+func SetClaims(c *gin.Context, claims Claims) {
+	c.Set(CTX_CLAIMS, claims)
+}
+
+// ClaimsFrom returns the verified claims of the request, false for anonymous requests
+func ClaimsFrom(c *gin.Context) (Claims, bool) {
+	value, exist := c.Get(CTX_CLAIMS)
+	if !exist {
+		return Claims{}, false
+	}
+	claims, ok := value.(Claims)
+	return claims, ok
+}
+
+// UserID returns the authenticated user of the request, "" for anonymous requests
+func UserID(c *gin.Context) string {
+	claims, _ := ClaimsFrom(c)
+	return claims.Subject
+}
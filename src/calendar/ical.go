@@ -0,0 +1,124 @@
+// Package calendar writes iCalendar (RFC 5545) feeds, so calendar apps can subscribe to the sessions
+package calendar
+
+import (
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	CONTENT_TYPE = "text/calendar"
+	PRODUCT_ID   = "-//ATV//Sessions//ES"
+
+	MAX_LINE = 75 // Octets per line before it is folded
+)
+
+// Statuses of an event
+const (
+	STATUS_CONFIRMED = "CONFIRMED"
+	STATUS_TENTATIVE = "TENTATIVE"
+	STATUS_CANCELLED = "CANCELLED"
+)
+
+// Event is one VEVENT of the feed. AllDay events only keep the date of Start and End.
+type Event struct {
+	UID         string // Stays the same for the event, so clients update it instead of adding another
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Status      string
+	Created     time.Time
+	Modified    time.Time
+}
+
+// Feed is a VCALENDAR holding the events of a student or companion
+type Feed struct {
+	Name   string
+	Events []Event
+}
+
+// Write sends the feed as iCalendar text, stamped with now
+func (f Feed) Write(w io.Writer, now time.Time) error {
+	var out writer
+	out.line("BEGIN", "VCALENDAR")
+	out.line("VERSION", "2.0")
+	out.line("PRODID", PRODUCT_ID)
+	out.line("CALSCALE", "GREGORIAN")
+	out.line("METHOD", "PUBLISH")
+	if f.Name != "" {
+		out.line("X-WR-CALNAME", Escape(f.Name))
+	}
+
+	for _, event := range f.Events {
+		out.line("BEGIN", "VEVENT")
+		out.line("UID", Escape(event.UID))
+		out.line("DTSTAMP", dateTime(now))
+		if event.AllDay {
+			out.line("DTSTART;VALUE=DATE", date(event.Start))
+			out.line("DTEND;VALUE=DATE", date(event.End))
+		} else {
+			out.line("DTSTART", dateTime(event.Start))
+			out.line("DTEND", dateTime(event.End))
+		}
+		out.line("SUMMARY", Escape(event.Summary))
+		if event.Description != "" {
+			out.line("DESCRIPTION", Escape(event.Description))
+		}
+		if event.Status != "" {
+			out.line("STATUS", event.Status)
+		}
+		if !event.Created.IsZero() {
+			out.line("CREATED", dateTime(event.Created))
+		}
+		if !event.Modified.IsZero() {
+			out.line("LAST-MODIFIED", dateTime(event.Modified))
+		}
+		out.line("END", "VEVENT")
+	}
+
+	out.line("END", "VCALENDAR")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// Escape quotes the characters with a meaning on TEXT values
+func Escape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(text)
+}
+
+func dateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func date(t time.Time) string {
+	return t.Format("20060102")
+}
+
+// writer builds the content lines, ended by CRLF and folded every MAX_LINE octets
+type writer struct {
+	strings.Builder
+}
+
+func (w *writer) line(name string, value string) {
+	line, limit := name+":"+value, MAX_LINE
+	for len(line) > limit {
+		cut := limit
+		for !utf8.RuneStart(line[cut]) {
+			cut-- // Never split a character
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line, limit = line[cut:], MAX_LINE-1 // The folded lines start with a space
+	}
+	w.WriteString(line + "\r\n")
+}
@@ -0,0 +1,44 @@
+package calendar
+
+import (
+	"dainxor/atv/models"
+	"strings"
+	"time"
+)
+
+const UID_DOMAIN = "sessions.atv" // Session UIDs are <session id>@UID_DOMAIN
+
+// SessionEvent turns a session into an event titled after its type, false when its date can not be read.
+// Sessions dated without a time last the whole day, the others last length.
+func SessionEvent(session models.SessionDBMongo, sessionType string, length time.Duration) (Event, bool) {
+	start, ok := session.When()
+	if !ok {
+		return Event{}, false
+	}
+
+	event := Event{
+		UID:      session.ID.Hex() + "@" + UID_DOMAIN,
+		Summary:  sessionType,
+		Start:    start,
+		End:      start.Add(length),
+		Status:   STATUS_CONFIRMED,
+		Created:  session.CreatedAt,
+		Modified: session.UpdatedAt,
+	}
+	if len(session.Date) == len(time.DateOnly) {
+		event.AllDay, event.End = true, start.AddDate(0, 0, 1)
+	}
+	if session.Is(models.STATUS_CANCELLED) {
+		event.Status = STATUS_CANCELLED
+	}
+
+	var description []string
+	if name := strings.TrimSpace(session.StudentName + " " + session.StudentSurname); name != "" {
+		description = append(description, "Estudiante: "+name)
+	}
+	if name := strings.TrimSpace(session.CompanionName + " " + session.CompanionSurname); name != "" {
+		description = append(description, "Acompañante: "+name)
+	}
+	event.Description = strings.Join(description, "\n")
+	return event, true
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"dainxor/atv/configs/settings"
+)
+
+// command runs a command line subcommand and returns the exit code
+func command(args []string) int {
+	switch strings.Join(args, " ") {
+	case "config check":
+		return configCheck()
+	default:
+		fmt.Fprintln(os.Stderr, "Unknown command:", strings.Join(args, " "))
+		fmt.Fprintln(os.Stderr, "Usage: atv [config check]")
+		return 2
+	}
+}
+
+// configCheck prints the effective configuration and where each value came from,
+// then every problem found. Exits with 1 when the configuration has problems.
+func configCheck() int {
+	loaded := settings.Load()
+
+	if loaded.ConfigFile != "" {
+		fmt.Println("Config file:", loaded.ConfigFile)
+	}
+	for _, field := range loaded.Fields {
+		fmt.Printf("%s = %s (%s)\n", field.Env, field.Value, field.Source)
+	}
+
+	if len(loaded.Problems) == 0 {
+		fmt.Println("\nConfiguration OK")
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%d problem(s) found:\n", len(loaded.Problems))
+	for _, problem := range loaded.Problems {
+		fmt.Fprintln(os.Stderr, " -", problem)
+	}
+	return 1
+}
@@ -2,12 +2,17 @@ package configs
 
 import (
 	"cmp"
+	"dainxor/atv/configs/settings"
 	"dainxor/atv/logger"
-	"os"
+	"dainxor/atv/models"
+	"runtime/debug"
 	"strconv"
 	"strings"
 )
 
+// Set at build time with -ldflags "-X dainxor/atv/configs.buildVersion=<version>"
+var buildVersion = "dev"
+
 const (
 	DEFAULT_ROUTE_VERSION = "1"     // Default version for the API routes
 	DEFAULT_API_VERSION   = "0.1.4" // Default version for the API
@@ -20,6 +25,8 @@ type appType struct {
 	apiMajorVersion uint64
 	apiMinorVersion uint64
 	apiPatchVersion uint64
+
+	buildCommit string
 }
 
 var App appType
@@ -31,32 +38,52 @@ func ReloadAppEnv() {
 	envInit()
 }
 func envInit() {
-	stringRoutesVersion := cmp.Or(os.Getenv("ATV_ROUTE_VERSION"), DEFAULT_ROUTE_VERSION)
-	App.routesVersion, _ = strconv.ParseUint(stringRoutesVersion, 10, 64)
+	config := settings.Get().App
+	App.routesVersion = cmp.Or(config.RoutesVersion, versionMajor(DEFAULT_ROUTE_VERSION))
+	models.SetRoutesVersion(App.routesVersion)
 
-	App.apiVersion = cmp.Or(os.Getenv("ATV_API_VERSION"), DEFAULT_API_VERSION)
+	App.apiVersion = cmp.Or(config.ApiVersion, DEFAULT_API_VERSION)
 	App.apiMajorVersion = versionMajor(App.apiVersion)
 	App.apiMinorVersion = versionMinor(App.apiVersion)
 	App.apiPatchVersion = versionPatch(App.apiVersion)
+	App.buildCommit = vcsRevision()
 
 	logger.Info("Application initialized with API version:", App.apiVersion)
 	logger.Info("Application initialized with Routes version:", App.routesVersion)
 }
 
-func versionMajor(version string) uint64 {
-	extractedStr := strings.Split(version, ".")[0]
-	num, _ := strconv.ParseUint(extractedStr, 10, 64)
+// vcsRevision returns the commit embedded by the go toolchain, "unknown" if there is none
+func vcsRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return "unknown"
+}
+
+// versionPart returns the numeric part at index of a "major.minor.patch" version,
+// 0 when the version is shorter or the part is not a number
+func versionPart(version string, index int) uint64 {
+	parts := strings.Split(version, ".")
+	if index >= len(parts) {
+		return 0
+	}
+	num, _ := strconv.ParseUint(parts[index], 10, 64)
 	return num
 }
+func versionMajor(version string) uint64 {
+	return versionPart(version, 0)
+}
 func versionMinor(version string) uint64 {
-	extractedStr := strings.Split(version, ".")[1]
-	num, _ := strconv.ParseUint(extractedStr, 10, 64)
-	return num
+	return versionPart(version, 1)
 }
 func versionPatch(version string) uint64 {
-	extractedStr := strings.Split(version, ".")[2]
-	num, _ := strconv.ParseUint(extractedStr, 10, 64)
-	return num
+	return versionPart(version, 2)
 }
 
 func (appType) RoutesVersion() uint64 {
@@ -75,3 +102,10 @@ func (appType) ApiMinor() uint64 {
 func (appType) ApiPatch() uint64 {
 	return App.apiPatchVersion
 }
+
+func (appType) BuildVersion() string {
+	return buildVersion
+}
+func (appType) BuildCommit() string {
+	return App.buildCommit
+}
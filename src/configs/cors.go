@@ -0,0 +1,264 @@
+package configs
+
+import (
+	"cmp"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"errors"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DEFAULT_CORS_PROFILE     = "development"
+	DEFAULT_CORS_CONFIG_FILE = "cors.yaml"
+	DEFAULT_CORS_MAX_AGE     = 12 * time.Hour
+)
+
+// CORSConfig is the cross-origin policy applied by the CORS middleware.
+// Origins may use a wildcard for subdomains, as in "https://*.example.com",
+// which matches any subdomain of example.com but not example.com itself.
+type CORSConfig struct {
+	AllowOrigins     []string      `yaml:"allow_origins"`
+	AllowMethods     []string      `yaml:"allow_methods"`
+	AllowHeaders     []string      `yaml:"allow_headers"`
+	ExposeHeaders    []string      `yaml:"expose_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// corsProfile is a CORSConfig as written on the config file, unset fields keep the inherited value
+type corsProfile struct {
+	AllowOrigins     []string       `yaml:"allow_origins"`
+	AllowMethods     []string       `yaml:"allow_methods"`
+	AllowHeaders     []string       `yaml:"allow_headers"`
+	ExposeHeaders    []string       `yaml:"expose_headers"`
+	AllowCredentials *bool          `yaml:"allow_credentials"`
+	MaxAge           *time.Duration `yaml:"max_age"`
+}
+
+// Built-in profiles, a config file can override or add to them
+var corsProfiles = map[string]CORSConfig{
+	"development": {
+		AllowOrigins: []string{"http://localhost:3000", "http://127.0.0.1:3000"},
+	},
+	"production": {
+		AllowOrigins: []string{}, // Must be configured, nothing is allowed by default
+	},
+}
+
+type corsType struct{}
+
+// corsStateType is the policy in use, replaced as a whole on reload
+type corsStateType struct {
+	profile    string
+	config     CORSConfig
+	generation uint64
+}
+
+var CORS corsType
+var corsState atomic.Pointer[corsStateType]
+
+func init() {
+	CORS.envInit()
+
+	// Always rebuilt, the profiles file may have changed even if the settings did not
+	settings.OnReload(func(_ *settings.Loaded, _ *settings.Loaded) {
+		ReloadCORSEnv()
+	})
+}
+func ReloadCORSEnv() {
+	CORS.envInit()
+}
+
+// DefaultCORSConfig returns the settings every profile starts from,
+// the headers include TENANCY_HEADER so a super-admin UI can pick a tenant
+func DefaultCORSConfig() CORSConfig {
+	headers := []string{"Origin", "Content-Length", "Content-Type", "Authorization", "traceparent"}
+	if header := settings.Get().Tenancy.Header; header != "" {
+		headers = append(headers, header)
+	}
+
+	return CORSConfig{
+		AllowOrigins:  []string{},
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:  headers,
+		ExposeHeaders: []string{"X-Trace-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
+		MaxAge:        DEFAULT_CORS_MAX_AGE,
+	}
+}
+
+// envInit builds the policy in layers: defaults, the built-in profile,
+// the profile on CORS_CONFIG_FILE and last the CORS_* settings
+func (corsType) envInit() {
+	loaded := settings.Current()
+	name := strings.ToLower(cmp.Or(loaded.Config.CORS.Profile, loaded.Config.App.Env, DEFAULT_CORS_PROFILE))
+	config := DefaultCORSConfig()
+
+	if profile, exist := corsProfiles[name]; exist {
+		config = mergeCORS(config, toProfile(profile))
+	}
+
+	path := cmp.Or(loaded.Config.CORS.ConfigFile, DEFAULT_CORS_CONFIG_FILE)
+	profiles, err := loadCORSFile(path)
+	if err != nil && (loaded.IsSet("CORS_CONFIG_FILE") || !errors.Is(err, os.ErrNotExist)) {
+		logger.Warning("Failed to load CORS config file:", err)
+	}
+	if profile, exist := profiles[name]; exist {
+		config = mergeCORS(config, profile)
+	} else if len(profiles) > 0 {
+		logger.Warning("CORS profile", name, "not found on the config file")
+	}
+
+	config = mergeCORS(config, corsFromSettings(loaded))
+	state := &corsStateType{profile: name, config: config.normalized()}
+	if previous := corsState.Load(); previous != nil {
+		state.generation = previous.generation + 1
+	}
+	corsState.Store(state)
+
+	logger.Info("CORS profile:", state.profile, "allowed origins:", state.config.AllowOrigins)
+}
+
+func toProfile(config CORSConfig) corsProfile {
+	return corsProfile{
+		AllowOrigins:  config.AllowOrigins,
+		AllowMethods:  config.AllowMethods,
+		AllowHeaders:  config.AllowHeaders,
+		ExposeHeaders: config.ExposeHeaders,
+	}
+}
+func mergeCORS(base CORSConfig, profile corsProfile) CORSConfig {
+	if profile.AllowOrigins != nil {
+		base.AllowOrigins = profile.AllowOrigins
+	}
+	if profile.AllowMethods != nil {
+		base.AllowMethods = profile.AllowMethods
+	}
+	if profile.AllowHeaders != nil {
+		base.AllowHeaders = profile.AllowHeaders
+	}
+	if profile.ExposeHeaders != nil {
+		base.ExposeHeaders = profile.ExposeHeaders
+	}
+	if profile.AllowCredentials != nil {
+		base.AllowCredentials = *profile.AllowCredentials
+	}
+	if profile.MaxAge != nil {
+		base.MaxAge = *profile.MaxAge
+	}
+	return base
+}
+
+// loadCORSFile reads a YAML file of profiles:
+//
+//	production:
+//	  allow_origins: ["https://atv.example.com", "https://*.atv.example.com"]
+//	  allow_credentials: true
+//	  max_age: 1h
+func loadCORSFile(path string) (map[string]corsProfile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := map[string]corsProfile{}
+	if err := yaml.Unmarshal(content, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// corsFromSettings returns the CORS_* settings that were explicitly given
+func corsFromSettings(loaded *settings.Loaded) corsProfile {
+	config := loaded.Config.CORS
+	var profile corsProfile
+
+	if loaded.IsSet("CORS_ALLOW_ORIGINS") {
+		profile.AllowOrigins = config.AllowOrigins
+	}
+	if loaded.IsSet("CORS_ALLOW_METHODS") {
+		profile.AllowMethods = config.AllowMethods
+	}
+	if loaded.IsSet("CORS_ALLOW_HEADERS") {
+		profile.AllowHeaders = config.AllowHeaders
+	}
+	if loaded.IsSet("CORS_EXPOSE_HEADERS") {
+		profile.ExposeHeaders = config.ExposeHeaders
+	}
+	if loaded.IsSet("CORS_ALLOW_CREDENTIALS") {
+		profile.AllowCredentials = &config.AllowCredentials
+	}
+	if loaded.IsSet("CORS_MAX_AGE") {
+		profile.MaxAge = &config.MaxAge
+	}
+	return profile
+}
+
+// normalized lowercases the origins and drops the credentials when every origin is allowed,
+// browsers reject credentials with a wildcard origin anyway
+func (c CORSConfig) normalized() CORSConfig {
+	origins := make([]string, 0, len(c.AllowOrigins))
+	for _, origin := range c.AllowOrigins {
+		origins = append(origins, strings.TrimSuffix(strings.ToLower(origin), "/"))
+	}
+	c.AllowOrigins = origins
+
+	if c.AllowsAll() && c.AllowCredentials {
+		logger.Warning("CORS credentials can not be used when every origin is allowed, disabling them")
+		c.AllowCredentials = false
+	}
+	return c
+}
+
+// AllowsAll reports whether the policy accepts any origin
+func (c CORSConfig) AllowsAll() bool {
+	return slices.Contains(c.AllowOrigins, "*")
+}
+
+// AllowsOrigin reports whether the origin matches one of the allowed origins
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" || allowed == origin || matchWildcardOrigin(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcardOrigin matches "scheme://*.domain[:port]" against an origin,
+// the scheme and port must be equal and the host a subdomain of domain
+func matchWildcardOrigin(pattern string, origin string) bool {
+	if !strings.Contains(pattern, "://*.") {
+		return false
+	}
+
+	patternURL, errPattern := url.Parse(strings.Replace(pattern, "://*.", "://", 1))
+	originURL, errOrigin := url.Parse(origin)
+	if errPattern != nil || errOrigin != nil {
+		return false
+	}
+
+	return patternURL.Scheme == originURL.Scheme &&
+		patternURL.Port() == originURL.Port() &&
+		strings.HasSuffix(originURL.Hostname(), "."+patternURL.Hostname())
+}
+
+func (corsType) Profile() string {
+	return corsState.Load().profile
+}
+func (corsType) Config() CORSConfig {
+	return corsState.Load().config
+}
+
+// Generation changes every time the policy is reloaded, so users of Config know when to rebuild
+func (corsType) Generation() uint64 {
+	return corsState.Load().generation
+}
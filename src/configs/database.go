@@ -3,10 +3,17 @@ package configs
 import (
 	"cmp"
 	"context"
+	"dainxor/atv/configs/settings"
 	"dainxor/atv/logger"
+	"dainxor/atv/metrics"
 	"dainxor/atv/models"
 	"dainxor/atv/types"
 	"dainxor/atv/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -16,9 +23,6 @@ import (
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
-
-	"fmt"
-	"os"
 )
 
 type mongoType struct {
@@ -31,6 +35,22 @@ type gormType struct {
 }
 type dbTypes struct {
 }
+type dbOperations struct {
+}
+
+const (
+	DEFAULT_DB_TIMEOUT         = 10 * time.Second       // Default timeout for any database operation
+	DEFAULT_DB_CONNECT_RETRIES = 5                      // Default attempts made by each connection try
+	DEFAULT_DB_CONNECT_BACKOFF = 500 * time.Millisecond // Default wait after the first failed attempt, doubled after each one
+)
+
+// ErrDBUnavailable wraps every error caused by not being able to reach the database
+var ErrDBUnavailable = errors.New("database unavailable")
+
+// IsDuplicateKey reports whether the write failed on an _id or unique index already in use
+func IsDuplicateKey(err error) bool {
+	return errors.Is(err, ErrMemoryDuplicateKey) || mongo.IsDuplicateKeyError(err)
+}
 
 func (dbTypes) Postgres() string {
 	return "POSTGRES"
@@ -41,27 +61,79 @@ func (dbTypes) MongoDB() string {
 func (dbTypes) SQLite() string {
 	return "SQLITE"
 }
+func (dbTypes) Memory() string {
+	return "MEMORY"
+}
 func (dbTypes) Default() string {
 	return DB.Types().SQLite()
 }
 
+// Operation kinds used to pick the timeout of a database call
+func (dbOperations) Read() string {
+	return "READ"
+}
+func (dbOperations) Write() string {
+	return "WRITE"
+}
+func (dbOperations) Delete() string {
+	return "DELETE"
+}
+func (dbOperations) Connect() string {
+	return "CONNECT"
+}
+func (dbOperations) All() []string {
+	return []string{
+		DB.Operations().Read(),
+		DB.Operations().Write(),
+		DB.Operations().Delete(),
+		DB.Operations().Connect(),
+	}
+}
+
 type db struct {
+}
+
+// dbConfigType is the connection configuration in use, replaced as a whole on reload
+type dbConfigType struct {
 	dbType string
 
 	dbName           string
 	connectionString string
+
+	timeouts map[string]time.Duration
+
+	connectRetries int
+	connectBackoff time.Duration
+}
+
+// connectionType keeps the state of the lazy connection.
+// lock is a channel instead of a mutex so callers can give up on it when their context ends.
+type connectionType struct {
+	lock          chan struct{}
+	connected     atomic.Bool
+	mutex         sync.Mutex
+	lastError     error
+	stopReconnect context.CancelFunc // Ends the background reconnect while one runs
 }
 
 var DB db
+var dbConfig atomic.Pointer[dbConfigType]
+var connection = connectionType{lock: make(chan struct{}, 1)}
 var mongoT mongoType
 var gormT gormType
 
 func (db) Type() string {
-	return DB.dbType
+	return DB.config().dbType
+}
+func (db) config() *dbConfigType {
+	return dbConfig.Load()
 }
 func (db) Types() dbTypes {
 	return dbTypes{}
 }
+func (db) Operations() dbOperations {
+	return dbOperations{}
+}
 
 func (db) Gorm() *gormType {
 	return &gormT
@@ -79,22 +151,49 @@ func (mongoType) Disconnect() {
 
 func init() {
 	DB.envInit()
+
+	settings.OnReload(func(previous *settings.Loaded, current *settings.Loaded) {
+		if previous.Config.DB != current.Config.DB {
+			ReloadDBEnv()
+		}
+	})
 }
+
+// ReloadDBEnv applies the current database settings. The connection is only closed,
+// and made again on next use, when the type, connection string or database name changed.
 func ReloadDBEnv() {
+	config := settings.Get().DB
+	connectionString, name := config.ActiveConnection()
+	previous := DB.config()
+
+	if previous.dbType == config.Type && previous.connectionString == connectionString && previous.dbName == name {
+		DB.envInit() // Only timeouts and retries changed, the connection is kept
+		logger.Info("Database timeouts reloaded")
+		return
+	}
+
+	logger.Info("Database connection settings changed, reconnecting on next use")
+	DB.stopReconnect()
+	connection.lock <- struct{}{} // Waits for a connection being made with the old settings
 	DB.Close()
 	DB.envInit()
+	<-connection.lock
 }
 func (db) envInit() error {
-	var exist bool
-	DB.dbType, exist = os.LookupEnv("DB_TYPE")
-	if !exist {
+	loaded := settings.Current()
+	config := loaded.Config.DB
+
+	next := &dbConfigType{dbType: config.Type}
+	if !loaded.IsSet("DB_TYPE") {
 		logger.Warning("DB_TYPE not found, using default: ", DB.Types().Default())
-		DB.dbType = DB.Types().Default()
+		next.dbType = DB.Types().Default()
 	}
 
-	DB.loadDBConfig()
-	DB.connectDB()
-	return DB.CreateDatabase()
+	next.loadDBConfig(config)
+	next.loadTimeouts(config)
+	next.loadConnectRetries(config)
+	dbConfig.Store(next)
+	return nil // The connection is made on first use, see Connect
 }
 
 func (db) GormDB() *gorm.DB {
@@ -112,21 +211,252 @@ func (db) From(v models.DBModelInterface) *mongo.Collection {
 	collectionName := v.TableName()
 	return DB.In(collectionName)
 }
-func (db) Context() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 10*time.Second)
+
+// Connect connects to the configured database if it is not connected yet, with a single attempt.
+// It is called by every helper, so requests never wait on DB_CONNECT_RETRIES: when the attempt
+// fails Reconnect keeps trying in the background, and while it does Connect fails fast.
+// A request only waits on an attempt already being made, at most until ctx ends.
+// Errors returned wrap ErrDBUnavailable.
+func (db) Connect(ctx context.Context) error {
+	if connection.connected.Load() {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if DB.reconnecting() {
+		return fmt.Errorf("%w: reconnecting: %w", ErrDBUnavailable, cmp.Or(DB.LastError(), errReconnecting))
+	}
+
+	select {
+	case connection.lock <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrDBUnavailable, ctx.Err())
+	}
+	if connection.connected.Load() {
+		<-connection.lock
+		return nil // Connected by whoever held the lock before
+	}
+	err := DB.attempt(ctx)
+	<-connection.lock
+
+	if err != nil {
+		go DB.Reconnect(context.Background())
+		return fmt.Errorf("%w: %w", ErrDBUnavailable, err)
+	}
+	return nil
+}
+
+// errReconnecting is reported while the first reconnect attempt has not finished yet
+var errReconnecting = errors.New("connection attempt in progress")
+
+// Reconnect connects to the configured database if it is not connected yet, retrying failed
+// attempts with an exponential backoff until DB_CONNECT_RETRIES is reached or ctx ends.
+// Meant for the warm-up and the background, Connect fails fast while it runs and
+// it returns right away when another reconnect is running.
+// Reloading the database settings or closing the connection stops it.
+func (db) Reconnect(ctx context.Context) error {
+	if connection.connected.Load() {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	connection.mutex.Lock()
+	if connection.stopReconnect != nil {
+		connection.mutex.Unlock()
+		return fmt.Errorf("%w: %w", ErrDBUnavailable, errReconnecting) // The one running is enough
+	}
+	connection.stopReconnect = cancel
+	connection.mutex.Unlock()
+	defer func() {
+		connection.mutex.Lock()
+		connection.stopReconnect = nil
+		connection.mutex.Unlock()
+	}()
+
+	retries, backoff := DB.config().connectRetries, DB.config().connectBackoff
+	var err error
+	for attempt := 1; attempt <= retries; attempt++ {
+		select { // The lock is only held during the attempt, reloads and closing never wait on the backoff
+		case connection.lock <- struct{}{}:
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrDBUnavailable, errors.Join(err, ctx.Err()))
+		}
+		if connection.connected.Load() {
+			<-connection.lock
+			return nil
+		}
+		err = DB.attempt(ctx)
+		<-connection.lock
+		if err == nil {
+			return nil
+		}
+		logger.Warning("Database connection attempt", attempt, "of", retries, "failed:", err)
+
+		if attempt == retries {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrDBUnavailable, errors.Join(err, ctx.Err()))
+		}
+	}
+
+	logger.Error("Could not connect to the database:", err)
+	return fmt.Errorf("%w: %w", ErrDBUnavailable, err)
+}
+
+// reconnecting reports whether Reconnect is running
+func (db) reconnecting() bool {
+	connection.mutex.Lock()
+	defer connection.mutex.Unlock()
+	return connection.stopReconnect != nil
 }
 
-func (db) FindOne(filter any, result models.DBModelInterface) error {
-	ctx, cancel := DB.Context()
+// stopReconnect ends the background reconnect, if one is running
+func (db) stopReconnect() {
+	connection.mutex.Lock()
+	defer connection.mutex.Unlock()
+	if connection.stopReconnect != nil {
+		connection.stopReconnect()
+	}
+}
+
+// attempt makes a single connection attempt, the caller holds connection.lock
+func (db) attempt(ctx context.Context) error {
+	err := DB.connectDB(ctx)
+	if err == nil {
+		err = DB.CreateDatabase()
+	}
+
+	connection.mutex.Lock()
+	connection.lastError = err
+	connection.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+	connection.connected.Store(true)
+	logger.Info("Database connection established")
+	return nil
+}
+
+// Connected reports whether a connection has been established
+func (db) Connected() bool {
+	return connection.connected.Load()
+}
+
+// LastError returns the error of the last connection try, nil if it succeeded or none was made
+func (db) LastError() error {
+	connection.mutex.Lock()
+	defer connection.mutex.Unlock()
+	return connection.lastError
+}
+
+// Ping connects if needed and checks the database answers within the connect timeout
+func (db) Ping(ctx context.Context) error {
+	if err := DB.Connect(ctx); err != nil {
+		return err
+	}
+
+	ctx, cancel := DB.Context(ctx, DB.Operations().Connect())
 	defer cancel()
 
-	return DB.From(result).FindOne(ctx, filter).Decode(result)
+	var err error
+	switch DB.Type() {
+	case DB.Types().MongoDB():
+		err = DB.Mongo().client.Ping(ctx, readpref.Primary())
+
+	case DB.Types().Memory():
+		err = nil // Lives in the process, it answers as long as the process does
+
+	default:
+		var sqlDB *sql.DB
+		sqlDB, err = DB.GormDB().DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDBUnavailable, err)
+	}
+	return nil
 }
-func (db) FindAll(filter any, result any) error {
-	logger.Lava("0.1.1", "This mf should be refactored to use []models.DBModelInterface instead of any for the result")
-	ctx, cancel := DB.Context()
+
+// collection connects on first use and returns the Mongo collection of the model
+func (db) collection(ctx context.Context, model models.DBModelInterface) (*mongo.Collection, error) {
+	if err := DB.Connect(ctx); err != nil {
+		return nil, err
+	}
+	if DB.MongoDB() == nil {
+		return nil, fmt.Errorf("%w: %s is not a document database", ErrDBUnavailable, DB.Type())
+	}
+	return DB.From(model), nil
+}
+
+// Timeout returns the configured timeout for the given operation kind
+func (db) Timeout(operation string) time.Duration {
+	if timeout, exist := DB.config().timeouts[operation]; exist {
+		return timeout
+	}
+	return DEFAULT_DB_TIMEOUT
+}
+
+// Context derives the context of a single database operation from the parent,
+// which usually is the request context. Canceling the request or reaching
+// its deadline cancels the operation as well.
+func (db) Context(parent context.Context, operation string) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, DB.Timeout(operation))
+}
+
+// observe records the duration of a database operation on the metrics endpoint.
+// Not finding a document is an expected outcome and is not counted as an error.
+func observe(collection string, operation string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		outcome = "error"
+	}
+	metrics.DBOperationDuration.Observe(time.Since(start).Seconds(), collection, operation, outcome)
+}
+
+func (db) FindOne(ctx context.Context, filter any, result models.DBModelInterface) error {
+	filter, err := scopeFilter(ctx, filter, result)
+	if err != nil {
+		return err
+	}
+
+	if memory, ok, err := DB.memory(ctx); ok {
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		err = memory.FindOne(result.TableName(), filter, result)
+		observe(result.TableName(), "FindOne", start, err)
+		return err
+	}
+
+	collection, err := DB.collection(ctx, result)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := DB.Context(ctx, DB.Operations().Read())
 	defer cancel()
 
+	start := time.Now()
+	err = collection.FindOne(ctx, filter).Decode(result)
+	observe(result.TableName(), "FindOne", start, err)
+	return err
+}
+func (db) FindAll(ctx context.Context, filter any, result any) error {
+	logger.Lava("0.1.1", "This mf should be refactored to use []models.DBModelInterface instead of any for the result")
 	eType, err := utils.SliceType(result)
 	if err != nil {
 		logger.Fatal("This function ONLY works with slices or pointers to slices")
@@ -136,147 +466,309 @@ func (db) FindAll(filter any, result any) error {
 	if !ok {
 		logger.Fatal("Result type does NOT IMPLEMENT TableName method")
 	}
+	if filter, err = scopeFilter(ctx, filter, iType); err != nil {
+		return err
+	}
+
+	if memory, ok, err := DB.memory(ctx); ok {
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		err = memory.FindAll(iType.TableName(), filter, result)
+		observe(iType.TableName(), "FindAll", start, err)
+		return err
+	}
+
+	collection, err := DB.collection(ctx, iType)
+	if err != nil {
+		return err
+	}
 
-	cursor, err := DB.From(iType).Find(ctx, filter)
+	ctx, cancel := DB.Context(ctx, DB.Operations().Read())
+	defer cancel()
+
+	start := time.Now()
+	cursor, err := collection.Find(ctx, filter)
 	if err != nil {
+		observe(iType.TableName(), "FindAll", start, err)
 		logger.Error("Failed to find documents:", err)
 		return err
 	}
 	defer cursor.Close(ctx)
 
-	return cursor.All(ctx, result)
+	err = cursor.All(ctx, result)
+	observe(iType.TableName(), "FindAll", start, err)
+	return err
 }
 
-func (db) InsertOne(document models.DBModelInterface) (*mongo.InsertOneResult, error) {
-	ctx, cancel := DB.Context()
+func (db) InsertOne(ctx context.Context, document models.DBModelInterface) (*mongo.InsertOneResult, error) {
+	scoped, err := scopeDocument(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+
+	if memory, ok, err := DB.memory(ctx); ok {
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		result, err := memory.InsertOne(document.TableName(), scoped)
+		observe(document.TableName(), "InsertOne", start, err)
+		written(ctx, document.TableName(), err)
+		return result, err
+	}
+
+	collection, err := DB.collection(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := DB.Context(ctx, DB.Operations().Write())
 	defer cancel()
 
-	return DB.From(document).InsertOne(ctx, document)
+	start := time.Now()
+	result, err := collection.InsertOne(ctx, scoped)
+	observe(document.TableName(), "InsertOne", start, err)
+	written(ctx, document.TableName(), err)
+	return result, err
 }
-func (db) UpdateOne(filter any, update any, result models.DBModelInterface) types.Result[mongo.UpdateResult] {
-	ctx, cancel := DB.Context()
-	defer cancel()
 
-	updateResult, err := DB.From(result).UpdateOne(ctx, filter, update)
+// updateOne runs the update on the store in use, without reading the document back
+func (db) updateOne(ctx context.Context, filter any, update any, model models.DBModelInterface) (*mongo.UpdateResult, error) {
+	filter, err := scopeFilter(ctx, filter, model)
+	if err != nil {
+		return nil, err
+	}
+	if update, err = scopeUpdate(ctx, update, model); err != nil {
+		return nil, err
+	}
+
+	if memory, ok, err := DB.memory(ctx); ok {
+		if err != nil {
+			return nil, err
+		}
+		return memory.UpdateOne(model.TableName(), filter, update)
+	}
+
+	collection, err := DB.collection(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := DB.Context(ctx, DB.Operations().Write())
+	defer cancel()
+	return collection.UpdateOne(ctx, filter, update)
+}
+func (db) UpdateOne(ctx context.Context, filter any, update any, result models.DBModelInterface) types.Result[mongo.UpdateResult] {
+	start := time.Now()
+	updateResult, err := DB.updateOne(ctx, filter, update, result)
+	observe(result.TableName(), "UpdateOne", start, err)
+	written(ctx, result.TableName(), err)
 	if err != nil {
 		logger.Error("Failed to update document:", err)
 		return types.ResultErr[mongo.UpdateResult](err)
 	}
 
-	err = DB.FindOne(filter, result)
+	err = DB.FindOne(ctx, filter, result)
 	if err != nil {
 		logger.Error("Failed to find updated document:", err)
 		return types.ResultErr[mongo.UpdateResult](err)
 	}
 	return types.ResultOk(*updateResult)
 }
-func (db) PatchOne(filter any, update any, result models.DBModelInterface) types.Result[mongo.UpdateResult] {
-	ctx, cancel := DB.Context()
-	defer cancel()
-
-	updateResult, err := DB.From(result).UpdateOne(ctx, filter, update)
+func (db) PatchOne(ctx context.Context, filter any, update any, result models.DBModelInterface) types.Result[mongo.UpdateResult] {
+	start := time.Now()
+	updateResult, err := DB.updateOne(ctx, filter, update, result)
+	observe(result.TableName(), "UpdateOne", start, err)
+	written(ctx, result.TableName(), err)
 	if err != nil {
 		logger.Error("Failed to update document:", err)
 		return types.ResultErr[mongo.UpdateResult](err)
 	}
 
-	err = DB.FindOne(filter, result)
+	err = DB.FindOne(ctx, filter, result)
 
 	return types.ResultOf(*updateResult, err, err != nil)
 }
+func (db) DeleteOne(ctx context.Context, filter any, model models.DBModelInterface) (*mongo.DeleteResult, error) {
+	filter, err := scopeFilter(ctx, filter, model)
+	if err != nil {
+		return nil, err
+	}
+
+	if memory, ok, err := DB.memory(ctx); ok {
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		result, err := memory.DeleteOne(model.TableName(), filter)
+		observe(model.TableName(), "DeleteOne", start, err)
+		written(ctx, model.TableName(), err)
+		return result, err
+	}
+
+	collection, err := DB.collection(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := DB.Context(ctx, DB.Operations().Delete())
+	defer cancel()
+
+	start := time.Now()
+	result, err := collection.DeleteOne(ctx, filter)
+	observe(model.TableName(), "DeleteOne", start, err)
+	written(ctx, model.TableName(), err)
+	return result, err
+}
+func (db) DeleteMany(ctx context.Context, filter any, model models.DBModelInterface) (*mongo.DeleteResult, error) {
+	filter, err := scopeFilter(ctx, filter, model)
+	if err != nil {
+		return nil, err
+	}
+
+	if memory, ok, err := DB.memory(ctx); ok {
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		result, err := memory.DeleteMany(model.TableName(), filter)
+		observe(model.TableName(), "DeleteMany", start, err)
+		written(ctx, model.TableName(), err)
+		return result, err
+	}
+
+	collection, err := DB.collection(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := DB.Context(ctx, DB.Operations().Delete())
+	defer cancel()
 
-// LoadDBConfig loads the database configuration from environment variables
-// and sets the default values if not found. It also sets the database type.
-func (db) loadDBConfig() {
-	useTesting, exist := os.LookupEnv("DB_TESTING")
+	start := time.Now()
+	result, err := collection.DeleteMany(ctx, filter)
+	observe(model.TableName(), "DeleteMany", start, err)
+	written(ctx, model.TableName(), err)
+	return result, err
+}
 
-	if exist || useTesting == "TRUE" {
+// LoadDBConfig sets the connection string and database name,
+// the testing ones when DB_TESTING is enabled
+func (c *dbConfigType) loadDBConfig(config settings.DBSettings) {
+	if config.Testing {
 		logger.Debug("Using testing database")
-		DB.connectionString = os.Getenv("CONECTION_STRING_TEST")
-		DB.dbName = os.Getenv("DB_NAME_TEST")
 	} else {
 		logger.Debug("Using production database")
-		DB.connectionString = os.Getenv("CONECTION_STRING")
-		DB.dbName = os.Getenv("DB_NAME")
+	}
+	c.connectionString, c.dbName = config.ActiveConnection()
+}
+
+// loadTimeouts sets the timeout of each operation kind from DB_TIMEOUT_<KIND>,
+// falling back to DB_TIMEOUT and then to DEFAULT_DB_TIMEOUT.
+func (c *dbConfigType) loadTimeouts(config settings.DBSettings) {
+	fallback := cmp.Or(config.Timeout, DEFAULT_DB_TIMEOUT)
+	c.timeouts = map[string]time.Duration{
+		DB.Operations().Read():    cmp.Or(config.TimeoutRead, fallback),
+		DB.Operations().Write():   cmp.Or(config.TimeoutWrite, fallback),
+		DB.Operations().Delete():  cmp.Or(config.TimeoutDelete, fallback),
+		DB.Operations().Connect(): cmp.Or(config.TimeoutConnect, fallback),
 	}
 }
-func (db) connectDB() {
+
+// loadConnectRetries sets how many times and how often a connection is attempted
+// from DB_CONNECT_RETRIES and DB_CONNECT_BACKOFF
+func (c *dbConfigType) loadConnectRetries(config settings.DBSettings) {
+	c.connectRetries = cmp.Or(config.ConnectRetries, DEFAULT_DB_CONNECT_RETRIES)
+	c.connectBackoff = cmp.Or(config.ConnectBackoff, DEFAULT_DB_CONNECT_BACKOFF)
+}
+func (db) connectDB(ctx context.Context) error {
 	switch DB.Type() {
 	case DB.Types().Postgres():
 		logger.Debug("Using Postgres database")
-		DB.ConnectPostgresEnv()
+		return DB.ConnectPostgresEnv()
 
 	case DB.Types().MongoDB():
 		logger.Debug("Using MongoDB database")
-		DB.ConnectMongoDBEnv()
+		return DB.ConnectMongoDBEnv(ctx)
 
 	case DB.Types().SQLite():
 		logger.Debug("Using SQLite database")
-		DB.ConnectSQLiteEnv()
+		return DB.ConnectSQLiteEnv()
+
+	case DB.Types().Memory():
+		logger.Debug("Using the in-process memory database")
+		return nil
+
 	default:
-		logger.Debug("Using default database:", DB.Types().Default())
-		DB.dbType = DB.Types().Default()
+		logger.Warning("Unknown DB_TYPE", DB.Type(), "using default:", DB.Types().Default())
+		fallback := *DB.config()
+		fallback.dbType = DB.Types().Default()
+		dbConfig.Store(&fallback)
+		return DB.connectDB(ctx)
 	}
-
-	logger.Debug("Database connection established")
 }
 
 // ConnectPostgresEnv connects to the Postgres database using environment variables
 // It checks for the testing environment and uses the appropriate database credentials
-func (db) ConnectPostgresEnv() {
-	DB.ConnectPostgres(DB.connectionString)
+func (db) ConnectPostgresEnv() error {
+	return DB.ConnectPostgres(DB.config().connectionString)
 }
 
 // ConnectPostgres connects to the Postgres database using the provided credentials
 // It uses the gorm library to establish the connection
-func (db) ConnectPostgres(connectionString string) {
-	var err error
+func (db) ConnectPostgres(connectionString string) error {
 	dsn := connectionString
 
 	logger.Debug("Connecting to database: ", dsn)
-	DB.Gorm().db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-
+	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
-		logger.Fatal(err)
+		return err
 	}
+
+	DB.Gorm().db = gormDB
+	return nil
 }
 
 // ConnectSQLiteEnv connects to the SQLite database using environment variables
 // It checks for the database name in the environment variables and uses a default if not found
-func (db) ConnectSQLiteEnv() {
-	DB.ConnectSQLite(cmp.Or(DB.dbName, "atvsqlite.db"))
+func (db) ConnectSQLiteEnv() error {
+	return DB.ConnectSQLite(cmp.Or(DB.config().dbName, "atvsqlite.db"))
 }
 
 // ConnectSQLite connects to the SQLite database using the provided database name
 // It uses the gorm library to establish the connection
-func (db) ConnectSQLite(dbname string) {
-	var err error
-	DB.Gorm().db, err = gorm.Open(sqlite.Open(dbname), &gorm.Config{})
-
+func (db) ConnectSQLite(dbname string) error {
+	gormDB, err := gorm.Open(sqlite.Open(dbname), &gorm.Config{})
 	if err != nil {
-		panic("failed to connect database")
+		return err
 	}
+
+	DB.Gorm().db = gormDB
+	return nil
 }
 
 // ConnectMongoDBEnv connects to the MongoDB database using environment variables
 // It checks for the testing environment and uses the appropriate database credentials
-func (db) ConnectMongoDBEnv() {
-	DB.ConnectMongoDB(DB.dbName, DB.connectionString)
+func (db) ConnectMongoDBEnv(ctx context.Context) error {
+	return DB.ConnectMongoDB(ctx, DB.config().dbName, DB.config().connectionString)
 }
 
 // Connects to the MongoDB database
-func (db) ConnectMongoDB(dbName, conectionString string) {
+func (db) ConnectMongoDB(ctx context.Context, dbName, conectionString string) error {
 	logger.Debug("Connecting to MongoDB: ", conectionString)
-	clientOpts := options.Client().ApplyURI(conectionString)
+	clientOpts := options.Client().ApplyURI(conectionString).SetMonitor(newCommandMonitor())
 	client, err := mongo.Connect(clientOpts)
 	if err != nil {
-		logger.Fatal(err)
+		return err
 	}
 
-	ctx, cancel := DB.Context()
+	pingCtx, cancel := DB.Context(ctx, DB.Operations().Connect())
 	defer cancel()
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		logger.Fatal(err)
+	if err = client.Ping(pingCtx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return err
 	}
 
 	DB.Mongo().client = client
@@ -284,9 +776,10 @@ func (db) ConnectMongoDB(dbName, conectionString string) {
 
 	DB.Mongo().disconectFunc = func() {
 		if err = DB.Mongo().client.Disconnect(context.Background()); err != nil {
-			logger.Fatal("Error disconnecting from MongoDB: ", err)
+			logger.Error("Error disconnecting from MongoDB: ", err)
 		}
 	}
+	return nil
 }
 
 // CreateDatabase creates the database based on the database type
@@ -321,24 +814,24 @@ func (db) CreateDatabase() error {
 func (db) CreatePostgresDatabase() error {
 	var exists bool
 	checkQuery := "SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = ?)"
-	if err := DB.GormDB().Raw(checkQuery, DB.dbName).Scan(&exists).Error; err != nil {
+	if err := DB.GormDB().Raw(checkQuery, DB.config().dbName).Scan(&exists).Error; err != nil {
 		logger.Error("Error checking database existence: ", err)
 		return err
 	}
 
 	if exists {
-		logger.Debug("Database", DB.dbName, "already exists")
+		logger.Debug("Database", DB.config().dbName, "already exists")
 		return nil
 	}
 
 	// Create the new database
-	createQuery := fmt.Sprintf("CREATE DATABASE \"%s\"", DB.dbName)
+	createQuery := fmt.Sprintf("CREATE DATABASE \"%s\"", DB.config().dbName)
 	if err := DB.GormDB().Exec(createQuery).Error; err != nil {
-		logger.Error("failed to create database", DB.dbName, ": ", err)
-		return fmt.Errorf("failed to create database '%s': %w", DB.dbName, err)
+		logger.Error("failed to create database", DB.config().dbName, ": ", err)
+		return fmt.Errorf("failed to create database '%s': %w", DB.config().dbName, err)
 	}
 
-	logger.Debug("Database", DB.dbName, "created successfully")
+	logger.Debug("Database", DB.config().dbName, "created successfully")
 	return nil
 }
 
@@ -361,7 +854,7 @@ func (db) CreateSQLiteDatabase() {
 func (db) Migrate(models ...any) {
 	logger.Info("Starting migrations")
 
-	if DB.dbType == DB.Types().Postgres() {
+	if DB.Type() == DB.Types().Postgres() {
 		for _, model := range models {
 			err := DB.GormDB().AutoMigrate(model)
 
@@ -375,7 +868,7 @@ func (db) Migrate(models ...any) {
 		logger.Info("Migrations completed")
 
 	} else {
-		logger.Info("No migrations needed for SQLite or MongoDB")
+		logger.Info("No migrations needed for SQLite, MongoDB or the memory database")
 	}
 }
 
@@ -395,12 +888,22 @@ func (db) ClosePostgres() {
 func (db) CloseSQLite() {
 	closeGormDB()
 }
+func (db) CloseMemory() {
+	DB.Memory().Reset()
+	logger.Info("Memory database dropped")
+}
 func (db) CloseMongoDB() {
 	DB.Mongo().Disconnect()
 	logger.Info("MongoDB connection closed")
 }
 
 func (db) Close() {
+	DB.stopReconnect()
+	if !connection.connected.Swap(false) {
+		logger.Debug("Database was never connected, nothing to close")
+		return
+	}
+
 	switch DB.Type() {
 	case DB.Types().Postgres():
 		DB.ClosePostgres()
@@ -411,6 +914,9 @@ func (db) Close() {
 	case DB.Types().SQLite():
 		DB.CloseSQLite()
 
+	case DB.Types().Memory():
+		DB.CloseMemory()
+
 	default:
 		logger.Warning("Unknown database type, no specific close method available")
 	}
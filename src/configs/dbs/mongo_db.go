@@ -31,12 +31,15 @@ func (mongoType) Disconnect() {
 		logger.Warning("MongoDB disconect function is nil, nothing to do")
 	}
 }
-func (mongoType) Context() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 10*time.Second)
+func (mongoType) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, 10*time.Second)
 }
 
-func (mongoType) CreateOne(document models.DBModelInterface) (*mongo.InsertOneResult, error) {
-	ctx, cancel := mongoT.Context()
+func (mongoType) CreateOne(ctx context.Context, document models.DBModelInterface) (*mongo.InsertOneResult, error) {
+	ctx, cancel := mongoT.Context(ctx)
 	defer cancel()
 
 	return mongoT.db.Collection(document.TableName()).InsertOne(ctx, document)
@@ -45,8 +48,8 @@ func (mongoType) CreateMany(documents any) types.Result[any] {
 	return types.ResultErr[any](errors.ErrUnsupported)
 }
 
-func (mongoType) GetOne(filter any, result models.DBModelInterface) types.Result[models.DBModelInterface] {
-	ctx, cancel := mongoT.Context()
+func (mongoType) GetOne(ctx context.Context, filter any, result models.DBModelInterface) types.Result[models.DBModelInterface] {
+	ctx, cancel := mongoT.Context(ctx)
 	defer cancel()
 
 	err := mongoT.db.Collection(result.TableName()).FindOne(ctx, filter).Decode(result)
@@ -57,7 +60,7 @@ func (mongoType) GetOne(filter any, result models.DBModelInterface) types.Result
 
 	return types.ResultOf(result, err, err != nil)
 }
-func (mongoType) GetAll(filter any, result any) types.Result[any] {
+func (mongoType) GetAll(ctx context.Context, filter any, result any) types.Result[any] {
 	logger.Lava("0.1.1", "This mf should be refactored to use []models.DBModelInterface instead of any for the result")
 
 	eType, err := utils.SliceType(result)
@@ -72,7 +75,7 @@ func (mongoType) GetAll(filter any, result any) types.Result[any] {
 		return types.ResultErr[any](ErrInvalidInput)
 	}
 
-	ctx, cancel := mongoT.Context()
+	ctx, cancel := mongoT.Context(ctx)
 	defer cancel()
 
 	cursor, err := mongoT.db.Collection(iType.TableName()).Find(ctx, filter)
@@ -86,11 +89,11 @@ func (mongoType) GetAll(filter any, result any) types.Result[any] {
 	return types.ResultOf(result, cursorErr, cursorErr != nil)
 }
 
-func (mongoType) UpdateOne(filter any, update any, result models.DBModelInterface) types.Result[models.DBModelInterface] {
-	ctx, cancel := mongoT.Context()
+func (mongoType) UpdateOne(ctx context.Context, filter any, update any, result models.DBModelInterface) types.Result[models.DBModelInterface] {
+	opCtx, cancel := mongoT.Context(ctx)
 	defer cancel()
 
-	updateResult, err := mongoT.db.Collection(result.TableName()).UpdateOne(ctx, filter, update)
+	updateResult, err := mongoT.db.Collection(result.TableName()).UpdateOne(opCtx, filter, update)
 	if err != nil {
 		logger.Error("Failed to update document:", err)
 		return types.ResultErr[models.DBModelInterface](err)
@@ -104,7 +107,7 @@ func (mongoType) UpdateOne(filter any, update any, result models.DBModelInterfac
 		return types.ResultErr[models.DBModelInterface](ErrNotModified)
 	}
 
-	res := mongoT.GetOne(filter, result)
+	res := mongoT.GetOne(ctx, filter, result)
 	if res.IsErr() {
 		logger.Error("Failed to find updated document:", res.Error())
 		return res
@@ -112,17 +115,17 @@ func (mongoType) UpdateOne(filter any, update any, result models.DBModelInterfac
 	return res
 }
 
-func (mongoType) PatchOne(filter any, update any, result models.DBModelInterface) types.Result[mongo.UpdateResult] {
-	ctx, cancel := mongoT.Context()
+func (mongoType) PatchOne(ctx context.Context, filter any, update any, result models.DBModelInterface) types.Result[mongo.UpdateResult] {
+	opCtx, cancel := mongoT.Context(ctx)
 	defer cancel()
 
-	updateResult, err := mongoT.db.Collection(result.TableName()).UpdateOne(ctx, filter, update)
+	updateResult, err := mongoT.db.Collection(result.TableName()).UpdateOne(opCtx, filter, update)
 	if err != nil {
 		logger.Error("Failed to update document:", err)
 		return types.ResultErr[mongo.UpdateResult](err)
 	}
 
-	res := mongoT.GetOne(filter, result)
+	res := mongoT.GetOne(ctx, filter, result)
 
 	return types.ResultOf(*updateResult, res.Error(), res.IsErr())
 }
@@ -136,7 +139,7 @@ func (mongoType) ConnectMongoDB(dbName, conectionString string) {
 		logger.Fatal(err)
 	}
 
-	ctx, cancel := mongoT.Context()
+	ctx, cancel := mongoT.Context(context.Background())
 	defer cancel()
 	if err = client.Ping(ctx, readpref.Primary()); err != nil {
 		logger.Fatal(err)
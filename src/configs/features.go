@@ -0,0 +1,33 @@
+package configs
+
+import (
+	"dainxor/atv/configs/settings"
+	"strconv"
+	"strings"
+)
+
+type featuresType struct{}
+
+// Features are the feature flags set with FEATURE_<NAME>=true or under "features" on the config file,
+// they are read on every call so they follow config reloads
+var Features featuresType
+
+// Enabled reports whether a feature flag is on, flags that are not set are off
+func (featuresType) Enabled(name string) bool {
+	enabled, _ := strconv.ParseBool(settings.Get().Features[featureKey(name)])
+	return enabled
+}
+
+// All returns every flag that is set, on or off
+func (featuresType) All() map[string]bool {
+	flags := map[string]bool{}
+	for name, value := range settings.Get().Features {
+		flags[name], _ = strconv.ParseBool(value)
+	}
+	return flags
+}
+
+// featureKey turns a flag name into its settings key, e.g. "new-search" into "new_search"
+func featureKey(name string) string {
+	return strings.ToLower(strings.NewReplacer("-", "_", " ", "_").Replace(name))
+}
@@ -0,0 +1,439 @@
+package configs
+
+import (
+	"bytes"
+	"context"
+	"dainxor/atv/models"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// memoryType is the in-process document store used when DB_TYPE is MEMORY.
+// Documents are kept as BSON, so they are encoded and decoded exactly as
+// with MongoDB, and the filters understand the subset of the query language
+// used by the db package: equality, $eq, $ne, $gt, $gte, $lt, $lte, $in,
+// $nin, $exists, $and and $or on top level fields, and $set and $unset updates.
+// Inserts are checked against the unique indexes of models.INDEXES.
+// It is meant for tests and local runs, the data is lost when the process stops.
+type memoryType struct {
+	mutex       sync.RWMutex
+	collections map[string][]bson.D
+}
+
+var memoryT = memoryType{collections: map[string][]bson.D{}}
+
+// ErrMemoryDuplicateKey is returned when a document is inserted with an _id or unique index keys already in use
+var ErrMemoryDuplicateKey = errors.New("duplicate key error")
+
+func (db) Memory() *memoryType {
+	return &memoryT
+}
+
+// memory connects on first use and returns the in-process store, false when DB_TYPE is another one
+func (db) memory(ctx context.Context) (*memoryType, bool, error) {
+	if DB.Type() != DB.Types().Memory() {
+		return nil, false, nil
+	}
+	if err := DB.Connect(ctx); err != nil {
+		return nil, true, err
+	}
+	return DB.Memory(), true, nil
+}
+
+// Reset drops every collection, the write listeners are told about each one
+func (m *memoryType) Reset() {
+	m.mutex.Lock()
+	dropped := slices.Collect(maps.Keys(m.collections))
+	m.collections = map[string][]bson.D{}
+	m.mutex.Unlock()
+
+	for _, collection := range dropped {
+		written(context.Background(), collection, nil)
+	}
+}
+
+func (m *memoryType) FindOne(collection string, filter any, result any) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return err
+	}
+	for _, document := range m.collections[collection] {
+		if matches(document, query) {
+			return fromDocument(document, result)
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+// FindAll decodes every matching document into result, which must be a pointer to a slice
+func (m *memoryType) FindAll(collection string, filter any, result any) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return err
+	}
+
+	slice := reflect.ValueOf(result)
+	if slice.Kind() != reflect.Pointer || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("memory store: result must be a pointer to a slice, got %T", result)
+	}
+	found := reflect.MakeSlice(slice.Elem().Type(), 0, 0)
+	for _, document := range m.collections[collection] {
+		if !matches(document, query) {
+			continue
+		}
+		element := reflect.New(found.Type().Elem())
+		if err := fromDocument(document, element.Interface()); err != nil {
+			return err
+		}
+		found = reflect.Append(found, element.Elem())
+	}
+	slice.Elem().Set(found)
+	return nil
+}
+
+func (m *memoryType) InsertOne(collection string, value any) (*mongo.InsertOneResult, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	document, err := toDocument(value)
+	if err != nil {
+		return nil, err
+	}
+
+	id, exist := lookup(document, "_id")
+	if !exist || id == nil {
+		id = bson.NewObjectID()
+		document = append(bson.D{{Key: "_id", Value: id}}, document...)
+	}
+	for _, stored := range m.collections[collection] {
+		if storedID, _ := lookup(stored, "_id"); sameValue(storedID, id) {
+			return nil, fmt.Errorf("%w: %s _id %v", ErrMemoryDuplicateKey, collection, id)
+		}
+		for _, keys := range uniqueKeys(collection) {
+			if sameKeys(stored, document, keys) {
+				return nil, fmt.Errorf("%w: %s %s", ErrMemoryDuplicateKey, collection, strings.Join(keys, "_"))
+			}
+		}
+	}
+
+	m.collections[collection] = append(m.collections[collection], document)
+	return &mongo.InsertOneResult{InsertedID: id, Acknowledged: true}, nil
+}
+
+// uniqueKeys returns the keys of each unique index of models.INDEXES on the collection
+func uniqueKeys(collection string) [][]string {
+	unique := [][]string{}
+	for _, index := range models.INDEXES {
+		if index.Unique && index.Model.TableName() == collection {
+			unique = append(unique, IndexKeys(index))
+		}
+	}
+	return unique
+}
+
+// sameKeys reports whether both documents hold the same values on the keys, or both miss them
+func sameKeys(a bson.D, b bson.D, keys []string) bool {
+	for _, key := range keys {
+		first, inA := lookup(a, key)
+		second, inB := lookup(b, key)
+		if inA != inB || (inA && !sameValue(first, second)) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateOne applies the update to the first matching document
+func (m *memoryType) UpdateOne(collection string, filter any, update any) (*mongo.UpdateResult, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &mongo.UpdateResult{Acknowledged: true}
+	for i, document := range m.collections[collection] {
+		if !matches(document, query) {
+			continue
+		}
+		updated, err := applyUpdate(document, changes)
+		if err != nil {
+			return nil, err
+		}
+		result.MatchedCount = 1
+		if !bytes.Equal(mustMarshal(document), mustMarshal(updated)) {
+			result.ModifiedCount = 1
+		}
+		m.collections[collection][i] = updated
+		break
+	}
+	return result, nil
+}
+
+func (m *memoryType) DeleteOne(collection string, filter any) (*mongo.DeleteResult, error) {
+	return m.delete(collection, filter, 1)
+}
+func (m *memoryType) DeleteMany(collection string, filter any) (*mongo.DeleteResult, error) {
+	return m.delete(collection, filter, -1)
+}
+
+// delete removes up to limit matching documents, every one when limit is negative
+func (m *memoryType) delete(collection string, filter any, limit int) (*mongo.DeleteResult, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := []bson.D{}
+	result := &mongo.DeleteResult{Acknowledged: true}
+	for _, document := range m.collections[collection] {
+		if (limit < 0 || result.DeletedCount < int64(limit)) && matches(document, query) {
+			result.DeletedCount++
+			continue
+		}
+		kept = append(kept, document)
+	}
+	m.collections[collection] = kept
+	return result, nil
+}
+
+// toDocument round trips a value through BSON, so filters, updates and models
+// are compared in the same form MongoDB would receive them
+func toDocument(value any) (bson.D, error) {
+	if value == nil {
+		return bson.D{}, nil
+	}
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("memory store: %w", err)
+	}
+	var document bson.D
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("memory store: %w", err)
+	}
+	return document, nil
+}
+
+func fromDocument(document bson.D, result any) error {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, result)
+}
+
+func mustMarshal(document bson.D) []byte {
+	raw, _ := bson.Marshal(document)
+	return raw
+}
+
+func lookup(document bson.D, key string) (any, bool) {
+	for _, element := range document {
+		if element.Key == key {
+			return element.Value, true
+		}
+	}
+	return nil, false
+}
+
+func matches(document bson.D, query bson.D) bool {
+	for _, condition := range query {
+		switch condition.Key {
+		case "$and", "$or":
+			clauses, _ := condition.Value.(bson.A)
+			matched := 0
+			for _, clause := range clauses {
+				if subquery, ok := clause.(bson.D); ok && matches(document, subquery) {
+					matched++
+				}
+			}
+			if condition.Key == "$and" && matched != len(clauses) || condition.Key == "$or" && matched == 0 {
+				return false
+			}
+		default:
+			value, exist := lookup(document, condition.Key)
+			if !matchesCondition(value, exist, condition.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesCondition evaluates a field condition, either a value to be equal to or a document of operators
+func matchesCondition(value any, exist bool, condition any) bool {
+	operators, ok := condition.(bson.D)
+	if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+		return equals(value, exist, condition)
+	}
+
+	for _, operator := range operators {
+		var matched bool
+		switch operator.Key {
+		case "$eq":
+			matched = equals(value, exist, operator.Value)
+		case "$ne":
+			matched = !equals(value, exist, operator.Value)
+		case "$in", "$nin":
+			options, _ := operator.Value.(bson.A)
+			for _, option := range options {
+				matched = matched || equals(value, exist, option)
+			}
+			matched = matched == (operator.Key == "$in")
+		case "$exists":
+			wanted, _ := operator.Value.(bool)
+			matched = exist == wanted
+		case "$gt", "$gte", "$lt", "$lte":
+			order, comparable := compare(value, operator.Value)
+			matched = exist && comparable &&
+				(operator.Key == "$gt" && order > 0 || operator.Key == "$gte" && order >= 0 ||
+					operator.Key == "$lt" && order < 0 || operator.Key == "$lte" && order <= 0)
+		default:
+			return false // Not supported, nothing matches instead of everything
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// equals follows MongoDB, null matches a missing field and arrays match any of their elements
+func equals(value any, exist bool, expected any) bool {
+	if expected == nil {
+		return !exist || value == nil
+	}
+	if !exist {
+		return false
+	}
+	if array, ok := value.(bson.A); ok {
+		if _, expectedArray := expected.(bson.A); !expectedArray {
+			for _, element := range array {
+				if sameValue(element, expected) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return sameValue(value, expected)
+}
+
+func sameValue(a any, b any) bool {
+	if order, comparable := compare(a, b); comparable {
+		return order == 0
+	}
+
+	typeA, rawA, errA := bson.MarshalValue(a)
+	typeB, rawB, errB := bson.MarshalValue(b)
+	return errA == nil && errB == nil && typeA == typeB && bytes.Equal(rawA, rawB)
+}
+
+// compare orders numbers, strings and dates, comparable is false for any other pair
+func compare(a any, b any) (order int, comparable bool) {
+	numberA, isNumberA := toFloat(a)
+	numberB, isNumberB := toFloat(b)
+	if isNumberA && isNumberB {
+		return cmpOrdered(numberA, numberB), true
+	}
+
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bson.DateTime:
+		if b, ok := b.(bson.DateTime); ok {
+			return cmpOrdered(a, b), true
+		}
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | float64 | bson.DateTime](a T, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(value any) (float64, bool) {
+	switch number := value.(type) {
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+func applyUpdate(document bson.D, update bson.D) (bson.D, error) {
+	updated := append(bson.D{}, document...)
+	for _, operation := range update {
+		fields, ok := operation.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("memory store: %s expects a document", operation.Key)
+		}
+
+		switch operation.Key {
+		case "$set":
+			for _, field := range fields {
+				updated = setField(updated, field.Key, field.Value)
+			}
+		case "$unset":
+			for _, field := range fields {
+				updated = unsetField(updated, field.Key)
+			}
+		default:
+			return nil, fmt.Errorf("memory store: update operator %s is not supported", operation.Key)
+		}
+	}
+	return updated, nil
+}
+
+func setField(document bson.D, key string, value any) bson.D {
+	for i, element := range document {
+		if element.Key == key {
+			document[i].Value = value
+			return document
+		}
+	}
+	return append(document, bson.E{Key: key, Value: value})
+}
+
+func unsetField(document bson.D, key string) bson.D {
+	for i, element := range document {
+		if element.Key == key {
+			return append(document[:i], document[i+1:]...)
+		}
+	}
+	return document
+}
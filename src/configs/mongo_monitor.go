@@ -0,0 +1,50 @@
+package configs
+
+import (
+	"context"
+	"dainxor/atv/tracing"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// newCommandMonitor creates a client span for every Mongo command sent inside a traced context.
+// Commands without a span in their context (handshakes, background work) are not traced.
+func newCommandMonitor() *event.CommandMonitor {
+	var spans sync.Map // RequestID -> *tracing.Span
+
+	finish := func(requestID int64, err error) {
+		value, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		span := value.(*tracing.Span)
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if tracing.FromContext(ctx) == nil {
+				return
+			}
+
+			_, span := tracing.StartKind(ctx, "mongo."+e.CommandName, tracing.KIND_CLIENT)
+			span.SetAttribute("db.system", "mongodb")
+			span.SetAttribute("db.name", e.DatabaseName)
+			span.SetAttribute("db.operation", e.CommandName)
+			if collection, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				span.SetAttribute("db.mongodb.collection", collection)
+			}
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, e.Failure)
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package configs
+
+import (
+	"context"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ReloadStatus describes the configuration reloads made since startup
+type ReloadStatus struct {
+	LoadedAt   time.Time `json:"loaded_at"`
+	Reloads    int       `json:"reloads"`
+	LastReason string    `json:"last_reason,omitempty"`
+}
+
+type reloaderType struct {
+	mutex      sync.Mutex
+	reloads    int
+	lastReason string
+}
+
+var reloader reloaderType
+
+// Reload reads the configuration again and applies it to every package registered with settings.OnReload.
+// Log levels, CORS, rate limits, feature flags and the database take effect right away,
+// changes to the settings that need a restart are only reported.
+func Reload(reason string) *settings.Loaded {
+	logger.Info("Reloading configuration:", reason)
+
+	previous := settings.Current()
+	loaded := settings.Reload()
+
+	for _, field := range settings.Changes(previous, loaded) {
+		if field.Restart {
+			logger.Warning("Config:", field.Env, "changed, restart the server to apply it")
+		} else {
+			logger.Info("Config:", field.Env, "=", field.Value, "("+field.Source+")")
+		}
+	}
+	for _, problem := range loaded.Problems {
+		logger.Warning("Config:", problem)
+	}
+
+	reloader.mutex.Lock()
+	reloader.reloads++
+	reloader.lastReason = reason
+	reloader.mutex.Unlock()
+
+	logger.Info("Configuration reloaded")
+	return loaded
+}
+
+// Reloads returns when the current configuration was loaded and how many reloads were made
+func Reloads() ReloadStatus {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+	return ReloadStatus{
+		LoadedAt:   settings.Current().LoadedAt,
+		Reloads:    reloader.reloads,
+		LastReason: reloader.lastReason,
+	}
+}
+
+// WatchConfig reloads the configuration whenever one of the config files changes,
+// checking them every ATV_CONFIG_WATCH until ctx ends. A zero interval disables it.
+func WatchConfig(ctx context.Context) {
+	interval := settings.Get().App.ConfigWatch
+	if interval <= 0 {
+		logger.Info("Config file watching disabled")
+		return
+	}
+
+	files := settings.Current().WatchedFiles()
+	stamps := fileStamps(files)
+	logger.Debug("Watching config files", files, "every", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := fileStamps(files)
+		if slices.Equal(stamps, current) {
+			continue
+		}
+		stamps = current
+
+		loaded := Reload("config file changed")
+		if next := loaded.WatchedFiles(); !slices.Equal(next, files) {
+			files, stamps = next, fileStamps(next) // CORS_CONFIG_FILE may point somewhere else now
+		}
+	}
+}
+
+// ReloadOnHangup reloads the configuration every time the process gets SIGHUP, until ctx ends.
+// The signal is handled once it returns, so a SIGHUP sent afterwards never kills the process.
+func ReloadOnHangup(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				Reload("SIGHUP")
+			}
+		}
+	}()
+}
+
+// fileStamps returns the modification time and size of each file, empty for the missing ones
+func fileStamps(files []string) []string {
+	stamps := make([]string, len(files))
+	for i, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[i] = info.ModTime().String() + "/" + strconv.FormatInt(info.Size(), 10)
+		}
+	}
+	return stamps
+}
@@ -0,0 +1,462 @@
+package settings
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DEFAULT_CONFIG_FILE = "config.yaml" // Optional, only an error when ATV_CONFIG_FILE points to a missing file
+	DEFAULT_ENV_FILE    = ".env"
+
+	REDACTED = "********"
+)
+
+// Where a value came from
+const (
+	SOURCE_DEFAULT = "default"
+	SOURCE_FILE    = "file"
+	SOURCE_DOTENV  = ".env"
+	SOURCE_ENV     = "env"
+)
+
+// Problem is a setting that is missing or has an invalid value
+type Problem struct {
+	Env     string `json:"env"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return p.Env + " " + p.Message
+}
+
+// Field describes the effective value of a setting, secrets are already redacted
+type Field struct {
+	Env     string `json:"env"`
+	Path    string `json:"path"`
+	Value   string `json:"value"`
+	Source  string `json:"source"`
+	Secret  bool   `json:"secret,omitempty"`
+	Restart bool   `json:"restart,omitempty"` // Changes only take effect after a restart
+
+	raw string // Unredacted value, used to detect changes of secrets
+}
+
+// Loaded is the outcome of reading every source
+type Loaded struct {
+	Config     Config
+	Fields     []Field
+	Problems   []Problem
+	ConfigFile string
+	LoadedAt   time.Time
+
+	set map[string]bool // Env names given by a source other than the default
+}
+
+func (l *Loaded) problem(env string, message string) {
+	l.Problems = append(l.Problems, Problem{Env: env, Message: message})
+}
+
+// IsSet reports whether the setting was given with a valid value by the file, .env or the environment
+func (l *Loaded) IsSet(env string) bool {
+	return l.set[env]
+}
+
+// Redacted returns the config with every secret replaced, safe to print or serve
+func (l *Loaded) Redacted() Config {
+	config := l.Config
+	redact(reflect.ValueOf(&config).Elem())
+	return config
+}
+func redact(value reflect.Value) {
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeFor[time.Duration]() {
+			redact(value.Field(i))
+			continue
+		}
+		if field.Tag.Get("secret") == "true" && value.Field(i).String() != "" {
+			value.Field(i).SetString(REDACTED)
+		}
+	}
+}
+
+type sources struct {
+	file   map[string]string // Flattened YAML, keyed by dotted path
+	dotenv map[string]string
+}
+
+var (
+	mutex   sync.RWMutex
+	current *Loaded
+
+	startupOnce   sync.Once
+	startupDotenv map[string]string // .env as read on the first Load, godotenv autoload copied it into the environment
+
+	reloadMutex sync.Mutex // Serializes reloads so hooks never run concurrently
+	hooks       []func(previous *Loaded, current *Loaded)
+)
+
+// Current returns the settings loaded by the last call to Load,
+// loading them the first time it is called
+func Current() *Loaded {
+	mutex.RLock()
+	loaded := current
+	mutex.RUnlock()
+
+	if loaded != nil {
+		return loaded
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if current == nil {
+		current = Load()
+	}
+	return current
+}
+
+// Get is a shortcut for Current().Config
+func Get() Config {
+	return Current().Config
+}
+
+// Load reads every source into a new Config and collects all the problems found on the way,
+// invalid values fall back to their defaults so the result is always usable
+func Load() *Loaded {
+	loaded := &Loaded{
+		LoadedAt: time.Now(),
+		set:      map[string]bool{},
+	}
+
+	src := sources{file: map[string]string{}, dotenv: map[string]string{}}
+
+	path, pathSet := os.LookupEnv("ATV_CONFIG_FILE")
+	loaded.ConfigFile = cmp.Or(path, DEFAULT_CONFIG_FILE)
+	if content, err := os.ReadFile(loaded.ConfigFile); err == nil {
+		var tree map[string]any
+		if err := yaml.Unmarshal(content, &tree); err != nil {
+			loaded.problem("ATV_CONFIG_FILE", fmt.Sprintf("%s is not valid YAML: %v", loaded.ConfigFile, err))
+		}
+		flatten("", tree, src.file)
+	} else if pathSet || !errors.Is(err, os.ErrNotExist) {
+		loaded.problem("ATV_CONFIG_FILE", err.Error())
+	} else {
+		loaded.ConfigFile = ""
+	}
+
+	if dotenv, err := godotenv.Read(DEFAULT_ENV_FILE); err == nil {
+		src.dotenv = dotenv
+	}
+	startupOnce.Do(func() { startupDotenv = src.dotenv })
+
+	loadStruct(reflect.ValueOf(&loaded.Config).Elem(), "", false, src, loaded)
+	loaded.Config.validate(loaded)
+	return loaded
+}
+
+// Reload reads every source again, makes the result the current settings
+// and then calls every hook registered with OnReload, in registration order
+func Reload() *Loaded {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	previous := Current()
+	loaded := Load()
+	mutex.Lock()
+	current = loaded
+	registered := slices.Clone(hooks)
+	mutex.Unlock()
+
+	for _, hook := range registered {
+		hook(previous, loaded)
+	}
+	return loaded
+}
+
+// OnReload registers a hook to apply the new settings after every Reload,
+// hooks should return early when the settings they use did not change
+func OnReload(hook func(previous *Loaded, current *Loaded)) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// Changes returns the fields of current whose value differs from previous,
+// including the map entries that were added or removed
+func Changes(previous *Loaded, current *Loaded) []Field {
+	before := map[string]Field{}
+	for _, field := range previous.Fields {
+		before[field.Env] = field
+	}
+
+	changes := []Field{}
+	for _, field := range current.Fields {
+		old, exist := before[field.Env]
+		if !exist || old.raw != field.raw {
+			changes = append(changes, field)
+		}
+		delete(before, field.Env)
+	}
+	for _, removed := range before {
+		removed.Value, removed.Source, removed.raw = "", SOURCE_DEFAULT, ""
+		changes = append(changes, removed)
+	}
+	return changes
+}
+
+// WatchedFiles returns the files a change on which should trigger a reload,
+// they may not exist yet
+func (l *Loaded) WatchedFiles() []string {
+	path, _ := os.LookupEnv("ATV_CONFIG_FILE")
+	return []string{cmp.Or(path, DEFAULT_CONFIG_FILE), DEFAULT_ENV_FILE, l.Config.CORS.ConfigFile}
+}
+
+// flatten turns nested YAML maps into dotted keys, "db: {type: MONGO}" into "db.type"
+func flatten(prefix string, tree map[string]any, out map[string]string) {
+	for key, value := range tree {
+		path := strings.ToLower(prefix + key)
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(path+".", v, out)
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			out[path] = strings.Join(items, ",")
+		case nil:
+			out[path] = ""
+		default:
+			out[path] = fmt.Sprint(v)
+		}
+	}
+}
+
+// autoloaded reports whether an environment variable was copied from .env at startup,
+// those follow the current .env so editing the file and reloading takes effect
+func autoloaded(env string, value string) bool {
+	dotenvValue, exist := startupDotenv[env]
+	return exist && dotenvValue == value
+}
+
+// lookup finds the raw value of a setting on the sources, highest priority first
+func (s sources) lookup(env string, path string) (string, string, bool) {
+	if value, exist := os.LookupEnv(env); exist && !autoloaded(env, value) {
+		return value, SOURCE_ENV, true
+	}
+	if value, exist := s.dotenv[env]; exist {
+		return value, SOURCE_DOTENV, true
+	}
+	if value, exist := s.file[path]; exist {
+		return value, SOURCE_FILE, true
+	}
+	return "", SOURCE_DEFAULT, false
+}
+
+func loadStruct(value reflect.Value, prefix string, restart bool, src sources, loaded *Loaded) {
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		path := prefix + field.Tag.Get("yaml")
+
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeFor[time.Duration]() {
+			loadStruct(value.Field(i), path+".", restart || field.Tag.Get("reload") == "restart", src, loaded)
+			continue
+		}
+		if field.Type.Kind() == reflect.Map {
+			loadMap(value.Field(i), field, path, src, loaded)
+			continue
+		}
+
+		env := field.Tag.Get("env")
+		defaultValue := field.Tag.Get("default")
+		raw, source, found := src.lookup(env, path)
+		if !found {
+			raw = defaultValue
+		}
+
+		if err := parseInto(value.Field(i), raw); err != nil {
+			loaded.problem(env, fmt.Sprintf("has an invalid value %q: %v", display(field, raw), err))
+			parseInto(value.Field(i), defaultValue)
+			source = SOURCE_DEFAULT
+		} else if found {
+			if err := check(field.Tag.Get("validate"), value.Field(i)); err != nil {
+				loaded.problem(env, fmt.Sprintf("has an invalid value %q: %v", display(field, raw), err))
+				parseInto(value.Field(i), defaultValue)
+				source = SOURCE_DEFAULT
+			}
+		}
+		loaded.set[env] = source != SOURCE_DEFAULT
+
+		loaded.Fields = append(loaded.Fields, Field{
+			Env:     env,
+			Path:    path,
+			Value:   display(field, format(value.Field(i))),
+			Source:  source,
+			Secret:  field.Tag.Get("secret") == "true",
+			Restart: restart,
+			raw:     format(value.Field(i)),
+		})
+	}
+}
+
+// loadMap fills a map of strings from every "<PREFIX>_<KEY>" variable and the "<path>.<key>" file entries,
+// e.g. RATE_LIMIT_STUDENT=30/m becomes Groups["student"]
+func loadMap(value reflect.Value, field reflect.StructField, path string, src sources, loaded *Loaded) {
+	envPrefix := strings.TrimSuffix(field.Tag.Get("env"), "*")
+	known := knownEnvNames()
+	result := map[string]string{}
+	sourceOf := map[string]string{}
+
+	for key, raw := range src.file {
+		if name, isEntry := strings.CutPrefix(key, path+"."); isEntry {
+			result[name], sourceOf[name] = raw, SOURCE_FILE
+		}
+	}
+	for env, raw := range src.dotenv {
+		if name, isEntry := strings.CutPrefix(env, envPrefix); isEntry && !known[env] {
+			result[strings.ToLower(name)], sourceOf[strings.ToLower(name)] = raw, SOURCE_DOTENV
+		}
+	}
+	for _, entry := range os.Environ() {
+		env, raw, _ := strings.Cut(entry, "=")
+		if name, isEntry := strings.CutPrefix(env, envPrefix); isEntry && !known[env] && !autoloaded(env, raw) {
+			result[strings.ToLower(name)], sourceOf[strings.ToLower(name)] = raw, SOURCE_ENV
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(result)) {
+		env := envPrefix + strings.ToUpper(name)
+		if err := check(field.Tag.Get("validate"), reflect.ValueOf(result[name])); err != nil {
+			loaded.problem(env, fmt.Sprintf("has an invalid value %q: %v", result[name], err))
+			delete(result, name)
+			continue
+		}
+
+		loaded.set[env] = true
+		loaded.Fields = append(loaded.Fields, Field{
+			Env:    env,
+			Path:   path + "." + name,
+			Value:  result[name],
+			Source: sourceOf[name],
+			raw:    result[name],
+		})
+	}
+	value.Set(reflect.ValueOf(result))
+}
+
+// knownEnvNames returns every env name declared on Config, so map prefixes do not capture them
+func knownEnvNames() map[string]bool {
+	names := map[string]bool{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeFor[time.Duration]() {
+				walk(field.Type)
+			} else {
+				names[field.Tag.Get("env")] = true
+			}
+		}
+	}
+	walk(reflect.TypeFor[Config]())
+	return names
+}
+
+func parseInto(value reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch value.Interface().(type) {
+	case time.Duration:
+		if raw == "" {
+			value.SetInt(0)
+			return nil
+		}
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("expected a duration like 500ms, 10s or 1m")
+		}
+		if duration < 0 {
+			return errors.New("must not be negative")
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		if raw == "" {
+			value.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("expected true or false")
+		}
+		value.SetBool(b)
+	case reflect.Int:
+		if raw == "" {
+			value.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("expected an integer")
+		}
+		value.SetInt(int64(n))
+	case reflect.Uint64:
+		if raw == "" {
+			value.SetUint(0)
+			return nil
+		}
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return errors.New("expected a positive integer")
+		}
+		value.SetUint(n)
+	case reflect.Slice:
+		list := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+	return nil
+}
+
+func format(value reflect.Value) string {
+	switch v := value.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// display hides the value of secret fields
+func display(field reflect.StructField, value string) string {
+	if field.Tag.Get("secret") == "true" && value != "" {
+		return REDACTED
+	}
+	return value
+}
@@ -0,0 +1,229 @@
+// Package settings holds the typed configuration of the whole application.
+//
+// Every value is read, in increasing priority, from the field default,
+// the YAML file on ATV_CONFIG_FILE, the .env file and the process environment.
+// The package imports nothing from the application so every other package,
+// the logger included, can read its settings from here.
+package settings
+
+import (
+	"time"
+)
+
+type AppSettings struct {
+	Env           string        `yaml:"env" json:"env" env:"ATV_ENV" default:"development"`
+	ApiVersion    string        `yaml:"api_version" json:"api_version" env:"ATV_API_VERSION" default:"0.1.4" validate:"semver"`
+	RoutesVersion uint64        `yaml:"routes_version" json:"routes_version" env:"ATV_ROUTE_VERSION" default:"1" validate:"min=1"`
+	ConfigWatch   time.Duration `yaml:"config_watch" json:"config_watch" env:"ATV_CONFIG_WATCH" default:"5s"` // How often the config files are checked for changes, 0 disables it
+}
+
+type ServerSettings struct {
+	Address         string        `yaml:"address" json:"address" env:"SERVER_ADDRESS" default:":8080"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"10s"`
+	DrainDelay      time.Duration `yaml:"drain_delay" json:"drain_delay" env:"SERVER_DRAIN_DELAY" default:"0s"`
+	TrustedProxies  []string      `yaml:"trusted_proxies" json:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES" validate:"networks"` // Peers whose X-Forwarded-For is believed, none when empty
+}
+
+type DBSettings struct {
+	Type                 string        `yaml:"type" json:"type" env:"DB_TYPE" default:"SQLITE" validate:"oneof=POSTGRES MONGO SQLITE MEMORY"`
+	Testing              bool          `yaml:"testing" json:"testing" env:"DB_TESTING" default:"false"`
+	ConnectionString     string        `yaml:"connection_string" json:"connection_string" env:"CONECTION_STRING" secret:"true"`
+	Name                 string        `yaml:"name" json:"name" env:"DB_NAME"`
+	ConnectionStringTest string        `yaml:"connection_string_test" json:"connection_string_test" env:"CONECTION_STRING_TEST" secret:"true"`
+	NameTest             string        `yaml:"name_test" json:"name_test" env:"DB_NAME_TEST"`
+	Timeout              time.Duration `yaml:"timeout" json:"timeout" env:"DB_TIMEOUT" default:"10s" validate:"positive"`
+	TimeoutRead          time.Duration `yaml:"timeout_read" json:"timeout_read" env:"DB_TIMEOUT_READ"`          // 0 uses Timeout
+	TimeoutWrite         time.Duration `yaml:"timeout_write" json:"timeout_write" env:"DB_TIMEOUT_WRITE"`       // 0 uses Timeout
+	TimeoutDelete        time.Duration `yaml:"timeout_delete" json:"timeout_delete" env:"DB_TIMEOUT_DELETE"`    // 0 uses Timeout
+	TimeoutConnect       time.Duration `yaml:"timeout_connect" json:"timeout_connect" env:"DB_TIMEOUT_CONNECT"` // 0 uses Timeout
+	ConnectRetries       int           `yaml:"connect_retries" json:"connect_retries" env:"DB_CONNECT_RETRIES" default:"5" validate:"min=1"`
+	ConnectBackoff       time.Duration `yaml:"connect_backoff" json:"connect_backoff" env:"DB_CONNECT_BACKOFF" default:"500ms" validate:"positive"`
+}
+
+// ActiveConnection returns the connection string and database name in use,
+// the testing ones when DB_TESTING is enabled
+func (db DBSettings) ActiveConnection() (connectionString string, name string) {
+	if db.Testing {
+		return db.ConnectionStringTest, db.NameTest
+	}
+	return db.ConnectionString, db.Name
+}
+
+type LogSettings struct {
+	MinLevel      string `yaml:"min_level" json:"min_level" env:"DNX_LOG_MIN_LEVEL" validate:"oneof=DEBUG INFO WARNING ERROR FATAL ALL NONE"`
+	DisableLevels string `yaml:"disable_levels" json:"disable_levels" env:"DNX_LOG_DISABLE_LEVELS" validate:"levels"`
+	Console       bool   `yaml:"console" json:"console" env:"DNX_LOG_CONSOLE" default:"true"`
+	File          bool   `yaml:"file" json:"file" env:"DNX_LOG_FILE" default:"false"`
+	Color         bool   `yaml:"color" json:"color" env:"DNX_LOG_WITH_COLOR" default:"false"`
+}
+
+type TracingSettings struct {
+	Exporter       string `yaml:"exporter" json:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none" validate:"oneof=none stdout otlp"`
+	ServiceName    string `yaml:"service_name" json:"service_name" env:"OTEL_SERVICE_NAME" default:"atv-backend"`
+	Endpoint       string `yaml:"endpoint" json:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" default:"http://localhost:4318" validate:"url"`
+	TracesEndpoint string `yaml:"traces_endpoint" json:"traces_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" validate:"url"`
+}
+
+type CORSSettings struct {
+	Profile          string        `yaml:"profile" json:"profile" env:"CORS_PROFILE"` // Empty uses the app env
+	ConfigFile       string        `yaml:"config_file" json:"config_file" env:"CORS_CONFIG_FILE" default:"cors.yaml"`
+	AllowOrigins     []string      `yaml:"allow_origins" json:"allow_origins" env:"CORS_ALLOW_ORIGINS" validate:"origins"`
+	AllowMethods     []string      `yaml:"allow_methods" json:"allow_methods" env:"CORS_ALLOW_METHODS"`
+	AllowHeaders     []string      `yaml:"allow_headers" json:"allow_headers" env:"CORS_ALLOW_HEADERS"`
+	ExposeHeaders    []string      `yaml:"expose_headers" json:"expose_headers" env:"CORS_EXPOSE_HEADERS"`
+	AllowCredentials bool          `yaml:"allow_credentials" json:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" json:"max_age" env:"CORS_MAX_AGE"`
+}
+
+type RateLimitSettings struct {
+	Enabled     bool              `yaml:"enabled" json:"enabled" env:"RATE_LIMIT_ENABLED" default:"true"`
+	Default     string            `yaml:"default" json:"default" env:"RATE_LIMIT_DEFAULT" default:"120/m" validate:"rate"`
+	DefaultUser string            `yaml:"default_user" json:"default_user" env:"RATE_LIMIT_DEFAULT_USER" default:"600/m" validate:"rate"`
+	Groups      map[string]string `yaml:"groups" json:"groups" env:"RATE_LIMIT_*" validate:"rate"` // Per route group, "student" or "student_user"
+}
+
+type AuthSettings struct {
+	JWTSecret string `yaml:"jwt_secret" json:"jwt_secret" env:"AUTH_JWT_SECRET" secret:"true" validate:"minlen=16"`
+}
+
+type HealthSettings struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" json:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s" validate:"positive"`
+}
+
+type StorageSettings struct {
+	Backend       string        `yaml:"backend" json:"backend" env:"STORAGE_BACKEND" default:"local" validate:"oneof=local s3"`
+	LocalDir      string        `yaml:"local_dir" json:"local_dir" env:"STORAGE_LOCAL_DIR" default:"uploads"`                // Root of the local backend
+	MaxSize       int           `yaml:"max_size" json:"max_size" env:"STORAGE_MAX_SIZE" default:"10485760" validate:"min=1"` // Largest file accepted, in bytes
+	S3Endpoint    string        `yaml:"s3_endpoint" json:"s3_endpoint" env:"STORAGE_S3_ENDPOINT" validate:"url"`             // Any S3-compatible service, addressed path-style
+	S3Bucket      string        `yaml:"s3_bucket" json:"s3_bucket" env:"STORAGE_S3_BUCKET"`
+	S3Region      string        `yaml:"s3_region" json:"s3_region" env:"STORAGE_S3_REGION" default:"us-east-1"`
+	S3AccessKey   string        `yaml:"s3_access_key" json:"s3_access_key" env:"STORAGE_S3_ACCESS_KEY" secret:"true"`
+	S3SecretKey   string        `yaml:"s3_secret_key" json:"s3_secret_key" env:"STORAGE_S3_SECRET_KEY" secret:"true"`
+	SigningSecret string        `yaml:"signing_secret" json:"signing_secret" env:"STORAGE_SIGNING_SECRET" secret:"true"` // Signs the download URLs, random per process when empty
+	URLExpiry     time.Duration `yaml:"url_expiry" json:"url_expiry" env:"STORAGE_URL_EXPIRY" default:"15m" validate:"positive"`
+}
+
+type NotificationSettings struct {
+	Sender         string        `yaml:"sender" json:"sender" env:"NOTIFY_SENDER" default:"log" validate:"oneof=log file smtp"` // log and file are meant for local development
+	From           string        `yaml:"from" json:"from" env:"NOTIFY_FROM" default:"ATV <no-reply@atv.local>"`
+	Language       string        `yaml:"language" json:"language" env:"NOTIFY_LANGUAGE" default:"es" validate:"oneof=es en"` // For users without a preference
+	FileDir        string        `yaml:"file_dir" json:"file_dir" env:"NOTIFY_FILE_DIR" default:"outbox"`                    // Where the file sender writes the messages
+	SMTPHost       string        `yaml:"smtp_host" json:"smtp_host" env:"NOTIFY_SMTP_HOST"`
+	SMTPPort       int           `yaml:"smtp_port" json:"smtp_port" env:"NOTIFY_SMTP_PORT" default:"587" validate:"min=1"`
+	SMTPUsername   string        `yaml:"smtp_username" json:"smtp_username" env:"NOTIFY_SMTP_USERNAME"`
+	SMTPPassword   string        `yaml:"smtp_password" json:"smtp_password" env:"NOTIFY_SMTP_PASSWORD" secret:"true"`
+	SMTPTimeout    time.Duration `yaml:"smtp_timeout" json:"smtp_timeout" env:"NOTIFY_SMTP_TIMEOUT" default:"30s" validate:"positive"`          // Longest a message may take to send
+	ReminderBefore time.Duration `yaml:"reminder_before" json:"reminder_before" env:"NOTIFY_REMINDER_BEFORE" default:"24h" validate:"positive"` // How long before a session its reminder is sent
+	ReminderCheck  time.Duration `yaml:"reminder_check" json:"reminder_check" env:"NOTIFY_REMINDER_CHECK" default:"5m"`                         // How often the sessions are checked for reminders, 0 disables them
+}
+
+type JobSettings struct {
+	Backend     string        `yaml:"backend" json:"backend" env:"JOBS_BACKEND" default:"memory" validate:"oneof=memory database"` // Read at startup, database keeps the queue on the configured database, surviving restarts
+	Workers     int           `yaml:"workers" json:"workers" env:"JOBS_WORKERS" default:"4" validate:"min=1"`                      // Read at startup
+	Poll        time.Duration `yaml:"poll" json:"poll" env:"JOBS_POLL" default:"1s" validate:"positive"`                           // How often idle workers look for due jobs
+	MaxAttempts int           `yaml:"max_attempts" json:"max_attempts" env:"JOBS_MAX_ATTEMPTS" default:"5" validate:"min=1"`
+	Backoff     time.Duration `yaml:"backoff" json:"backoff" env:"JOBS_BACKOFF" default:"10s" validate:"positive"` // Wait before the first retry, doubled on every other one
+	MaxBackoff  time.Duration `yaml:"max_backoff" json:"max_backoff" env:"JOBS_MAX_BACKOFF" default:"1h" validate:"positive"`
+	Lease       time.Duration `yaml:"lease" json:"lease" env:"JOBS_LEASE" default:"5m" validate:"positive"`               // A job running for longer is taken as crashed and run again
+	Retention   time.Duration `yaml:"retention" json:"retention" env:"JOBS_RETENTION" default:"168h" validate:"positive"` // Done and dead jobs are dropped this long after they finished
+}
+
+type WebhookSettings struct {
+	Timeout       time.Duration `yaml:"timeout" json:"timeout" env:"WEBHOOK_TIMEOUT" default:"10s" validate:"positive"`        // Longest wait for a subscriber to answer a delivery
+	AllowNetworks []string      `yaml:"allow_networks" json:"allow_networks" env:"WEBHOOK_ALLOW_NETWORKS" validate:"networks"` // Private or loopback destinations deliveries may still go to, none when empty
+}
+
+type StreamSettings struct {
+	Source    string        `yaml:"source" json:"source" env:"STREAM_SOURCE" default:"local" validate:"oneof=local change_stream"` // change_stream follows the changes of every instance through MongoDB, needs a replica set
+	Heartbeat time.Duration `yaml:"heartbeat" json:"heartbeat" env:"STREAM_HEARTBEAT" default:"25s" validate:"positive"`           // Keeps idle streams open through proxies
+	Buffer    int           `yaml:"buffer" json:"buffer" env:"STREAM_BUFFER" default:"64" validate:"min=1"`                        // Events a slow client may fall behind before it is dropped
+}
+
+type CalendarSettings struct {
+	SessionLength time.Duration `yaml:"session_length" json:"session_length" env:"CALENDAR_SESSION_LENGTH" default:"1h" validate:"positive"` // Sessions have no end, the feed shows them this long
+}
+
+type SearchSettings struct {
+	IndexTTL time.Duration `yaml:"index_ttl" json:"index_ttl" env:"SEARCH_INDEX_TTL" default:"5m" validate:"positive"` // The index kept between searches is rebuilt this often, catching the writes of other instances
+}
+
+type TenancySettings struct {
+	Enabled     bool   `yaml:"enabled" json:"enabled" env:"TENANCY_ENABLED" default:"false"`                // Keeps every request to the records of one university
+	Header      string `yaml:"header" json:"header" env:"TENANCY_HEADER" default:"X-ATV-Tenant"`            // Picks the university of super-admin requests, see TENANCY_TRUST_HEADER
+	TrustHeader bool   `yaml:"trust_header" json:"trust_header" env:"TENANCY_TRUST_HEADER" default:"false"` // Takes the header from any caller without a tenant, only behind a gateway that sets it
+}
+
+// Config is every setting of the application.
+// Sections tagged reload:"restart" are read once at startup, changing them on a reload has no effect.
+type Config struct {
+	App       AppSettings          `yaml:"app" json:"app" reload:"restart"`
+	Server    ServerSettings       `yaml:"server" json:"server" reload:"restart"`
+	DB        DBSettings           `yaml:"db" json:"db"`
+	Log       LogSettings          `yaml:"log" json:"log"`
+	Tracing   TracingSettings      `yaml:"tracing" json:"tracing" reload:"restart"`
+	CORS      CORSSettings         `yaml:"cors" json:"cors"`
+	RateLimit RateLimitSettings    `yaml:"rate_limit" json:"rate_limit"`
+	Auth      AuthSettings         `yaml:"auth" json:"auth" reload:"restart"`
+	Health    HealthSettings       `yaml:"health" json:"health" reload:"restart"`
+	Storage   StorageSettings      `yaml:"storage" json:"storage"`
+	Notify    NotificationSettings `yaml:"notify" json:"notify"`
+	Jobs      JobSettings          `yaml:"jobs" json:"jobs"`
+	Webhooks  WebhookSettings      `yaml:"webhooks" json:"webhooks"`
+	Stream    StreamSettings       `yaml:"stream" json:"stream"`
+	Calendar  CalendarSettings     `yaml:"calendar" json:"calendar"`
+	Search    SearchSettings       `yaml:"search" json:"search"`
+	Tenancy   TenancySettings      `yaml:"tenancy" json:"tenancy"`
+	Features  map[string]string    `yaml:"features" json:"features" env:"FEATURE_*" validate:"bool"` // Feature flags, FEATURE_NEW_SEARCH=true becomes Features["new_search"]
+}
+
+// validate checks the rules that involve more than one field
+func (c Config) validate(loaded *Loaded) {
+	connectionString, name := c.DB.ActiveConnection()
+	connectionEnv, nameEnv := "CONECTION_STRING", "DB_NAME"
+	if c.DB.Testing {
+		connectionEnv, nameEnv = "CONECTION_STRING_TEST", "DB_NAME_TEST"
+	}
+
+	switch c.DB.Type {
+	case "MONGO":
+		if connectionString == "" {
+			loaded.problem(connectionEnv, "is required when DB_TYPE is MONGO")
+		}
+		if name == "" {
+			loaded.problem(nameEnv, "is required when DB_TYPE is MONGO")
+		}
+	case "POSTGRES":
+		if connectionString == "" {
+			loaded.problem(connectionEnv, "is required when DB_TYPE is POSTGRES")
+		}
+	}
+
+	if c.Storage.Backend == "s3" {
+		if c.Storage.S3Endpoint == "" {
+			loaded.problem("STORAGE_S3_ENDPOINT", "is required when STORAGE_BACKEND is s3")
+		}
+		if c.Storage.S3Bucket == "" {
+			loaded.problem("STORAGE_S3_BUCKET", "is required when STORAGE_BACKEND is s3")
+		}
+	}
+
+	if c.Notify.Sender == "smtp" && c.Notify.SMTPHost == "" {
+		loaded.problem("NOTIFY_SMTP_HOST", "is required when NOTIFY_SENDER is smtp")
+	}
+
+	if c.Stream.Source == "change_stream" && c.DB.Type != "MONGO" {
+		loaded.problem("STREAM_SOURCE", "change_stream needs DB_TYPE MONGO")
+	}
+
+	if c.Tenancy.Enabled && c.Tenancy.Header == "" {
+		loaded.problem("TENANCY_HEADER", "is required when TENANCY_ENABLED is true")
+	}
+
+	if c.Jobs.MaxBackoff < c.Jobs.Backoff {
+		loaded.problem("JOBS_MAX_BACKOFF", "can not be shorter than JOBS_BACKOFF")
+	}
+
+	if c.CORS.AllowCredentials && len(c.CORS.AllowOrigins) == 1 && c.CORS.AllowOrigins[0] == "*" {
+		loaded.problem("CORS_ALLOW_CREDENTIALS", "can not be used when every origin is allowed")
+	}
+}
@@ -0,0 +1,114 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	semverRegex = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+	rateRegex   = regexp.MustCompile(`^(off|none|[1-9]\d*/(s|m|h|\d+(\.\d+)?(ns|us|µs|ms|s|m|h)))$`)
+	logLevels   = []string{"DEBUG", "INFO", "WARNING", "ERROR", "FATAL", "ALL", "NONE"}
+)
+
+// check applies the rules of a validate tag, e.g. "oneof=A B C" or "min=1", to a parsed value.
+// Empty values are only checked by the rules that say so, a missing value is not a format error.
+func check(rules string, value reflect.Value) error {
+	if rules == "" || value.IsZero() {
+		return nil
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, argument, _ := strings.Cut(rule, "=")
+
+		var err error
+		switch name {
+		case "oneof":
+			options := strings.Fields(argument)
+			if !slices.Contains(options, value.String()) {
+				err = fmt.Errorf("expected one of %s", strings.Join(options, ", "))
+			}
+		case "semver":
+			if !semverRegex.MatchString(value.String()) {
+				err = errors.New("expected a version like 1.2.3")
+			}
+		case "url":
+			parsed, parseErr := url.Parse(value.String())
+			if parseErr != nil || parsed.Scheme == "" || parsed.Host == "" {
+				err = errors.New("expected an absolute URL like http://host:port")
+			}
+		case "min":
+			minimum, _ := strconv.ParseInt(argument, 10, 64)
+			if toInt(value) < minimum {
+				err = fmt.Errorf("must be at least %d", minimum)
+			}
+		case "minlen":
+			minimum, _ := strconv.Atoi(argument)
+			if len(value.String()) < minimum {
+				err = fmt.Errorf("must be at least %d characters long", minimum)
+			}
+		case "positive":
+			if value.Interface().(time.Duration) <= 0 {
+				err = errors.New("must be greater than zero")
+			}
+		case "bool":
+			if _, parseErr := strconv.ParseBool(strings.TrimSpace(value.String())); parseErr != nil {
+				err = errors.New("expected true or false")
+			}
+		case "rate":
+			if !rateRegex.MatchString(strings.ToLower(strings.TrimSpace(value.String()))) {
+				err = errors.New("expected <requests>/<period> like 60/m, 10/s or 100/30s, or off")
+			}
+		case "levels":
+			for _, level := range strings.Split(value.String(), "|") {
+				if !slices.Contains(logLevels, strings.TrimSpace(level)) {
+					err = fmt.Errorf("unknown level %q, expected levels of %s separated by |", strings.TrimSpace(level), strings.Join(logLevels, ", "))
+					break
+				}
+			}
+		case "origins":
+			for _, origin := range value.Interface().([]string) {
+				if origin == "*" {
+					continue
+				}
+				parsed, parseErr := url.Parse(origin)
+				if parseErr != nil || parsed.Scheme == "" || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+					err = fmt.Errorf("%q is not an origin like https://example.com", origin)
+					break
+				}
+			}
+		case "networks":
+			for _, network := range value.Interface().([]string) {
+				_, parseErr := netip.ParsePrefix(network)
+				if _, addrErr := netip.ParseAddr(network); parseErr != nil && addrErr != nil {
+					err = fmt.Errorf("%q is not an IP or a CIDR like 10.0.0.0/8", network)
+					break
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown validation rule %q", name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toInt(value reflect.Value) int64 {
+	switch value.Kind() {
+	case reflect.Uint64:
+		return int64(value.Uint())
+	default:
+		return value.Int()
+	}
+}
@@ -0,0 +1,116 @@
+package configs
+
+import (
+	"context"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tenant"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// tenantOf returns the tenant the records of the model are kept to on ctx,
+// false for shared models and contexts acting across tenants
+func tenantOf(ctx context.Context, model models.DBModelInterface) (models.DBID, bool) {
+	if _, shared := model.(models.SharedModel); shared {
+		return models.DBID{}, false
+	}
+	return tenant.From(ctx)
+}
+
+// scopeFilter adds the tenant of ctx to the filter, so only the records of the tenant match
+func scopeFilter(ctx context.Context, filter any, model models.DBModelInterface) (any, error) {
+	id, scoped := tenantOf(ctx, model)
+	if !scoped {
+		return filter, nil
+	}
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: "$and", Value: bson.A{query, bson.D{{Key: tenant.FIELD, Value: id}}}}}, nil
+}
+
+// scopeDocument stamps the tenant of ctx on a new record
+func scopeDocument(ctx context.Context, document models.DBModelInterface) (any, error) {
+	id, scoped := tenantOf(ctx, document)
+	if !scoped {
+		return document, nil
+	}
+	stamped, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	return setField(stamped, tenant.FIELD, id), nil
+}
+
+// scopeUpdate keeps the tenant of ctx on an updated record, an update can not move it to another tenant
+func scopeUpdate(ctx context.Context, update any, model models.DBModelInterface) (any, error) {
+	id, scoped := tenantOf(ctx, model)
+	if !scoped {
+		return update, nil
+	}
+	operators, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+	set, _ := lookup(operators, "$set")
+	fields, _ := set.(bson.D)
+	operators = setField(operators, "$set", setField(fields, tenant.FIELD, id))
+
+	if unset, ok := lookup(operators, "$unset"); ok {
+		if fields, ok := unset.(bson.D); ok {
+			operators = setField(operators, "$unset", unsetField(fields, tenant.FIELD))
+		}
+	}
+	return operators, nil
+}
+
+// IndexKeys returns the keys of the index in order, led by the tenant unless the model is shared
+func IndexKeys(index models.Index) []string {
+	if _, shared := index.Model.(models.SharedModel); shared {
+		return index.Keys
+	}
+	return append([]string{tenant.FIELD}, index.Keys...)
+}
+
+// EnsureIndexes makes the indexes in models.INDEXES, those already made are left as they are.
+// Only MongoDB keeps indexes, on any other database it does nothing, the memory store checks the unique ones itself.
+func (db) EnsureIndexes(ctx context.Context) error {
+	if DB.Type() != DB.Types().MongoDB() {
+		return nil
+	}
+
+	var failed []error
+	for _, index := range models.INDEXES {
+		collection, err := DB.collection(ctx, index.Model)
+		if err != nil {
+			return err
+		}
+
+		keys := IndexKeys(index)
+		document := bson.D{}
+		for _, key := range keys {
+			document = append(document, bson.E{Key: key, Value: 1})
+		}
+		name := strings.Join(keys, "_")
+
+		writeCtx, cancel := DB.Context(ctx, DB.Operations().Write())
+		_, err = collection.Indexes().CreateOne(writeCtx, mongo.IndexModel{
+			Keys:    document,
+			Options: options.Index().SetName(name).SetUnique(index.Unique),
+		})
+		cancel()
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s.%s: %w", index.Model.TableName(), name, err))
+			continue
+		}
+		logger.Debug("Index", name, "of", index.Model.TableName(), "ready")
+	}
+	return errors.Join(failed...)
+}
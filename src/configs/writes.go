@@ -0,0 +1,45 @@
+package configs
+
+import (
+	"context"
+	"dainxor/atv/logger"
+	"sync"
+)
+
+// WriteListener is told the collection of every write that went through DB, once it is stored.
+// It runs on the writer's goroutine, so it has to be quick.
+type WriteListener func(ctx context.Context, collection string)
+
+var writeListeners struct {
+	mutex sync.RWMutex
+	list  []WriteListener
+}
+
+// OnWrite adds a listener of the writes, like a cache of the collection
+func (db) OnWrite(listener WriteListener) {
+	writeListeners.mutex.Lock()
+	defer writeListeners.mutex.Unlock()
+	writeListeners.list = append(writeListeners.list, listener)
+}
+
+// written tells every listener about a write on the collection, unless it failed.
+// A panicking listener does not fail the write.
+func written(ctx context.Context, collection string, err error) {
+	if err != nil {
+		return
+	}
+	writeListeners.mutex.RLock()
+	listeners := writeListeners.list
+	writeListeners.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					logger.WithContext(ctx).Error("Write listener failed on", collection, ":", recovered)
+				}
+			}()
+			listener(ctx, collection)
+		}()
+	}
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"dainxor/atv/configs"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+type adminType struct{}
+
+var Admin adminType
+
+// configReport is the effective configuration with secrets redacted
+func configReport(loaded *settings.Loaded) gin.H {
+	return gin.H{
+		"config":       loaded.Redacted(),
+		"fields":       loaded.Fields,
+		"problems":     loaded.Problems,
+		"config_file":  loaded.ConfigFile,
+		"cors_profile": configs.CORS.Profile(),
+		"features":     configs.Features.All(),
+		"reload":       configs.Reloads(),
+	}
+}
+
+// Config shows the configuration in use, where each value came from and when it was loaded
+func (adminType) Config(c *gin.Context) {
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			configReport(settings.Current()),
+			"",
+		),
+	)
+}
+
+// ReloadConfig reloads the configuration right away, as SIGHUP does
+func (adminType) ReloadConfig(c *gin.Context) {
+	loaded := configs.Reload("admin request")
+
+	message := "Configuration reloaded"
+	if len(loaded.Problems) > 0 {
+		message = "Configuration reloaded with problems, invalid values use their defaults"
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			configReport(loaded),
+			message,
+		),
+	)
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/types"
+	"dainxor/atv/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type assignmentType struct{}
+
+var Assignment assignmentType
+
+func (assignmentType) Create(c *gin.Context) {
+	var body models.AssignmentCreate
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		expected := utils.StructToString(body)
+		logger.Error(err.Error())
+		logger.Error("Failed to create assignment: JSON request body is invalid")
+		logger.Error("Expected body: ", expected)
+
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid request body",
+				"Expected body: "+expected,
+			),
+		)
+		return
+	}
+
+	logger.Debug("Creating assignment: ", body)
+	respondAssignment(c, db.Assignment.Create(c.Request.Context(), body), types.Http.C200().Created())
+}
+
+func (assignmentType) GetByID(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Getting assignment by ID: ", id)
+
+	respondAssignment(c, db.Assignment.GetByID(c.Request.Context(), id), types.Http.C200().Ok())
+}
+
+func (assignmentType) GetAll(c *gin.Context) {
+	respondAssignments(c, db.Assignment.GetAll(c.Request.Context()))
+}
+
+func (assignmentType) GetAllByStudentID(c *gin.Context) {
+	id := c.Param("student_id")
+	logger.Debug("Getting assignments by student ID: ", id)
+
+	respondAssignments(c, db.Assignment.GetAllByStudentID(c.Request.Context(), id))
+}
+
+func (assignmentType) Reassign(c *gin.Context) {
+	var body models.AssignmentChange
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		expected := utils.StructToString(body)
+		logger.Error(err.Error())
+		logger.Error("Failed to reassign: JSON request body is invalid")
+		logger.Error("Expected body: ", expected)
+
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid request body",
+				"Expected body: "+expected,
+			),
+		)
+		return
+	}
+
+	id := c.Param("id")
+	logger.Debug("Reassigning assignment: ", id)
+	respondAssignment(c, db.Assignment.Reassign(c.Request.Context(), id, body), types.Http.C200().Created())
+}
+
+func (assignmentType) End(c *gin.Context) {
+	var body models.AssignmentChange
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		expected := utils.StructToString(body)
+		logger.Error(err.Error())
+		logger.Error("Failed to end assignment: JSON request body is invalid")
+		logger.Error("Expected body: ", expected)
+
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid request body",
+				"Expected body: "+expected,
+			),
+		)
+		return
+	}
+
+	id := c.Param("id")
+	logger.Debug("Ending assignment: ", id)
+	respondAssignment(c, db.Assignment.End(c.Request.Context(), id, body), types.Http.C200().Ok())
+}
+
+func respondAssignment(c *gin.Context, result types.Result[models.AssignmentDBMongo], status int) {
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(status,
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
+
+func respondAssignments(c *gin.Context, result types.Result[[]models.AssignmentDBMongo]) {
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	assignments := utils.Map(result.Value(), models.AssignmentDBMongo.ToResponse)
+	if len(assignments) == 0 {
+		logger.Warning("No assignments found")
+		c.JSON(types.Http.C400().NotFound(),
+			types.EmptyResponse(
+				"No assignments found",
+			))
+		return
+	}
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			assignments,
+			"",
+		),
+	)
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"bytes"
+	"cmp"
+	"dainxor/atv/calendar"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CALENDAR_NAME    = "ATV - Sesiones"
+	CALENDAR_SESSION = "Sesión" // Summary of the sessions whose type is gone
+)
+
+// respondCalendarToken answers with the feed URL of the owner
+func respondCalendarToken(c *gin.Context, result types.Result[models.CalendarTokenDBMongo], msg string) {
+	if result.IsErr() {
+		respondError(c, result.Error())
+		return
+	}
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			result.Value().ToResponse(),
+			msg,
+		),
+	)
+}
+
+// serveCalendar answers with the sessions of the owner as an iCalendar feed, when the token query parameter opens it
+func serveCalendar(c *gin.Context, owner string) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	check := db.Calendar.CheckToken(ctx, owner, id, c.Query("token"))
+	if check.IsErr() {
+		respondError(c, check.Error())
+		return
+	}
+
+	var sessions types.Result[[]models.SessionDBMongo]
+	if owner == models.CALENDAR_STUDENT {
+		sessions = db.Session.GetAllByStudentID(ctx, id)
+	} else {
+		sessions = db.Session.GetAllByCompanionID(ctx, id)
+	}
+	if sessions.IsErr() {
+		respondError(c, sessions.Error())
+		return
+	}
+
+	names := map[models.DBID]string{}
+	for _, sessionType := range db.SessionType.GetAll(ctx).ValueOr(nil) {
+		names[sessionType.ID] = sessionType.Name
+	}
+
+	feed := calendar.Feed{Name: CALENDAR_NAME}
+	length := settings.Get().Calendar.SessionLength
+	for _, session := range sessions.Value() {
+		event, ok := calendar.SessionEvent(session, cmp.Or(names[session.IDSessionType], CALENDAR_SESSION), length)
+		if !ok {
+			logger.Warning("Leaving session", session.ID.Hex(), "out of the calendar, its date can not be read:", session.Date)
+			continue
+		}
+		feed.Events = append(feed.Events, event)
+	}
+
+	var body bytes.Buffer
+	if err := feed.Write(&body, time.Now()); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("Content-Disposition", `inline; filename="`+owner+`.ics"`)
+	c.Data(types.Http.C200().Ok(), calendar.CONTENT_TYPE+"; charset=utf-8", body.Bytes())
+}
+
+// GetCalendar returns the calendar feed URL of the companion, creating its token the first time
+func (companionType) GetCalendar(c *gin.Context) {
+	logger.Debug("Getting calendar feed of companion: ", c.Param("id"))
+	respondCalendarToken(c, db.Calendar.GetToken(c.Request.Context(), models.CALENDAR_COMPANION, c.Param("id")), "")
+}
+
+// RevokeCalendar stops the current calendar feed URL of the companion from working
+func (companionType) RevokeCalendar(c *gin.Context) {
+	logger.Info("Revoking calendar feed of companion: ", c.Param("id"))
+	respondCalendarToken(c, db.Calendar.RevokeToken(c.Request.Context(), models.CALENDAR_COMPANION, c.Param("id")), "Calendar feed revoked")
+}
+
+// Calendar serves the sessions of the companion as an iCalendar feed
+func (companionType) Calendar(c *gin.Context) {
+	serveCalendar(c, models.CALENDAR_COMPANION)
+}
+
+// GetCalendar returns the calendar feed URL of the student, creating its token the first time
+func (studentType) GetCalendar(c *gin.Context) {
+	logger.Debug("Getting calendar feed of student: ", c.Param("id"))
+	respondCalendarToken(c, db.Calendar.GetToken(c.Request.Context(), models.CALENDAR_STUDENT, c.Param("id")), "")
+}
+
+// RevokeCalendar stops the current calendar feed URL of the student from working
+func (studentType) RevokeCalendar(c *gin.Context) {
+	logger.Info("Revoking calendar feed of student: ", c.Param("id"))
+	respondCalendarToken(c, db.Calendar.RevokeToken(c.Request.Context(), models.CALENDAR_STUDENT, c.Param("id")), "Calendar feed revoked")
+}
+
+// Calendar serves the sessions of the student as an iCalendar feed
+func (studentType) Calendar(c *gin.Context) {
+	serveCalendar(c, models.CALENDAR_STUDENT)
+}
@@ -6,7 +6,10 @@ import (
 	"dainxor/atv/models"
 	"dainxor/atv/types"
 	"dainxor/atv/utils"
+	"dainxor/atv/workload"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,7 +22,7 @@ func (companionType) GetByIDMongo(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Getting companion by ID: ", id)
 
-	result := db.Companion.GetByID(id)
+	result := db.Companion.GetByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -41,8 +44,60 @@ func (companionType) GetByIDMongo(c *gin.Context) {
 		),
 	)
 }
+
+// GetByNumberID returns the companion with the document number, deleted ones are not found
+func (companionType) GetByNumberID(c *gin.Context) {
+	number := c.Param("number_id")
+	logger.Debug("Getting companion by document number: ", number)
+
+	result := db.Companion.GetByNumberID(c.Request.Context(), number)
+
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
+
+// GetByEmail returns the companion with the email, deleted ones are not found
+func (companionType) GetByEmail(c *gin.Context) {
+	email := c.Param("email")
+	logger.Debug("Getting companion by email: ", email)
+
+	result := db.Companion.GetByEmail(c.Request.Context(), email)
+
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
 func (companionType) GetAllMongo(c *gin.Context) {
-	result := db.Companion.GetAll()
+	result := db.Companion.GetAll(c.Request.Context())
 
 	if result.IsErr() {
 		err := result.Error().(*types.HttpError)
@@ -92,7 +147,7 @@ func (companionType) CreateMongo(c *gin.Context) {
 
 	logger.Debug("Creating companion in MongoDB: ", body)
 
-	result := db.Companion.Create(body)
+	result := db.Companion.Create(c.Request.Context(), body)
 
 	if result.IsErr() {
 		logger.Error("Failed to create companion in MongoDB: ", result.Error())
@@ -137,11 +192,16 @@ func (companionType) UpdateMongo(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Updating companion by ID: ", id)
 
-	result := db.Companion.UpdateByID(id, body)
+	result := db.Companion.UpdateByID(c.Request.Context(), id, body)
 	if result.IsErr() {
 		err := result.Error()
 		cerror := err.(*types.HttpError)
-		c.JSON(cerror.Code, err)
+		c.JSON(cerror.Code,
+			types.EmptyResponse(
+				cerror.Msg(),
+				cerror.Details(),
+			),
+		)
 		return
 	}
 
@@ -174,7 +234,7 @@ func (companionType) PatchMongo(c *gin.Context) {
 
 	id := c.Param("id")
 
-	result := db.Companion.PatchByID(id, body)
+	result := db.Companion.PatchByID(c.Request.Context(), id, body)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -197,12 +257,74 @@ func (companionType) PatchMongo(c *gin.Context) {
 	)
 }
 
+// PutPhoto replaces the profile photo of the companion, the previous one is removed once replaced
+func (companionType) PutPhoto(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+
+	current := db.Companion.GetByID(ctx, id)
+	if current.IsErr() {
+		err := current.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	stored, ok := uploadPhoto(c, "companion", id)
+	if !ok {
+		return
+	}
+
+	result := db.Companion.SetPhoto(ctx, id, stored)
+	if result.IsErr() {
+		removePhoto(ctx, stored)
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+	removePhoto(ctx, current.Value().Photo)
+
+	c.JSON(http.StatusOK,
+		types.Response(
+			result.Value().ToResponse(),
+			"Photo updated",
+		),
+	)
+}
+
+// GetPhoto serves the profile photo of the companion
+func (companionType) GetPhoto(c *gin.Context) {
+	id := c.Param("id")
+
+	result := db.Companion.GetByID(c.Request.Context(), id)
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+	servePhoto(c, "companion", result.Value().Photo)
+}
+
 // DeleteByID deletes a companion by ID
 func (companionType) DeleteByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Deleting companion by ID: ", id)
 
-	result := db.Companion.DeleteByID(id)
+	result := db.Companion.DeleteByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -240,7 +362,7 @@ func (companionType) ForceDeleteByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Info("Force deleting companion by ID: ", id)
 
-	result := db.Companion.DeletePermanentByID(id)
+	result := db.Companion.DeletePermanentByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -264,6 +386,129 @@ func (companionType) ForceDeleteByID(c *gin.Context) {
 	)
 }
 
+// Caseload shows the active students, upcoming sessions and hours this week of a companion
+func (companionType) Caseload(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Getting caseload of companion: ", id)
+
+	companion := db.Companion.GetByID(c.Request.Context(), id)
+	if companion.IsErr() {
+		err := companion.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	sessions := db.Session.GetAllByCompanionID(c.Request.Context(), id)
+	if sessions.IsErr() {
+		err := sessions.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			workload.Caseload(companion.Value(), sessions.Value(), time.Now()),
+			"",
+		),
+	)
+}
+
+// Suggest ranks the companions of a speciality free at the given date,
+// by past sessions with the student first and by their load that week next.
+// The list is empty when none is free.
+func (companionType) Suggest(c *gin.Context) {
+	speciality := c.Query("speciality")
+	if speciality == "" {
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Missing speciality",
+				"Use ?speciality=<speciality id>",
+			),
+		)
+		return
+	}
+
+	date := time.Now()
+	if value := c.Query("date"); value != "" {
+		parsed, ok := models.ParseSessionDate(value)
+		if !ok {
+			c.JSON(types.Http.C400().BadRequest(),
+				types.EmptyResponse(
+					"Invalid date",
+					"Expected one of the layouts: "+strings.Join(models.SESSION_DATE_LAYOUTS, ", "),
+				),
+			)
+			return
+		}
+		date = parsed
+	}
+
+	var student models.DBID
+	if value := c.Query("student"); value != "" {
+		var err error
+		if student, err = models.ID.ToDB(value); err != nil {
+			c.JSON(types.Http.C400().UnprocessableEntity(),
+				types.EmptyResponse(
+					"Invalid value",
+					"Invalid student ID format: "+err.Error(),
+				),
+			)
+			return
+		}
+	}
+
+	companions := db.Companion.GetAllBySpeciality(c.Request.Context(), speciality)
+	if companions.IsErr() {
+		err := companions.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	ids := utils.Map(companions.Value(), func(companion models.CompanionDBMongo) models.DBID { return companion.ID })
+	sessions := db.Session.GetAllByCompanionIDs(c.Request.Context(), ids)
+	if sessions.IsErr() {
+		err := sessions.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	byCompanion := map[models.DBID][]models.SessionDBMongo{}
+	for _, session := range sessions.Value() {
+		byCompanion[session.IDCompanion] = append(byCompanion[session.IDCompanion], session)
+	}
+	candidates := utils.Map(companions.Value(), func(companion models.CompanionDBMongo) workload.Candidate {
+		return workload.Candidate{Companion: companion, Sessions: byCompanion[companion.ID]}
+	})
+
+	// Nobody free is a valid answer, an empty list
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			workload.Suggest(candidates, student, date, time.Now()),
+			"",
+		),
+	)
+}
+
 func (companionType) GetByIDGorm(c *gin.Context) {
 	c.Header("Location", "/api/v1/companion/"+c.Param("id"))
 	c.JSON(types.Http.C300().MovedPermanently(),
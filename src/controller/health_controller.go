@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"dainxor/atv/configs"
+	"dainxor/atv/health"
+	"dainxor/atv/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+type healthType struct{}
+
+var Health healthType
+
+func versionInfo() gin.H {
+	return gin.H{
+		"api":    configs.App.ApiVersion(),
+		"routes": configs.App.RoutesVersion(),
+		"build":  configs.App.BuildVersion(),
+		"commit": configs.App.BuildCommit(),
+	}
+}
+
+// Live answers as long as the process is able to serve requests, it checks no dependency
+func (healthType) Live(c *gin.Context) {
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			gin.H{"status": "alive"},
+			"",
+		),
+	)
+}
+
+// Ready checks every dependency and answers 503 when any of them is down
+func (healthType) Ready(c *gin.Context) {
+	report := health.Check(c.Request.Context())
+
+	status := "ready"
+	code := types.Http.C200().Ok()
+	message := ""
+	if report.Draining {
+		status = "draining"
+		code = types.Http.C500().ServiceUnavailable()
+		message = "The server is shutting down"
+	} else if !report.Ready {
+		status = "not ready"
+		code = types.Http.C500().ServiceUnavailable()
+		message = "One or more dependencies are unavailable"
+	}
+
+	c.JSON(code,
+		types.Response(
+			gin.H{
+				"status":  status,
+				"version": versionInfo(),
+				"checks":  report.Checks,
+			},
+			message,
+		),
+	)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"dainxor/atv/jobs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/types"
+	"dainxor/atv/utils"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type jobType struct{}
+
+var Job jobType
+
+// GetAll lists the jobs on the queue, filtered by the status and kind query parameters
+func (jobType) GetAll(c *gin.Context) {
+	status, kind := c.Query("status"), c.Query("kind")
+	logger.Debug("Getting jobs with status", status, "and kind", kind)
+
+	if status != "" && !slices.Contains(models.JOB_STATUSES, status) {
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid status",
+				"Expected one of: "+strings.Join(models.JOB_STATUSES, ", "),
+			),
+		)
+		return
+	}
+
+	list, err := jobs.Current().List(c.Request.Context(), status, kind)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			utils.Map(list, models.JobDBMongo.ToResponse),
+			"",
+		),
+	)
+}
+
+func (jobType) GetByID(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Getting job by ID: ", id)
+
+	job, err := jobs.Current().Get(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			job.ToResponse(),
+			"",
+		),
+	)
+}
+
+// Retry queues a dead job again with every attempt available
+func (jobType) Retry(c *gin.Context) {
+	id := c.Param("id")
+	logger.Info("Retrying job: ", id)
+
+	job, err := jobs.Retry(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			job.ToResponse(),
+			"Job queued again",
+		),
+	)
+}
+
+// respondError answers with the error, as an internal one when it carries no HTTP code
+func respondError(c *gin.Context, err error) {
+	httpErr, ok := err.(*types.HttpError)
+	if !ok {
+		internal := types.ErrorInternal("Internal server error", err.Error())
+		httpErr = &internal
+	}
+	c.JSON(httpErr.Code,
+		types.EmptyResponse(
+			httpErr.Msg(),
+			httpErr.Details(),
+		),
+	)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/types"
+	"dainxor/atv/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type notificationType struct{}
+
+var Notification notificationType
+
+func (notificationType) GetPreference(c *gin.Context) {
+	kind, id := c.Param("kind"), c.Param("target_id")
+	logger.Debug("Getting notification preferences of", kind, id)
+
+	respondPreference(c, db.Notification.GetPreference(c.Request.Context(), kind, id))
+}
+
+func (notificationType) SetPreference(c *gin.Context) {
+	var body models.NotificationPreferenceCreate
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		expected := utils.StructToString(body)
+		logger.Error(err.Error())
+		logger.Error("Failed to set notification preferences: JSON request body is invalid")
+		logger.Error("Expected body: ", expected)
+
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid request body",
+				"Expected body: "+expected,
+			),
+		)
+		return
+	}
+
+	kind, id := c.Param("kind"), c.Param("target_id")
+	logger.Debug("Setting notification preferences of", kind, id)
+	respondPreference(c, db.Notification.SetPreference(c.Request.Context(), kind, id, body))
+}
+
+func (notificationType) GetAllBySessionID(c *gin.Context) {
+	id := c.Param("session_id")
+	logger.Debug("Getting notifications of session: ", id)
+
+	result := db.Notification.GetAllBySessionID(c.Request.Context(), id)
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	notifications := utils.Map(result.Value(), models.NotificationDBMongo.ToResponse)
+	if len(notifications) == 0 {
+		logger.Warning("No notifications found for session ID")
+		c.JSON(types.Http.C400().NotFound(),
+			types.EmptyResponse(
+				"No notifications found for session ID",
+			))
+		return
+	}
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			notifications,
+			"",
+		),
+	)
+}
+
+func respondPreference(c *gin.Context, result types.Result[models.NotificationPreferenceDBMongo]) {
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
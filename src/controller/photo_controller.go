@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/photo"
+	"dainxor/atv/storage"
+	"dainxor/atv/types"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PHOTO_FIELD  = "photo"  // Multipart field carrying the picture
+	PHOTO_PREFIX = "photos" // Blob store keys of the photos start with it
+)
+
+// uploadPhoto processes the picture of the request and stores every size of it
+// for the owner. On failure it answers the request and returns false.
+func uploadPhoto(c *gin.Context, owner string, id string) (models.PhotoDB, bool) {
+	ctx := c.Request.Context()
+
+	file, _, ok := formFile(c, PHOTO_FIELD)
+	if !ok {
+		return models.PhotoDB{}, false
+	}
+	defer file.Close()
+
+	processed, err := photo.Process(storage.Limited(file))
+	if err != nil {
+		logger.Error("Failed to process photo: ", err)
+		switch {
+		case errors.Is(err, storage.ErrTooLarge):
+			respondTooLarge(c)
+		case errors.Is(err, photo.ErrUnsupportedFormat), errors.Is(err, photo.ErrTooManyPixels):
+			c.JSON(types.Http.C400().UnprocessableEntity(),
+				types.EmptyResponse(
+					"Invalid photo",
+					err.Error(),
+					"Accepted formats: "+strings.Join(photo.Formats, ", "),
+				),
+			)
+		default:
+			c.JSON(types.Http.C500().InternalServerError(),
+				types.EmptyResponse(
+					"Failed to process photo",
+					err.Error(),
+				),
+			)
+		}
+		return models.PhotoDB{}, false
+	}
+
+	stored := models.PhotoDB{
+		Key:         storage.NewKey(path.Join(PHOTO_PREFIX, owner, id), ""),
+		Extension:   processed.Extension,
+		ContentType: processed.ContentType,
+		UpdatedAt:   models.Time.Now(),
+	}
+	store := storage.Current()
+	for _, image := range processed.Images {
+		if _, err := store.Put(ctx, stored.SizeKey(image.Size), bytes.NewReader(image.Content)); err != nil {
+			logger.Error("Failed to store photo: ", err)
+			removePhoto(ctx, stored)
+			c.JSON(types.Http.C500().InternalServerError(),
+				types.EmptyResponse(
+					"Failed to store file",
+					err.Error(),
+				),
+			)
+			return models.PhotoDB{}, false
+		}
+	}
+	return stored, true
+}
+
+// removePhoto deletes every size of the photo, failures are only logged
+func removePhoto(ctx context.Context, stored models.PhotoDB) {
+	if stored.IsZero() {
+		return
+	}
+	store := storage.Current()
+	for size := range photo.Sizes {
+		err := store.Delete(ctx, stored.SizeKey(size))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			logger.Warning("Failed to remove photo", stored.SizeKey(size), ":", err)
+		}
+	}
+}
+
+// servePhoto answers the size of the photo asked on the size query, medium by default
+func servePhoto(c *gin.Context, owner string, stored models.PhotoDB) {
+	size := cmp.Or(c.Query("size"), photo.SIZE_MEDIUM)
+	if _, exists := photo.Sizes[size]; !exists {
+		sizes := make([]string, 0, len(photo.Sizes))
+		for name := range photo.Sizes {
+			sizes = append(sizes, name)
+		}
+		slices.Sort(sizes)
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid size",
+				"Unknown photo size "+size,
+				"Expected one of: "+strings.Join(sizes, ", "),
+			),
+		)
+		return
+	}
+	if stored.IsZero() {
+		c.JSON(types.Http.C400().NotFound(),
+			types.EmptyResponse(
+				"Photo not found",
+				"The "+owner+" has no photo",
+			),
+		)
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	serveBlob(c, stored.SizeKey(size), stored.ContentType, owner+"-"+size+"."+stored.Extension)
+}
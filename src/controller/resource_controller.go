@@ -0,0 +1,293 @@
+package controller
+
+import (
+	"dainxor/atv/auth"
+	"dainxor/atv/configs"
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/storage"
+	"dainxor/atv/types"
+	"dainxor/atv/utils"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RESOURCE_FIELD  = "file"      // Multipart field carrying the upload
+	RESOURCE_PREFIX = "resources" // Blob store keys of the uploads start with it
+)
+
+type resourceType struct{}
+
+var Resource resourceType
+
+// Upload stores a file and its metadata. Content the caller already uploaded is not stored twice,
+// their existing resource is answered with 200 instead. Anonymous uploads are never deduplicated,
+// matching the uploads of someone else would reveal them.
+func (resourceType) Upload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	file, header, ok := formFile(c, RESOURCE_FIELD)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	store := storage.Current()
+	stored, ok := saveUpload(c, store, RESOURCE_PREFIX, header.Filename, file)
+	if !ok {
+		return
+	}
+
+	uploadedBy := auth.UserID(c)
+	existing := types.ResultErr[models.ResourceDBMongo](errors.New("anonymous uploads are not deduplicated"))
+	if uploadedBy != "" {
+		existing = db.Resource.GetByChecksum(ctx, stored.Checksum, uploadedBy)
+	}
+	if existing.IsOk() {
+		if err := store.Delete(ctx, stored.Key); err != nil {
+			logger.Warning("Failed to remove duplicate upload", stored.Key, ":", err)
+		}
+		logger.Debug("Upload deduplicated as resource", existing.Value().ID.Hex())
+		c.JSON(types.Http.C200().Ok(),
+			types.Response(
+				existing.Value().ToResponse(),
+				"Already uploaded",
+			),
+		)
+		return
+	}
+
+	result := db.Resource.Create(ctx, models.ResourceDBMongo{
+		Name:        stored.Name,
+		Key:         stored.Key,
+		Kind:        stored.Kind.Code(),
+		Extension:   stored.Kind.Extension(),
+		ContentType: stored.ContentType,
+		Size:        stored.Size,
+		Checksum:    stored.Checksum,
+		UploadedBy:  uploadedBy,
+	})
+	if result.IsErr() {
+		if err := store.Delete(ctx, stored.Key); err != nil {
+			logger.Warning("Failed to remove orphan upload", stored.Key, ":", err)
+		}
+	}
+	respondResource(c, result, types.Http.C200().Created())
+}
+
+func (resourceType) GetByID(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Getting resource by ID: ", id)
+
+	respondResource(c, db.Resource.GetByID(c.Request.Context(), id), types.Http.C200().Ok())
+}
+
+// SignedURL answers a download URL for the resource that works without a token until it expires
+func (resourceType) SignedURL(c *gin.Context) {
+	id := c.Param("id")
+
+	result := db.Resource.GetByID(c.Request.Context(), id)
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code, types.EmptyResponse(err.Msg(), err.Details()))
+		return
+	}
+
+	expires, signature := storage.Sign(id, time.Now())
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", signature)
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			models.SignedURLResponse{
+				URL:       "/api/v" + strconv.FormatUint(configs.App.RoutesVersion(), 10) + "/resource/" + id + "/download?" + query.Encode(),
+				ExpiresAt: time.Unix(expires, 0).UTC(),
+			},
+			"",
+		),
+	)
+}
+
+// Download streams the file of a resource, the URL must carry a valid signature
+func (resourceType) Download(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	if err := storage.Verify(id, c.Query("expires"), c.Query("signature"), time.Now()); err != nil {
+		logger.Warning("Rejected download of resource", id, ":", err)
+		c.JSON(types.Http.C400().Forbidden(),
+			types.EmptyResponse(
+				"Forbidden",
+				"The download URL is invalid or expired: "+err.Error(),
+			),
+		)
+		return
+	}
+
+	result := db.Resource.GetByID(ctx, id)
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code, types.EmptyResponse(err.Msg(), err.Details()))
+		return
+	}
+	resource := result.Value()
+	serveBlob(c, resource.Key, resource.ContentType, resource.Name)
+}
+
+func (resourceType) Link(c *gin.Context) {
+	var body models.ResourceLink
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		expected := utils.StructToString(body)
+		logger.Error(err.Error())
+		logger.Error("Failed to link resource: JSON request body is invalid")
+		logger.Error("Expected body: ", expected)
+
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid request body",
+				"Expected body: "+expected,
+			),
+		)
+		return
+	}
+
+	id := c.Param("id")
+	logger.Debug("Linking resource", id, "to", body.Kind, body.ID)
+	respondResource(c, db.Resource.Link(c.Request.Context(), id, body), types.Http.C200().Ok())
+}
+
+func (resourceType) GetAllLinkedTo(c *gin.Context) {
+	link := models.ResourceLink{Kind: c.Param("kind"), ID: c.Param("target_id")}
+
+	result := db.Resource.GetAllLinkedTo(c.Request.Context(), link)
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	resources := utils.Map(result.Value(), models.ResourceDBMongo.ToResponse)
+	if len(resources) == 0 {
+		c.JSON(types.Http.C400().NotFound(),
+			types.EmptyResponse(
+				"No resources found",
+				"Nothing is linked to "+link.Kind+" "+link.ID,
+			))
+		return
+	}
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			resources,
+			"",
+		),
+	)
+}
+
+func (resourceType) DeleteByID(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Deleting resource by ID: ", id)
+
+	respondResource(c, db.Resource.DeleteByID(c.Request.Context(), id), types.Http.C200().Ok())
+}
+
+func respondResource(c *gin.Context, result types.Result[models.ResourceDBMongo], status int) {
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(status,
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
+
+// formFile reads the file of a multipart upload on field, within storage.MaxSize.
+// On failure it answers the request and returns false.
+func formFile(c *gin.Context, field string) (multipart.File, *multipart.FileHeader, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, storage.MaxSize()+MULTIPART_OVERHEAD)
+	file, header, err := c.Request.FormFile(field)
+	if err != nil {
+		logger.Error("Failed to read upload: ", err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondTooLarge(c)
+			return nil, nil, false
+		}
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid request body",
+				"Expected a multipart form with the file on the \""+field+"\" field",
+			),
+		)
+		return nil, nil, false
+	}
+	if header.Size > storage.MaxSize() {
+		file.Close()
+		respondTooLarge(c)
+		return nil, nil, false
+	}
+	return file, header, true
+}
+
+// saveUpload stores the upload below prefix. On failure it answers the request and returns false.
+func saveUpload(c *gin.Context, store storage.Store, prefix string, name string, file multipart.File) (storage.Stored, bool) {
+	stored, err := storage.Save(c.Request.Context(), store, prefix, name, file)
+	if errors.Is(err, storage.ErrTooLarge) {
+		respondTooLarge(c)
+		return storage.Stored{}, false
+	}
+	if err != nil {
+		logger.Error("Failed to store upload: ", err)
+		c.JSON(types.Http.C500().InternalServerError(),
+			types.EmptyResponse(
+				"Failed to store file",
+				err.Error(),
+			),
+		)
+		return storage.Stored{}, false
+	}
+	return stored, true
+}
+
+// serveBlob streams a file of the blob store as a download named name
+func serveBlob(c *gin.Context, key string, contentType string, name string) {
+	content, blob, err := storage.Current().Get(c.Request.Context(), key)
+	if err != nil {
+		logger.Error("Failed to read file", key, ":", err)
+		code := types.Http.C500().InternalServerError()
+		if errors.Is(err, storage.ErrNotFound) {
+			code = types.Http.C400().NotFound()
+		}
+		c.JSON(code, types.EmptyResponse("Failed to read file", err.Error()))
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, blob.Size, contentType, content, map[string]string{
+		"Content-Disposition": "attachment; filename=" + strconv.Quote(name),
+	})
+}
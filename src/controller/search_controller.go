@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/search"
+	"dainxor/atv/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type searchType struct{}
+
+var Search searchType
+
+// searchSources read the records of each kind of result, when the cached ones are stale
+var searchSources = map[string]func(ctx context.Context, fresh *search.Index) error{
+	search.KIND_STUDENT: func(ctx context.Context, fresh *search.Index) error {
+		students := db.Student.GetAll(ctx)
+		if students.IsErr() {
+			return students.Error()
+		}
+		fresh.AddStudents(students.Value())
+		return nil
+	},
+	search.KIND_COMPANION: func(ctx context.Context, fresh *search.Index) error {
+		companions := db.Companion.GetAll(ctx)
+		if companions.IsErr() {
+			return companions.Error()
+		}
+		fresh.AddCompanions(companions.Value())
+		return nil
+	},
+	search.KIND_UNIVERSITY: func(ctx context.Context, fresh *search.Index) error {
+		universities := db.University.GetAll(ctx)
+		if universities.IsErr() {
+			return universities.Error()
+		}
+		fresh.AddUniversities(universities.Value())
+		return nil
+	},
+}
+
+// Find looks the q query parameter up across students, companions and universities, best matches first.
+// The kind query parameter keeps one kind of result and limit caps how many are answered.
+func (searchType) Find(c *gin.Context) {
+	ctx := c.Request.Context()
+	query, kind := c.Query("q"), c.Query("kind")
+	logger.Debug("Searching", kind, "for", query)
+
+	if len([]rune(search.Normalize(query))) < search.MIN_QUERY {
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Query too short",
+				"Use ?q=<name, document number or email> with at least "+strconv.Itoa(search.MIN_QUERY)+" characters",
+			),
+		)
+		return
+	}
+	if kind != "" && !slices.Contains(search.KINDS, kind) {
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid kind",
+				"Expected one of: "+strings.Join(search.KINDS, ", "),
+			),
+		)
+		return
+	}
+	limit := search.DEFAULT_LIMIT
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(types.Http.C400().BadRequest(),
+				types.EmptyResponse(
+					"Invalid limit",
+					"Expected a positive number, at most "+strconv.Itoa(search.MAX_LIMIT),
+				),
+			)
+			return
+		}
+		limit = min(parsed, search.MAX_LIMIT)
+	}
+
+	var index search.Index
+	for _, source := range search.KINDS {
+		if kind != "" && kind != source {
+			continue
+		}
+		if err := index.AddCached(ctx, source, searchSources[source]); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			index.Find(query, limit),
+			"",
+		),
+	)
+}
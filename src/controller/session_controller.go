@@ -1,12 +1,16 @@
 package controller
 
 import (
+	"context"
 	"dainxor/atv/db"
 	"dainxor/atv/logger"
 	"dainxor/atv/models"
+	"dainxor/atv/notify"
 	"dainxor/atv/types"
 	"dainxor/atv/utils"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -35,7 +39,7 @@ func (sessionType) Create(c *gin.Context) {
 
 	logger.Debug("Creating session in MongoDB: ", body)
 
-	result := db.Session.Create(body)
+	result := db.Session.Create(c.Request.Context(), body)
 
 	if result.IsErr() {
 		logger.Warning("Failed to create session in MongoDB: ", result.Error())
@@ -51,6 +55,23 @@ func (sessionType) Create(c *gin.Context) {
 	}
 
 	session := result.Value()
+	if err := notify.SessionCreated(c.Request.Context(), session); err != nil {
+		logger.Warning("Session", session.ID.Hex(), "created without queueing its confirmation:", err)
+	}
+
+	assigned := db.Assignment.AssignedCompanions(c.Request.Context(), session.IDStudent.Hex())
+	if len(assigned) > 0 && !slices.Contains(assigned, session.IDCompanion.Hex()) {
+		logger.Warning("Session booked with a companion not assigned to the student: ", session.ID.Hex())
+		c.JSON(types.Http.C200().Created(),
+			types.Response(
+				session.ToResponse(),
+				"Warning: the companion is not assigned to the student",
+				"Assigned companion IDs: "+strings.Join(assigned, ", "),
+			),
+		)
+		return
+	}
+
 	c.JSON(types.Http.C200().Created(),
 		types.Response(
 			session.ToResponse(),
@@ -63,7 +84,7 @@ func (sessionType) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Getting session by ID: ", id)
 
-	result := db.Session.GetByID(id)
+	result := db.Session.GetByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -89,7 +110,7 @@ func (sessionType) GetAllByStudentID(c *gin.Context) {
 	studentID := c.Param("student_id")
 	logger.Debug("Getting all sessions by student ID: ", studentID)
 
-	result := db.Session.GetAllByStudentID(studentID)
+	result := db.Session.GetAllByStudentID(c.Request.Context(), studentID)
 
 	if result.IsErr() {
 		err := result.Error().(*types.HttpError)
@@ -120,7 +141,7 @@ func (sessionType) GetAllByStudentID(c *gin.Context) {
 	)
 }
 func (sessionType) GetAll(c *gin.Context) {
-	result := db.Session.GetAll()
+	result := db.Session.GetAll(c.Request.Context())
 
 	if result.IsErr() {
 		err := result.Error().(*types.HttpError)
@@ -171,15 +192,23 @@ func (sessionType) UpdateByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Updating session by ID: ", id)
 
-	result := db.Session.UpdateByID(id, body)
+	before := db.Session.GetByID(c.Request.Context(), id)
+	result := db.Session.UpdateByID(c.Request.Context(), id, body)
 	if result.IsErr() {
 		err := result.Error()
 		cerror := err.(*types.HttpError)
-		c.JSON(cerror.Code, err)
+		c.JSON(cerror.Code,
+			types.EmptyResponse(
+				cerror.Msg(),
+				cerror.Details(),
+			),
+		)
 		return
 	}
 
 	session := result.Value()
+	notifySessionChange(c.Request.Context(), before, id)
+
 	c.JSON(http.StatusOK,
 		types.Response(
 			session.ToResponse(),
@@ -208,7 +237,8 @@ func (sessionType) PatchByID(c *gin.Context) {
 
 	id := c.Param("id")
 
-	result := db.Session.PatchByID(id, body)
+	before := db.Session.GetByID(c.Request.Context(), id)
+	result := db.Session.PatchByID(c.Request.Context(), id, body)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -223,6 +253,8 @@ func (sessionType) PatchByID(c *gin.Context) {
 	}
 
 	session := result.Value()
+	notifySessionChange(c.Request.Context(), before, id)
+
 	c.JSON(types.Http.C200().Ok(),
 		types.Response(
 			session.ToResponse(),
@@ -235,7 +267,7 @@ func (sessionType) DeleteByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Deleting session by ID: ", id)
 
-	result := db.Session.DeleteByID(id)
+	result := db.Session.DeleteByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -250,6 +282,10 @@ func (sessionType) DeleteByID(c *gin.Context) {
 	}
 
 	session := result.Value()
+	if err := notify.SessionDeleted(c.Request.Context(), session); err != nil {
+		logger.Warning("Session", session.ID.Hex(), "deleted without queueing its cancellation:", err)
+	}
+
 	c.JSON(types.Http.C200().Ok(),
 		types.Response(
 			session.ToResponse(),
@@ -257,3 +293,16 @@ func (sessionType) DeleteByID(c *gin.Context) {
 		),
 	)
 }
+
+// notifySessionChange tells the student and companion when an update cancelled or moved the session.
+// The session is read again since a patch only returns the changed fields.
+func notifySessionChange(ctx context.Context, before types.Result[models.SessionDBMongo], id string) {
+	if before.IsErr() {
+		return
+	}
+	if after := db.Session.GetByID(ctx, id); after.IsOk() {
+		if err := notify.SessionChanged(ctx, before.Value(), after.Value()); err != nil {
+			logger.Warning("Session", id, "updated without queueing its notice:", err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"dainxor/atv/auth"
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/storage"
+	"dainxor/atv/types"
+	"dainxor/atv/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	NOTE_ATTACHMENT_FIELD  = "file"          // Multipart field carrying the attachment
+	NOTE_ATTACHMENT_PREFIX = "session-notes" // Blob store keys of the attachments start with it
+	MULTIPART_OVERHEAD     = 1 << 20         // Room for the multipart headers on top of the file size limit
+)
+
+type sessionNoteType struct{}
+
+var SessionNote sessionNoteType
+
+func (sessionNoteType) GetLatest(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Getting notes of session: ", id)
+
+	respondSessionNote(c, db.SessionNote.GetLatest(c.Request.Context(), id), types.Http.C200().Ok())
+}
+
+func (sessionNoteType) GetHistory(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Getting notes history of session: ", id)
+
+	result := db.SessionNote.GetHistory(c.Request.Context(), id)
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	notes := utils.Map(result.Value(), models.SessionNoteDBMongo.ToResponse)
+	if len(notes) == 0 {
+		c.JSON(types.Http.C400().NotFound(),
+			types.EmptyResponse(
+				"Session notes not found",
+				"Session with ID "+id+" has no notes",
+			))
+		return
+	}
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			notes,
+			"",
+		),
+	)
+}
+
+func (sessionNoteType) Append(c *gin.Context) {
+	var body models.SessionNoteCreate
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		expected := utils.StructToString(body)
+		logger.Error(err.Error())
+		logger.Error("Failed to write session notes: JSON request body is invalid")
+		logger.Error("Expected body: ", expected)
+
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid request body",
+				"Expected body: "+expected,
+			),
+		)
+		return
+	}
+
+	id := c.Param("id")
+	logger.Debug("Writing notes of session: ", id)
+	respondSessionNote(c, db.SessionNote.Append(c.Request.Context(), id, body, auth.UserID(c)), types.Http.C200().Created())
+}
+
+// Attach stores the uploaded file on the blob store and adds it to a new version of the notes
+func (sessionNoteType) Attach(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	// Checked first so no file is stored for a missing session
+	if session := db.Session.GetByID(ctx, id); session.IsErr() {
+		err := session.Error().(*types.HttpError)
+		c.JSON(err.Code, types.EmptyResponse(err.Msg(), err.Details()))
+		return
+	}
+
+	file, header, ok := formFile(c, NOTE_ATTACHMENT_FIELD)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	store := storage.Current()
+	stored, ok := saveUpload(c, store, NOTE_ATTACHMENT_PREFIX+"/"+id, header.Filename, file)
+	if !ok {
+		return
+	}
+
+	attachment := models.NoteAttachment{
+		Name:        stored.Name,
+		Key:         stored.Key,
+		Kind:        stored.Kind.Code(),
+		Extension:   stored.Kind.Extension(),
+		ContentType: stored.ContentType,
+		Size:        stored.Size,
+		AddedAt:     models.Time.Now(),
+	}
+	result := db.SessionNote.Attach(ctx, id, attachment, auth.UserID(c))
+	if result.IsErr() {
+		if err := store.Delete(ctx, stored.Key); err != nil {
+			logger.Warning("Failed to remove orphan attachment", stored.Key, ":", err)
+		}
+	}
+	respondSessionNote(c, result, types.Http.C200().Created())
+}
+
+// Download streams an attachment of the current version of the notes
+func (sessionNoteType) Download(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	file := c.Param("file")
+
+	result := db.SessionNote.GetLatest(ctx, id)
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code, types.EmptyResponse(err.Msg(), err.Details()))
+		return
+	}
+
+	attachment, exist := result.Value().Attachment(file)
+	if !exist {
+		c.JSON(types.Http.C400().NotFound(),
+			types.EmptyResponse(
+				"Attachment not found",
+				"Session with ID "+id+" has no attachment "+file,
+			))
+		return
+	}
+
+	serveBlob(c, attachment.Key, attachment.ContentType, attachment.Name)
+}
+
+func respondSessionNote(c *gin.Context, result types.Result[models.SessionNoteDBMongo], status int) {
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(status,
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
+
+func respondTooLarge(c *gin.Context) {
+	c.JSON(types.Http.C400().ContentTooLarge(),
+		types.EmptyResponse(
+			"File too large",
+			"The largest file accepted is "+strconv.FormatInt(storage.MaxSize(), 10)+" bytes",
+		),
+	)
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/openapi"
+	"dainxor/atv/stream"
+	"dainxor/atv/tenant"
+	"dainxor/atv/types"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stream sends the session changes of the tenant as server-sent events until the client leaves, only those of
+// the id_student and id_companion query parameters when given. Each event is named after its type,
+// like session.created, and carries the session before and after the change.
+func (sessionType) Stream(c *gin.Context) {
+	var filter stream.Filter
+	for name, id := range map[string]*models.DBID{"id_student": &filter.IDStudent, "id_companion": &filter.IDCompanion} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		oid, err := models.ID.ToDB(value)
+		if err != nil {
+			c.JSON(types.Http.C400().UnprocessableEntity(),
+				types.EmptyResponse(
+					"Invalid value",
+					"Invalid ID format on "+name+": "+err.Error(),
+				),
+			)
+			return
+		}
+		*id = oid
+	}
+
+	filter.Tenant, _ = tenant.From(c.Request.Context())
+
+	subscription := stream.Subscribe(filter)
+	defer subscription.Close()
+	logger.Debug("Streaming session changes, student", c.Query("id_student"), "companion", c.Query("id_companion"))
+
+	c.Header("Content-Type", openapi.EVENT_STREAM_CONTENT_TYPE)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // Proxies like nginx would hold the events back
+	c.Status(types.Http.C200().Ok())
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(settings.Get().Stream.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, open := <-subscription.Events:
+			if !open {
+				return // Fell behind, the client reconnects and reads the sessions again
+			}
+			c.SSEvent(event.Type, event.ToResponse())
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n") // A comment, ignored by EventSource
+		}
+		c.Writer.Flush()
+	}
+}
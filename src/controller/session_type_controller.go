@@ -33,7 +33,7 @@ func (sessionTypeType) Create(c *gin.Context) {
 	}
 
 	logger.Debug("Creating session type in MongoDB: ", body)
-	existent := db.SessionType.GetAll()
+	existent := db.SessionType.GetAll(c.Request.Context())
 	if existent.IsOk() && len(existent.Value()) > 0 {
 		match := utils.Filter(existent.Value(), func(st models.SessionTypeDBMongo) bool {
 			return st.Name == body.Name
@@ -51,7 +51,7 @@ func (sessionTypeType) Create(c *gin.Context) {
 		}
 	}
 
-	result := db.SessionType.Create(body)
+	result := db.SessionType.Create(c.Request.Context(), body)
 
 	if result.IsErr() {
 		logger.Error("Failed to create session type in MongoDB: ", result.Error())
@@ -79,7 +79,7 @@ func (sessionTypeType) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Getting session type by ID: ", id)
 
-	result := db.SessionType.GetByID(id)
+	result := db.SessionType.GetByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -102,7 +102,7 @@ func (sessionTypeType) GetByID(c *gin.Context) {
 	)
 }
 func (sessionTypeType) GetAll(c *gin.Context) {
-	result := db.SessionType.GetAll()
+	result := db.SessionType.GetAll(c.Request.Context())
 
 	if result.IsErr() {
 		err := result.Error().(*types.HttpError)
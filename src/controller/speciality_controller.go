@@ -34,7 +34,7 @@ func (specialityType) Create(c *gin.Context) {
 
 	logger.Debug("Creating speciality in MongoDB: ", body)
 
-	result := db.Speciality.Create(body)
+	result := db.Speciality.Create(c.Request.Context(), body)
 
 	if result.IsErr() {
 		logger.Error("Failed to create speciality in MongoDB: ", result.Error())
@@ -62,7 +62,7 @@ func (specialityType) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Getting speciality by ID: ", id)
 
-	result := db.Speciality.GetByID(id)
+	result := db.Speciality.GetByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -85,7 +85,7 @@ func (specialityType) GetByID(c *gin.Context) {
 	)
 }
 func (specialityType) GetAll(c *gin.Context) {
-	result := db.Speciality.GetAll()
+	result := db.Speciality.GetAll(c.Request.Context())
 
 	if result.IsErr() {
 		err := result.Error().(*types.HttpError)
@@ -19,7 +19,7 @@ func (studentType) GetByIDMongo(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Getting student by ID: ", id)
 
-	result := db.Student.GetByID(id)
+	result := db.Student.GetByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -41,8 +41,60 @@ func (studentType) GetByIDMongo(c *gin.Context) {
 		),
 	)
 }
+
+// GetByNumberID returns the student with the document number, deleted ones are not found
+func (studentType) GetByNumberID(c *gin.Context) {
+	number := c.Param("number_id")
+	logger.Debug("Getting student by document number: ", number)
+
+	result := db.Student.GetByNumberID(c.Request.Context(), number)
+
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
+
+// GetByEmail returns the student with the email, deleted ones are not found
+func (studentType) GetByEmail(c *gin.Context) {
+	email := c.Param("email")
+	logger.Debug("Getting student by email: ", email)
+
+	result := db.Student.GetByEmail(c.Request.Context(), email)
+
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
 func (studentType) GetAllMongo(c *gin.Context) {
-	result := db.Student.GetAll()
+	result := db.Student.GetAll(c.Request.Context())
 
 	if result.IsErr() {
 		err := result.Error().(*types.HttpError)
@@ -92,7 +144,7 @@ func (studentType) CreateMongo(c *gin.Context) {
 
 	logger.Debug("Creating student in MongoDB: ", body)
 
-	result := db.Student.Create(body)
+	result := db.Student.Create(c.Request.Context(), body)
 
 	if result.IsErr() {
 		logger.Error("Failed to create student in MongoDB: ", result.Error())
@@ -137,11 +189,16 @@ func (studentType) UpdateMongo(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Updating student by ID: ", id)
 
-	result := db.Student.UpdateByID(id, body)
+	result := db.Student.UpdateByID(c.Request.Context(), id, body)
 	if result.IsErr() {
 		err := result.Error()
 		cerror := err.(*types.HttpError)
-		c.JSON(cerror.Code, err)
+		c.JSON(cerror.Code,
+			types.EmptyResponse(
+				cerror.Msg(),
+				cerror.Details(),
+			),
+		)
 		return
 	}
 
@@ -174,7 +231,7 @@ func (studentType) PatchMongo(c *gin.Context) {
 
 	id := c.Param("id")
 
-	result := db.Student.PatchByID(id, body)
+	result := db.Student.PatchByID(c.Request.Context(), id, body)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -197,12 +254,74 @@ func (studentType) PatchMongo(c *gin.Context) {
 	)
 }
 
+// PutPhoto replaces the profile photo of the student, the previous one is removed once replaced
+func (studentType) PutPhoto(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+
+	current := db.Student.GetByID(ctx, id)
+	if current.IsErr() {
+		err := current.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+
+	stored, ok := uploadPhoto(c, "student", id)
+	if !ok {
+		return
+	}
+
+	result := db.Student.SetPhoto(ctx, id, stored)
+	if result.IsErr() {
+		removePhoto(ctx, stored)
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+	removePhoto(ctx, current.Value().Photo)
+
+	c.JSON(http.StatusOK,
+		types.Response(
+			result.Value().ToResponse(),
+			"Photo updated",
+		),
+	)
+}
+
+// GetPhoto serves the profile photo of the student
+func (studentType) GetPhoto(c *gin.Context) {
+	id := c.Param("id")
+
+	result := db.Student.GetByID(c.Request.Context(), id)
+	if result.IsErr() {
+		err := result.Error().(*types.HttpError)
+		c.JSON(err.Code,
+			types.EmptyResponse(
+				err.Msg(),
+				err.Details(),
+			),
+		)
+		return
+	}
+	servePhoto(c, "student", result.Value().Photo)
+}
+
 // DeleteByID deletes a student by ID
 func (studentType) DeleteByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Deleting student by ID: ", id)
 
-	result := db.Student.DeleteByID(id)
+	result := db.Student.DeleteByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -240,7 +359,7 @@ func (studentType) ForceDeleteByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Info("Force deleting student by ID: ", id)
 
-	result := db.Student.DeletePermanentByID(id)
+	result := db.Student.DeletePermanentByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -34,7 +34,7 @@ func (universityType) Create(c *gin.Context) {
 
 	logger.Debug("Creating university in MongoDB: ", body)
 
-	result := db.University.Create(body)
+	result := db.University.Create(c.Request.Context(), body)
 
 	if result.IsErr() {
 		logger.Error("Failed to create university in MongoDB: ", result.Error())
@@ -62,7 +62,7 @@ func (universityType) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	logger.Debug("Getting university by ID: ", id)
 
-	result := db.University.GetByID(id)
+	result := db.University.GetByID(c.Request.Context(), id)
 
 	if result.IsErr() {
 		err := result.Error()
@@ -85,7 +85,7 @@ func (universityType) GetByID(c *gin.Context) {
 	)
 }
 func (universityType) GetAll(c *gin.Context) {
-	result := db.University.GetAll()
+	result := db.University.GetAll(c.Request.Context())
 
 	if result.IsErr() {
 		err := result.Error().(*types.HttpError)
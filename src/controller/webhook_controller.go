@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/types"
+	"dainxor/atv/utils"
+	"dainxor/atv/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+type webhookType struct{}
+
+var Webhook webhookType
+
+// Create subscribes a URL to session events, the secret is only answered here
+func (webhookType) Create(c *gin.Context) {
+	var body models.WebhookCreate
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		expected := utils.StructToString(body)
+		logger.Error(err.Error())
+		logger.Error("Failed to create webhook: JSON request body is invalid")
+		logger.Error("Expected body: ", expected)
+
+		c.JSON(types.Http.C400().BadRequest(),
+			types.EmptyResponse(
+				"Invalid request body",
+				"Expected body: "+expected,
+			),
+		)
+		return
+	}
+
+	if err := webhooks.CheckDestination(c.Request.Context(), body.URL); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	secret := body.Secret
+	if secret == "" {
+		secret = webhooks.NewSecret()
+	}
+	result := db.Webhook.Create(c.Request.Context(), body, secret)
+	if result.IsErr() {
+		respondError(c, result.Error())
+		return
+	}
+
+	response := result.Value().ToResponse()
+	response.Secret = secret
+	c.JSON(types.Http.C200().Created(),
+		types.Response(
+			response,
+			"Webhook created, keep the secret to check the signatures",
+		),
+	)
+}
+
+func (webhookType) GetAll(c *gin.Context) {
+	logger.Debug("Getting all webhooks")
+
+	result := db.Webhook.GetAll(c.Request.Context())
+	if result.IsErr() {
+		respondError(c, result.Error())
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			utils.Map(result.Value(), models.WebhookDBMongo.ToResponse),
+			"",
+		),
+	)
+}
+
+func (webhookType) GetByID(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Getting webhook by ID: ", id)
+
+	result := db.Webhook.GetByID(c.Request.Context(), id)
+	if result.IsErr() {
+		respondError(c, result.Error())
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			result.Value().ToResponse(),
+			"",
+		),
+	)
+}
+
+func (webhookType) DeleteByID(c *gin.Context) {
+	id := c.Param("id")
+	logger.Info("Deleting webhook: ", id)
+
+	result := db.Webhook.DeleteByID(c.Request.Context(), id)
+	if result.IsErr() {
+		respondError(c, result.Error())
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			result.Value().ToResponse(),
+			"Webhook deleted",
+		),
+	)
+}
+
+// GetDeliveries lists the delivery log of the webhook, newest first
+func (webhookType) GetDeliveries(c *gin.Context) {
+	id := c.Param("id")
+	logger.Debug("Getting deliveries of webhook: ", id)
+
+	result := db.Webhook.GetDeliveriesByWebhookID(c.Request.Context(), id)
+	if result.IsErr() {
+		respondError(c, result.Error())
+		return
+	}
+
+	c.JSON(types.Http.C200().Ok(),
+		types.Response(
+			utils.Map(result.Value(), models.WebhookDeliveryDBMongo.ToResponse),
+			"",
+		),
+	)
+}
+
+// Replay sends a delivery again as a new one
+func (webhookType) Replay(c *gin.Context) {
+	id := c.Param("id")
+	logger.Info("Replaying webhook delivery: ", id)
+
+	result := webhooks.Replay(c.Request.Context(), id)
+	if result.IsErr() {
+		respondError(c, result.Error())
+		return
+	}
+
+	c.JSON(types.Http.C200().Created(),
+		types.Response(
+			result.Value().ToResponse(),
+			"Delivery queued again",
+		),
+	)
+}
@@ -0,0 +1,320 @@
+package db
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"errors"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type assignmentType struct{}
+
+var Assignment assignmentType
+
+// Create assigns the companion to the student. A student has at most one active
+// assignment per speciality, a change of companion must go through Reassign.
+func (assignmentType) Create(ctx context.Context, assignment models.AssignmentCreate) types.Result[models.AssignmentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Assignment.Create")
+	defer span.End()
+
+	return Assignment.create(ctx, assignment, models.DBID{})
+}
+
+func (assignmentType) create(ctx context.Context, assignment models.AssignmentCreate, previous models.DBID) types.Result[models.AssignmentDBMongo] {
+	studentResult := Student.GetByID(ctx, assignment.IDStudent)
+	if studentResult.IsErr() {
+		return types.ResultErr[models.AssignmentDBMongo](studentResult.Error())
+	}
+	companionResult := Assignment.companionOn(ctx, assignment.IDCompanion, assignment.IDSpeciality)
+	if companionResult.IsErr() {
+		return types.ResultErr[models.AssignmentDBMongo](companionResult.Error())
+	}
+	speciality := companionResult.Value().IDSpeciality
+
+	assignmentDB, ok := assignment.ToInsert(speciality)
+	if !ok {
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid assignment data",
+			"Expected 24 hex characters IDs and a start date like 2006-01-02",
+		)
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+	assignmentDB.IDPrevious = previous
+
+	active := Assignment.GetActiveByStudentID(ctx, assignment.IDStudent)
+	if active.IsErr() {
+		return types.ResultErr[models.AssignmentDBMongo](active.Error())
+	}
+	if slices.ContainsFunc(active.Value(), func(a models.AssignmentDBMongo) bool { return a.IDSpeciality == speciality }) {
+		httpErr := types.Error(
+			types.Http.C400().Conflict(),
+			"Student already assigned",
+			"The student has an active assignment on this speciality, reassign it instead",
+			"Student ID: "+assignment.IDStudent,
+		)
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+
+	result, err := configs.DB.InsertOne(ctx, assignmentDB)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to create assignment in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create assignment", err.Error())
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+
+	assignmentDB.ID, err = models.ID.ToDB(result.InsertedID)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert inserted ID to ObjectID: ", err)
+		httpErr := types.ErrorInternal(
+			"Failed to create assignment",
+			"Failed to convert inserted ID to ObjectID",
+			"Error: "+err.Error(),
+		)
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+
+	return types.ResultOk(assignmentDB)
+}
+
+// companionOn returns the companion, checking they work on the speciality when one is given
+func (assignmentType) companionOn(ctx context.Context, companionID string, specialityID string) types.Result[models.CompanionDBMongo] {
+	result := Companion.GetByID(ctx, companionID)
+	if result.IsErr() {
+		return result
+	}
+
+	speciality := result.Value().IDSpeciality
+	if specialityID != "" && specialityID != speciality.Hex() {
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"The companion does not work on speciality "+specialityID,
+			"Companion speciality: "+speciality.Hex(),
+		)
+		return types.ResultErr[models.CompanionDBMongo](&httpErr)
+	}
+	return result
+}
+
+func (assignmentType) GetByID(ctx context.Context, id string) types.Result[models.AssignmentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Assignment.GetByID")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Assignment ID: "+id,
+		)
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+
+	filter := bson.D{models.Filter.ID(oid), models.Filter.NotDeleted()}
+	var assignment models.AssignmentDBMongo
+
+	err = configs.DB.FindOne(ctx, filter, &assignment)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to get assignment by ID: ", err)
+		httpErr := types.ErrorNotFound(
+			"Assignment not found",
+			"Assignment with ID "+id+" not found",
+		)
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			httpErr = errorFrom(ctx, err, "Failed to retrieve assignment", err.Error())
+		}
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+
+	return types.ResultOk(assignment)
+}
+
+func (assignmentType) GetAll(ctx context.Context) types.Result[[]models.AssignmentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Assignment.GetAll")
+	defer span.End()
+
+	return Assignment.find(ctx, bson.D{models.Filter.NotDeleted()}, "Failed to retrieve assignments")
+}
+
+// GetAllByStudentID returns every assignment of the student, the ended ones included
+func (assignmentType) GetAllByStudentID(ctx context.Context, id string) types.Result[[]models.AssignmentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Assignment.GetAllByStudentID")
+	defer span.End()
+
+	return Assignment.byStudent(ctx, id, false)
+}
+
+// GetActiveByStudentID returns the active assignments of the student, at most one per speciality
+func (assignmentType) GetActiveByStudentID(ctx context.Context, id string) types.Result[[]models.AssignmentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Assignment.GetActiveByStudentID")
+	defer span.End()
+
+	return Assignment.byStudent(ctx, id, true)
+}
+
+func (assignmentType) byStudent(ctx context.Context, id string, activeOnly bool) types.Result[[]models.AssignmentDBMongo] {
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Student ID: "+id,
+		)
+		return types.ResultErr[[]models.AssignmentDBMongo](&httpErr)
+	}
+
+	filter := bson.D{models.Filter.IDOf("student", oid), models.Filter.NotDeleted()}
+	if activeOnly {
+		filter = append(filter, bson.E{Key: "status", Value: models.ASSIGNMENT_ACTIVE})
+	}
+	return Assignment.find(ctx, filter, "Failed to retrieve assignments by student ID")
+}
+
+func (assignmentType) find(ctx context.Context, filter bson.D, message string) types.Result[[]models.AssignmentDBMongo] {
+	assignments := []models.AssignmentDBMongo{}
+
+	err := configs.DB.FindAll(ctx, filter, &assignments)
+	if err != nil {
+		logger.WithContext(ctx).Error(message+":", err)
+		httpErr := errorFrom(ctx, err, message, err.Error())
+		return types.ResultErr[[]models.AssignmentDBMongo](&httpErr)
+	}
+
+	logger.WithContext(ctx).Debug("Retrieved", len(assignments), "assignments from MongoDB database")
+	return types.ResultOk(assignments)
+}
+
+// Reassign ends an active assignment and assigns the student to another companion of the same speciality
+func (assignmentType) Reassign(ctx context.Context, id string, change models.AssignmentChange) types.Result[models.AssignmentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Assignment.Reassign")
+	defer span.End()
+
+	current := Assignment.GetByID(ctx, id)
+	if current.IsErr() {
+		return current
+	}
+	assignment := current.Value()
+
+	if change.IDCompanion == "" || change.IDCompanion == assignment.IDCompanion.Hex() {
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"A different companion is required to reassign",
+			"Current companion ID: "+assignment.IDCompanion.Hex(),
+		)
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+
+	// Checked before ending the current one, so a bad companion leaves the student assigned
+	if companion := Assignment.companionOn(ctx, change.IDCompanion, assignment.IDSpeciality.Hex()); companion.IsErr() {
+		return types.ResultErr[models.AssignmentDBMongo](companion.Error())
+	}
+
+	ended := Assignment.end(ctx, assignment, assignment.Ended(models.ASSIGNMENT_REASSIGNED, change.Reason, models.Time.Now()))
+	if ended.IsErr() {
+		return ended
+	}
+
+	created := Assignment.create(ctx, models.AssignmentCreate{
+		IDStudent:    assignment.IDStudent.Hex(),
+		IDCompanion:  change.IDCompanion,
+		IDSpeciality: assignment.IDSpeciality.Hex(),
+		Reason:       change.Reason,
+	}, assignment.ID)
+	if created.IsErr() {
+		Assignment.restore(ctx, assignment)
+	}
+	return created
+}
+
+// restore makes an assignment ended by a failed reassign active again
+func (assignmentType) restore(ctx context.Context, assignment models.AssignmentDBMongo) {
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: assignment.Status},
+		{Key: "end_date", Value: assignment.EndDate},
+		{Key: "end_reason", Value: assignment.EndReason},
+		{Key: "updated_at", Value: assignment.UpdatedAt},
+	}}}
+
+	var restored models.AssignmentDBMongo
+	if result := configs.DB.UpdateOne(ctx, bson.D{models.Filter.ID(assignment.ID)}, update, &restored); result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to restore assignment", assignment.ID.Hex(), "after a failed reassign:", result.Error())
+	}
+}
+
+// End closes an active assignment, the student is left without a companion on that speciality
+func (assignmentType) End(ctx context.Context, id string, change models.AssignmentChange) types.Result[models.AssignmentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Assignment.End")
+	defer span.End()
+
+	current := Assignment.GetByID(ctx, id)
+	if current.IsErr() {
+		return current
+	}
+	assignment := current.Value()
+	return Assignment.end(ctx, assignment, assignment.Ended(models.ASSIGNMENT_ENDED, change.Reason, models.Time.Now()))
+}
+
+// end stores the ended version of an active assignment
+func (assignmentType) end(ctx context.Context, assignment models.AssignmentDBMongo, ended models.AssignmentDBMongo) types.Result[models.AssignmentDBMongo] {
+	if !assignment.IsActive() {
+		httpErr := types.Error(
+			types.Http.C400().Conflict(),
+			"Assignment already ended",
+			"Only active assignments can be ended or reassigned",
+			"Assignment ID: "+assignment.ID.Hex(),
+		)
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: ended.Status},
+		{Key: "end_date", Value: ended.EndDate},
+		{Key: "end_reason", Value: ended.EndReason},
+		{Key: "updated_at", Value: ended.UpdatedAt},
+	}}}
+
+	var updated models.AssignmentDBMongo
+	result := configs.DB.UpdateOne(ctx, bson.D{models.Filter.ID(assignment.ID)}, update, &updated)
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to end assignment in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to end assignment", result.Error().Error())
+		return types.ResultErr[models.AssignmentDBMongo](&httpErr)
+	}
+	return types.ResultOk(updated)
+}
+
+// CurrentCompanion returns the companion of the latest active assignment of the student,
+// empty when the student has none or they cannot be read
+func (assignmentType) CurrentCompanion(ctx context.Context, studentID string) string {
+	active := Assignment.GetActiveByStudentID(ctx, studentID).ValueOr(nil)
+	if len(active) == 0 {
+		return ""
+	}
+	latest := slices.MaxFunc(active, func(a models.AssignmentDBMongo, b models.AssignmentDBMongo) int {
+		return a.StartDate.Compare(b.StartDate)
+	})
+	return latest.IDCompanion.Hex()
+}
+
+// AssignedCompanions returns the companions of the active assignments of the student
+func (assignmentType) AssignedCompanions(ctx context.Context, studentID string) []string {
+	companions := []string{}
+	for _, assignment := range Assignment.GetActiveByStudentID(ctx, studentID).ValueOr(nil) {
+		companions = append(companions, assignment.IDCompanion.Hex())
+	}
+	return companions
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"dainxor/atv/configs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type calendarType struct{}
+
+var Calendar calendarType
+
+// ownerID validates the owner kind and checks the student or companion exists
+func (calendarType) ownerID(ctx context.Context, owner string, id string) (models.DBID, error) {
+	switch owner {
+	case models.CALENDAR_STUDENT:
+		student := Student.GetByID(ctx, id)
+		return student.Value().ID, student.ErrorOr(nil)
+	case models.CALENDAR_COMPANION:
+		companion := Companion.GetByID(ctx, id)
+		return companion.Value().ID, companion.ErrorOr(nil)
+	}
+	httpErr := types.Error(
+		types.Http.C400().UnprocessableEntity(),
+		"Invalid value",
+		"Unknown calendar owner "+owner,
+		"Expected one of: "+strings.Join(models.CALENDAR_OWNERS, ", "),
+	)
+	return models.DBID{}, &httpErr
+}
+
+func (calendarType) find(ctx context.Context, filter bson.D) types.Result[models.CalendarTokenDBMongo] {
+	var token models.CalendarTokenDBMongo
+	err := configs.DB.FindOne(ctx, filter, &token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		httpErr := types.ErrorNotFound("Calendar feed not found", "The feed was never opened or its token was revoked")
+		return types.ResultErr[models.CalendarTokenDBMongo](&httpErr)
+	}
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to get calendar token: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve calendar feed", err.Error())
+		return types.ResultErr[models.CalendarTokenDBMongo](&httpErr)
+	}
+	return types.ResultOk(token)
+}
+
+// GetToken returns the feed token of the student or companion, creating it the first time
+func (calendarType) GetToken(ctx context.Context, owner string, id string) types.Result[models.CalendarTokenDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Calendar.GetToken")
+	defer span.End()
+
+	oid, err := Calendar.ownerID(ctx, owner, id)
+	if err != nil {
+		return types.ResultErr[models.CalendarTokenDBMongo](err)
+	}
+
+	current := Calendar.find(ctx, bson.D{{Key: "owner", Value: owner}, models.Filter.IDOf("owner", oid)})
+	if current.IsOk() || current.Error().(*types.HttpError).Code != types.Http.C400().NotFound() {
+		return current
+	}
+
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	token := models.CalendarTokenDBMongo{
+		Owner:     owner,
+		IDOwner:   oid,
+		Token:     hex.EncodeToString(secret),
+		CreatedAt: models.Time.Now(),
+	}
+	result, err := configs.DB.InsertOne(ctx, token)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to create calendar token in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create calendar feed", err.Error())
+		return types.ResultErr[models.CalendarTokenDBMongo](&httpErr)
+	}
+	token.ID, _ = models.ID.ToDB(result.InsertedID)
+	return types.ResultOk(token)
+}
+
+// RevokeToken deletes the feed token, the old URL stops working and the next GetToken makes another
+func (calendarType) RevokeToken(ctx context.Context, owner string, id string) types.Result[models.CalendarTokenDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Calendar.RevokeToken")
+	defer span.End()
+
+	oid, err := Calendar.ownerID(ctx, owner, id)
+	if err != nil {
+		return types.ResultErr[models.CalendarTokenDBMongo](err)
+	}
+
+	current := Calendar.find(ctx, bson.D{{Key: "owner", Value: owner}, models.Filter.IDOf("owner", oid)})
+	if current.IsErr() {
+		return current
+	}
+	if _, err := configs.DB.DeleteOne(ctx, bson.D{models.Filter.ID(current.Value().ID)}, models.CalendarTokenDBMongo{}); err != nil {
+		logger.WithContext(ctx).Error("Failed to delete calendar token in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to revoke calendar feed", err.Error())
+		return types.ResultErr[models.CalendarTokenDBMongo](&httpErr)
+	}
+	return current
+}
+
+// CheckToken returns the ID of the student or companion when token opens their feed, 401 otherwise
+func (calendarType) CheckToken(ctx context.Context, owner string, id string, token string) types.Result[models.DBID] {
+	ctx, span := tracing.Start(ctx, "db.Calendar.CheckToken")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil || token == "" {
+		httpErr := types.Error(types.Http.C400().Unauthorized(), "Invalid calendar token", "Subscribe to the URL of the calendar feed")
+		return types.ResultErr[models.DBID](&httpErr)
+	}
+
+	found := Calendar.find(ctx, bson.D{{Key: "owner", Value: owner}, models.Filter.IDOf("owner", oid), {Key: "token", Value: token}})
+	if found.IsErr() {
+		if found.Error().(*types.HttpError).Code != types.Http.C400().NotFound() {
+			return types.ResultErr[models.DBID](found.Error())
+		}
+		httpErr := types.Error(types.Http.C400().Unauthorized(), "Invalid calendar token", "Subscribe to the URL of the calendar feed")
+		return types.ResultErr[models.DBID](&httpErr)
+	}
+
+	// The owner may have been deleted since
+	if _, err := Calendar.ownerID(ctx, owner, id); err != nil {
+		return types.ResultErr[models.DBID](err)
+	}
+	return types.ResultOk(oid)
+}
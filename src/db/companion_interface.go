@@ -1,10 +1,13 @@
 package db
 
 import (
+	"context"
 	"dainxor/atv/configs"
 	"dainxor/atv/logger"
 	"dainxor/atv/models"
+	"dainxor/atv/tracing"
 	"dainxor/atv/types"
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -15,19 +18,23 @@ type companionType struct{}
 
 var Companion companionType
 
-func (companionType) Create(companion models.CompanionCreate) types.Result[models.CompanionDBMongo] {
+func (companionType) Create(ctx context.Context, companion models.CompanionCreate) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.Create")
+	defer span.End()
+
 	companionDB := companion.ToInsert()
-	result, err := configs.DB.InsertOne(companionDB)
+	result, err := configs.DB.InsertOne(ctx, companionDB)
 
 	if err != nil {
-		logger.Error("Failed to create companion in MongoDB: ", err)
-		return types.ResultErr[models.CompanionDBMongo](err)
+		logger.WithContext(ctx).Error("Failed to create companion in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create companion", err.Error())
+		return types.ResultErr[models.CompanionDBMongo](&httpErr)
 	}
 
 	companionDB.ID, err = models.DBIDFrom(result.InsertedID)
 
 	if err != nil {
-		logger.Error("Failed to convert inserted ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert inserted ID to ObjectID: ", err)
 		httpErr := types.ErrorInternal(
 			"Failed to create companion",
 			"Failed to convert inserted ID to ObjectID",
@@ -40,11 +47,14 @@ func (companionType) Create(companion models.CompanionCreate) types.Result[model
 	return types.ResultOk(companionDB)
 }
 
-func (companionType) GetByID(id string) types.Result[models.CompanionDBMongo] {
+func (companionType) GetByID(ctx context.Context, id string) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.GetByID")
+	defer span.End()
+
 	oid, err := bson.ObjectIDFromHex(id)
 
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -57,19 +67,19 @@ func (companionType) GetByID(id string) types.Result[models.CompanionDBMongo] {
 	filter := bson.D{{Key: "_id", Value: oid}}
 	var companion models.CompanionDBMongo
 
-	err = configs.DB.FindOne(filter, &companion)
+	err = configs.DB.FindOne(ctx, filter, &companion)
 	if err != nil {
 		var httpErr types.HttpError
 
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get companion by ID: ", err)
+			logger.WithContext(ctx).Error("Failed to get companion by ID: ", err)
 			httpErr = types.ErrorNotFound(
 				"Companion not found",
 				"Companion with ID "+id+" not found",
 			)
 		} else {
-			logger.Error("Failed to get companion by ID: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get companion by ID: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve companion",
 				"Decoding error",
 				err.Error(),
@@ -82,22 +92,25 @@ func (companionType) GetByID(id string) types.Result[models.CompanionDBMongo] {
 
 	return types.ResultOk(companion)
 }
-func (companionType) GetByNumberID(idNumber string) types.Result[models.CompanionDBMongo] {
-	filter := bson.D{{Key: "id_number", Value: idNumber}}
+func (companionType) GetByNumberID(ctx context.Context, idNumber string) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.GetByNumberID")
+	defer span.End()
+
+	filter := bson.D{{Key: "number_id", Value: idNumber}, models.Filter.NotDeleted()}
 	var companion models.CompanionDBMongo
 
-	err := configs.DB.FindOne(filter, &companion)
+	err := configs.DB.FindOne(ctx, filter, &companion)
 	if err != nil {
 		var httpErr types.HttpError
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get companion by ID number: ", err)
+			logger.WithContext(ctx).Error("Failed to get companion by ID number: ", err)
 			httpErr = types.ErrorNotFound(
 				"Companion not found",
 				"Companion with ID number "+idNumber+" not found",
 			)
 		} else {
-			logger.Error("Failed to get companion by ID number: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get companion by ID number: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve companion",
 				"Decoding error",
 				err.Error(),
@@ -110,23 +123,33 @@ func (companionType) GetByNumberID(idNumber string) types.Result[models.Companio
 
 	return types.ResultOk(companion)
 }
-func (companionType) GetByEmail(email string) types.Result[models.CompanionDBMongo] {
-	filter := bson.D{{Key: "email", Value: email}}
+func (companionType) GetByEmail(ctx context.Context, email string) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.GetByEmail")
+	defer span.End()
+
+	// Either email of the record, personal or institutional
+	filter := bson.D{
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "email", Value: email}},
+			bson.D{{Key: "institution_email", Value: email}},
+		}},
+		models.Filter.NotDeleted(),
+	}
 	var companion models.CompanionDBMongo
 
-	err := configs.DB.FindOne(filter, &companion)
+	err := configs.DB.FindOne(ctx, filter, &companion)
 	if err != nil {
 		var httpErr types.HttpError
 
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get companion by email: ", err)
+			logger.WithContext(ctx).Error("Failed to get companion by email: ", err)
 			httpErr = types.ErrorNotFound(
 				"Companion not found",
 				"Companion with email "+email+" not found",
 			)
 		} else {
-			logger.Error("Failed to get companion by email: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get companion by email: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve companion",
 				"Decoding error",
 				err.Error(),
@@ -139,14 +162,17 @@ func (companionType) GetByEmail(email string) types.Result[models.CompanionDBMon
 
 	return types.ResultOk(companion)
 }
-func (companionType) GetAll() types.Result[[]models.CompanionDBMongo] {
+func (companionType) GetAll(ctx context.Context) types.Result[[]models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.GetAll")
+	defer span.End()
+
 	filter := bson.D{{Key: "deleted_at", Value: models.Time.Zero()}} // Filter to exclude deleted companions
 	companions := []models.CompanionDBMongo{}
 
-	err := configs.DB.FindAll(filter, &companions)
+	err := configs.DB.FindAll(ctx, filter, &companions)
 	if err != nil {
-		logger.Error("Failed to get all companions from MongoDB:", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to get all companions from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve companions",
 			err.Error(),
 		)
@@ -154,14 +180,52 @@ func (companionType) GetAll() types.Result[[]models.CompanionDBMongo] {
 		return types.ResultErr[[]models.CompanionDBMongo](&httpErr)
 	}
 
-	logger.Debug("Retrieved", len(companions), "companions from MongoDB database")
+	logger.WithContext(ctx).Debug("Retrieved", len(companions), "companions from MongoDB database")
 	return types.ResultOk(companions)
 }
 
-func (companionType) UpdateByID(id string, companion models.CompanionCreate) types.Result[models.CompanionDBMongo] {
+// GetAllBySpeciality returns the companions of a speciality, excluding the deleted ones
+func (companionType) GetAllBySpeciality(ctx context.Context, id string) types.Result[[]models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.GetAllBySpeciality")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Speciality ID: "+id,
+		)
+		return types.ResultErr[[]models.CompanionDBMongo](&httpErr)
+	}
+
+	filter := bson.D{{Key: "id_speciality", Value: oid}, models.Filter.NotDeleted()} // Filter to exclude deleted companions
+	companions := []models.CompanionDBMongo{}
+
+	err = configs.DB.FindAll(ctx, filter, &companions)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to get companions by speciality from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
+			"Failed to retrieve companions by speciality",
+			err.Error(),
+		)
+
+		return types.ResultErr[[]models.CompanionDBMongo](&httpErr)
+	}
+
+	logger.WithContext(ctx).Debug("Retrieved", len(companions), "companions for speciality", id, "from MongoDB database")
+	return types.ResultOk(companions)
+}
+
+func (companionType) UpdateByID(ctx context.Context, id string, companion models.CompanionCreate) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.UpdateByID")
+	defer span.End()
+
 	oid, err := models.BsonIDFrom(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -175,13 +239,13 @@ func (companionType) UpdateByID(id string, companion models.CompanionCreate) typ
 	update := bson.D{{Key: "$set", Value: companion.ToUpdate()}}
 	companionDB := companion.ToUpdate()
 
-	result := configs.DB.PatchOne(filter, update, &companionDB)
+	result := configs.DB.PatchOne(ctx, filter, update, &companionDB)
 	// .From(models.CompanionDBMongo{}).UpdateOne(ctx, filter, update)
 
 	if result.IsErr() {
 		err := result.Error()
-		logger.Error("Failed to update companion in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to update companion in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to update companion",
 			err.Error(),
 			"Companion ID: "+id,
@@ -198,7 +262,7 @@ func (companionType) UpdateByID(id string, companion models.CompanionCreate) typ
 	}
 
 	if result.Value().ModifiedCount == 0 {
-		logger.Info("No changes made to companion with ID: ", id)
+		logger.WithContext(ctx).Info("No changes made to companion with ID: ", id)
 		logger.Lava("0.1.2", "Send a more proper code for no changes made")
 		httpErr := types.Error(
 			types.Http.C200().Accepted(),
@@ -208,13 +272,16 @@ func (companionType) UpdateByID(id string, companion models.CompanionCreate) typ
 		return types.ResultErr[models.CompanionDBMongo](&httpErr)
 	}
 
-	return Companion.GetByID(id)
+	return Companion.GetByID(ctx, id)
 }
 
-func (companionType) PatchByID(id string, companion models.CompanionCreate) types.Result[models.CompanionDBMongo] {
+func (companionType) PatchByID(ctx context.Context, id string, companion models.CompanionCreate) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.PatchByID")
+	defer span.End()
+
 	oid, err := models.BsonIDFrom(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -226,7 +293,7 @@ func (companionType) PatchByID(id string, companion models.CompanionCreate) type
 
 	companionDB := companion.ToUpdate()
 	if companionDB == (models.CompanionDBMongo{}) {
-		logger.Error("Error converting companion to DB model")
+		logger.WithContext(ctx).Error("Error converting companion to DB model")
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -239,12 +306,12 @@ func (companionType) PatchByID(id string, companion models.CompanionCreate) type
 	filter := bson.D{{Key: "_id", Value: oid}}
 	update := bson.D{{Key: "$set", Value: companionDB}}
 
-	result := configs.DB.PatchOne(filter, update, &companionDB)
+	result := configs.DB.PatchOne(ctx, filter, update, &companionDB)
 
 	if result.IsErr() {
 		err := result.Error()
-		logger.Error("Failed to update companion in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to update companion in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to update companion",
 			err.Error(),
 			"Companion ID: "+id,
@@ -261,7 +328,7 @@ func (companionType) PatchByID(id string, companion models.CompanionCreate) type
 	}
 
 	if result.Value().ModifiedCount == 0 {
-		logger.Info("No changes made to companion with ID: ", id)
+		logger.WithContext(ctx).Info("No changes made to companion with ID: ", id)
 		logger.Lava("0.1.2", "Send a more proper code for no changes made")
 		httpErr := types.Error(
 			types.Http.C200().Accepted(),
@@ -274,10 +341,13 @@ func (companionType) PatchByID(id string, companion models.CompanionCreate) type
 	return types.ResultOk(companionDB)
 }
 
-func (companionType) DeleteByID(id string) types.Result[models.CompanionDBMongo] {
+func (companionType) DeleteByID(ctx context.Context, id string) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.DeleteByID")
+	defer span.End()
+
 	oid, err := bson.ObjectIDFromHex(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -289,25 +359,20 @@ func (companionType) DeleteByID(id string) types.Result[models.CompanionDBMongo]
 
 	filter := bson.D{{Key: "_id", Value: oid}}
 	update := bson.D{{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: time.Now()}}}}
-	ctx, cancel := configs.DB.Context()
-	defer cancel()
 
 	var companion models.CompanionDBMongo
-	//result, err := configs.DB.UpdateOne(filter, update, deletedCompanion)
-	v := logger.Lava("0.1.1", "Use the code above to update the companion with deleted_at field")
-	v.LavaStart()
-	result, err := configs.DB.From(models.CompanionDBMongo{}).UpdateOne(ctx, filter, update)
-	if err != nil {
-		logger.Error("Failed to delete companion in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+	result := configs.DB.UpdateOne(ctx, filter, update, &companion)
+	if result.IsErr() && !errors.Is(result.Error(), mongo.ErrNoDocuments) {
+		logger.WithContext(ctx).Error("Failed to delete companion in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(),
 			"Failed to delete companion",
-			err.Error(),
+			result.Error().Error(),
 			"Companion ID: "+id,
 		)
 		return types.ResultErr[models.CompanionDBMongo](&httpErr)
 	}
 
-	if result.MatchedCount == 0 {
+	if result.IsErr() || result.Value().MatchedCount == 0 {
 		httpErr := types.ErrorNotFound(
 			"Companion not found",
 			"Companion with ID "+id+" not found",
@@ -315,10 +380,10 @@ func (companionType) DeleteByID(id string) types.Result[models.CompanionDBMongo]
 		return types.ResultErr[models.CompanionDBMongo](&httpErr)
 	}
 
-	err = configs.DB.FindOne(filter, &companion)
+	err = configs.DB.FindOne(ctx, filter, &companion)
 	if err != nil {
-		logger.Error("Failed to retrieve deleted companion: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to retrieve deleted companion: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve deleted companion",
 			err.Error(),
 			"Companion ID: "+id,
@@ -326,15 +391,16 @@ func (companionType) DeleteByID(id string) types.Result[models.CompanionDBMongo]
 		return types.ResultErr[models.CompanionDBMongo](&httpErr)
 	}
 
-	v.LavaEnd()
-
 	return types.ResultOk(companion)
 }
-func (companionType) DeletePermanentByID(id string) types.Result[models.CompanionDBMongo] {
-	logger.Warning("Permanently deleting companion by ID: ", id)
+func (companionType) DeletePermanentByID(ctx context.Context, id string) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.DeletePermanentByID")
+	defer span.End()
+
+	logger.WithContext(ctx).Warning("Permanently deleting companion by ID: ", id)
 	oid, err := models.BsonIDFrom(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -345,13 +411,11 @@ func (companionType) DeletePermanentByID(id string) types.Result[models.Companio
 	}
 
 	filter := bson.D{{Key: "_id", Value: oid}, {Key: "deleted_at", Value: bson.M{"$ne": time.Time{}}}} // Ensure the companion is marked as deleted
-	ctx, cancel := configs.DB.Context()
-	defer cancel()
 
 	var companion models.CompanionDBMongo
-	err = configs.DB.FindOne(filter, &companion)
+	err = configs.DB.FindOne(ctx, filter, &companion)
 	if err != nil {
-		logger.Debug("Failed to find companion for permanent deletion: ", err)
+		logger.WithContext(ctx).Debug("Failed to find companion for permanent deletion: ", err)
 
 		if err == mongo.ErrNoDocuments {
 			httpErr := types.ErrorNotFound(
@@ -361,7 +425,7 @@ func (companionType) DeletePermanentByID(id string) types.Result[models.Companio
 			return types.ResultErr[models.CompanionDBMongo](&httpErr)
 		}
 
-		httpErr := types.ErrorInternal(
+		httpErr := errorFrom(ctx, err,
 			"Failed to find companion for permanent deletion",
 			err.Error(),
 			"Companion ID: "+id,
@@ -369,10 +433,10 @@ func (companionType) DeletePermanentByID(id string) types.Result[models.Companio
 		return types.ResultErr[models.CompanionDBMongo](&httpErr)
 	}
 
-	result, err := configs.DB.From(models.CompanionDBMongo{}).DeleteOne(ctx, filter)
+	result, err := configs.DB.DeleteOne(ctx, filter, models.CompanionDBMongo{})
 	if err != nil {
-		logger.Debug("Failed to permanently delete companion in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Debug("Failed to permanently delete companion in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to permanently delete companion",
 			err.Error(),
 			"Companion ID: "+id,
@@ -391,15 +455,16 @@ func (companionType) DeletePermanentByID(id string) types.Result[models.Companio
 
 	return types.ResultOk(companion)
 }
-func (companionType) DeletePermanentAll() types.Result[[]models.CompanionDBMongo] {
+func (companionType) DeletePermanentAll(ctx context.Context) types.Result[[]models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.DeletePermanentAll")
+	defer span.End()
+
 	filter := bson.D{{Key: "deleted_at", Value: bson.M{"$ne": nil}}}
-	ctx, cancel := configs.DB.Context()
-	defer cancel()
 
-	result, err := configs.DB.From(models.CompanionDBMongo{}).DeleteMany(ctx, filter)
+	result, err := configs.DB.DeleteMany(ctx, filter, models.CompanionDBMongo{})
 	if err != nil {
-		logger.Error("Failed to permanently delete all companions in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to permanently delete all companions in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to permanently delete all companions",
 			err.Error(),
 		)
@@ -416,3 +481,30 @@ func (companionType) DeletePermanentAll() types.Result[[]models.CompanionDBMongo
 
 	return types.ResultOk([]models.CompanionDBMongo{})
 }
+
+// SetPhoto replaces the profile photo of the companion, returning the updated companion.
+// The blobs of the previous photo are left to the caller.
+func (companionType) SetPhoto(ctx context.Context, id string, photo models.PhotoDB) types.Result[models.CompanionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Companion.SetPhoto")
+	defer span.End()
+
+	current := Companion.GetByID(ctx, id)
+	if current.IsErr() {
+		return current
+	}
+
+	filter := bson.D{models.Filter.ID(current.Value().ID), models.Filter.NotDeleted()}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "photo", Value: photo},
+		{Key: "updated_at", Value: photo.UpdatedAt},
+	}}}
+
+	var companion models.CompanionDBMongo
+	result := configs.DB.UpdateOne(ctx, filter, update, &companion)
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to set companion photo in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to set companion photo", result.Error().Error())
+		return types.ResultErr[models.CompanionDBMongo](&httpErr)
+	}
+	return types.ResultOk(companion)
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// errorFrom builds the error returned to the controllers for a failed database call.
+// A call that ran out of time is reported as 504 and an unreachable database as 503,
+// so both can be told apart from a broken call.
+// The error is also recorded on the current span.
+func errorFrom(ctx context.Context, err error, information ...string) types.HttpError {
+	tracing.FromContext(ctx).RecordError(err)
+
+	if errors.Is(err, configs.ErrDBUnavailable) {
+		return types.ErrorServiceUnavailable(information...)
+	}
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+		return types.ErrorGatewayTimeout(information...)
+	}
+	return types.ErrorInternal(information...)
+}
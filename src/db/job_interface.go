@@ -0,0 +1,222 @@
+package db
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"errors"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type jobType struct{}
+
+var Job jobType
+
+// Create queues a new job
+func (jobType) Create(ctx context.Context, job models.JobDBMongo) types.Result[models.JobDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Job.Create")
+	defer span.End()
+
+	result, err := configs.DB.InsertOne(ctx, job)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to create job in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to queue job", err.Error())
+		return types.ResultErr[models.JobDBMongo](&httpErr)
+	}
+	job.ID, _ = models.ID.ToDB(result.InsertedID)
+	return types.ResultOk(job)
+}
+
+func (jobType) GetByID(ctx context.Context, id string) types.Result[models.JobDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Job.GetByID")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Job ID: "+id,
+		)
+		return types.ResultErr[models.JobDBMongo](&httpErr)
+	}
+
+	var job models.JobDBMongo
+	err = configs.DB.FindOne(ctx, bson.D{models.Filter.ID(oid)}, &job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		httpErr := types.ErrorNotFound(
+			"Job not found",
+			"Job with ID "+id+" not found",
+		)
+		return types.ResultErr[models.JobDBMongo](&httpErr)
+	}
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve job: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve job", err.Error())
+		return types.ResultErr[models.JobDBMongo](&httpErr)
+	}
+	return types.ResultOk(job)
+}
+
+// GetAll returns the jobs with the status and kind, any of them when empty, newest first
+func (jobType) GetAll(ctx context.Context, status string, kind string) types.Result[[]models.JobDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Job.GetAll")
+	defer span.End()
+
+	filter := bson.D{}
+	if status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: status})
+	}
+	if kind != "" {
+		filter = append(filter, bson.E{Key: "kind", Value: kind})
+	}
+
+	jobs := []models.JobDBMongo{}
+	err := configs.DB.FindAll(ctx, filter, &jobs)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve jobs:", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve jobs", err.Error())
+		return types.ResultErr[[]models.JobDBMongo](&httpErr)
+	}
+
+	slices.SortStableFunc(jobs, func(a models.JobDBMongo, b models.JobDBMongo) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return types.ResultOk(jobs)
+}
+
+// Due returns the jobs a worker can take at now, the longest waiting first
+func (jobType) Due(ctx context.Context, now time.Time) types.Result[[]models.JobDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Job.Due")
+	defer span.End()
+
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "status", Value: models.JOB_QUEUED}, {Key: "run_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+		bson.D{{Key: "status", Value: models.JOB_RUNNING}, {Key: "locked_until", Value: bson.D{{Key: "$lte", Value: now}}}},
+	}}}
+
+	jobs := []models.JobDBMongo{}
+	err := configs.DB.FindAll(ctx, filter, &jobs)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve due jobs:", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve due jobs", err.Error())
+		return types.ResultErr[[]models.JobDBMongo](&httpErr)
+	}
+
+	slices.SortStableFunc(jobs, func(a models.JobDBMongo, b models.JobDBMongo) int {
+		return a.RunAt.Compare(b.RunAt)
+	})
+	return types.ResultOk(jobs)
+}
+
+// Claim takes the job for a worker, only when nobody else changed it since it was read.
+// It returns false when another worker got it first.
+func (jobType) Claim(ctx context.Context, job models.JobDBMongo, claimed models.JobDBMongo) (bool, error) {
+	ctx, span := tracing.Start(ctx, "db.Job.Claim")
+	defer span.End()
+
+	filter := bson.D{
+		models.Filter.ID(job.ID),
+		{Key: "status", Value: job.Status},
+		{Key: "attempts", Value: job.Attempts},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: claimed.Status},
+		{Key: "attempts", Value: claimed.Attempts},
+		{Key: "claim", Value: claimed.Claim},
+		{Key: "locked_until", Value: claimed.LockedUntil},
+		{Key: "updated_at", Value: claimed.UpdatedAt},
+	}}}
+
+	// The filter no longer matches once claimed, so the job is read back by its claim
+	var found models.JobDBMongo
+	configs.DB.PatchOne(ctx, filter, update, &found)
+	err := configs.DB.FindOne(ctx, bson.D{models.Filter.ID(job.ID), {Key: "claim", Value: claimed.Claim}}, &found)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to claim job: ", err)
+		return false, err
+	}
+	return found.Status == models.JOB_RUNNING && found.Attempts == claimed.Attempts, nil
+}
+
+// Save stores the outcome of a run, unless the job was claimed again meanwhile after its lease ran out.
+// An empty claim saves the job whatever run holds it.
+func (jobType) Save(ctx context.Context, claim string, job models.JobDBMongo) types.Result[models.JobDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Job.Save")
+	defer span.End()
+
+	fields := bson.D{
+		{Key: "status", Value: job.Status},
+		{Key: "attempts", Value: job.Attempts},
+		{Key: "max_attempts", Value: job.MaxAttempts},
+		{Key: "run_at", Value: job.RunAt},
+		{Key: "updated_at", Value: job.UpdatedAt},
+		{Key: "finished_at", Value: job.FinishedAt},
+	}
+	update := bson.D{{Key: "$set", Value: fields}}
+	if job.LastError == "" {
+		update = append(update, bson.E{Key: "$unset", Value: bson.D{{Key: "last_error", Value: ""}}})
+	} else {
+		fields = append(fields, bson.E{Key: "last_error", Value: job.LastError})
+		update[0].Value = fields
+	}
+
+	filter := bson.D{models.Filter.ID(job.ID)}
+	if claim != "" {
+		filter = append(filter, bson.E{Key: "claim", Value: claim})
+	}
+
+	var saved models.JobDBMongo
+	result := configs.DB.UpdateOne(ctx, filter, update, &saved)
+	if errors.Is(result.Error(), mongo.ErrNoDocuments) && claim != "" {
+		httpErr := types.Error(
+			types.Http.C400().Conflict(),
+			"Job claimed again",
+			"Job "+job.ID.Hex()+" ran past its lease and was claimed by another worker",
+		)
+		return types.ResultErr[models.JobDBMongo](&httpErr)
+	}
+	if errors.Is(result.Error(), mongo.ErrNoDocuments) {
+		httpErr := types.ErrorNotFound(
+			"Job not found",
+			"Job with ID "+job.ID.Hex()+" not found",
+		)
+		return types.ResultErr[models.JobDBMongo](&httpErr)
+	}
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to save job: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to save job", result.Error().Error())
+		return types.ResultErr[models.JobDBMongo](&httpErr)
+	}
+	return types.ResultOk(saved)
+}
+
+// DeleteFinished removes the done and dead jobs that finished before the time, returning how many
+func (jobType) DeleteFinished(ctx context.Context, before time.Time) types.Result[int64] {
+	ctx, span := tracing.Start(ctx, "db.Job.DeleteFinished")
+	defer span.End()
+
+	filter := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{models.JOB_DONE, models.JOB_DEAD}}}},
+		{Key: "finished_at", Value: bson.D{{Key: "$lt", Value: before}}},
+	}
+	result, err := configs.DB.DeleteMany(ctx, filter, models.JobDBMongo{})
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to delete finished jobs: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to delete finished jobs", err.Error())
+		return types.ResultErr[int64](&httpErr)
+	}
+	return types.ResultOk(result.DeletedCount)
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"errors"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type notificationType struct{}
+
+var Notification notificationType
+
+// recipientID validates the recipient kind and checks the student or companion exists
+func (notificationType) recipientID(ctx context.Context, recipient string, id string) (models.DBID, error) {
+	switch recipient {
+	case models.NOTIFICATION_STUDENT:
+		student := Student.GetByID(ctx, id)
+		return student.Value().ID, student.ErrorOr(nil)
+	case models.NOTIFICATION_COMPANION:
+		companion := Companion.GetByID(ctx, id)
+		return companion.Value().ID, companion.ErrorOr(nil)
+	}
+	httpErr := types.Error(
+		types.Http.C400().UnprocessableEntity(),
+		"Invalid value",
+		"Unknown recipient "+recipient,
+		"Expected one of: "+strings.Join(models.NOTIFICATION_RECIPIENTS, ", "),
+	)
+	return models.DBID{}, &httpErr
+}
+
+// GetPreference returns the preferences of the student or companion, the default ones when they set none
+func (notificationType) GetPreference(ctx context.Context, recipient string, id string) types.Result[models.NotificationPreferenceDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Notification.GetPreference")
+	defer span.End()
+
+	oid, err := Notification.recipientID(ctx, recipient, id)
+	if err != nil {
+		return types.ResultErr[models.NotificationPreferenceDBMongo](err)
+	}
+	return Notification.preference(ctx, recipient, oid)
+}
+
+func (notificationType) preference(ctx context.Context, recipient string, oid models.DBID) types.Result[models.NotificationPreferenceDBMongo] {
+	filter := bson.D{{Key: "recipient", Value: recipient}, {Key: "id_recipient", Value: oid}}
+	var preference models.NotificationPreferenceDBMongo
+
+	err := configs.DB.FindOne(ctx, filter, &preference)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return types.ResultOk(models.DefaultNotificationPreference(recipient, oid))
+	}
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to get notification preferences: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve notification preferences", err.Error())
+		return types.ResultErr[models.NotificationPreferenceDBMongo](&httpErr)
+	}
+	return types.ResultOk(preference)
+}
+
+// PreferenceOf returns the preferences of a recipient already known to exist, the default ones on any failure
+func (notificationType) PreferenceOf(ctx context.Context, recipient string, oid models.DBID) models.NotificationPreferenceDBMongo {
+	return Notification.preference(ctx, recipient, oid).ValueOr(models.DefaultNotificationPreference(recipient, oid))
+}
+
+// SetPreference replaces the preferences of the student or companion
+func (notificationType) SetPreference(ctx context.Context, recipient string, id string, body models.NotificationPreferenceCreate) types.Result[models.NotificationPreferenceDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Notification.SetPreference")
+	defer span.End()
+
+	oid, err := Notification.recipientID(ctx, recipient, id)
+	if err != nil {
+		return types.ResultErr[models.NotificationPreferenceDBMongo](err)
+	}
+
+	preference, ok := body.ToInsert(recipient, oid)
+	if !ok {
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid notification preferences",
+			"Expected address personal or institution, language one of "+strings.Join(models.NOTIFICATION_LANGUAGES, ", ")+
+				" and muted events among "+strings.Join(models.NOTIFICATION_EVENTS, ", "),
+		)
+		return types.ResultErr[models.NotificationPreferenceDBMongo](&httpErr)
+	}
+
+	current := Notification.preference(ctx, recipient, oid)
+	if current.IsErr() {
+		return current
+	}
+
+	if current.Value().ID.IsZero() {
+		result, err := configs.DB.InsertOne(ctx, preference)
+		if err != nil {
+			logger.WithContext(ctx).Error("Failed to create notification preferences in MongoDB: ", err)
+			httpErr := errorFrom(ctx, err, "Failed to save notification preferences", err.Error())
+			return types.ResultErr[models.NotificationPreferenceDBMongo](&httpErr)
+		}
+		preference.ID, _ = models.ID.ToDB(result.InsertedID)
+		return types.ResultOk(preference)
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "address", Value: preference.Address},
+		{Key: "language", Value: preference.Language},
+		{Key: "muted", Value: preference.Muted},
+		{Key: "updated_at", Value: preference.UpdatedAt},
+	}}}
+	var updated models.NotificationPreferenceDBMongo
+	result := configs.DB.UpdateOne(ctx, bson.D{models.Filter.ID(current.Value().ID)}, update, &updated)
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to update notification preferences in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to save notification preferences", result.Error().Error())
+		return types.ResultErr[models.NotificationPreferenceDBMongo](&httpErr)
+	}
+	return types.ResultOk(updated)
+}
+
+// Record stores a notification sent or failed
+func (notificationType) Record(ctx context.Context, notification models.NotificationDBMongo) types.Result[models.NotificationDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Notification.Record")
+	defer span.End()
+
+	result, err := configs.DB.InsertOne(ctx, notification)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to record notification in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to record notification", err.Error())
+		return types.ResultErr[models.NotificationDBMongo](&httpErr)
+	}
+	notification.ID, _ = models.ID.ToDB(result.InsertedID)
+	return types.ResultOk(notification)
+}
+
+// WasSent reports whether the event of the session on that date already reached the recipient
+func (notificationType) WasSent(ctx context.Context, session models.SessionDBMongo, event string, recipient models.DBID) bool {
+	ctx, span := tracing.Start(ctx, "db.Notification.WasSent")
+	defer span.End()
+
+	filter := bson.D{
+		models.Filter.IDOf("session", session.ID),
+		{Key: "event", Value: event},
+		{Key: "id_recipient", Value: recipient},
+		{Key: "session_date", Value: session.Date},
+		{Key: "error", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	var notification models.NotificationDBMongo
+	return configs.DB.FindOne(ctx, filter, &notification) == nil
+}
+
+// GetAllBySessionID returns the notifications about the session, oldest first
+func (notificationType) GetAllBySessionID(ctx context.Context, id string) types.Result[[]models.NotificationDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Notification.GetAllBySessionID")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Session ID: "+id,
+		)
+		return types.ResultErr[[]models.NotificationDBMongo](&httpErr)
+	}
+
+	notifications := []models.NotificationDBMongo{}
+	err = configs.DB.FindAll(ctx, bson.D{models.Filter.IDOf("session", oid)}, &notifications)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve notifications:", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve notifications", err.Error())
+		return types.ResultErr[[]models.NotificationDBMongo](&httpErr)
+	}
+
+	slices.SortStableFunc(notifications, func(a models.NotificationDBMongo, b models.NotificationDBMongo) int {
+		return a.SentAt.Compare(b.SentAt)
+	})
+	return types.ResultOk(notifications)
+}
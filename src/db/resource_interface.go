@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type resourceType struct{}
+
+var Resource resourceType
+
+func (resourceType) Create(ctx context.Context, resource models.ResourceDBMongo) types.Result[models.ResourceDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Resource.Create")
+	defer span.End()
+
+	resource.CreatedAt = models.Time.Now()
+	resource.UpdatedAt = models.Time.Now()
+	resource.DeletedAt = models.Time.Zero()
+	if resource.Links == nil {
+		resource.Links = []models.ResourceLinkDB{}
+	}
+
+	result, err := configs.DB.InsertOne(ctx, resource)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to create resource in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create resource", err.Error())
+		return types.ResultErr[models.ResourceDBMongo](&httpErr)
+	}
+
+	resource.ID, err = models.ID.ToDB(result.InsertedID)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert inserted ID to ObjectID: ", err)
+		httpErr := types.ErrorInternal(
+			"Failed to create resource",
+			"Failed to convert inserted ID to ObjectID",
+			"Error: "+err.Error(),
+		)
+		return types.ResultErr[models.ResourceDBMongo](&httpErr)
+	}
+	return types.ResultOk(resource)
+}
+
+func (resourceType) GetByID(ctx context.Context, id string) types.Result[models.ResourceDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Resource.GetByID")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Resource ID: "+id,
+		)
+		return types.ResultErr[models.ResourceDBMongo](&httpErr)
+	}
+
+	return Resource.findOne(ctx, bson.D{models.Filter.ID(oid), models.Filter.NotDeleted()}, "Resource with ID "+id+" not found")
+}
+
+// GetByChecksum returns the resource with the same content uploaded by the user, 404 when there is none
+func (resourceType) GetByChecksum(ctx context.Context, checksum string, uploadedBy string) types.Result[models.ResourceDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Resource.GetByChecksum")
+	defer span.End()
+
+	filter := bson.D{{Key: "checksum", Value: checksum}, {Key: "uploaded_by", Value: uploadedBy}, models.Filter.NotDeleted()}
+	return Resource.findOne(ctx, filter, "No resource with checksum "+checksum)
+}
+
+func (resourceType) findOne(ctx context.Context, filter bson.D, notFound string) types.Result[models.ResourceDBMongo] {
+	var resource models.ResourceDBMongo
+	err := configs.DB.FindOne(ctx, filter, &resource)
+	if err != nil {
+		httpErr := types.ErrorNotFound("Resource not found", notFound)
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			logger.WithContext(ctx).Error("Failed to get resource: ", err)
+			httpErr = errorFrom(ctx, err, "Failed to retrieve resource", err.Error())
+		}
+		return types.ResultErr[models.ResourceDBMongo](&httpErr)
+	}
+	return types.ResultOk(resource)
+}
+
+// GetAllLinkedTo returns the resources linked to a student, a companion or a session
+func (resourceType) GetAllLinkedTo(ctx context.Context, link models.ResourceLink) types.Result[[]models.ResourceDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Resource.GetAllLinkedTo")
+	defer span.End()
+
+	linkDB, ok := link.ToDB()
+	if !ok {
+		httpErr := resourceLinkError(link)
+		return types.ResultErr[[]models.ResourceDBMongo](&httpErr)
+	}
+
+	resources := []models.ResourceDBMongo{}
+	filter := bson.D{
+		{Key: "links", Value: bson.D{{Key: "kind", Value: linkDB.Kind}, {Key: "id", Value: linkDB.ID}}}, // Matches an element of the array
+		models.Filter.NotDeleted(),
+	}
+	err := configs.DB.FindAll(ctx, filter, &resources)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve linked resources:", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve linked resources", err.Error())
+		return types.ResultErr[[]models.ResourceDBMongo](&httpErr)
+	}
+	return types.ResultOk(resources)
+}
+
+// Link points the resource to an existing student, companion or session, linking twice is a no-op
+func (resourceType) Link(ctx context.Context, id string, link models.ResourceLink) types.Result[models.ResourceDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Resource.Link")
+	defer span.End()
+
+	linkDB, ok := link.ToDB()
+	if !ok {
+		httpErr := resourceLinkError(link)
+		return types.ResultErr[models.ResourceDBMongo](&httpErr)
+	}
+
+	var target error
+	switch link.Kind {
+	case models.RESOURCE_LINK_STUDENT:
+		target = Student.GetByID(ctx, link.ID).ErrorOr(nil)
+	case models.RESOURCE_LINK_COMPANION:
+		target = Companion.GetByID(ctx, link.ID).ErrorOr(nil)
+	case models.RESOURCE_LINK_SESSION:
+		target = Session.GetByID(ctx, link.ID).ErrorOr(nil)
+	}
+	if target != nil {
+		return types.ResultErr[models.ResourceDBMongo](target)
+	}
+
+	current := Resource.GetByID(ctx, id)
+	if current.IsErr() || current.Value().LinkedTo(linkDB) {
+		return current
+	}
+	resource := current.Value()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "links", Value: append(resource.Links, linkDB)},
+		{Key: "updated_at", Value: models.Time.Now()},
+	}}}
+	var updated models.ResourceDBMongo
+	result := configs.DB.UpdateOne(ctx, bson.D{models.Filter.ID(resource.ID)}, update, &updated)
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to link resource in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to link resource", result.Error().Error())
+		return types.ResultErr[models.ResourceDBMongo](&httpErr)
+	}
+	return types.ResultOk(updated)
+}
+
+// DeleteByID marks the resource as deleted, its file stays on the blob store
+func (resourceType) DeleteByID(ctx context.Context, id string) types.Result[models.ResourceDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Resource.DeleteByID")
+	defer span.End()
+
+	current := Resource.GetByID(ctx, id)
+	if current.IsErr() {
+		return current
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: models.Time.Now()}}}}
+	var deleted models.ResourceDBMongo
+	result := configs.DB.UpdateOne(ctx, bson.D{models.Filter.ID(current.Value().ID)}, update, &deleted)
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to delete resource in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to delete resource", result.Error().Error())
+		return types.ResultErr[models.ResourceDBMongo](&httpErr)
+	}
+	return types.ResultOk(deleted)
+}
+
+func resourceLinkError(link models.ResourceLink) types.HttpError {
+	return types.Error(
+		types.Http.C400().UnprocessableEntity(),
+		"Invalid value",
+		"Invalid resource link",
+		"Expected kind one of student, companion or session and a 24 hex characters ID",
+		"Kind: "+link.Kind+", ID: "+link.ID,
+	)
+}
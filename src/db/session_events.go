@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"sync"
+)
+
+// SessionListener is told about every change made through db.Session, once it is stored.
+// It runs on the caller's goroutine, so long work belongs on a job.
+type SessionListener func(ctx context.Context, event models.SessionEvent)
+
+var sessionListeners struct {
+	mutex sync.RWMutex
+	list  []SessionListener
+}
+
+// Listen adds a listener of the session changes
+func (sessionType) Listen(listener SessionListener) {
+	sessionListeners.mutex.Lock()
+	defer sessionListeners.mutex.Unlock()
+	sessionListeners.list = append(sessionListeners.list, listener)
+}
+
+// emit tells every listener about the change, a panicking listener does not fail the change
+func (sessionType) emit(ctx context.Context, kind string, previous models.SessionDBMongo, session models.SessionDBMongo) {
+	sessionListeners.mutex.RLock()
+	listeners := sessionListeners.list
+	sessionListeners.mutex.RUnlock()
+
+	event := models.SessionEvent{Type: kind, Session: session, Previous: previous, At: models.Time.Now()}
+	for _, listener := range listeners {
+		func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					logger.WithContext(ctx).Error("Session listener failed on", kind, ":", recovered)
+				}
+			}()
+			listener(ctx, event)
+		}()
+	}
+}
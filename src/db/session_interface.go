@@ -1,9 +1,12 @@
 package db
 
 import (
+	"context"
 	"dainxor/atv/configs"
 	"dainxor/atv/logger"
 	"dainxor/atv/models"
+	"dainxor/atv/tenant"
+	"dainxor/atv/tracing"
 	"dainxor/atv/types"
 	"dainxor/atv/utils"
 
@@ -15,10 +18,17 @@ type sessionType struct{}
 
 var Session sessionType
 
-func (sessionType) Create(u models.SessionCreate) types.Result[models.SessionDBMongo] {
-	logger.Debug("Creating session with data: ", u)
+func (sessionType) Create(ctx context.Context, u models.SessionCreate) types.Result[models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.Create")
+	defer span.End()
 
-	sessionOptional := utils.Transform(getExtraInfo(u), func(res types.Result[map[string]string]) types.Optional[models.SessionDBMongo] {
+	logger.WithContext(ctx).Debug("Creating session with data: ", u)
+
+	if u.IDCompanion == "" && u.IDStudent != "" {
+		u.IDCompanion = Assignment.CurrentCompanion(ctx, u.IDStudent) // Default to the assigned companion
+	}
+
+	sessionOptional := utils.Transform(getExtraInfo(ctx, u), func(res types.Result[map[string]string]) types.Optional[models.SessionDBMongo] {
 		if res.IsErr() {
 			return types.OptionalEmpty[models.SessionDBMongo]()
 		}
@@ -26,7 +36,7 @@ func (sessionType) Create(u models.SessionCreate) types.Result[models.SessionDBM
 	})
 
 	if sessionOptional.IsEmpty() {
-		logger.Warning("Failed to create session: Invalid session data")
+		logger.WithContext(ctx).Warning("Failed to create session: Invalid session data")
 		httpErr := types.ErrorInternal(
 			"Failed to create session",
 			"Invalid session data provided",
@@ -35,18 +45,19 @@ func (sessionType) Create(u models.SessionCreate) types.Result[models.SessionDBM
 		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 	session := sessionOptional.Get()
-	logger.Debug("Session object to insert: ", session)
-	result, err := configs.DB.InsertOne(session)
+	logger.WithContext(ctx).Debug("Session object to insert: ", session)
+	result, err := configs.DB.InsertOne(ctx, session)
 
 	if err != nil {
-		logger.Warning("Error inserting session: ", err)
-		return types.ResultErr[models.SessionDBMongo](err)
+		logger.WithContext(ctx).Warning("Error inserting session: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create session", err.Error())
+		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 
 	session.ID, err = models.ID.ToDB(result.InsertedID)
 
 	if err != nil {
-		logger.Error("Error converting inserted ID to PrimitiveID: ", err)
+		logger.WithContext(ctx).Error("Error converting inserted ID to PrimitiveID: ", err)
 		httpErr := types.ErrorInternal(
 			"Failed to create session",
 			"Failed to convert inserted ID to PrimitiveID",
@@ -55,14 +66,19 @@ func (sessionType) Create(u models.SessionCreate) types.Result[models.SessionDBM
 		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 
+	session.IDUniversity, _ = tenant.From(ctx) // Stamped on the stored one by configs.DB
+	Session.emit(ctx, models.SESSION_CREATED, models.SessionDBMongo{}, session)
 	return types.ResultOk(session)
 }
 
-func (sessionType) GetByID(id string) types.Result[models.SessionDBMongo] {
+func (sessionType) GetByID(ctx context.Context, id string) types.Result[models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.GetByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -75,19 +91,19 @@ func (sessionType) GetByID(id string) types.Result[models.SessionDBMongo] {
 	filter := bson.D{{Key: "_id", Value: oid}}
 	var session models.SessionDBMongo
 
-	err = configs.DB.FindOne(filter, &session)
+	err = configs.DB.FindOne(ctx, filter, &session)
 	if err != nil {
 		var httpErr types.HttpError
 
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get session by ID: ", err)
+			logger.WithContext(ctx).Error("Failed to get session by ID: ", err)
 			httpErr = types.ErrorNotFound(
 				"Session not found",
 				"Session with ID "+id+" not found",
 			)
 		} else {
-			logger.Error("Failed to get session by ID: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get session by ID: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve session",
 				"Decoding error",
 				err.Error(),
@@ -100,14 +116,17 @@ func (sessionType) GetByID(id string) types.Result[models.SessionDBMongo] {
 
 	return types.ResultOk(session)
 }
-func (sessionType) GetAll() types.Result[[]models.SessionDBMongo] {
+func (sessionType) GetAll(ctx context.Context) types.Result[[]models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.GetAll")
+	defer span.End()
+
 	filter := bson.D{models.Filter.NotDeleted()} // Filter to exclude deleted sessions
 	sessions := []models.SessionDBMongo{}
 
-	err := configs.DB.FindAll(filter, &sessions)
+	err := configs.DB.FindAll(ctx, filter, &sessions)
 	if err != nil {
-		logger.Error("Failed to get all sessions from MongoDB:", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to get all sessions from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve sessions",
 			err.Error(),
 		)
@@ -115,14 +134,17 @@ func (sessionType) GetAll() types.Result[[]models.SessionDBMongo] {
 		return types.ResultErr[[]models.SessionDBMongo](&httpErr)
 	}
 
-	logger.Debug("Retrieved", len(sessions), "sessions from MongoDB database")
+	logger.WithContext(ctx).Debug("Retrieved", len(sessions), "sessions from MongoDB database")
 	return types.ResultOk(sessions)
 }
-func (sessionType) GetAllByStudentID(id string) types.Result[[]models.SessionDBMongo] {
+func (sessionType) GetAllByStudentID(ctx context.Context, id string) types.Result[[]models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.GetAllByStudentID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -135,10 +157,10 @@ func (sessionType) GetAllByStudentID(id string) types.Result[[]models.SessionDBM
 	filter := bson.D{{Key: "id_student", Value: oid}, models.Filter.NotDeleted()} // Filter to exclude deleted sessions
 	sessions := []models.SessionDBMongo{}
 
-	err = configs.DB.FindAll(filter, &sessions)
+	err = configs.DB.FindAll(ctx, filter, &sessions)
 	if err != nil {
-		logger.Error("Failed to get all sessions by student ID from MongoDB:", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to get all sessions by student ID from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve sessions by student ID",
 			err.Error(),
 		)
@@ -146,14 +168,59 @@ func (sessionType) GetAllByStudentID(id string) types.Result[[]models.SessionDBM
 		return types.ResultErr[[]models.SessionDBMongo](&httpErr)
 	}
 
-	logger.Debug("Retrieved", len(sessions), "sessions for student ID", id, "from MongoDB database")
+	logger.WithContext(ctx).Debug("Retrieved", len(sessions), "sessions for student ID", id, "from MongoDB database")
+	return types.ResultOk(sessions)
+}
+
+func (sessionType) GetAllByCompanionID(ctx context.Context, id string) types.Result[[]models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.GetAllByCompanionID")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Companion ID: "+id,
+		)
+		return types.ResultErr[[]models.SessionDBMongo](&httpErr)
+	}
+
+	return Session.GetAllByCompanionIDs(ctx, []models.DBID{oid})
+}
+
+// GetAllByCompanionIDs returns the sessions of any of the companions, excluding the deleted ones
+func (sessionType) GetAllByCompanionIDs(ctx context.Context, ids []models.DBID) types.Result[[]models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.GetAllByCompanionIDs")
+	defer span.End()
+
+	filter := bson.D{{Key: "id_companion", Value: bson.M{"$in": ids}}, models.Filter.NotDeleted()} // Filter to exclude deleted sessions
+	sessions := []models.SessionDBMongo{}
+
+	err := configs.DB.FindAll(ctx, filter, &sessions)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to get all sessions by companion ID from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
+			"Failed to retrieve sessions by companion ID",
+			err.Error(),
+		)
+
+		return types.ResultErr[[]models.SessionDBMongo](&httpErr)
+	}
+
+	logger.WithContext(ctx).Debug("Retrieved", len(sessions), "sessions for", len(ids), "companions from MongoDB database")
 	return types.ResultOk(sessions)
 }
 
-func (sessionType) UpdateByID(id string, session models.SessionCreate) types.Result[models.SessionDBMongo] {
+func (sessionType) UpdateByID(ctx context.Context, id string, session models.SessionCreate) types.Result[models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.UpdateByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -163,14 +230,14 @@ func (sessionType) UpdateByID(id string, session models.SessionCreate) types.Res
 		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 
-	sessionData := utils.Transform(getExtraInfo(session), func(res types.Result[map[string]string]) types.Result[models.SessionDBMongo] {
+	sessionData := utils.Transform(getExtraInfo(ctx, session), func(res types.Result[map[string]string]) types.Result[models.SessionDBMongo] {
 		if res.IsErr() {
 			return types.ResultErr[models.SessionDBMongo](res.Error())
 		}
 		return session.ToUpdate(res.Value())
 	})
 	if sessionData.IsErr() {
-		logger.Warning("Failed to update session:", sessionData.Error())
+		logger.WithContext(ctx).Warning("Failed to update session:", sessionData.Error())
 		httpErr := types.ErrorInternal(
 			"Failed to update session",
 			"Invalid session data",
@@ -182,12 +249,15 @@ func (sessionType) UpdateByID(id string, session models.SessionCreate) types.Res
 	filter := bson.D{{Key: "_id", Value: oid}}
 	update := bson.D{{Key: "$set", Value: sessionDB}}
 
-	result := configs.DB.UpdateOne(filter, update, &sessionDB)
+	var previous models.SessionDBMongo
+	configs.DB.FindOne(ctx, filter, &previous) // For the listeners, a missing session fails on the update
+
+	result := configs.DB.UpdateOne(ctx, filter, update, &sessionDB)
 
 	if result.IsErr() {
 		err := result.Error()
-		logger.Error("Failed to update session in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to update session in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to update session",
 			err.Error(),
 			"Session ID: "+id,
@@ -204,7 +274,7 @@ func (sessionType) UpdateByID(id string, session models.SessionCreate) types.Res
 	}
 
 	if result.Value().ModifiedCount == 0 {
-		logger.Info("No changes made to session with ID: ", id)
+		logger.WithContext(ctx).Info("No changes made to session with ID: ", id)
 		logger.Lava("0.1.2", "Send a more proper code for no changes made")
 		httpErr := types.Error(
 			types.Http.C300().NotModified(),
@@ -214,13 +284,17 @@ func (sessionType) UpdateByID(id string, session models.SessionCreate) types.Res
 		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 
+	Session.emit(ctx, models.SessionChange(previous, sessionDB), previous, sessionDB)
 	return types.ResultOk(sessionDB)
 }
 
-func (sessionType) PatchByID(id string, session models.SessionCreate) types.Result[models.SessionDBMongo] {
+func (sessionType) PatchByID(ctx context.Context, id string, session models.SessionCreate) types.Result[models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.PatchByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -230,7 +304,7 @@ func (sessionType) PatchByID(id string, session models.SessionCreate) types.Resu
 		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 
-	sessionData := utils.Transform(getExtraInfoAllowEmpty(session),
+	sessionData := utils.Transform(getExtraInfoAllowEmpty(ctx, session),
 		func(res types.Result[map[string]string]) types.Result[models.SessionDBMongo] {
 			if res.IsErr() {
 				return types.ResultErr[models.SessionDBMongo](res.Error())
@@ -239,7 +313,7 @@ func (sessionType) PatchByID(id string, session models.SessionCreate) types.Resu
 		},
 	)
 	if sessionData.IsErr() {
-		logger.Warning("Failed to update session:", sessionData.Error())
+		logger.WithContext(ctx).Warning("Failed to update session:", sessionData.Error())
 		httpErr := types.ErrorInternal(
 			"Failed to update session",
 			"Invalid session data",
@@ -251,12 +325,15 @@ func (sessionType) PatchByID(id string, session models.SessionCreate) types.Resu
 	filter := bson.D{{Key: "_id", Value: oid}}
 	update := bson.D{{Key: "$set", Value: sessionDB}}
 
-	result := configs.DB.PatchOne(filter, update, &sessionDB)
+	var previous models.SessionDBMongo
+	configs.DB.FindOne(ctx, filter, &previous) // For the listeners, a missing session fails on the update
+
+	result := configs.DB.PatchOne(ctx, filter, update, &sessionDB)
 
 	if result.IsErr() {
 		err := result.Error()
-		logger.Error("Failed to patch session in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to patch session in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to patch session",
 			err.Error(),
 			"Session ID: "+id,
@@ -273,7 +350,7 @@ func (sessionType) PatchByID(id string, session models.SessionCreate) types.Resu
 	}
 
 	if result.Value().ModifiedCount == 0 {
-		logger.Info("No changes made to session with ID: ", id)
+		logger.WithContext(ctx).Info("No changes made to session with ID: ", id)
 		httpErr := types.Error(
 			types.Http.C300().NotModified(),
 			"No changes made",
@@ -282,13 +359,17 @@ func (sessionType) PatchByID(id string, session models.SessionCreate) types.Resu
 		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 
+	Session.emit(ctx, models.SessionChange(previous, sessionDB), previous, sessionDB)
 	return types.ResultOk(sessionDB)
 }
 
-func (sessionType) DeleteByID(id string) types.Result[models.SessionDBMongo] {
+func (sessionType) DeleteByID(ctx context.Context, id string) types.Result[models.SessionDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Session.DeleteByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -302,10 +383,10 @@ func (sessionType) DeleteByID(id string) types.Result[models.SessionDBMongo] {
 	update := bson.D{{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: models.Time.Now()}}}}
 
 	var deletedSession models.SessionDBMongo
-	result := configs.DB.UpdateOne(filter, update, &deletedSession)
+	result := configs.DB.UpdateOne(ctx, filter, update, &deletedSession)
 	if result.IsErr() {
-		logger.Error("Failed to delete session in MongoDB: ", result.Error())
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to delete session in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(),
 			"Failed to delete session",
 			result.Error().Error(),
 			"Session ID: "+id,
@@ -321,10 +402,10 @@ func (sessionType) DeleteByID(id string) types.Result[models.SessionDBMongo] {
 		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 
-	err = configs.DB.FindOne(filter, &deletedSession)
+	err = configs.DB.FindOne(ctx, filter, &deletedSession)
 	if err != nil {
-		logger.Error("Failed to retrieve deleted session: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to retrieve deleted session: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve deleted session",
 			err.Error(),
 			"Session ID: "+id,
@@ -332,30 +413,34 @@ func (sessionType) DeleteByID(id string) types.Result[models.SessionDBMongo] {
 		return types.ResultErr[models.SessionDBMongo](&httpErr)
 	}
 
+	Session.emit(ctx, models.SESSION_DELETED, models.SessionDBMongo{}, deletedSession)
 	return types.ResultOk(deletedSession)
 }
 
-func getExtraInfo(session models.SessionCreate) types.Result[map[string]string] {
-	studentResult := Student.GetByID(session.IDStudent)
+func getExtraInfo(ctx context.Context, session models.SessionCreate) types.Result[map[string]string] {
+	ctx, span := tracing.Start(ctx, "db.getExtraInfo")
+	defer span.End()
+
+	studentResult := Student.GetByID(ctx, session.IDStudent)
 	if studentResult.IsErr() {
 		httpErr := studentResult.Error().(*types.HttpError)
-		logger.Warning("Failed to get student by ID: ", httpErr)
+		logger.WithContext(ctx).Warning("Failed to get student by ID: ", httpErr)
 		return types.ResultErr[map[string]string](httpErr)
 	}
 
-	companionResult := Companion.GetByID(session.IDCompanion)
+	companionResult := Companion.GetByID(ctx, session.IDCompanion)
 	if companionResult.IsErr() {
 		httpErr := companionResult.Error().(*types.HttpError)
-		logger.Warning("Failed to get companion by ID: ", httpErr)
+		logger.WithContext(ctx).Warning("Failed to get companion by ID: ", httpErr)
 		return types.ResultErr[map[string]string](httpErr)
 	}
 	student := studentResult.Value()
 	companion := companionResult.Value()
 
-	specialityResult := Speciality.GetByID(companion.IDSpeciality.Hex())
+	specialityResult := Speciality.GetByID(ctx, companion.IDSpeciality.Hex())
 	if specialityResult.IsErr() {
 		httpErr := specialityResult.Error().(*types.HttpError)
-		logger.Warning("Failed to get speciality by ID: ", httpErr)
+		logger.WithContext(ctx).Warning("Failed to get speciality by ID: ", httpErr)
 		return types.ResultErr[map[string]string](httpErr)
 	}
 
@@ -369,13 +454,16 @@ func getExtraInfo(session models.SessionCreate) types.Result[map[string]string]
 	return types.ResultOk(extraInfo)
 }
 
-func getExtraInfoAllowEmpty(session models.SessionCreate) types.Result[map[string]string] {
+func getExtraInfoAllowEmpty(ctx context.Context, session models.SessionCreate) types.Result[map[string]string] {
+	ctx, span := tracing.Start(ctx, "db.getExtraInfoAllowEmpty")
+	defer span.End()
+
 	var student models.StudentDBMongo
 	if session.IDStudent != "" {
-		studentResult := Student.GetByID(session.IDStudent)
+		studentResult := Student.GetByID(ctx, session.IDStudent)
 		if studentResult.IsErr() {
 			httpErr := studentResult.Error().(*types.HttpError)
-			logger.Warning("Failed to get student by ID: ", httpErr)
+			logger.WithContext(ctx).Warning("Failed to get student by ID: ", httpErr)
 			return types.ResultErr[map[string]string](httpErr)
 		}
 
@@ -385,19 +473,19 @@ func getExtraInfoAllowEmpty(session models.SessionCreate) types.Result[map[strin
 	var companion models.CompanionDBMongo
 	var speciality models.SpecialityDBMongo
 	if session.IDCompanion != "" {
-		companionResult := Companion.GetByID(session.IDCompanion)
+		companionResult := Companion.GetByID(ctx, session.IDCompanion)
 		if companionResult.IsErr() {
 			httpErr := companionResult.Error().(*types.HttpError)
-			logger.Warning("Failed to get companion by ID: ", httpErr)
+			logger.WithContext(ctx).Warning("Failed to get companion by ID: ", httpErr)
 			return types.ResultErr[map[string]string](httpErr)
 		}
 
 		companion = companionResult.Value()
 
-		specialityResult := Speciality.GetByID(companion.IDSpeciality.Hex())
+		specialityResult := Speciality.GetByID(ctx, companion.IDSpeciality.Hex())
 		if specialityResult.IsErr() {
 			httpErr := specialityResult.Error().(*types.HttpError)
-			logger.Warning("Failed to get speciality by ID: ", httpErr)
+			logger.WithContext(ctx).Warning("Failed to get speciality by ID: ", httpErr)
 			return types.ResultErr[map[string]string](httpErr)
 		}
 
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// NOTE_VERSION_ATTEMPTS is how many times an edit is written again on top of a concurrent one
+const NOTE_VERSION_ATTEMPTS = 5
+
+type sessionNoteType struct{}
+
+var SessionNote sessionNoteType
+
+// GetHistory returns every version of the notes of the session, oldest first
+func (sessionNoteType) GetHistory(ctx context.Context, sessionID string) types.Result[[]models.SessionNoteDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.SessionNote.GetHistory")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(sessionID)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Session ID: "+sessionID,
+		)
+		return types.ResultErr[[]models.SessionNoteDBMongo](&httpErr)
+	}
+
+	notes := []models.SessionNoteDBMongo{}
+	filter := bson.D{models.Filter.IDOf("session", oid), models.Filter.NotDeleted()}
+	err = configs.DB.FindAll(ctx, filter, &notes)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve session notes:", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve session notes", err.Error())
+		return types.ResultErr[[]models.SessionNoteDBMongo](&httpErr)
+	}
+
+	slices.SortFunc(notes, func(a models.SessionNoteDBMongo, b models.SessionNoteDBMongo) int {
+		return a.Version - b.Version
+	})
+	return types.ResultOk(notes)
+}
+
+// GetLatest returns the current version of the notes of the session
+func (sessionNoteType) GetLatest(ctx context.Context, sessionID string) types.Result[models.SessionNoteDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.SessionNote.GetLatest")
+	defer span.End()
+
+	history := SessionNote.GetHistory(ctx, sessionID)
+	if history.IsErr() {
+		return types.ResultErr[models.SessionNoteDBMongo](history.Error())
+	}
+
+	notes := history.Value()
+	if len(notes) == 0 {
+		httpErr := types.ErrorNotFound(
+			"Session notes not found",
+			"Session with ID "+sessionID+" has no notes",
+		)
+		return types.ResultErr[models.SessionNoteDBMongo](&httpErr)
+	}
+	return types.ResultOk(notes[len(notes)-1])
+}
+
+// Append writes the next version of the notes of the session, the attachments are kept
+func (sessionNoteType) Append(ctx context.Context, sessionID string, note models.SessionNoteCreate, author string) types.Result[models.SessionNoteDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.SessionNote.Append")
+	defer span.End()
+
+	return SessionNote.next(ctx, sessionID, func(previous models.SessionNoteDBMongo, session models.DBID) (models.SessionNoteDBMongo, bool) {
+		return note.ToInsert(previous, session, author)
+	})
+}
+
+// Attach writes the next version of the notes of the session with one more attachment,
+// the first version when the session has no notes yet
+func (sessionNoteType) Attach(ctx context.Context, sessionID string, attachment models.NoteAttachment, author string) types.Result[models.SessionNoteDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.SessionNote.Attach")
+	defer span.End()
+
+	return SessionNote.next(ctx, sessionID, func(previous models.SessionNoteDBMongo, session models.DBID) (models.SessionNoteDBMongo, bool) {
+		return previous.WithAttachment(attachment, session, author), true
+	})
+}
+
+// next inserts the version built from the latest one, a zero note when there is none.
+// A version written meanwhile by a concurrent edit is met by the unique index, the edit is
+// then built again on top of it up to NOTE_VERSION_ATTEMPTS times before answering 409.
+func (sessionNoteType) next(ctx context.Context, sessionID string, build func(models.SessionNoteDBMongo, models.DBID) (models.SessionNoteDBMongo, bool)) types.Result[models.SessionNoteDBMongo] {
+	sessionResult := Session.GetByID(ctx, sessionID)
+	if sessionResult.IsErr() {
+		return types.ResultErr[models.SessionNoteDBMongo](sessionResult.Error())
+	}
+
+	for range NOTE_VERSION_ATTEMPTS {
+		history := SessionNote.GetHistory(ctx, sessionID)
+		if history.IsErr() {
+			return types.ResultErr[models.SessionNoteDBMongo](history.Error())
+		}
+		previous := models.SessionNoteDBMongo{}
+		if notes := history.Value(); len(notes) > 0 {
+			previous = notes[len(notes)-1]
+		}
+
+		note, ok := build(previous, sessionResult.Value().ID)
+		if !ok {
+			httpErr := types.Error(
+				types.Http.C400().UnprocessableEntity(),
+				"Invalid value",
+				"Invalid session note data",
+				"Expected a follow-up date like 2006-01-02",
+			)
+			return types.ResultErr[models.SessionNoteDBMongo](&httpErr)
+		}
+
+		result, err := configs.DB.InsertOne(ctx, note)
+		if configs.IsDuplicateKey(err) {
+			logger.WithContext(ctx).Debug("Session", sessionID, "notes version", note.Version, "written meanwhile, building on it")
+			continue
+		}
+		if err != nil {
+			logger.WithContext(ctx).Error("Failed to create session note in MongoDB: ", err)
+			httpErr := errorFrom(ctx, err, "Failed to create session note", err.Error())
+			return types.ResultErr[models.SessionNoteDBMongo](&httpErr)
+		}
+
+		note.ID, err = models.ID.ToDB(result.InsertedID)
+		if err != nil {
+			logger.WithContext(ctx).Error("Failed to convert inserted ID to ObjectID: ", err)
+			httpErr := types.ErrorInternal(
+				"Failed to create session note",
+				"Failed to convert inserted ID to ObjectID",
+				"Error: "+err.Error(),
+			)
+			return types.ResultErr[models.SessionNoteDBMongo](&httpErr)
+		}
+
+		logger.WithContext(ctx).Debug("Session", sessionID, "notes at version", note.Version)
+		return types.ResultOk(note)
+	}
+
+	httpErr := types.Error(
+		types.Http.C400().Conflict(),
+		"Session notes edited concurrently",
+		"Other edits kept writing the next version, read the notes and try again",
+		"Session ID: "+sessionID,
+	)
+	return types.ResultErr[models.SessionNoteDBMongo](&httpErr)
+}
@@ -1,9 +1,11 @@
 package db
 
 import (
+	"context"
 	"dainxor/atv/configs"
 	"dainxor/atv/logger"
 	"dainxor/atv/models"
+	"dainxor/atv/tracing"
 	"dainxor/atv/types"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -14,19 +16,23 @@ type sessionTypeType struct{}
 
 var SessionType sessionTypeType
 
-func (sessionTypeType) Create(u models.SessionTypeCreate) types.Result[models.SessionTypeDBMongo] {
+func (sessionTypeType) Create(ctx context.Context, u models.SessionTypeCreate) types.Result[models.SessionTypeDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.SessionType.Create")
+	defer span.End()
+
 	sessionTypeDB := u.ToInsert()
-	result, err := configs.DB.InsertOne(sessionTypeDB)
+	result, err := configs.DB.InsertOne(ctx, sessionTypeDB)
 
 	if err != nil {
-		logger.Error("Error inserting session type: ", err)
-		return types.ResultErr[models.SessionTypeDBMongo](err)
+		logger.WithContext(ctx).Error("Error inserting session type: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create session type", err.Error())
+		return types.ResultErr[models.SessionTypeDBMongo](&httpErr)
 	}
 
 	sessionTypeDB.ID, err = models.DBIDFrom(result.InsertedID)
 
 	if err != nil {
-		logger.Error("Error converting inserted ID to PrimitiveID: ", err)
+		logger.WithContext(ctx).Error("Error converting inserted ID to PrimitiveID: ", err)
 		httpErr := types.ErrorInternal(
 			"Failed to create session type",
 			"Failed to convert inserted ID to PrimitiveID",
@@ -38,11 +44,14 @@ func (sessionTypeType) Create(u models.SessionTypeCreate) types.Result[models.Se
 	return types.ResultOk(sessionTypeDB)
 }
 
-func (sessionTypeType) GetByID(id string) types.Result[models.SessionTypeDBMongo] {
+func (sessionTypeType) GetByID(ctx context.Context, id string) types.Result[models.SessionTypeDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.SessionType.GetByID")
+	defer span.End()
+
 	oid, err := models.BsonIDFrom(id)
 
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -55,19 +64,19 @@ func (sessionTypeType) GetByID(id string) types.Result[models.SessionTypeDBMongo
 	filter := bson.D{{Key: "_id", Value: oid}}
 	var sessionType models.SessionTypeDBMongo
 
-	err = configs.DB.FindOne(filter, &sessionType)
+	err = configs.DB.FindOne(ctx, filter, &sessionType)
 	if err != nil {
 		var httpErr types.HttpError
 
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get session type by ID: ", err)
+			logger.WithContext(ctx).Error("Failed to get session type by ID: ", err)
 			httpErr = types.ErrorNotFound(
 				"SessionType not found",
 				"SessionType with ID "+id+" not found",
 			)
 		} else {
-			logger.Error("Failed to get session type by ID: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get session type by ID: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve session type",
 				"Decoding error",
 				err.Error(),
@@ -80,14 +89,17 @@ func (sessionTypeType) GetByID(id string) types.Result[models.SessionTypeDBMongo
 
 	return types.ResultOk(sessionType)
 }
-func (sessionTypeType) GetAll() types.Result[[]models.SessionTypeDBMongo] {
-	filter := bson.D{{Key: "deleted_at", Value: models.Time.Zero()}} // Filter to exclude deleted session types
+func (sessionTypeType) GetAll(ctx context.Context) types.Result[[]models.SessionTypeDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.SessionType.GetAll")
+	defer span.End()
+
+	filter := bson.D{{Key: "deleted_at", Value: nil}} // Filter to exclude deleted session types, deleted_at is omitted while empty
 	sessionTypes := []models.SessionTypeDBMongo{}
 
-	err := configs.DB.FindAll(filter, &sessionTypes)
+	err := configs.DB.FindAll(ctx, filter, &sessionTypes)
 	if err != nil {
-		logger.Error("Failed to get all session types from MongoDB:", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to get all session types from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve session types",
 			err.Error(),
 		)
@@ -95,6 +107,6 @@ func (sessionTypeType) GetAll() types.Result[[]models.SessionTypeDBMongo] {
 		return types.ResultErr[[]models.SessionTypeDBMongo](&httpErr)
 	}
 
-	logger.Debug("Retrieved", len(sessionTypes), "session types from MongoDB database")
+	logger.WithContext(ctx).Debug("Retrieved", len(sessionTypes), "session types from MongoDB database")
 	return types.ResultOk(sessionTypes)
 }
@@ -1,9 +1,11 @@
 package db
 
 import (
+	"context"
 	"dainxor/atv/configs"
 	"dainxor/atv/logger"
 	"dainxor/atv/models"
+	"dainxor/atv/tracing"
 	"dainxor/atv/types"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -14,19 +16,23 @@ type specialityType struct{}
 
 var Speciality specialityType
 
-func (specialityType) Create(u models.SpecialityCreate) types.Result[models.SpecialityDBMongo] {
+func (specialityType) Create(ctx context.Context, u models.SpecialityCreate) types.Result[models.SpecialityDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Speciality.Create")
+	defer span.End()
+
 	specialityDB := u.ToInsert()
-	result, err := configs.DB.InsertOne(specialityDB)
+	result, err := configs.DB.InsertOne(ctx, specialityDB)
 
 	if err != nil {
-		logger.Error("Error inserting speciality: ", err)
-		return types.ResultErr[models.SpecialityDBMongo](err)
+		logger.WithContext(ctx).Error("Error inserting speciality: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create speciality", err.Error())
+		return types.ResultErr[models.SpecialityDBMongo](&httpErr)
 	}
 
 	specialityDB.ID, err = models.DBIDFrom(result.InsertedID)
 
 	if err != nil {
-		logger.Error("Error converting inserted ID to PrimitiveID: ", err)
+		logger.WithContext(ctx).Error("Error converting inserted ID to PrimitiveID: ", err)
 		httpErr := types.ErrorInternal(
 			"Failed to create speciality",
 			"Failed to convert inserted ID to PrimitiveID",
@@ -38,11 +44,14 @@ func (specialityType) Create(u models.SpecialityCreate) types.Result[models.Spec
 	return types.ResultOk(specialityDB)
 }
 
-func (specialityType) GetByID(id string) types.Result[models.SpecialityDBMongo] {
+func (specialityType) GetByID(ctx context.Context, id string) types.Result[models.SpecialityDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Speciality.GetByID")
+	defer span.End()
+
 	oid, err := models.ID.ToBson(id)
 
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -55,19 +64,19 @@ func (specialityType) GetByID(id string) types.Result[models.SpecialityDBMongo]
 	filter := bson.D{{Key: "_id", Value: oid}}
 	var speciality models.SpecialityDBMongo
 
-	err = configs.DB.FindOne(filter, &speciality)
+	err = configs.DB.FindOne(ctx, filter, &speciality)
 	if err != nil {
 		var httpErr types.HttpError
 
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get speciality by ID: ", err)
+			logger.WithContext(ctx).Error("Failed to get speciality by ID: ", err)
 			httpErr = types.ErrorNotFound(
 				"Speciality not found",
 				"Speciality with ID "+id+" not found",
 			)
 		} else {
-			logger.Error("Failed to get speciality by ID: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get speciality by ID: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve speciality",
 				"Decoding error",
 				err.Error(),
@@ -80,14 +89,17 @@ func (specialityType) GetByID(id string) types.Result[models.SpecialityDBMongo]
 
 	return types.ResultOk(speciality)
 }
-func (specialityType) GetAll() types.Result[[]models.SpecialityDBMongo] {
+func (specialityType) GetAll(ctx context.Context) types.Result[[]models.SpecialityDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Speciality.GetAll")
+	defer span.End()
+
 	filter := bson.D{{Key: "deleted_at", Value: nil}} // Filter to exclude deleted specialities
 	specialities := []models.SpecialityDBMongo{}
 
-	err := configs.DB.FindAll(filter, &specialities)
+	err := configs.DB.FindAll(ctx, filter, &specialities)
 	if err != nil {
-		logger.Error("Failed to get all specialities from MongoDB:", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to get all specialities from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve specialities",
 			err.Error(),
 		)
@@ -95,6 +107,6 @@ func (specialityType) GetAll() types.Result[[]models.SpecialityDBMongo] {
 		return types.ResultErr[[]models.SpecialityDBMongo](&httpErr)
 	}
 
-	logger.Debug("Retrieved", len(specialities), "specialities from MongoDB database")
+	logger.WithContext(ctx).Debug("Retrieved", len(specialities), "specialities from MongoDB database")
 	return types.ResultOk(specialities)
 }
@@ -1,9 +1,11 @@
 package db
 
 import (
+	"context"
 	"dainxor/atv/configs"
 	"dainxor/atv/logger"
 	"dainxor/atv/models"
+	"dainxor/atv/tracing"
 	"dainxor/atv/types"
 	"time"
 
@@ -15,10 +17,13 @@ type studentType struct{}
 
 var Student studentType
 
-func (studentType) Create(student models.StudentCreate) types.Result[models.StudentDBMongo] {
+func (studentType) Create(ctx context.Context, student models.StudentCreate) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.Create")
+	defer span.End()
+
 	studentDB := student.ToInsert()
 	if studentDB.IsEmpty() {
-		logger.Error("Error converting student to DB model")
+		logger.WithContext(ctx).Error("Error converting student to DB model")
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -28,17 +33,18 @@ func (studentType) Create(student models.StudentCreate) types.Result[models.Stud
 		return types.ResultErr[models.StudentDBMongo](&httpErr)
 	}
 
-	result, err := configs.DB.InsertOne(studentDB)
+	result, err := configs.DB.InsertOne(ctx, studentDB)
 
 	if err != nil {
-		logger.Error("Failed to create student in MongoDB: ", err)
-		return types.ResultErr[models.StudentDBMongo](err)
+		logger.WithContext(ctx).Error("Failed to create student in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create student", err.Error())
+		return types.ResultErr[models.StudentDBMongo](&httpErr)
 	}
 
 	studentDB.ID, err = models.ID.ToDB(result.InsertedID)
 
 	if err != nil {
-		logger.Error("Failed to convert inserted ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert inserted ID to ObjectID: ", err)
 		httpErr := types.ErrorInternal(
 			"Failed to create student",
 			"Failed to convert inserted ID to ObjectID",
@@ -51,11 +57,14 @@ func (studentType) Create(student models.StudentCreate) types.Result[models.Stud
 	return types.ResultOk(studentDB)
 }
 
-func (studentType) GetByID(id string) types.Result[models.StudentDBMongo] {
+func (studentType) GetByID(ctx context.Context, id string) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.GetByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -68,19 +77,19 @@ func (studentType) GetByID(id string) types.Result[models.StudentDBMongo] {
 	filter := bson.D{{Key: "_id", Value: oid}}
 	var student models.StudentDBMongo
 
-	err = configs.DB.FindOne(filter, &student)
+	err = configs.DB.FindOne(ctx, filter, &student)
 	if err != nil {
 		var httpErr types.HttpError
 
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get student by ID: ", err)
+			logger.WithContext(ctx).Error("Failed to get student by ID: ", err)
 			httpErr = types.ErrorNotFound(
 				"Student not found",
 				"Student with ID "+id+" not found",
 			)
 		} else {
-			logger.Error("Failed to get student by ID: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get student by ID: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve student",
 				"Decoding error",
 				err.Error(),
@@ -93,22 +102,25 @@ func (studentType) GetByID(id string) types.Result[models.StudentDBMongo] {
 
 	return types.ResultOk(student)
 }
-func (studentType) GetByNumberID(idNumber string) types.Result[models.StudentDBMongo] {
-	filter := bson.D{{Key: "id_number", Value: idNumber}}
+func (studentType) GetByNumberID(ctx context.Context, idNumber string) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.GetByNumberID")
+	defer span.End()
+
+	filter := bson.D{{Key: "number_id", Value: idNumber}, models.Filter.NotDeleted()}
 	var student models.StudentDBMongo
 
-	err := configs.DB.FindOne(filter, &student)
+	err := configs.DB.FindOne(ctx, filter, &student)
 	if err != nil {
 		var httpErr types.HttpError
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get student by ID number: ", err)
+			logger.WithContext(ctx).Error("Failed to get student by ID number: ", err)
 			httpErr = types.ErrorNotFound(
 				"Student not found",
 				"Student with ID number "+idNumber+" not found",
 			)
 		} else {
-			logger.Error("Failed to get student by ID number: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get student by ID number: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve student",
 				"Decoding error",
 				err.Error(),
@@ -121,23 +133,33 @@ func (studentType) GetByNumberID(idNumber string) types.Result[models.StudentDBM
 
 	return types.ResultOk(student)
 }
-func (studentType) GetByEmail(email string) types.Result[models.StudentDBMongo] {
-	filter := bson.D{{Key: "email", Value: email}}
+func (studentType) GetByEmail(ctx context.Context, email string) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.GetByEmail")
+	defer span.End()
+
+	// Either email of the record, personal or institutional
+	filter := bson.D{
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "email", Value: email}},
+			bson.D{{Key: "institution_email", Value: email}},
+		}},
+		models.Filter.NotDeleted(),
+	}
 	var student models.StudentDBMongo
 
-	err := configs.DB.FindOne(filter, &student)
+	err := configs.DB.FindOne(ctx, filter, &student)
 	if err != nil {
 		var httpErr types.HttpError
 
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get student by email: ", err)
+			logger.WithContext(ctx).Error("Failed to get student by email: ", err)
 			httpErr = types.ErrorNotFound(
 				"Student not found",
 				"Student with email "+email+" not found",
 			)
 		} else {
-			logger.Error("Failed to get student by email: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get student by email: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve student",
 				"Decoding error",
 				err.Error(),
@@ -150,14 +172,17 @@ func (studentType) GetByEmail(email string) types.Result[models.StudentDBMongo]
 
 	return types.ResultOk(student)
 }
-func (studentType) GetAll() types.Result[[]models.StudentDBMongo] {
+func (studentType) GetAll(ctx context.Context) types.Result[[]models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.GetAll")
+	defer span.End()
+
 	filter := bson.D{{Key: "deleted_at", Value: models.Time.Zero()}} // Filter to exclude deleted students
 	students := []models.StudentDBMongo{}
 
-	err := configs.DB.FindAll(filter, &students)
+	err := configs.DB.FindAll(ctx, filter, &students)
 	if err != nil {
-		logger.Error("Failed to get all students from MongoDB:", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to get all students from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve students",
 			err.Error(),
 		)
@@ -165,14 +190,17 @@ func (studentType) GetAll() types.Result[[]models.StudentDBMongo] {
 		return types.ResultErr[[]models.StudentDBMongo](&httpErr)
 	}
 
-	logger.Debug("Retrieved", len(students), "students from MongoDB database")
+	logger.WithContext(ctx).Debug("Retrieved", len(students), "students from MongoDB database")
 	return types.ResultOk(students)
 }
 
-func (studentType) UpdateByID(id string, student models.StudentCreate) types.Result[models.StudentDBMongo] {
+func (studentType) UpdateByID(ctx context.Context, id string, student models.StudentCreate) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.UpdateByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -186,12 +214,12 @@ func (studentType) UpdateByID(id string, student models.StudentCreate) types.Res
 	filter := bson.D{{Key: "_id", Value: oid}}
 	update := bson.D{{Key: "$set", Value: studentDB}}
 
-	result := configs.DB.PatchOne(filter, update, &studentDB)
+	result := configs.DB.PatchOne(ctx, filter, update, &studentDB)
 
 	if result.IsErr() {
 		err := result.Error()
-		logger.Error("Failed to update student in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to update student in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to update student",
 			err.Error(),
 			"Student ID: "+id,
@@ -208,7 +236,7 @@ func (studentType) UpdateByID(id string, student models.StudentCreate) types.Res
 	}
 
 	if result.Value().ModifiedCount == 0 {
-		logger.Info("No changes made to student with ID: ", id)
+		logger.WithContext(ctx).Info("No changes made to student with ID: ", id)
 		httpErr := types.Error(
 			types.Http.C300().NotModified(),
 			"No changes made",
@@ -220,10 +248,13 @@ func (studentType) UpdateByID(id string, student models.StudentCreate) types.Res
 	return types.ResultOk(studentDB)
 }
 
-func (studentType) PatchByID(id string, student models.StudentCreate) types.Result[models.StudentDBMongo] {
+func (studentType) PatchByID(ctx context.Context, id string, student models.StudentCreate) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.PatchByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -235,7 +266,7 @@ func (studentType) PatchByID(id string, student models.StudentCreate) types.Resu
 
 	studentDB := student.ToUpdate()
 	if studentDB == (models.StudentDBMongo{}) {
-		logger.Error("Error converting student to DB model")
+		logger.WithContext(ctx).Error("Error converting student to DB model")
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -248,12 +279,12 @@ func (studentType) PatchByID(id string, student models.StudentCreate) types.Resu
 	filter := bson.D{{Key: "_id", Value: oid}}
 	update := bson.D{{Key: "$set", Value: studentDB}}
 
-	result := configs.DB.PatchOne(filter, update, &studentDB)
+	result := configs.DB.PatchOne(ctx, filter, update, &studentDB)
 
 	if result.IsErr() {
 		err := result.Error()
-		logger.Error("Failed to update student in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to update student in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to update student",
 			err.Error(),
 			"Student ID: "+id,
@@ -270,7 +301,7 @@ func (studentType) PatchByID(id string, student models.StudentCreate) types.Resu
 	}
 
 	if result.Value().ModifiedCount == 0 {
-		logger.Info("No changes made to student with ID: ", id)
+		logger.WithContext(ctx).Info("No changes made to student with ID: ", id)
 		logger.Lava("0.1.2", "Send a more proper code for no changes made")
 		httpErr := types.Error(
 			types.Http.C200().Accepted(),
@@ -283,10 +314,13 @@ func (studentType) PatchByID(id string, student models.StudentCreate) types.Resu
 	return types.ResultOk(studentDB)
 }
 
-func (studentType) DeleteByID(id string) types.Result[models.StudentDBMongo] {
+func (studentType) DeleteByID(ctx context.Context, id string) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.DeleteByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -300,10 +334,10 @@ func (studentType) DeleteByID(id string) types.Result[models.StudentDBMongo] {
 	update := bson.D{{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: models.Time.Now()}}}}
 
 	var deletedStudent models.StudentDBMongo
-	result := configs.DB.UpdateOne(filter, update, &deletedStudent)
+	result := configs.DB.UpdateOne(ctx, filter, update, &deletedStudent)
 	if result.IsErr() {
-		logger.Error("Failed to delete student in MongoDB: ", result.Error())
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to delete student in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(),
 			"Failed to delete student",
 			result.Error().Error(),
 			"Student ID: "+id,
@@ -319,10 +353,10 @@ func (studentType) DeleteByID(id string) types.Result[models.StudentDBMongo] {
 		return types.ResultErr[models.StudentDBMongo](&httpErr)
 	}
 
-	err = configs.DB.FindOne(filter, &deletedStudent)
+	err = configs.DB.FindOne(ctx, filter, &deletedStudent)
 	if err != nil {
-		logger.Error("Failed to retrieve deleted student: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to retrieve deleted student: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve deleted student",
 			err.Error(),
 			"Student ID: "+id,
@@ -332,11 +366,14 @@ func (studentType) DeleteByID(id string) types.Result[models.StudentDBMongo] {
 
 	return types.ResultOk(deletedStudent)
 }
-func (studentType) DeletePermanentByID(id string) types.Result[models.StudentDBMongo] {
-	logger.Warning("Permanently deleting student by ID: ", id)
+func (studentType) DeletePermanentByID(ctx context.Context, id string) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.DeletePermanentByID")
+	defer span.End()
+
+	logger.WithContext(ctx).Warning("Permanently deleting student by ID: ", id)
 	oid, err := models.BsonIDFrom(id)
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.UnprocessableEntity(),
 			"Invalid value",
@@ -347,13 +384,11 @@ func (studentType) DeletePermanentByID(id string) types.Result[models.StudentDBM
 	}
 
 	filter := bson.D{{Key: "_id", Value: oid}, {Key: "deleted_at", Value: bson.M{"$ne": time.Time{}}}} // Ensure the student is marked as deleted
-	ctx, cancel := configs.DB.Context()
-	defer cancel()
 
 	var student models.StudentDBMongo
-	err = configs.DB.FindOne(filter, &student)
+	err = configs.DB.FindOne(ctx, filter, &student)
 	if err != nil {
-		logger.Debug("Failed to find student for permanent deletion: ", err)
+		logger.WithContext(ctx).Debug("Failed to find student for permanent deletion: ", err)
 
 		if err == mongo.ErrNoDocuments {
 			httpErr := types.ErrorNotFound(
@@ -363,7 +398,7 @@ func (studentType) DeletePermanentByID(id string) types.Result[models.StudentDBM
 			return types.ResultErr[models.StudentDBMongo](&httpErr)
 		}
 
-		httpErr := types.ErrorInternal(
+		httpErr := errorFrom(ctx, err,
 			"Failed to find student for permanent deletion",
 			err.Error(),
 			"Student ID: "+id,
@@ -371,10 +406,10 @@ func (studentType) DeletePermanentByID(id string) types.Result[models.StudentDBM
 		return types.ResultErr[models.StudentDBMongo](&httpErr)
 	}
 
-	result, err := configs.DB.From(models.StudentDBMongo{}).DeleteOne(ctx, filter)
+	result, err := configs.DB.DeleteOne(ctx, filter, models.StudentDBMongo{})
 	if err != nil {
-		logger.Debug("Failed to permanently delete student in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Debug("Failed to permanently delete student in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to permanently delete student",
 			err.Error(),
 			"Student ID: "+id,
@@ -393,15 +428,16 @@ func (studentType) DeletePermanentByID(id string) types.Result[models.StudentDBM
 
 	return types.ResultOk(student)
 }
-func (studentType) DeletePermanentAll() types.Result[[]models.StudentDBMongo] {
+func (studentType) DeletePermanentAll(ctx context.Context) types.Result[[]models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.DeletePermanentAll")
+	defer span.End()
+
 	filter := bson.D{{Key: "deleted_at", Value: bson.M{"$ne": nil}}}
-	ctx, cancel := configs.DB.Context()
-	defer cancel()
 
-	result, err := configs.DB.From(models.StudentDBMongo{}).DeleteMany(ctx, filter)
+	result, err := configs.DB.DeleteMany(ctx, filter, models.StudentDBMongo{})
 	if err != nil {
-		logger.Error("Failed to permanently delete all students in MongoDB: ", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to permanently delete all students in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to permanently delete all students",
 			err.Error(),
 		)
@@ -418,3 +454,30 @@ func (studentType) DeletePermanentAll() types.Result[[]models.StudentDBMongo] {
 
 	return types.ResultOk([]models.StudentDBMongo{})
 }
+
+// SetPhoto replaces the profile photo of the student, returning the updated student.
+// The blobs of the previous photo are left to the caller.
+func (studentType) SetPhoto(ctx context.Context, id string, photo models.PhotoDB) types.Result[models.StudentDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Student.SetPhoto")
+	defer span.End()
+
+	current := Student.GetByID(ctx, id)
+	if current.IsErr() {
+		return current
+	}
+
+	filter := bson.D{models.Filter.ID(current.Value().ID), models.Filter.NotDeleted()}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "photo", Value: photo},
+		{Key: "updated_at", Value: photo.UpdatedAt},
+	}}}
+
+	var student models.StudentDBMongo
+	result := configs.DB.UpdateOne(ctx, filter, update, &student)
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to set student photo in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to set student photo", result.Error().Error())
+		return types.ResultErr[models.StudentDBMongo](&httpErr)
+	}
+	return types.ResultOk(student)
+}
@@ -1,9 +1,11 @@
 package db
 
 import (
+	"context"
 	"dainxor/atv/configs"
 	"dainxor/atv/logger"
 	"dainxor/atv/models"
+	"dainxor/atv/tracing"
 	"dainxor/atv/types"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -14,19 +16,23 @@ type universityType struct{}
 
 var University universityType
 
-func (universityType) Create(u models.UniversityCreate) types.Result[models.UniversityDBMongo] {
+func (universityType) Create(ctx context.Context, u models.UniversityCreate) types.Result[models.UniversityDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.University.Create")
+	defer span.End()
+
 	universityDB := u.ToInsert()
-	result, err := configs.DB.InsertOne(universityDB)
+	result, err := configs.DB.InsertOne(ctx, universityDB)
 
 	if err != nil {
-		logger.Error("Error inserting university: ", err)
-		return types.ResultErr[models.UniversityDBMongo](err)
+		logger.WithContext(ctx).Error("Error inserting university: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create university", err.Error())
+		return types.ResultErr[models.UniversityDBMongo](&httpErr)
 	}
 
 	universityDB.ID, err = models.ID.ToDB(result.InsertedID)
 
 	if err != nil {
-		logger.Error("Error converting inserted ID to PrimitiveID: ", err)
+		logger.WithContext(ctx).Error("Error converting inserted ID to PrimitiveID: ", err)
 		httpErr := types.ErrorInternal(
 			"Failed to create university",
 			"Failed to convert inserted ID to PrimitiveID",
@@ -38,11 +44,14 @@ func (universityType) Create(u models.UniversityCreate) types.Result[models.Univ
 	return types.ResultOk(universityDB)
 }
 
-func (universityType) GetByID(id string) types.Result[models.UniversityDBMongo] {
+func (universityType) GetByID(ctx context.Context, id string) types.Result[models.UniversityDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.University.GetByID")
+	defer span.End()
+
 	oid, err := models.ID.ToDB(id)
 
 	if err != nil {
-		logger.Error("Failed to convert ID to ObjectID: ", err)
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
 		httpErr := types.Error(
 			types.Http.C400().UnprocessableEntity(),
 			"Invalid value",
@@ -55,19 +64,19 @@ func (universityType) GetByID(id string) types.Result[models.UniversityDBMongo]
 	filter := bson.D{{Key: "_id", Value: oid}}
 	var university models.UniversityDBMongo
 
-	err = configs.DB.FindOne(filter, &university)
+	err = configs.DB.FindOne(ctx, filter, &university)
 	if err != nil {
 		var httpErr types.HttpError
 
 		if err == mongo.ErrNoDocuments {
-			logger.Error("Failed to get university by ID: ", err)
+			logger.WithContext(ctx).Error("Failed to get university by ID: ", err)
 			httpErr = types.ErrorNotFound(
 				"University not found",
 				"University with ID "+id+" not found",
 			)
 		} else {
-			logger.Error("Failed to get university by ID: ", err)
-			httpErr = types.ErrorInternal(
+			logger.WithContext(ctx).Error("Failed to get university by ID: ", err)
+			httpErr = errorFrom(ctx, err,
 				"Failed to retrieve university",
 				"Decoding error",
 				err.Error(),
@@ -80,14 +89,17 @@ func (universityType) GetByID(id string) types.Result[models.UniversityDBMongo]
 
 	return types.ResultOk(university)
 }
-func (universityType) GetAll() types.Result[[]models.UniversityDBMongo] {
+func (universityType) GetAll(ctx context.Context) types.Result[[]models.UniversityDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.University.GetAll")
+	defer span.End()
+
 	filter := bson.D{{Key: "deleted_at", Value: nil}} // Filter to exclude deleted universities
 	universities := []models.UniversityDBMongo{}
 
-	err := configs.DB.FindAll(filter, &universities)
+	err := configs.DB.FindAll(ctx, filter, &universities)
 	if err != nil {
-		logger.Error("Failed to get all universities from MongoDB:", err)
-		httpErr := types.ErrorInternal(
+		logger.WithContext(ctx).Error("Failed to get all universities from MongoDB:", err)
+		httpErr := errorFrom(ctx, err,
 			"Failed to retrieve universities",
 			err.Error(),
 		)
@@ -95,6 +107,6 @@ func (universityType) GetAll() types.Result[[]models.UniversityDBMongo] {
 		return types.ResultErr[[]models.UniversityDBMongo](&httpErr)
 	}
 
-	logger.Debug("Retrieved", len(universities), "universities from MongoDB database")
+	logger.WithContext(ctx).Debug("Retrieved", len(universities), "universities from MongoDB database")
 	return types.ResultOk(universities)
 }
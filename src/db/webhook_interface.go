@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"errors"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type webhookType struct{}
+
+var Webhook webhookType
+
+// Create subscribes the URL to the events, signing the deliveries with secret
+func (webhookType) Create(ctx context.Context, body models.WebhookCreate, secret string) types.Result[models.WebhookDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Webhook.Create")
+	defer span.End()
+
+	webhook, ok := body.ToInsert(secret)
+	if !ok {
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid webhook subscription",
+			"Expected an http or https URL and events among "+strings.Join(models.SESSION_EVENTS, ", "),
+		)
+		return types.ResultErr[models.WebhookDBMongo](&httpErr)
+	}
+
+	result, err := configs.DB.InsertOne(ctx, webhook)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to create webhook in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to create webhook", err.Error())
+		return types.ResultErr[models.WebhookDBMongo](&httpErr)
+	}
+	webhook.ID, _ = models.ID.ToDB(result.InsertedID)
+	return types.ResultOk(webhook)
+}
+
+func (webhookType) GetByID(ctx context.Context, id string) types.Result[models.WebhookDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Webhook.GetByID")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Webhook ID: "+id,
+		)
+		return types.ResultErr[models.WebhookDBMongo](&httpErr)
+	}
+
+	var webhook models.WebhookDBMongo
+	err = configs.DB.FindOne(ctx, bson.D{models.Filter.ID(oid), models.Filter.NotDeleted()}, &webhook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		httpErr := types.ErrorNotFound(
+			"Webhook not found",
+			"Webhook with ID "+id+" not found",
+		)
+		return types.ResultErr[models.WebhookDBMongo](&httpErr)
+	}
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve webhook: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve webhook", err.Error())
+		return types.ResultErr[models.WebhookDBMongo](&httpErr)
+	}
+	return types.ResultOk(webhook)
+}
+
+// GetAll returns the subscriptions not deleted, oldest first
+func (webhookType) GetAll(ctx context.Context) types.Result[[]models.WebhookDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Webhook.GetAll")
+	defer span.End()
+
+	webhooks := []models.WebhookDBMongo{}
+	err := configs.DB.FindAll(ctx, bson.D{models.Filter.NotDeleted()}, &webhooks)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve webhooks:", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve webhooks", err.Error())
+		return types.ResultErr[[]models.WebhookDBMongo](&httpErr)
+	}
+
+	slices.SortStableFunc(webhooks, func(a models.WebhookDBMongo, b models.WebhookDBMongo) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return types.ResultOk(webhooks)
+}
+
+// GetAllByEvent returns the subscriptions listening to the event
+func (webhookType) GetAllByEvent(ctx context.Context, event string) types.Result[[]models.WebhookDBMongo] {
+	webhooks := Webhook.GetAll(ctx)
+	if webhooks.IsErr() {
+		return webhooks
+	}
+	return types.ResultOk(slices.DeleteFunc(webhooks.Value(), func(webhook models.WebhookDBMongo) bool {
+		return !webhook.Wants(event)
+	}))
+}
+
+// DeleteByID unsubscribes the webhook, its deliveries are kept
+func (webhookType) DeleteByID(ctx context.Context, id string) types.Result[models.WebhookDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Webhook.DeleteByID")
+	defer span.End()
+
+	webhook := Webhook.GetByID(ctx, id)
+	if webhook.IsErr() {
+		return webhook
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: models.Time.Now()}}}}
+	var deleted models.WebhookDBMongo
+	result := configs.DB.PatchOne(ctx, bson.D{models.Filter.ID(webhook.Value().ID)}, update, &deleted)
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to delete webhook in MongoDB: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to delete webhook", result.Error().Error(), "Webhook ID: "+id)
+		return types.ResultErr[models.WebhookDBMongo](&httpErr)
+	}
+	return types.ResultOk(deleted)
+}
+
+// CreateDelivery records a delivery about to be sent
+func (webhookType) CreateDelivery(ctx context.Context, delivery models.WebhookDeliveryDBMongo) types.Result[models.WebhookDeliveryDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Webhook.CreateDelivery")
+	defer span.End()
+
+	result, err := configs.DB.InsertOne(ctx, delivery)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to record webhook delivery in MongoDB: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to record webhook delivery", err.Error())
+		return types.ResultErr[models.WebhookDeliveryDBMongo](&httpErr)
+	}
+	delivery.ID, _ = models.ID.ToDB(result.InsertedID)
+	return types.ResultOk(delivery)
+}
+
+func (webhookType) GetDeliveryByID(ctx context.Context, id string) types.Result[models.WebhookDeliveryDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Webhook.GetDeliveryByID")
+	defer span.End()
+
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to convert ID to ObjectID: ", err)
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Delivery ID: "+id,
+		)
+		return types.ResultErr[models.WebhookDeliveryDBMongo](&httpErr)
+	}
+
+	var delivery models.WebhookDeliveryDBMongo
+	err = configs.DB.FindOne(ctx, bson.D{models.Filter.ID(oid)}, &delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		httpErr := types.ErrorNotFound(
+			"Delivery not found",
+			"Webhook delivery with ID "+id+" not found",
+		)
+		return types.ResultErr[models.WebhookDeliveryDBMongo](&httpErr)
+	}
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve webhook delivery: ", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve webhook delivery", err.Error())
+		return types.ResultErr[models.WebhookDeliveryDBMongo](&httpErr)
+	}
+	return types.ResultOk(delivery)
+}
+
+// GetDeliveriesByWebhookID returns the delivery log of the subscription, newest first
+func (webhookType) GetDeliveriesByWebhookID(ctx context.Context, id string) types.Result[[]models.WebhookDeliveryDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Webhook.GetDeliveriesByWebhookID")
+	defer span.End()
+
+	webhook := Webhook.GetByID(ctx, id)
+	if webhook.IsErr() {
+		return types.ResultErr[[]models.WebhookDeliveryDBMongo](webhook.Error())
+	}
+
+	deliveries := []models.WebhookDeliveryDBMongo{}
+	err := configs.DB.FindAll(ctx, bson.D{models.Filter.IDOf("webhook", webhook.Value().ID)}, &deliveries)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to retrieve webhook deliveries:", err)
+		httpErr := errorFrom(ctx, err, "Failed to retrieve webhook deliveries", err.Error())
+		return types.ResultErr[[]models.WebhookDeliveryDBMongo](&httpErr)
+	}
+
+	slices.SortStableFunc(deliveries, func(a models.WebhookDeliveryDBMongo, b models.WebhookDeliveryDBMongo) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return types.ResultOk(deliveries)
+}
+
+// SaveDelivery stores the outcome of an attempt
+func (webhookType) SaveDelivery(ctx context.Context, delivery models.WebhookDeliveryDBMongo) types.Result[models.WebhookDeliveryDBMongo] {
+	ctx, span := tracing.Start(ctx, "db.Webhook.SaveDelivery")
+	defer span.End()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: delivery.Status},
+		{Key: "attempts", Value: delivery.Attempts},
+		{Key: "response_status", Value: delivery.ResponseStatus},
+		{Key: "last_error", Value: delivery.LastError},
+		{Key: "updated_at", Value: delivery.UpdatedAt},
+		{Key: "delivered_at", Value: delivery.DeliveredAt},
+	}}}
+	var saved models.WebhookDeliveryDBMongo
+	result := configs.DB.UpdateOne(ctx, bson.D{models.Filter.ID(delivery.ID)}, update, &saved)
+	if result.IsErr() {
+		logger.WithContext(ctx).Error("Failed to save webhook delivery: ", result.Error())
+		httpErr := errorFrom(ctx, result.Error(), "Failed to save webhook delivery", result.Error().Error())
+		return types.ResultErr[models.WebhookDeliveryDBMongo](&httpErr)
+	}
+	return types.ResultOk(saved)
+}
@@ -0,0 +1,147 @@
+package health
+
+import (
+	"cmp"
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"dainxor/atv/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DEFAULT_CHECK_TIMEOUT = 2 * time.Second // Default time each dependency has to answer
+
+	STATUS_UP   = "up"
+	STATUS_DOWN = "down"
+)
+
+// Dependency is something the service needs to serve requests
+type Dependency struct {
+	Name     string
+	Check    func(ctx context.Context) error
+	Describe func() map[string]string // Optional, extra information shown on the report
+}
+
+// CheckResult is the outcome of checking a single dependency
+type CheckResult struct {
+	Status    string            `json:"status"`
+	LatencyMS float64           `json:"latency_ms"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Report is the outcome of checking every registered dependency
+type Report struct {
+	Ready    bool                   `json:"ready"`
+	Draining bool                   `json:"draining"`
+	Checks   map[string]CheckResult `json:"checks"`
+}
+
+type registryType struct {
+	mutex        sync.RWMutex
+	dependencies []Dependency
+	timeout      time.Duration
+	draining     atomic.Bool
+}
+
+var registry registryType
+
+func init() {
+	envInit()
+
+	Register(Dependency{
+		Name:  "database",
+		Check: configs.DB.Ping,
+		Describe: func() map[string]string {
+			return map[string]string{"type": configs.DB.Type()}
+		},
+	})
+}
+func envInit() {
+	registry.timeout = cmp.Or(settings.Get().Health.CheckTimeout, DEFAULT_CHECK_TIMEOUT)
+}
+
+// Register adds a dependency to the readiness checks
+func Register(dependency Dependency) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.dependencies = append(registry.dependencies, dependency)
+}
+
+// StartDraining marks the service as shutting down, from then on every report is not ready
+// so load balancers stop sending new requests while the in-flight ones finish
+func StartDraining() {
+	registry.draining.Store(true)
+}
+func Draining() bool {
+	return registry.draining.Load()
+}
+
+// Check runs every registered dependency check concurrently,
+// each one bounded by HEALTH_CHECK_TIMEOUT
+func Check(ctx context.Context) Report {
+	if Draining() {
+		return Report{
+			Ready:    false,
+			Draining: true,
+			Checks:   map[string]CheckResult{},
+		}
+	}
+
+	registry.mutex.RLock()
+	dependencies := append([]Dependency(nil), registry.dependencies...)
+	registry.mutex.RUnlock()
+
+	report := Report{
+		Ready:  true,
+		Checks: make(map[string]CheckResult, len(dependencies)),
+	}
+
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	for _, dependency := range dependencies {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			result := check(ctx, dependency)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[dependency.Name] = result
+			if result.Status != STATUS_UP {
+				report.Ready = false
+			}
+		}()
+	}
+	wait.Wait()
+
+	return report
+}
+func check(ctx context.Context, dependency Dependency) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, registry.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dependency.Check(ctx)
+	result := CheckResult{
+		Status:    STATUS_UP,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if dependency.Describe != nil {
+		result.Details = dependency.Describe()
+	}
+
+	if err != nil {
+		logger.WithContext(ctx).Warning("Readiness check failed for", dependency.Name, ":", err)
+		result.Status = STATUS_DOWN
+		result.Error = err.Error()
+		metrics.DependencyUp.Set(0, dependency.Name)
+	} else {
+		metrics.DependencyUp.Set(1, dependency.Name)
+	}
+	return result
+}
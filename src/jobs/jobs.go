@@ -0,0 +1,279 @@
+// Package jobs runs work off the request path. Jobs are queued with Enqueue under a kind,
+// and a pool of JOBS_WORKERS workers runs them with the handler registered for that kind.
+// A failed job is retried with exponential backoff, JOBS_BACKOFF doubled on every attempt
+// up to JOBS_MAX_BACKOFF, and after JOBS_MAX_ATTEMPTS it is kept as dead for an admin to
+// look at and retry. Done and dead jobs are dropped JOBS_RETENTION after they finished.
+// The queue lives in memory or, with JOBS_BACKEND=database, on the configured database
+// so jobs survive restarts and can be shared by several instances.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tenant"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BACKEND_MEMORY   = "memory"
+	BACKEND_DATABASE = "database"
+
+	PRUNE_EVERY = time.Hour // How often the finished jobs past JOBS_RETENTION are dropped
+)
+
+// Handler runs a job of its kind, returning an error retries it later
+type Handler func(ctx context.Context, job models.JobDBMongo) error
+
+type jobsType struct {
+	queue    atomic.Pointer[Queue]
+	handlers sync.Map // kind -> Handler
+	wake     chan struct{}
+	workers  sync.WaitGroup
+}
+
+var current = jobsType{wake: make(chan struct{}, 1)}
+
+func init() {
+	envInit()
+}
+func envInit() {
+	var queue Queue = NewMemory()
+	if settings.Get().Jobs.Backend == BACKEND_DATABASE {
+		queue = Database{}
+	}
+	Use(queue)
+	logger.Info("Job queue:", Current().Name())
+}
+
+// Current returns the queue in use
+func Current() Queue {
+	return *current.queue.Load()
+}
+
+// Use replaces the queue in use, for tests and for custom queues
+func Use(queue Queue) {
+	current.queue.Store(&queue)
+}
+
+// Register sets the handler of the jobs of the kind, replacing any previous one
+func Register(kind string, handler Handler) {
+	current.handlers.Store(kind, handler)
+}
+
+func handlerOf(kind string) (Handler, bool) {
+	handler, exists := current.handlers.Load(kind)
+	if !exists {
+		return nil, false
+	}
+	return handler.(Handler), true
+}
+
+// Enqueue queues a job of the kind with the payload as JSON, due right away
+func Enqueue(ctx context.Context, kind string, payload any) (models.JobDBMongo, error) {
+	ctx, span := tracing.Start(ctx, "jobs.Enqueue")
+	defer span.End()
+
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return models.JobDBMongo{}, fmt.Errorf("encoding the %s job: %w", kind, err)
+	}
+
+	job := models.NewJob(kind, string(content), settings.Get().Jobs.MaxAttempts, models.Time.Now())
+	job.Tenant, _ = tenant.From(ctx)
+	job, err = Current().Add(ctx, job)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to queue", kind, "job:", err)
+		return job, err
+	}
+	notifyWorkers()
+	return job, nil
+}
+
+// Decode reads the payload of the job into v
+func Decode(job models.JobDBMongo, v any) error {
+	return json.Unmarshal([]byte(job.Payload), v)
+}
+
+// Retry queues a dead job again with every attempt available
+func Retry(ctx context.Context, id string) (models.JobDBMongo, error) {
+	queue := Current()
+	job, err := queue.Get(ctx, id)
+	if err != nil {
+		return job, err
+	}
+	if job.Status != models.JOB_DEAD {
+		httpErr := types.Error(
+			types.Http.C400().Conflict(),
+			"Job not dead",
+			"Job "+id+" is "+job.Status+", only dead jobs can be retried",
+		)
+		return job, &httpErr
+	}
+
+	job = job.Retried(settings.Get().Jobs.MaxAttempts, models.Time.Now())
+	if err := queue.Save(ctx, "", job); err != nil {
+		return job, err
+	}
+	notifyWorkers()
+	return job, nil
+}
+
+// Backoff returns how long to wait before retrying a job that failed its attempt-th run
+func Backoff(attempt int) time.Duration {
+	config := settings.Get().Jobs
+	wait := config.Backoff
+	for i := 1; i < attempt && wait < config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, config.MaxBackoff)
+}
+
+// notifyWorkers wakes an idle worker without waiting for its next poll
+func notifyWorkers() {
+	select {
+	case current.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the worker pool until ctx ends. Jobs already running when it ends are finished.
+func Start(ctx context.Context) {
+	workers := settings.Get().Jobs.Workers
+	for range workers {
+		current.workers.Add(1)
+		go func() {
+			defer current.workers.Done()
+			work(ctx)
+		}()
+	}
+	go prune(ctx)
+	logger.Info("Started", workers, "job workers on the", Current().Name(), "queue")
+}
+
+// prune drops the finished jobs past their retention every PRUNE_EVERY until ctx ends
+func prune(ctx context.Context) {
+	for {
+		Prune(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(PRUNE_EVERY):
+		}
+	}
+}
+
+// Prune removes the done and dead jobs that finished JOBS_RETENTION before now, returning how many.
+// Workers do this on their own every PRUNE_EVERY.
+func Prune(ctx context.Context, now time.Time) int {
+	pruned, err := Current().Prune(ctx, now.Add(-settings.Get().Jobs.Retention))
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to prune the finished jobs:", err)
+	}
+	if pruned > 0 {
+		logger.WithContext(ctx).Debug("Pruned", pruned, "finished jobs")
+	}
+	return pruned
+}
+
+// Wait blocks until every worker stopped
+func Wait() {
+	current.workers.Wait()
+}
+
+func work(ctx context.Context) {
+	for ctx.Err() == nil {
+		if runNext(ctx, time.Now()) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-current.wake:
+		case <-time.After(settings.Get().Jobs.Poll):
+		}
+	}
+}
+
+// RunDue runs every job due at now, one after the other, and returns how many ran.
+// Workers do this on their own, it is meant for tests and maintenance.
+func RunDue(ctx context.Context, now time.Time) int {
+	ran := 0
+	for runNext(ctx, now) {
+		ran++
+	}
+	return ran
+}
+
+// runNext claims a job due at now and runs it, reporting whether there was one
+func runNext(ctx context.Context, now time.Time) bool {
+	claim := newClaim()
+	job, claimed, err := Current().Claim(ctx, claim, now, settings.Get().Jobs.Lease)
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to claim a job:", err)
+		return false
+	}
+	if !claimed {
+		return false
+	}
+	run(context.WithoutCancel(ctx), job)
+	return true
+}
+
+// run executes the claimed job and stores the outcome
+func run(ctx context.Context, job models.JobDBMongo) {
+	ctx, span := tracing.Start(ctx, "jobs."+job.Kind)
+	defer span.End()
+
+	claim := job.Claim
+	now := models.Time.Now()
+	handler, exists := handlerOf(job.Kind)
+	switch {
+	case !exists:
+		job = job.Dead("no handler for the "+job.Kind+" kind", now)
+	default:
+		err := execute(tenant.With(ctx, job.Tenant), handler, job)
+		now = models.Time.Now()
+		if err == nil {
+			job = job.Succeeded(now)
+		} else {
+			job = job.Failed(err.Error(), now.Add(Backoff(job.Attempts)), now)
+		}
+	}
+
+	switch job.Status {
+	case models.JOB_DEAD:
+		logger.WithContext(ctx).Error("Job", job.ID.Hex(), "of kind", job.Kind, "is dead after", job.Attempts, "attempts:", job.LastError)
+	case models.JOB_QUEUED:
+		logger.WithContext(ctx).Warning("Job", job.ID.Hex(), "of kind", job.Kind, "failed, retrying at", job.RunAt, ":", job.LastError)
+	}
+
+	if err := Current().Save(ctx, claim, job); err != nil {
+		logger.WithContext(ctx).Error("Failed to save job", job.ID.Hex(), ":", err)
+	}
+}
+
+// execute runs the handler, a panic counts as a failure
+func execute(ctx context.Context, handler Handler, job models.JobDBMongo) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// newClaim returns a random token identifying a run of a job
+func newClaim() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
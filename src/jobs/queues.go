@@ -0,0 +1,187 @@
+package jobs
+
+import (
+	"context"
+	"dainxor/atv/db"
+	"dainxor/atv/models"
+	"dainxor/atv/types"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Queue stores the jobs. Claim must hand a due job to a single worker even when
+// several claim at once, Save with a claim only stores the outcome of that run.
+type Queue interface {
+	Add(ctx context.Context, job models.JobDBMongo) (models.JobDBMongo, error)
+	Claim(ctx context.Context, claim string, now time.Time, lease time.Duration) (models.JobDBMongo, bool, error)
+	Save(ctx context.Context, claim string, job models.JobDBMongo) error
+	Get(ctx context.Context, id string) (models.JobDBMongo, error)
+	List(ctx context.Context, status string, kind string) ([]models.JobDBMongo, error)
+	Prune(ctx context.Context, before time.Time) (int, error) // Removes the done and dead jobs finished before the time
+	Name() string
+}
+
+// Memory keeps the jobs in the process, they are lost on restart
+type Memory struct {
+	mutex sync.Mutex
+	jobs  []models.JobDBMongo
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (*Memory) Name() string {
+	return BACKEND_MEMORY
+}
+
+func (m *Memory) Add(ctx context.Context, job models.JobDBMongo) (models.JobDBMongo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job.ID = bson.NewObjectID()
+	m.jobs = append(m.jobs, job)
+	return job, nil
+}
+
+func (m *Memory) Claim(ctx context.Context, claim string, now time.Time, lease time.Duration) (models.JobDBMongo, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	next := -1
+	for i, job := range m.jobs {
+		if job.Due(now) && (next < 0 || job.RunAt.Before(m.jobs[next].RunAt)) {
+			next = i
+		}
+	}
+	if next < 0 {
+		return models.JobDBMongo{}, false, nil
+	}
+	m.jobs[next] = m.jobs[next].Claimed(claim, now, lease)
+	return m.jobs[next], true, nil
+}
+
+func (m *Memory) Save(ctx context.Context, claim string, job models.JobDBMongo) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	i := slices.IndexFunc(m.jobs, func(stored models.JobDBMongo) bool { return stored.ID == job.ID })
+	if i < 0 {
+		httpErr := types.ErrorNotFound("Job not found", "Job with ID "+job.ID.Hex()+" not found")
+		return &httpErr
+	}
+	if claim != "" && m.jobs[i].Claim != claim {
+		httpErr := types.Error(
+			types.Http.C400().Conflict(),
+			"Job claimed again",
+			"Job "+job.ID.Hex()+" ran past its lease and was claimed by another worker",
+		)
+		return &httpErr
+	}
+	m.jobs[i] = job
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, id string) (models.JobDBMongo, error) {
+	oid, err := models.ID.ToDB(id)
+	if err != nil {
+		httpErr := types.Error(
+			types.Http.C400().UnprocessableEntity(),
+			"Invalid value",
+			"Invalid ID format: "+err.Error(),
+			"Job ID: "+id,
+		)
+		return models.JobDBMongo{}, &httpErr
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	i := slices.IndexFunc(m.jobs, func(job models.JobDBMongo) bool { return job.ID == oid })
+	if i < 0 {
+		httpErr := types.ErrorNotFound("Job not found", "Job with ID "+id+" not found")
+		return models.JobDBMongo{}, &httpErr
+	}
+	return m.jobs[i], nil
+}
+
+func (m *Memory) List(ctx context.Context, status string, kind string) ([]models.JobDBMongo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	jobs := []models.JobDBMongo{}
+	for _, job := range slices.Backward(m.jobs) {
+		if (status == "" || job.Status == status) && (kind == "" || job.Kind == kind) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *Memory) Prune(ctx context.Context, before time.Time) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	kept := len(m.jobs)
+	m.jobs = slices.DeleteFunc(m.jobs, func(job models.JobDBMongo) bool { return job.FinishedBefore(before) })
+	return kept - len(m.jobs), nil
+}
+
+// Database keeps the jobs on the configured database, a job is claimed with a
+// conditional update so only one worker of any instance runs it
+type Database struct{}
+
+func (Database) Name() string {
+	return BACKEND_DATABASE
+}
+
+func (Database) Add(ctx context.Context, job models.JobDBMongo) (models.JobDBMongo, error) {
+	result := db.Job.Create(ctx, job)
+	return result.Value(), result.ErrorOr(nil)
+}
+
+func (Database) Claim(ctx context.Context, claim string, now time.Time, lease time.Duration) (models.JobDBMongo, bool, error) {
+	due := db.Job.Due(ctx, now)
+	if due.IsErr() {
+		return models.JobDBMongo{}, false, due.Error()
+	}
+
+	for _, job := range due.Value() {
+		claimed := job.Claimed(claim, now, lease)
+		ok, err := db.Job.Claim(ctx, job, claimed)
+		if err != nil {
+			return models.JobDBMongo{}, false, err
+		}
+		if ok {
+			return claimed, true, nil
+		}
+	}
+	return models.JobDBMongo{}, false, nil
+}
+
+func (Database) Save(ctx context.Context, claim string, job models.JobDBMongo) error {
+	return db.Job.Save(ctx, claim, job).ErrorOr(nil)
+}
+
+func (Database) Get(ctx context.Context, id string) (models.JobDBMongo, error) {
+	result := db.Job.GetByID(ctx, id)
+	return result.Value(), result.ErrorOr(nil)
+}
+
+func (Database) List(ctx context.Context, status string, kind string) ([]models.JobDBMongo, error) {
+	result := db.Job.GetAll(ctx, status, kind)
+	return result.Value(), result.ErrorOr(nil)
+}
+
+func (Database) Prune(ctx context.Context, before time.Time) (int, error) {
+	result := db.Job.DeleteFinished(ctx, before)
+	return int(result.ValueOr(0)), result.ErrorOr(nil)
+}
+
+var (
+	_ Queue = (*Memory)(nil)
+	_ Queue = Database{}
+)
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"context"
+)
+
+// traceIDExtractor returns the trace ID carried by a context, set by the tracing package.
+// The logger does not import tracing directly to avoid an import cycle.
+var traceIDExtractor func(ctx context.Context) string
+
+// SetTraceIDExtractor registers the function used to read trace IDs from contexts
+func SetTraceIDExtractor(extractor func(ctx context.Context) string) {
+	traceIDExtractor = extractor
+}
+
+// ctxLogger logs like the package functions, prefixing every line with the trace ID of its context
+type ctxLogger struct {
+	traceID string
+}
+
+// WithContext returns a logger that tags its lines with the trace ID found in ctx, if any.
+//
+//	logger.WithContext(ctx).Error("Failed to get student:", err)
+func WithContext(ctx context.Context) ctxLogger {
+	if ctx == nil || traceIDExtractor == nil {
+		return ctxLogger{}
+	}
+	return ctxLogger{traceID: traceIDExtractor(ctx)}
+}
+
+// TraceID returns the trace ID the logger tags its lines with, "" if none
+func (l ctxLogger) TraceID() string {
+	return l.traceID
+}
+
+func (l ctxLogger) args(v []any) []any {
+	if l.traceID == "" {
+		return v
+	}
+	return append([]any{"trace_id=" + l.traceID}, v...)
+}
+
+func (l ctxLogger) Debug(v ...any) {
+	iLogDebug(false, 0, l.args(v)...)
+}
+func (l ctxLogger) Info(v ...any) {
+	iLogInfo(false, 0, l.args(v)...)
+}
+func (l ctxLogger) Warning(v ...any) {
+	iLogWarning(false, 0, l.args(v)...)
+}
+func (l ctxLogger) Error(v ...any) {
+	iLogError(false, 0, l.args(v)...)
+}
+func (l ctxLogger) Fatal(v ...any) {
+	iLogFatal(false, 0, l.args(v)...)
+}
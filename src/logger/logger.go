@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/metrics"
 	"dainxor/atv/utils"
 	"regexp"
 
@@ -10,6 +12,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	_ "github.com/joho/godotenv/autoload"
 )
@@ -31,6 +34,14 @@ func hasLogLevel(options logLevel, level logLevel) bool {
 	return options&level == level
 }
 
+// minLevelMask returns the options that enable level and every level above it
+func minLevelMask(level logLevel) logLevel {
+	if level == LEVEL_NONE || level == LEVEL_ALL {
+		return level
+	}
+	return LEVEL_ALL &^ (level - 1)
+}
+
 const (
 	LOG_PATH      = "../artifacts/"
 	LOG_FILE      = "logs.log"
@@ -104,13 +115,31 @@ type dnxLogger struct {
 	usingDefaults bool // Indicates if the logger is using default settings
 }
 
-var dnxLoggerInstance *dnxLogger
+// The instance is swapped as a whole on reload, so readers never see half applied settings
+var dnxLoggerInstance atomic.Pointer[dnxLogger]
 
 func init() {
 	defaultInit()
+
+	settings.OnReload(func(previous *settings.Loaded, current *settings.Loaded) {
+		if previous.Config.Log != current.Config.Log {
+			ReloadEnv()
+		}
+	})
 }
 func defaultInit() {
-	dnxLoggerInstance = &dnxLogger{
+	dnxLoggerInstance.Store(newDefaultLogger())
+
+	envInit(get()) // Initialize environment variables for logger
+
+	if LogsToFile() {
+		tryCreateLogFile() // Create the path if it doesn't exist, else it will set log to console only
+	}
+
+	Info("Logger initialized")
+}
+func newDefaultLogger() *dnxLogger {
+	return &dnxLogger{
 		LogToFile:       DEFAULT_LOGS_TO_FILE,
 		LogToConsole:    DEFAULT_LOGS_TO_CONSOLE,
 		ColorLogs:       DEFAULT_COLOR_LOGGING,
@@ -128,27 +157,25 @@ func defaultInit() {
 		ErrorLogger:   log.New(os.Stderr, "| ERROR | ", log.LstdFlags),
 		FatalLogger:   log.New(os.Stderr, "| FATAL | ", log.LstdFlags),
 	}
+}
 
-	envInit() // Initialize environment variables for logger
+// envInit applies the log settings to l, which may not be the instance in use yet
+func envInit(l *dnxLogger) {
+	Debug("Loading environment variables for logger")
 
-	if LogsToFile() {
-		tryCreateLogFile() // Create the path if it doesn't exist, else it will set log to console only
-	}
+	loaded := settings.Current() // Values are already validated, invalid ones are reported by the configs package
+	config := loaded.Config.Log
 
-	Info("Logger initialized")
-}
-func envInit() {
-	Debug("Loading environment variables for logger")
-  
-	minLogLevel, existMinLevel := os.LookupEnv("DNX_LOG_MIN_LEVEL")
-	disableLevels, existDisableLevels := os.LookupEnv("DNX_LOG_DISABLE_LEVELS")
-	logConsole, existLogConsole := os.LookupEnv("DNX_LOG_CONSOLE")
-	logFile, existLogFile := os.LookupEnv("DNX_LOG_FILE")
-	logWithColor, existLogWithColor := os.LookupEnv("DNX_LOG_WITH_COLOR")
+	minLogLevel, existMinLevel := config.MinLevel, loaded.IsSet("DNX_LOG_MIN_LEVEL")
+	disableLevels, existDisableLevels := config.DisableLevels, loaded.IsSet("DNX_LOG_DISABLE_LEVELS")
+	existLogConsole := loaded.IsSet("DNX_LOG_CONSOLE")
+	existLogFile := loaded.IsSet("DNX_LOG_FILE")
+	existLogWithColor := loaded.IsSet("DNX_LOG_WITH_COLOR")
 
 	if existMinLevel {
 		Info("Setting minimum log level to ", minLogLevel)
-		get().usingDefaults = !SetMinLogLevel(LogLevelValue(minLogLevel)) // If any environment variable is set, we are not using defaults
+		l.LogLevels = minLevelMask(LogLevelValue(minLogLevel))
+		l.usingDefaults = false // If any environment variable is set, we are not using defaults
 	} else {
 		Debug("DNX_LOG_MIN_LEVEL not set, using default level: ", currentLogLevels())
 	}
@@ -163,60 +190,59 @@ func envInit() {
 			Info(" - ", level)
 		}
 
-		get().usingDefaults = !DisableLogOptions(options) && get().usingDefaults // If any environment variable is set, we are not using defaults
+		l.LogLevels &= ^options
+		l.usingDefaults = false // If any environment variable is set, we are not using defaults
 	} else {
 		Debug("DNX_LOG_DISABLE_LEVELS not set, keeping current log levels: ", currentLogLevels())
 	}
 	if existLogConsole {
-		b, err := strconv.ParseBool(logConsole)
-		if err != nil {
-			Warning("Failed to parse DNX_LOG_CONSOLE value")
-			Warning("Defaulting to console logging: ", DEFAULT_LOGS_TO_CONSOLE)
-			SetLogToConsole(DEFAULT_LOGS_TO_CONSOLE)
-		} else {
-			SetLogToConsole(b)
-			get().usingDefaults = false // If any environment variable is set, we are not using defaults
-		}
+		Info("Console logging set to ", config.Console)
+		l.LogToConsole = config.Console
+		l.usingDefaults = false // If any environment variable is set, we are not using defaults
 	} else {
 		Debug("DNX_LOG_CONSOLE not set, using default value: ", DEFAULT_LOGS_TO_CONSOLE)
 	}
 	if existLogFile {
-		b, err := strconv.ParseBool(logFile)
-		if err != nil {
-			Warning("Failed to parse DNX_LOG_FILE value")
-			Warning("Defaulting to file logging: ", DEFAULT_LOGS_TO_FILE)
-			SetLogToFile(DEFAULT_LOGS_TO_FILE)
-		} else {
-			SetLogToFile(b)
-			get().usingDefaults = false // If any environment variable is set, we are not using defaults
-		}
+		Info("File logging set to ", config.File)
+		l.LogToFile = config.File
+		l.usingDefaults = false // If any environment variable is set, we are not using defaults
 	} else {
 		Debug("DNX_LOG_FILE not set, using default value: ", DEFAULT_LOGS_TO_FILE)
 	}
 	if existLogWithColor {
-		b, err := strconv.ParseBool(logWithColor)
-		if err != nil {
-			Warning("Failed to parse DNX_LOG_WITH_COLOR value")
-			Warning("Defaulting to color logging: ", DEFAULT_COLOR_LOGGING)
-			SetLogWithColor(DEFAULT_COLOR_LOGGING)
-		} else {
-			SetLogWithColor(b)
-			get().usingDefaults = false // If any environment variable is set, we are not using defaults
-		}
+		Info("Color logging set to ", config.Color)
+		l.ColorLogs = config.Color
+		l.usingDefaults = false // If any environment variable is set, we are not using defaults
 	} else {
 		Debug("DNX_LOG_WITH_COLOR not set, using default value: ", DEFAULT_COLOR_LOGGING)
-		get().DebugLogger = log.New(os.Stdout, "|"+colorWith(" DEBUG ", CLR_DEBUG)+"| ", log.LstdFlags)
-		get().InfoLogger = log.New(os.Stdout, "|"+colorWith(" INFO ", CLR_INFO)+"| ", log.LstdFlags)
-		get().WarningLogger = log.New(os.Stdout, "|"+colorWith(" WARNING ", CLR_WARN)+"| ", log.LstdFlags)
-		get().ErrorLogger = log.New(os.Stderr, "|"+colorWith(" ERROR ", CLR_ERROR)+"| ", log.LstdFlags)
-		get().FatalLogger = log.New(os.Stderr, "|"+colorWith(" FATAL ", CLR_FATAL)+"| ", log.LstdFlags)
+		l.DebugLogger = log.New(os.Stdout, "|"+colorWith(" DEBUG ", CLR_DEBUG)+"| ", log.LstdFlags)
+		l.InfoLogger = log.New(os.Stdout, "|"+colorWith(" INFO ", CLR_INFO)+"| ", log.LstdFlags)
+		l.WarningLogger = log.New(os.Stdout, "|"+colorWith(" WARNING ", CLR_WARN)+"| ", log.LstdFlags)
+		l.ErrorLogger = log.New(os.Stderr, "|"+colorWith(" ERROR ", CLR_ERROR)+"| ", log.LstdFlags)
+		l.FatalLogger = log.New(os.Stderr, "|"+colorWith(" FATAL ", CLR_FATAL)+"| ", log.LstdFlags)
 	}
 
 	Debug("Logger environment variables loaded")
 }
 
+// ReloadEnv applies the current log settings to a fresh logger and swaps it in,
+// the app version and the log attempts are kept
 func ReloadEnv() {
-	envInit()
+	previous := get()
+	next := newDefaultLogger()
+	next.logAttempts = previous.logAttempts
+	next.appVersion = previous.appVersion
+	next.appVersionMajor = previous.appVersionMajor
+	next.appVersionMinor = previous.appVersionMinor
+	next.appVersionPatch = previous.appVersionPatch
+
+	envInit(next)
+	dnxLoggerInstance.Store(next)
+
+	if next.LogToFile && !previous.LogToFile {
+		tryCreateLogFile()
+	}
+	Info("Logger reloaded, levels: ", currentLogLevels())
 }
 
 // Returns the singleton instance of dnxLogger, initializing it if necessary.
@@ -224,10 +250,11 @@ func ReloadEnv() {
 // It abstracts the initialization logic and provides a single point of access to the logger instance.
 // It ensures that the logger is initialized only once, and provides a consistent interface for logging.
 func get() *dnxLogger {
-	if dnxLoggerInstance == nil {
-		defaultInit()
+	if instance := dnxLoggerInstance.Load(); instance != nil {
+		return instance
 	}
-	return dnxLoggerInstance
+	defaultInit()
+	return dnxLoggerInstance.Load()
 }
 func UsingDefaults() bool {
 	return get().usingDefaults
@@ -576,7 +603,7 @@ func SetMinLogLevel(level logLevel) bool {
 	}
 
 	Info("Minimum logging level set to: ", msg)
-	SetLogLevels(level)
+	SetLogLevels(minLevelMask(level))
 	return true
 }
 func LogLevelValue(levelName string) logLevel {
@@ -606,7 +633,7 @@ func LogLevelValue(levelName string) logLevel {
 func canLogWith(logger *log.Logger) bool {
 	if LogLevelsHas(LEVEL_ALL) {
 		return true
-	} else if LogLevelsHas(LEVEL_NONE) {
+	} else if LogLevels() == LEVEL_NONE {
 		return false
 	}
 
@@ -737,7 +764,20 @@ func Fatal(v ...any) {
 	logFatal(false, v...)
 }
 
+// callSite returns the file and line that called into the logger from outside of it,
+// used to label the metrics of deprecated and lava code
+func callSite() string {
+	for depth := 3; depth < 8; depth++ {
+		site := utils.CallOrigin(depth)
+		if !strings.HasPrefix(site, "logger.go:") {
+			return site
+		}
+	}
+	return utils.CallOrigin(3)
+}
+
 func Deprecate(deprecatedVersion string, removalVersion string, v ...any) (bool, error) {
+	metrics.DeprecateHits.Inc(callSite())
 	args := utils.Join(v, " ")
 	deprecateTxt := colorWith(" DEPRECATED:", CLR_DEPRECATE)
 	reasonTxt := colorWith(" REASON:", CLR_DEPR_REASON)
@@ -763,6 +803,12 @@ func Deprecate(deprecatedVersion string, removalVersion string, v ...any) (bool,
 	}
 	return true, nil
 }
+
+// VersionReached reports whether the app version is the given one or a later one,
+// the same comparison Deprecate uses to decide if something is deprecated or removed
+func VersionReached(version string) bool {
+	return compareVersions(AppVersion(), version) >= 0
+}
 func DeprecateMsg(deprecatedVersion string, removalVersion string, v ...any) string {
 	_, err := Deprecate(deprecatedVersion, removalVersion, v...)
 	return err.Error()
@@ -779,6 +825,7 @@ type volcano struct {
 }
 
 func Lava(version string, v ...any) volcano {
+	metrics.LavaHits.Inc(callSite())
 	args := utils.Join(v, " ")
 	lavaTxt := colorWith(" LAVA:", CLR_LAVA)
 	coldLavaTxt := colorWith(" COLD LAVA:", CLR_COLD_LAVA)
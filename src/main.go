@@ -2,15 +2,27 @@ package main
 
 import (
 	"cmp"
+	"context"
+	"errors"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/joho/godotenv/autoload"
 
 	"dainxor/atv/configs"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/health"
+	"dainxor/atv/jobs"
 	"dainxor/atv/logger"
 	"dainxor/atv/middleware"
+	"dainxor/atv/notify"
 	"dainxor/atv/routes"
+	"dainxor/atv/stream"
+	"dainxor/atv/tracing"
 )
 
 //var envErr = godotenv.Load()
@@ -22,34 +34,117 @@ func init() {
 	logger.Debug("Starting server")
 }
 
-// address returns the server address from the environment variable
-func address() string {
-	envAddress := os.Getenv("SERVER_ADDRESS")
-	return cmp.Or(envAddress, ":8080")
+const (
+	DEFAULT_SHUTDOWN_TIMEOUT = 10 * time.Second // Cloud Run kills the container 10 seconds after SIGTERM
+	DEFAULT_ADDRESS          = ":8080"
+)
+
+// shutdown drains the server and releases every resource once a stop signal arrives.
+// Readiness fails first, then the listener waits for in-flight requests up to
+// SERVER_SHUTDOWN_TIMEOUT, then for the jobs the workers are running, and last the
+// database and the tracer are closed.
+func shutdown(server *http.Server) {
+	config := settings.Get().Server
+	shutdownTimeout := cmp.Or(config.ShutdownTimeout, DEFAULT_SHUTDOWN_TIMEOUT)
+	drainDelay := config.DrainDelay
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	health.StartDraining()
+	if drainDelay > 0 {
+		logger.Info("Draining, waiting", drainDelay, "before closing the listener")
+		time.Sleep(min(drainDelay, shutdownTimeout))
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("Requests still in flight after", shutdownTimeout, "closing them:", err)
+		server.Close()
+	} else {
+		logger.Info("All in-flight requests finished")
+	}
+
+	worked := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(worked)
+	}()
+	select {
+	case <-worked:
+	case <-ctx.Done():
+		logger.Warning("Jobs still running, leaving them unfinished")
+	}
+
+	configs.DB.Close()
+	if err := tracing.Shutdown(ctx); err != nil {
+		logger.Warning("Failed to flush pending spans:", err)
+	}
+	logger.Info("Server stopped")
 }
 
 func main() {
-	defer configs.DB.Close()
+	if len(os.Args) > 1 {
+		os.Exit(command(os.Args[1:]))
+	}
+	for _, problem := range settings.Current().Problems {
+		logger.Warning("Config:", problem)
+	}
+
+	go func() { // Warm up the connection, requests start another reconnect if this fails
+		ctx := context.Background()
+		if configs.DB.Reconnect(ctx) != nil {
+			return
+		}
+		if err := configs.DB.EnsureIndexes(ctx); err != nil {
+			logger.Warning("Some indexes could not be made:", err)
+		}
+	}()
 
 	router := gin.Default()
+	if err := routes.TrustProxies(router); err != nil {
+		logger.Fatal("Invalid SERVER_TRUSTED_PROXIES:", err)
+	}
+	router.Use(middleware.MetricsMiddleware()) // Middleware to record request counts and latencies
+	router.Use(middleware.TracingMiddleware()) // Middleware to start a span per request and propagate trace IDs
 	router.Use(middleware.RecoverMiddleware()) // Middleware to recover from panics and logs a small trace
 	router.Use(middleware.CORSMiddleware())
-	//router.Use(middleware.TokenMiddleware())
+	router.Use(middleware.TokenMiddleware()) // Middleware to identify the caller from its bearer token
+
+	routes.Register(router)
+
+	server := &http.Server{
+		Addr:    cmp.Or(settings.Get().Server.Address, DEFAULT_ADDRESS), // listen and serve on 0.0.0.0:8080 (for windows ":8080")
+		Handler: router,
+	}
+	server.RegisterOnShutdown(stream.CloseAll) // Session streams never finish on their own
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Listening on", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
 
-	// Root level routes
-	routes.MainRoutes(router)
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	// Api routes
-	routes.InfoRoutes(router) // Routes for information about the API
-	routes.TestRoutes(router) // Routes for testing purposes
+	go configs.WatchConfig(stop)
+	configs.ReloadOnHangup(stop)
+	go notify.Run(stop) // Session reminders
+	jobs.Start(stop)    // Workers stop taking jobs on the stop signal
+	go stream.Run(stop) // Session changes from the MongoDB change stream, when enabled
 
-	// Versioned API routes
-	routes.StudentRoutes(router)
-	routes.UniversityRoutes(router)
-	routes.SpecialityRoutes(router)
-	routes.CompanionRoutes(router)
-	routes.SessionTypeRoutes(router)
-	routes.SessionRoutes(router)
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Server failed:", err)
+			exitCode = 1
+		}
+	case <-stop.Done():
+		logger.Info("Shutdown signal received")
+	}
 
-	router.Run(address()) // listen and serve on 0.0.0.0:8080 (for windows ":8080")
+	cancel() // A second signal kills the process right away
+	shutdown(server)
+	os.Exit(exitCode)
 }
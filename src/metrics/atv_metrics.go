@@ -0,0 +1,57 @@
+package metrics
+
+// Metrics exposed by the application on /metrics
+
+var (
+	HttpRequests = NewCounter(
+		"atv_http_requests_total",
+		"Number of HTTP requests handled, by method, route template and status code.",
+		"method", "route", "status",
+	)
+	HttpRequestDuration = NewHistogram(
+		"atv_http_request_duration_seconds",
+		"Latency of HTTP requests, by method, route template and status code.",
+		DEFAULT_BUCKETS,
+		"method", "route", "status",
+	)
+	HttpRequestsInFlight = NewGauge(
+		"atv_http_requests_in_flight",
+		"Number of HTTP requests currently being served.",
+	)
+
+	DBOperationDuration = NewHistogram(
+		"atv_db_operation_duration_seconds",
+		"Latency of database operations, by collection, operation and outcome.",
+		DEFAULT_BUCKETS,
+		"collection", "operation", "outcome",
+	)
+
+	DependencyUp = NewGauge(
+		"atv_dependency_up",
+		"Whether a dependency passed its last readiness check (1) or not (0), by dependency.",
+		"dependency",
+	)
+
+	RateLimited = NewCounter(
+		"atv_rate_limited_total",
+		"Number of requests rejected by the rate limiter, by route group and caller kind.",
+		"group", "caller",
+	)
+
+	PanicsRecovered = NewCounter(
+		"atv_panics_recovered_total",
+		"Number of panics recovered by the recover middleware, by route template.",
+		"route",
+	)
+
+	DeprecateHits = NewCounter(
+		"atv_deprecate_hits_total",
+		"Number of times deprecated code ran, by call site.",
+		"site",
+	)
+	LavaHits = NewCounter(
+		"atv_lava_hits_total",
+		"Number of times code flagged as lava ran, by call site.",
+		"site",
+	)
+)
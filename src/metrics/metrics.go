@@ -0,0 +1,269 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Content type of the Prometheus text exposition format (version 0.0.4)
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default histogram buckets, in seconds. Same as the Prometheus client defaults.
+var DEFAULT_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+const labelSeparator = "\xff"
+
+type collector interface {
+	write(w io.Writer)
+}
+
+type registry struct {
+	mutex      sync.Mutex
+	collectors []collector
+}
+
+var defaultRegistry registry
+
+func register(c collector) {
+	defaultRegistry.mutex.Lock()
+	defer defaultRegistry.mutex.Unlock()
+	defaultRegistry.collectors = append(defaultRegistry.collectors, c)
+}
+
+// Write writes every registered metric to w using the Prometheus text format
+func Write(w io.Writer) {
+	defaultRegistry.mutex.Lock()
+	collectors := append([]collector{}, defaultRegistry.collectors...)
+	defaultRegistry.mutex.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// metric holds the parts shared by every metric kind
+type metric struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+}
+
+func (m *metric) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+}
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", m.name, len(m.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, labelSeparator)
+}
+func (m *metric) labels(key string, extra ...string) string {
+	pairs := make([]string, 0, len(m.labelNames)+1)
+
+	if len(m.labelNames) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, m.labelNames[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a monotonically increasing value partitioned by labels
+type CounterVec struct {
+	metric
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+func NewCounter(name string, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{
+		metric: metric{name: name, help: help, kind: "counter", labelNames: labelNames},
+		values: map[string]float64{},
+	}
+	register(c)
+	return c
+}
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return // Counters can only go up
+	}
+
+	key := c.key(labelValues)
+	c.mutex.Lock()
+	c.values[key] += value
+	c.mutex.Unlock()
+}
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[key]
+}
+func (c *CounterVec) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.header(w)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labels(key), formatFloat(c.values[key]))
+	}
+}
+
+// GaugeVec is a value that can go up and down partitioned by labels
+type GaugeVec struct {
+	metric
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+func NewGauge(name string, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{
+		metric: metric{name: name, help: help, kind: "gauge", labelNames: labelNames},
+		values: map[string]float64{},
+	}
+	register(g)
+	return g
+}
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+func (g *GaugeVec) Add(value float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.mutex.Lock()
+	g.values[key] += value
+	g.mutex.Unlock()
+}
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.mutex.Lock()
+	g.values[key] = value
+	g.mutex.Unlock()
+}
+func (g *GaugeVec) write(w io.Writer) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.header(w)
+	if len(g.labelNames) == 0 && len(g.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", g.name)
+		return
+	}
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labels(key), formatFloat(g.values[key]))
+	}
+}
+
+type histogramSeries struct {
+	buckets []uint64 // Non cumulative count per bucket, cumulated when written
+	count   uint64
+	sum     float64
+}
+
+// HistogramVec samples observations into buckets partitioned by labels
+type HistogramVec struct {
+	metric
+	mutex   sync.Mutex
+	bounds  []float64
+	samples map[string]*histogramSeries
+}
+
+func NewHistogram(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DEFAULT_BUCKETS
+	}
+
+	bounds := append([]float64{}, buckets...)
+	sort.Float64s(bounds)
+
+	h := &HistogramVec{
+		metric:  metric{name: name, help: help, kind: "histogram", labelNames: labelNames},
+		bounds:  bounds,
+		samples: map[string]*histogramSeries{},
+	}
+	register(h)
+	return h
+}
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	series, exist := h.samples[key]
+	if !exist {
+		series = &histogramSeries{buckets: make([]uint64, len(h.bounds))}
+		h.samples[key] = series
+	}
+
+	for i, bound := range h.bounds {
+		if value <= bound {
+			series.buckets[i]++
+			break
+		}
+	}
+	series.count++
+	series.sum += value
+}
+func (h *HistogramVec) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.header(w)
+	for _, key := range sortedKeys(h.samples) {
+		series := h.samples[key]
+		cumulative := uint64(0)
+
+		for i, bound := range h.bounds {
+			cumulative += series.buckets[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(key, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(key), formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(key), series.count)
+	}
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
@@ -1,56 +1,48 @@
 package middleware
 
 import (
-	"dainxor/atv/logger"
-	"net/http"
+	"dainxor/atv/configs"
+	"sync/atomic"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	jcors "github.com/itsjamie/gin-cors"
 )
 
-func CORSMiddleware() gin.HandlerFunc {
-	return corsLib()
+type corsHandler struct {
+	generation uint64
+	handle     gin.HandlerFunc
 }
 
-func corsOwn() gin.HandlerFunc {
+// CORSMiddleware applies the policy configured on configs.CORS, rebuilt when the config is reloaded
+func CORSMiddleware() gin.HandlerFunc {
+	var current atomic.Pointer[corsHandler]
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		// c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+		handler := current.Load()
+		if generation := configs.CORS.Generation(); handler == nil || handler.generation != generation {
+			handler = &corsHandler{generation: generation, handle: CORSMiddlewareWith(configs.CORS.Config())}
+			current.Store(handler)
 		}
-
-		c.Next()
+		handler.handle(c)
 	}
 }
 
-func corsLib() gin.HandlerFunc {
-	//front_url := os.Getenv("FRONTEND_URL")
-	//proxy_url := os.Getenv("PROXY_URL")
-
-	//allowedOrigins := []string{front_url + ", " + proxy_url + ", https://fuzzy-fiesta-g6xqxp4w6vw296v-3000.app.github.dev"}
+// CORSMiddlewareWith applies the given policy, requests from origins it does not allow get 403
+func CORSMiddlewareWith(config configs.CORSConfig) gin.HandlerFunc {
+	corsConfig := cors.Config{
+		AllowMethods:     config.AllowMethods,
+		AllowHeaders:     config.AllowHeaders,
+		ExposeHeaders:    config.ExposeHeaders,
+		AllowCredentials: config.AllowCredentials,
+		MaxAge:           config.MaxAge,
+	}
 
-	return cors.New(cors.Config{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
-		AllowHeaders: []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		// AllowCredentials: true,
-		AllowWildcard:   true,
-		AllowOriginFunc: func(origin string) bool { logger.Info(origin); return true },
-	})
-}
+	if config.AllowsAll() {
+		corsConfig.AllowAllOrigins = true
+		corsConfig.AllowCredentials = false // Browsers reject credentials with a wildcard origin
+	} else {
+		corsConfig.AllowOriginFunc = config.AllowsOrigin
+	}
 
-func corsJamie() gin.HandlerFunc {
-	return jcors.Middleware(jcors.Config{
-		Origins:        "http://localhost:3000, https://fuzzy-fiesta-g6xqxp4w6vw296v-3000.app.github.dev/",
-		Methods:        "GET, PUT, POST, PATCH, DELETE",
-		RequestHeaders: "Origin, Content-Type, Content-Length",
-		// Credentials:     true,
-		ValidateHeaders: false,
-	})
+	return cors.New(corsConfig)
 }
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"dainxor/atv/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route label used for requests that did not match any registered route,
+// this keeps random paths from creating new series
+const UNMATCHED_ROUTE = "unmatched"
+
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HttpRequestsInFlight.Inc()
+		defer metrics.HttpRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := routeLabel(c)
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HttpRequests.Inc(c.Request.Method, route, status)
+		metrics.HttpRequestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
+
+// routeLabel returns the registered route of the request, UNMATCHED_ROUTE when none matched
+func routeLabel(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return UNMATCHED_ROUTE
+}
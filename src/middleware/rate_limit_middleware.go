@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"dainxor/atv/auth"
+	"dainxor/atv/logger"
+	"dainxor/atv/metrics"
+	"dainxor/atv/ratelimit"
+	"dainxor/atv/types"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware limits the requests a caller can make to a route group.
+// Authenticated callers get a bucket per user, anonymous ones a bucket per IP,
+// limits come from RATE_LIMIT_<GROUP> and RATE_LIMIT_<GROUP>_USER and follow config reloads.
+// TokenMiddleware must run before it for users to be recognized.
+func RateLimitMiddleware(group string) gin.HandlerFunc {
+	ipLimit, userLimit := ratelimit.LimitsFor(group)
+	logger.Debug("Rate limits for", group, ":", ipLimit, "per IP,", userLimit, "per user")
+
+	return func(c *gin.Context) {
+		if !ratelimit.Enabled() {
+			c.Next()
+			return
+		}
+
+		ipLimit, userLimit := ratelimit.LimitsFor(group)
+
+		caller, key, limit := "ip", group+":ip:"+c.ClientIP(), ipLimit
+		if user := auth.UserID(c); user != "" {
+			caller, key, limit = "user", group+":user:"+user, userLimit
+		}
+		if limit.IsUnlimited() {
+			c.Next()
+			return
+		}
+
+		decision, err := ratelimit.Take(c.Request.Context(), key, limit)
+		if err != nil {
+			// Failing open, an unavailable store must not take the API down with it
+			logger.WithContext(c.Request.Context()).Warning("Rate limit store failed:", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("X-RateLimit-Reset", ceilSeconds(decision.Reset))
+
+		if !decision.Allowed {
+			metrics.RateLimited.Inc(group, caller)
+			retryAfter := ceilSeconds(decision.RetryAfter)
+			c.Header("Retry-After", retryAfter)
+			c.AbortWithStatusJSON(types.Http.C400().TooManyRequests(),
+				types.EmptyResponse(
+					"Too many requests",
+					"Retry in "+retryAfter+" seconds",
+				),
+			)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"dainxor/atv/logger"
+	"dainxor/atv/metrics"
+	"dainxor/atv/tracing"
 	"dainxor/atv/types"
 	"dainxor/atv/utils"
 	"fmt"
@@ -13,13 +15,16 @@ func RecoverMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				logger.Error("Recovered from panic:", err)
+				ctx := c.Request.Context()
+				logger.WithContext(ctx).Error("Recovered from panic:", err)
+				metrics.PanicsRecovered.Inc(routeLabel(c))
+				tracing.FromContext(ctx).RecordError(fmt.Errorf("panic: %v", err))
 
 				origin1 := utils.CallOrigin(5)
 				origin2 := utils.CallOrigin(6)
 				origin3 := utils.CallOrigin(7)
 
-				logger.Error(fmt.Sprintf("Error originated at: %s > %s > %s", origin3, origin2, origin1))
+				logger.WithContext(ctx).Error(fmt.Sprintf("Error originated at: %s > %s > %s", origin3, origin2, origin1))
 
 				c.AbortWithStatusJSON(types.Http.C500().InternalServerError(),
 					types.Response(
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"dainxor/atv/auth"
+	"dainxor/atv/tenant"
+	"dainxor/atv/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleMiddleware only lets through callers whose token has the given role.
+// A super-admin passes as any role. Anonymous callers get 401 and authenticated callers with another role 403.
+// TokenMiddleware must run before it.
+func RoleMiddleware(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, authenticated := auth.ClaimsFrom(c)
+		if !authenticated {
+			c.AbortWithStatusJSON(types.Http.C400().Unauthorized(),
+				types.EmptyResponse(
+					"Authentication required",
+					"Send a bearer token with the "+role+" role",
+				),
+			)
+			return
+		}
+		if claims.Role != role && claims.Role != tenant.SUPER_ADMIN_ROLE {
+			c.AbortWithStatusJSON(types.Http.C400().Forbidden(),
+				types.EmptyResponse(
+					"Forbidden",
+					"The "+role+" role is required",
+				),
+			)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// OwnerMiddleware only lets through callers whose token subject is the :id of the route, or that have the given role.
+// A super-admin passes as any role. Anonymous callers get 401 and other authenticated callers 403.
+// TokenMiddleware must run before it.
+func OwnerMiddleware(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, authenticated := auth.ClaimsFrom(c)
+		if !authenticated {
+			c.AbortWithStatusJSON(types.Http.C400().Unauthorized(),
+				types.EmptyResponse(
+					"Authentication required",
+					"Send a bearer token of "+c.Param("id")+" or with the "+role+" role",
+				),
+			)
+			return
+		}
+		if claims.Subject != c.Param("id") && claims.Role != role && claims.Role != tenant.SUPER_ADMIN_ROLE {
+			c.AbortWithStatusJSON(types.Http.C400().Forbidden(),
+				types.EmptyResponse(
+					"Forbidden",
+					"Only "+c.Param("id")+" or the "+role+" role can do this",
+				),
+			)
+			return
+		}
+
+		c.Next()
+	}
+}
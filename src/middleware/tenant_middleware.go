@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"dainxor/atv/auth"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/db"
+	"dainxor/atv/models"
+	"dainxor/atv/tenant"
+	"dainxor/atv/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantMiddleware resolves the university the request acts for when TENANCY_ENABLED is set,
+// from the tenant of the token or else from the TENANCY_HEADER header, and keeps the db calls
+// of the request to it. Only a super-admin picks the tenant through the header, acting across
+// tenants when sending none. Other callers sending it get 401 when anonymous and 403 otherwise,
+// unless TENANCY_TRUST_HEADER is set because a gateway in front sets the header itself.
+// Requests without a tenant get 400, unless optional, then they act across tenants.
+// TokenMiddleware must run before it.
+func TenantMiddleware(optional bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := settings.Get().Tenancy
+		if !config.Enabled {
+			c.Next()
+			return
+		}
+
+		header := strings.TrimSpace(c.GetHeader(config.Header))
+		claims, authenticated := auth.ClaimsFrom(c)
+
+		requested := header
+		switch {
+		case claims.Role == tenant.SUPER_ADMIN_ROLE:
+			// The header, if any, picks the tenant
+
+		case claims.Tenant != "" && header != "" && header != claims.Tenant:
+			c.AbortWithStatusJSON(types.Http.C400().Forbidden(),
+				types.EmptyResponse(
+					"Forbidden",
+					"The token belongs to another tenant than "+config.Header,
+				),
+			)
+			return
+
+		case claims.Tenant != "":
+			requested = claims.Tenant
+
+		case header != "" && !config.TrustHeader && !authenticated:
+			c.AbortWithStatusJSON(types.Http.C400().Unauthorized(),
+				types.EmptyResponse(
+					"Authentication required",
+					"Send a token with a tenant instead of the "+config.Header+" header",
+				),
+			)
+			return
+
+		case header != "" && !config.TrustHeader:
+			c.AbortWithStatusJSON(types.Http.C400().Forbidden(),
+				types.EmptyResponse(
+					"Forbidden",
+					"Only the "+tenant.SUPER_ADMIN_ROLE+" role picks the tenant with "+config.Header,
+				),
+			)
+			return
+		}
+
+		if requested == "" {
+			if optional || claims.Role == tenant.SUPER_ADMIN_ROLE {
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(types.Http.C400().BadRequest(),
+				types.EmptyResponse(
+					"Tenant required",
+					"Send a token with a tenant or the "+config.Header+" header",
+				),
+			)
+			return
+		}
+
+		id, err := models.ID.ToDB(requested)
+		if err != nil || db.University.GetByID(c.Request.Context(), requested).IsErr() {
+			c.AbortWithStatusJSON(types.Http.C400().Forbidden(),
+				types.EmptyResponse(
+					"Unknown tenant",
+					"No university with id "+requested,
+				),
+			)
+			return
+		}
+
+		c.Request = c.Request.WithContext(tenant.With(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// SuperAdminMiddleware keeps the routes acting across tenants, like the server config or the job queue,
+// to super-admins when TENANCY_ENABLED is set, any other caller gets 403. Without tenancy it lets every
+// caller through, the role checks are left to RoleMiddleware.
+// TokenMiddleware must run before it.
+func SuperAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !settings.Get().Tenancy.Enabled {
+			c.Next()
+			return
+		}
+		if claims, _ := auth.ClaimsFrom(c); claims.Role != tenant.SUPER_ADMIN_ROLE {
+			c.AbortWithStatusJSON(types.Http.C400().Forbidden(),
+				types.EmptyResponse(
+					"Forbidden",
+					"The "+tenant.SUPER_ADMIN_ROLE+" role is required, this acts across tenants",
+				),
+			)
+			return
+		}
+
+		c.Next()
+	}
+}
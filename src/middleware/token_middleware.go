@@ -1,15 +1,33 @@
 package middleware
 
 import (
+	"dainxor/atv/auth"
 	"dainxor/atv/logger"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// TokenMiddleware identifies the caller from an "Authorization: Bearer <jwt>" header.
+// Requests without a valid token continue as anonymous, the routes needing a caller
+// reject them on their own, see RoleMiddleware.
 func TokenMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		logger.Debug("Header:", c.Request.Header)
+		header := c.GetHeader("Authorization")
+		token, isBearer := strings.CutPrefix(header, "Bearer ")
+		if header == "" || !isBearer || !auth.Enabled() {
+			c.Next()
+			return
+		}
 
+		claims, err := auth.Verify(strings.TrimSpace(token))
+		if err != nil {
+			logger.WithContext(c.Request.Context()).Warning("Ignored token, continuing as anonymous:", err)
+			c.Next()
+			return
+		}
+
+		auth.SetClaims(c, claims)
 		c.Next()
 	}
 }
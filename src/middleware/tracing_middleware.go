@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"dainxor/atv/logger"
+	"dainxor/atv/tracing"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response header carrying the trace ID, so clients can report it with their issues
+const TRACE_ID_HEADER = "X-Trace-ID"
+
+// TracingMiddleware starts a server span for each request, continuing the caller's trace
+// when a traceparent header is present. The span travels in c.Request.Context().
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		route := c.FullPath()
+		if route == "" {
+			route = UNMATCHED_ROUTE
+		}
+
+		ctx := tracing.Extract(c.Request.Context(), c.GetHeader(tracing.TRACEPARENT_HEADER))
+		ctx, span := tracing.StartKind(ctx, c.Request.Method+" "+route, tracing.KIND_SERVER)
+		defer span.End()
+
+		span.SetAttribute("http.request.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("url.path", c.Request.URL.Path)
+		span.SetAttribute("client.address", c.ClientIP())
+		span.SetAttribute("user_agent.original", c.Request.UserAgent())
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(tracing.TRACEPARENT_HEADER, tracing.Traceparent(span))
+		c.Header(TRACE_ID_HEADER, span.TraceID().String())
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.response.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.STATUS_ERROR, http.StatusText(status))
+		}
+		for _, err := range c.Errors {
+			span.RecordError(err.Err)
+		}
+
+		logger.WithContext(ctx).Debug(c.Request.Method, c.Request.URL.Path, status, time.Since(start))
+	}
+}
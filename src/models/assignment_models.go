@@ -0,0 +1,144 @@
+package models
+
+import "time"
+
+// AssignmentDBMongo links a student with the companion in charge of them on a speciality.
+// Reassigning ends the assignment and starts a new one pointing back to it, so the history is kept.
+type AssignmentDBMongo struct {
+	ID           DBID             `json:"_id,omitempty" bson:"_id,omitempty"`
+	IDStudent    DBID             `json:"id_student" bson:"id_student"`
+	IDCompanion  DBID             `json:"id_companion" bson:"id_companion"`
+	IDSpeciality DBID             `json:"id_speciality" bson:"id_speciality"`
+	IDPrevious   DBID             `json:"id_previous,omitzero" bson:"id_previous,omitzero"` // The assignment this one replaced
+	StartDate    DBDateTime       `json:"start_date" bson:"start_date"`
+	EndDate      DBDateTime       `json:"end_date,omitzero" bson:"end_date"` // Zero while active
+	Status       assignmentStatus `json:"status" bson:"status"`
+	Reason       string           `json:"reason,omitempty" bson:"reason,omitempty"`         // Why it started
+	EndReason    string           `json:"end_reason,omitempty" bson:"end_reason,omitempty"` // Why it ended or was reassigned
+	CreatedAt    DBDateTime       `json:"created_at,omitzero" bson:"created_at,omitempty"`
+	UpdatedAt    DBDateTime       `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
+	DeletedAt    DBDateTime       `json:"deleted_at" bson:"deleted_at"`
+}
+
+// AssignmentCreate represents the request body for assigning a companion to a student,
+// the speciality defaults to the companion's one and the start date to now
+type AssignmentCreate struct {
+	IDStudent    string `json:"id_student"`
+	IDCompanion  string `json:"id_companion"`
+	IDSpeciality string `json:"id_speciality,omitempty"`
+	StartDate    string `json:"start_date,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// AssignmentChange represents the request body for reassigning or ending an assignment,
+// the companion is only used to reassign
+type AssignmentChange struct {
+	IDCompanion string `json:"id_companion,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+// AssignmentResponse represents the response body for an assignment
+type AssignmentResponse struct {
+	ID           string     `json:"id"`
+	IDStudent    string     `json:"id_student"`
+	IDCompanion  string     `json:"id_companion"`
+	IDSpeciality string     `json:"id_speciality"`
+	IDPrevious   string     `json:"id_previous,omitempty"`
+	StartDate    DBDateTime `json:"start_date"`
+	EndDate      DBDateTime `json:"end_date,omitzero"`
+	Status       string     `json:"status"`
+	Reason       string     `json:"reason,omitempty"`
+	EndReason    string     `json:"end_reason,omitempty"`
+	CreatedAt    DBDateTime `json:"created_at,omitzero"`
+	UpdatedAt    DBDateTime `json:"updated_at,omitzero"`
+}
+
+type assignmentStatus uint8
+
+const (
+	ASSIGNMENT_ACTIVE assignmentStatus = iota + 1
+	ASSIGNMENT_ENDED
+	ASSIGNMENT_REASSIGNED
+)
+
+var ASSIGNMENT_STATUS = map[assignmentStatus]string{
+	ASSIGNMENT_ACTIVE:     "Activa",
+	ASSIGNMENT_ENDED:      "Finalizada",
+	ASSIGNMENT_REASSIGNED: "Reasignada",
+}
+
+func assignmentStatusName(code assignmentStatus) string {
+	if name, exists := ASSIGNMENT_STATUS[code]; exists {
+		return name
+	}
+	return "Desconocido"
+}
+
+// ToInsert builds an active assignment, false when an ID or the start date is invalid
+func (a AssignmentCreate) ToInsert(speciality DBID) (AssignmentDBMongo, bool) {
+	obj := AssignmentDBMongo{
+		IDSpeciality: speciality,
+		StartDate:    Time.Now(),
+		Status:       ASSIGNMENT_ACTIVE,
+		Reason:       a.Reason,
+		CreatedAt:    Time.Now(),
+		UpdatedAt:    Time.Now(),
+		DeletedAt:    Time.Zero(),
+	}
+
+	if a.StartDate != "" {
+		start, ok := ParseSessionDate(a.StartDate)
+		if !ok {
+			return AssignmentDBMongo{}, false
+		}
+		obj.StartDate = start
+	}
+
+	if !ID.Ensure(a.IDStudent, &obj.IDStudent, "IDStudent") ||
+		!ID.Ensure(a.IDCompanion, &obj.IDCompanion, "IDCompanion") {
+		return AssignmentDBMongo{}, false
+	}
+	return obj, true
+}
+
+// Ended returns the changes that close the assignment with the given status
+func (a AssignmentDBMongo) Ended(status assignmentStatus, reason string, at time.Time) AssignmentDBMongo {
+	a.Status = status
+	a.EndReason = reason
+	a.EndDate = at
+	a.UpdatedAt = at
+	return a
+}
+
+func (a AssignmentDBMongo) IsActive() bool {
+	return a.Status == ASSIGNMENT_ACTIVE
+}
+
+func (a AssignmentDBMongo) ToResponse() AssignmentResponse {
+	response := AssignmentResponse{
+		ID:           a.ID.Hex(),
+		IDStudent:    a.IDStudent.Hex(),
+		IDCompanion:  a.IDCompanion.Hex(),
+		IDSpeciality: a.IDSpeciality.Hex(),
+		StartDate:    a.StartDate,
+		EndDate:      a.EndDate,
+		Status:       assignmentStatusName(a.Status),
+		Reason:       a.Reason,
+		EndReason:    a.EndReason,
+		CreatedAt:    a.CreatedAt,
+		UpdatedAt:    a.UpdatedAt,
+	}
+	if !a.IDPrevious.IsZero() {
+		response.IDPrevious = a.IDPrevious.Hex()
+	}
+	return response
+}
+func (a AssignmentDBMongo) IsEmpty() bool {
+	return a == (AssignmentDBMongo{})
+}
+
+func (AssignmentDBMongo) TableName() string {
+	return "assignments"
+}
+
+var _ DBModelInterface = (*AssignmentDBMongo)(nil)
@@ -0,0 +1,51 @@
+package models
+
+// Owners of a calendar feed
+const (
+	CALENDAR_STUDENT   = "student"
+	CALENDAR_COMPANION = "companion"
+)
+
+var CALENDAR_OWNERS = []string{CALENDAR_STUDENT, CALENDAR_COMPANION}
+
+// CalendarTokenDBMongo is the secret on the calendar feed URL of a student or companion,
+// calendar apps subscribe to the URL without signing in so the token is all the access they get
+type CalendarTokenDBMongo struct {
+	ID        DBID       `json:"_id,omitempty" bson:"_id,omitempty"`
+	Owner     string     `json:"owner" bson:"owner"`
+	IDOwner   DBID       `json:"id_owner" bson:"id_owner"`
+	Token     string     `json:"-" bson:"token"`
+	CreatedAt DBDateTime `json:"created_at" bson:"created_at"`
+}
+
+// CalendarFeedResponse represents the response body for the calendar feed of a student or companion
+type CalendarFeedResponse struct {
+	Owner     string     `json:"owner"`
+	IDOwner   string     `json:"id_owner"`
+	Token     string     `json:"token"`
+	URL       string     `json:"url"` // Relative to the API, ready to subscribe to
+	CreatedAt DBDateTime `json:"created_at"`
+}
+
+// URL returns where the feed is served, routes since v1 are served on every later version
+func (t CalendarTokenDBMongo) URL() string {
+	return "/api/v1/" + t.Owner + "/" + t.IDOwner.Hex() + "/calendar.ics?token=" + t.Token
+}
+
+func (t CalendarTokenDBMongo) ToResponse() CalendarFeedResponse {
+	return CalendarFeedResponse{
+		Owner:     t.Owner,
+		IDOwner:   t.IDOwner.Hex(),
+		Token:     t.Token,
+		URL:       t.URL(),
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func (t CalendarTokenDBMongo) IsEmpty() bool {
+	return t == (CalendarTokenDBMongo{})
+}
+
+func (CalendarTokenDBMongo) TableName() string {
+	return "calendar_tokens"
+}
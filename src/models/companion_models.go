@@ -9,6 +9,7 @@ type CompanionDBMongo struct {
 	InstitutionEmail string     `json:"institution_email,omitempty" bson:"institution_email,omitempty"`
 	PhoneNumber      string     `json:"phone_number" bson:"phone_number"`
 	IDSpeciality     DBID       `json:"id_speciality" bson:"id_speciality"`
+	Photo            PhotoDB    `json:"photo,omitzero" bson:"photo,omitempty"`
 	CreatedAt        DBDateTime `json:"created_at,omitzero" bson:"created_at,omitzero"`
 	UpdatedAt        DBDateTime `json:"updated_at,omitzero" bson:"updated_at,omitzero"`
 	DeletedAt        DBDateTime `json:"deleted_at" bson:"deleted_at"`
@@ -31,6 +32,7 @@ type CompanionResponse struct {
 	InstitutionEmail string     `json:"institution_email,omitempty" bson:"institution_email,omitempty"`
 	PhoneNumber      string     `json:"phone_number" bson:"phone_number"`
 	IDSpeciality     string     `json:"id_speciality" bson:"id_speciality"`
+	PhotoURL         string     `json:"photo_url,omitempty" bson:"photo_url,omitempty"`
 	CreatedAt        DBDateTime `json:"created_at,omitzero" bson:"created_at,omitzero"`
 	UpdatedAt        DBDateTime `json:"updated_at,omitzero" bson:"updated_at,omitzero"`
 }
@@ -81,6 +83,7 @@ func (c CompanionDBMongo) ToResponse() CompanionResponse {
 		InstitutionEmail: c.InstitutionEmail,
 		PhoneNumber:      c.PhoneNumber,
 		IDSpeciality:     c.IDSpeciality.Hex(),
+		PhotoURL:         c.Photo.URL("companion", c.ID),
 		CreatedAt:        c.CreatedAt,
 		UpdatedAt:        c.UpdatedAt,
 	}
@@ -94,3 +97,34 @@ func (CompanionDBMongo) TableName() string {
 }
 
 var _ DBModelInterface = (*CompanionDBMongo)(nil)
+
+// CaseloadResponse is the current workload of a companion
+type CaseloadResponse struct {
+	IDCompanion      string            `json:"id_companion"`
+	FirstName        string            `json:"first_name,omitempty"`
+	LastName         string            `json:"last_name,omitempty"`
+	WeekStart        DBDateTime        `json:"week_start"`
+	HoursThisWeek    float64           `json:"hours_this_week"`
+	SessionsThisWeek int               `json:"sessions_this_week"`
+	ActiveStudents   []CaseloadStudent `json:"active_students"`
+	UpcomingSessions []SessionResponse `json:"upcoming_sessions"`
+}
+
+// CaseloadStudent is a student a companion is currently working with
+type CaseloadStudent struct {
+	IDStudent   string `json:"id_student"`
+	FirstName   string `json:"first_name,omitempty"`
+	LastName    string `json:"last_name,omitempty"`
+	Sessions    int    `json:"sessions"`
+	LastSession string `json:"last_session,omitempty"`
+	NextSession string `json:"next_session,omitempty"`
+}
+
+// CompanionSuggestion is a companion proposed for a session, the best suited first
+type CompanionSuggestion struct {
+	Rank                int               `json:"rank"`
+	Companion           CompanionResponse `json:"companion"`
+	HoursThatWeek       float64           `json:"hours_that_week"`
+	ActiveStudents      int               `json:"active_students"`
+	SessionsWithStudent int               `json:"sessions_with_student"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// Index is a secondary index of a collection, configs.DB prefixes the keys
+// with the tenant on every collection not shared by the tenants
+type Index struct {
+	Model  DBModelInterface
+	Keys   []string
+	Unique bool
+}
+
+// INDEXES are made on MongoDB once connected, see configs.DB.EnsureIndexes
+var INDEXES = []Index{
+	{Model: StudentDBMongo{}, Keys: []string{"number_id"}},
+	{Model: StudentDBMongo{}, Keys: []string{"email"}},
+	{Model: StudentDBMongo{}, Keys: []string{"institution_email"}},
+	{Model: CompanionDBMongo{}, Keys: []string{"number_id"}},
+	{Model: CompanionDBMongo{}, Keys: []string{"email"}},
+	{Model: CompanionDBMongo{}, Keys: []string{"institution_email"}},
+	{Model: CompanionDBMongo{}, Keys: []string{"id_speciality"}},
+	{Model: SessionDBMongo{}, Keys: []string{"id_student"}},
+	{Model: SessionDBMongo{}, Keys: []string{"id_companion"}},
+	{Model: SessionDBMongo{}, Keys: []string{"date"}},
+	{Model: AssignmentDBMongo{}, Keys: []string{"id_student"}},
+	{Model: AssignmentDBMongo{}, Keys: []string{"id_companion"}},
+	{Model: SessionNoteDBMongo{}, Keys: []string{"id_session", "version"}, Unique: true}, // One note per version, concurrent edits can not both write the next
+	{Model: NotificationDBMongo{}, Keys: []string{"id_session"}},
+	{Model: NotificationPreferenceDBMongo{}, Keys: []string{"recipient", "id_recipient"}},
+	{Model: CalendarTokenDBMongo{}, Keys: []string{"owner", "id_owner"}},
+	{Model: JobDBMongo{}, Keys: []string{"status", "run_at"}},
+	{Model: WebhookDeliveryDBMongo{}, Keys: []string{"id_webhook"}},
+}
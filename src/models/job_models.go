@@ -0,0 +1,154 @@
+package models
+
+import "time"
+
+// Statuses of a job, a failed job goes back to queued until it runs out of attempts
+const (
+	JOB_QUEUED  = "queued"
+	JOB_RUNNING = "running"
+	JOB_DONE    = "done"
+	JOB_DEAD    = "dead" // Out of attempts or without a handler, kept for inspection and manual retry
+)
+
+var JOB_STATUSES = []string{JOB_QUEUED, JOB_RUNNING, JOB_DONE, JOB_DEAD}
+
+// JobDBMongo is a unit of background work, run by a worker once RunAt passes
+type JobDBMongo struct {
+	ID          DBID       `json:"_id,omitempty" bson:"_id,omitempty"`
+	Kind        string     `json:"kind" bson:"kind"`
+	Payload     string     `json:"payload" bson:"payload"` // JSON given to the handler of the kind
+	Status      string     `json:"status" bson:"status"`
+	Attempts    int        `json:"attempts" bson:"attempts"` // Runs started so far
+	MaxAttempts int        `json:"max_attempts" bson:"max_attempts"`
+	RunAt       DBDateTime `json:"run_at" bson:"run_at"`                   // When it is due, pushed back on every retry
+	LockedUntil DBDateTime `json:"locked_until" bson:"locked_until"`       // While running, a job still running past it is taken as crashed
+	Claim       string     `json:"claim,omitempty" bson:"claim,omitempty"` // Token of the latest run, only that run may store its outcome
+	LastError   string     `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	Tenant      DBID       `json:"tenant,omitzero" bson:"tenant,omitempty"` // University the job runs for, the one that queued it
+	CreatedAt   DBDateTime `json:"created_at,omitzero" bson:"created_at,omitempty"`
+	UpdatedAt   DBDateTime `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
+	FinishedAt  DBDateTime `json:"finished_at,omitzero" bson:"finished_at,omitempty"`
+}
+
+// JobResponse represents the response body for a job
+type JobResponse struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAt       DBDateTime `json:"run_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	Tenant      string     `json:"tenant,omitempty"`
+	CreatedAt   DBDateTime `json:"created_at,omitzero"`
+	UpdatedAt   DBDateTime `json:"updated_at,omitzero"`
+	FinishedAt  DBDateTime `json:"finished_at,omitzero"`
+}
+
+// NewJob builds a job due right away
+func NewJob(kind string, payload string, maxAttempts int, now time.Time) JobDBMongo {
+	return JobDBMongo{
+		Kind:        kind,
+		Payload:     payload,
+		Status:      JOB_QUEUED,
+		MaxAttempts: maxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Due reports whether a worker can take the job at now
+func (j JobDBMongo) Due(now time.Time) bool {
+	return j.Status == JOB_QUEUED && !j.RunAt.After(now) ||
+		j.Status == JOB_RUNNING && !j.LockedUntil.After(now)
+}
+
+// FinishedBefore reports whether the job is done or dead since before the time
+func (j JobDBMongo) FinishedBefore(before time.Time) bool {
+	return (j.Status == JOB_DONE || j.Status == JOB_DEAD) && j.FinishedAt.Before(before)
+}
+
+// Claimed returns the job taken by a worker until now plus lease
+func (j JobDBMongo) Claimed(claim string, now time.Time, lease time.Duration) JobDBMongo {
+	j.Status = JOB_RUNNING
+	j.Attempts++
+	j.Claim = claim
+	j.LockedUntil = now.Add(lease)
+	j.UpdatedAt = now
+	return j
+}
+
+// Succeeded returns the job finished at now
+func (j JobDBMongo) Succeeded(now time.Time) JobDBMongo {
+	j.Status = JOB_DONE
+	j.LastError = ""
+	j.UpdatedAt = now
+	j.FinishedAt = now
+	return j
+}
+
+// Failed returns the job queued again at retryAt, or dead when it has no attempts left
+func (j JobDBMongo) Failed(reason string, retryAt time.Time, now time.Time) JobDBMongo {
+	j.LastError = reason
+	j.UpdatedAt = now
+	if j.Attempts >= j.MaxAttempts {
+		j.Status = JOB_DEAD
+		j.FinishedAt = now
+		return j
+	}
+	j.Status = JOB_QUEUED
+	j.RunAt = retryAt
+	return j
+}
+
+// Dead returns the job given up at now, whatever its attempts left
+func (j JobDBMongo) Dead(reason string, now time.Time) JobDBMongo {
+	j.MaxAttempts = j.Attempts
+	return j.Failed(reason, now, now)
+}
+
+// Retried returns a dead job queued again with every attempt available
+func (j JobDBMongo) Retried(maxAttempts int, now time.Time) JobDBMongo {
+	j.Status = JOB_QUEUED
+	j.Attempts = 0
+	j.MaxAttempts = maxAttempts
+	j.RunAt = now
+	j.UpdatedAt = now
+	j.FinishedAt = time.Time{}
+	return j
+}
+
+func (j JobDBMongo) ToResponse() JobResponse {
+	tenant := ""
+	if !j.Tenant.IsZero() {
+		tenant = j.Tenant.Hex()
+	}
+	return JobResponse{
+		ID:          j.ID.Hex(),
+		Kind:        j.Kind,
+		Payload:     j.Payload,
+		Status:      j.Status,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		RunAt:       j.RunAt,
+		LastError:   j.LastError,
+		Tenant:      tenant,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+		FinishedAt:  j.FinishedAt,
+	}
+}
+func (j JobDBMongo) IsEmpty() bool {
+	return j == (JobDBMongo{})
+}
+
+// Shared marks the queue as shared, each job runs for the tenant that queued it
+func (JobDBMongo) Shared() {}
+
+func (JobDBMongo) TableName() string {
+	return "jobs"
+}
+
+var _ DBModelInterface = (*JobDBMongo)(nil)
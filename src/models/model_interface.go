@@ -21,6 +21,13 @@ type DBModelInterface interface {
 	IsEmpty() bool
 }
 
+// SharedModel is implemented by the models every tenant shares. configs.DB keeps the records
+// of any other model to the tenant of the context, see the tenant package.
+type SharedModel interface {
+	DBModelInterface
+	Shared()
+}
+
 type DBID = bson.ObjectID
 type DBDateTime = time.Time
 
@@ -0,0 +1,177 @@
+package models
+
+import "slices"
+
+// Events a student or companion is notified about
+const (
+	NOTIFICATION_CONFIRMATION = "confirmation" // The session was booked
+	NOTIFICATION_REMINDER     = "reminder"     // The session starts soon
+	NOTIFICATION_CANCELLATION = "cancellation" // The session was cancelled or deleted
+	NOTIFICATION_RESCHEDULE   = "reschedule"   // The session moved to another date
+)
+
+var NOTIFICATION_EVENTS = []string{
+	NOTIFICATION_CONFIRMATION,
+	NOTIFICATION_REMINDER,
+	NOTIFICATION_CANCELLATION,
+	NOTIFICATION_RESCHEDULE,
+}
+
+// Who gets notified
+const (
+	NOTIFICATION_STUDENT   = "student"
+	NOTIFICATION_COMPANION = "companion"
+)
+
+var NOTIFICATION_RECIPIENTS = []string{NOTIFICATION_STUDENT, NOTIFICATION_COMPANION}
+
+// Which email of the recipient is used
+const (
+	NOTIFICATION_ADDRESS_PERSONAL    = "personal"
+	NOTIFICATION_ADDRESS_INSTITUTION = "institution"
+)
+
+var NOTIFICATION_LANGUAGES = []string{"es", "en"}
+
+// NotificationPreferenceDBMongo is how a student or companion wants to be notified,
+// users without one get the personal email in the default language
+type NotificationPreferenceDBMongo struct {
+	ID          DBID       `json:"_id,omitempty" bson:"_id,omitempty"`
+	Recipient   string     `json:"recipient" bson:"recipient"`
+	IDRecipient DBID       `json:"id_recipient" bson:"id_recipient"`
+	Address     string     `json:"address" bson:"address"`
+	Language    string     `json:"language,omitempty" bson:"language,omitempty"` // Empty uses NOTIFY_LANGUAGE
+	Muted       []string   `json:"muted" bson:"muted"`                           // Events not sent
+	UpdatedAt   DBDateTime `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
+}
+
+// NotificationPreferenceCreate represents the request body for setting the preferences,
+// every field is replaced and empty ones go back to the default
+type NotificationPreferenceCreate struct {
+	Address  string   `json:"address,omitempty"`
+	Language string   `json:"language,omitempty"`
+	Muted    []string `json:"muted,omitempty"`
+}
+
+// NotificationPreferenceResponse represents the response body for the preferences
+type NotificationPreferenceResponse struct {
+	Recipient   string     `json:"recipient"`
+	IDRecipient string     `json:"id_recipient"`
+	Address     string     `json:"address"`
+	Language    string     `json:"language,omitempty"`
+	Muted       []string   `json:"muted"`
+	UpdatedAt   DBDateTime `json:"updated_at,omitzero"`
+}
+
+// NotificationDBMongo records a notification sent, or that failed to be sent, about a session
+type NotificationDBMongo struct {
+	ID          DBID       `json:"_id,omitempty" bson:"_id,omitempty"`
+	IDSession   DBID       `json:"id_session" bson:"id_session"`
+	Event       string     `json:"event" bson:"event"`
+	Recipient   string     `json:"recipient" bson:"recipient"`
+	IDRecipient DBID       `json:"id_recipient" bson:"id_recipient"`
+	To          string     `json:"to" bson:"to"`
+	Language    string     `json:"language" bson:"language"`
+	SessionDate string     `json:"session_date" bson:"session_date"` // A reminder is sent once per date, a rescheduled session gets another
+	Sender      string     `json:"sender" bson:"sender"`
+	Error       string     `json:"error,omitempty" bson:"error,omitempty"`
+	SentAt      DBDateTime `json:"sent_at" bson:"sent_at"`
+}
+
+// NotificationResponse represents the response body for a notification
+type NotificationResponse struct {
+	ID          string     `json:"id"`
+	IDSession   string     `json:"id_session"`
+	Event       string     `json:"event"`
+	Recipient   string     `json:"recipient"`
+	IDRecipient string     `json:"id_recipient"`
+	To          string     `json:"to"`
+	Language    string     `json:"language"`
+	SessionDate string     `json:"session_date"`
+	Sender      string     `json:"sender"`
+	Error       string     `json:"error,omitempty"`
+	SentAt      DBDateTime `json:"sent_at"`
+}
+
+// DefaultNotificationPreference is used for recipients who never set theirs
+func DefaultNotificationPreference(recipient string, id DBID) NotificationPreferenceDBMongo {
+	return NotificationPreferenceDBMongo{
+		Recipient:   recipient,
+		IDRecipient: id,
+		Address:     NOTIFICATION_ADDRESS_PERSONAL,
+		Muted:       []string{},
+	}
+}
+
+// ToInsert builds the preferences of the recipient, false when a value is not one of the accepted ones
+func (p NotificationPreferenceCreate) ToInsert(recipient string, id DBID) (NotificationPreferenceDBMongo, bool) {
+	obj := DefaultNotificationPreference(recipient, id)
+	obj.Language = p.Language
+	obj.UpdatedAt = Time.Now()
+	if p.Address != "" {
+		obj.Address = p.Address
+	}
+	if p.Muted != nil {
+		obj.Muted = p.Muted
+	}
+
+	if !slices.Contains([]string{NOTIFICATION_ADDRESS_PERSONAL, NOTIFICATION_ADDRESS_INSTITUTION}, obj.Address) ||
+		obj.Language != "" && !slices.Contains(NOTIFICATION_LANGUAGES, obj.Language) {
+		return NotificationPreferenceDBMongo{}, false
+	}
+	for _, event := range obj.Muted {
+		if !slices.Contains(NOTIFICATION_EVENTS, event) {
+			return NotificationPreferenceDBMongo{}, false
+		}
+	}
+	return obj, true
+}
+
+// Mutes reports whether the recipient does not want the event
+func (p NotificationPreferenceDBMongo) Mutes(event string) bool {
+	return slices.Contains(p.Muted, event)
+}
+
+func (p NotificationPreferenceDBMongo) ToResponse() NotificationPreferenceResponse {
+	return NotificationPreferenceResponse{
+		Recipient:   p.Recipient,
+		IDRecipient: p.IDRecipient.Hex(),
+		Address:     p.Address,
+		Language:    p.Language,
+		Muted:       nonNil(p.Muted),
+		UpdatedAt:   p.UpdatedAt,
+	}
+}
+func (p NotificationPreferenceDBMongo) IsEmpty() bool {
+	return p.ID.IsZero() && p.IDRecipient.IsZero() && p.Recipient == ""
+}
+
+func (NotificationPreferenceDBMongo) TableName() string {
+	return "notification_preferences"
+}
+
+func (n NotificationDBMongo) ToResponse() NotificationResponse {
+	return NotificationResponse{
+		ID:          n.ID.Hex(),
+		IDSession:   n.IDSession.Hex(),
+		Event:       n.Event,
+		Recipient:   n.Recipient,
+		IDRecipient: n.IDRecipient.Hex(),
+		To:          n.To,
+		Language:    n.Language,
+		SessionDate: n.SessionDate,
+		Sender:      n.Sender,
+		Error:       n.Error,
+		SentAt:      n.SentAt,
+	}
+}
+func (n NotificationDBMongo) IsEmpty() bool {
+	return n == (NotificationDBMongo{})
+}
+
+func (NotificationDBMongo) TableName() string {
+	return "notifications"
+}
+
+var _ DBModelInterface = (*NotificationPreferenceDBMongo)(nil)
+var _ DBModelInterface = (*NotificationDBMongo)(nil)
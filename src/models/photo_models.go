@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// photoRoutesVersion is the routes version the photo URLs are built on, see SetRoutesVersion
+var photoRoutesVersion atomic.Uint64
+
+// SetRoutesVersion sets the routes version the photo URLs point to, configs sets it from
+// configs.App.RoutesVersion since models can not import configs
+func SetRoutesVersion(version uint64) {
+	photoRoutesVersion.Store(version)
+}
+
+// PhotoDB locates the profile photo of a student or companion on the blob store,
+// every size is stored as <key>/<size>.<extension>
+type PhotoDB struct {
+	Key         string     `json:"key" bson:"key"`
+	Extension   string     `json:"extension" bson:"extension"`
+	ContentType string     `json:"content_type" bson:"content_type"`
+	UpdatedAt   DBDateTime `json:"updated_at" bson:"updated_at"`
+}
+
+// IsZero reports whether there is no photo, so updates leave the stored one alone
+func (p PhotoDB) IsZero() bool {
+	return p.Key == ""
+}
+
+// SizeKey returns the blob key of one size of the photo
+func (p PhotoDB) SizeKey(size string) string {
+	return p.Key + "/" + size + "." + p.Extension
+}
+
+// URL returns where the photo of the owner is served, empty when there is no photo.
+// The version query changes with every upload so clients can cache it for good.
+func (p PhotoDB) URL(owner string, id DBID) string {
+	if p.IsZero() {
+		return ""
+	}
+	return "/api/v" + strconv.FormatUint(max(photoRoutesVersion.Load(), 1), 10) + "/" + owner + "/" + id.Hex() + "/photo?v=" + strconv.FormatInt(p.UpdatedAt.Unix(), 10)
+}
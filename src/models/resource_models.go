@@ -0,0 +1,111 @@
+package models
+
+import (
+	"dainxor/atv/types"
+	"slices"
+)
+
+// Kinds of records a resource can be linked to
+const (
+	RESOURCE_LINK_STUDENT   = "student"
+	RESOURCE_LINK_COMPANION = "companion"
+	RESOURCE_LINK_SESSION   = "session"
+)
+
+var RESOURCE_LINK_KINDS = []string{RESOURCE_LINK_STUDENT, RESOURCE_LINK_COMPANION, RESOURCE_LINK_SESSION}
+
+// ResourceDBMongo is the metadata of an uploaded file, its content lives on the blob store under Key.
+// Files are deduplicated by Checksum per uploader, uploading the same content again returns their existing resource.
+type ResourceDBMongo struct {
+	ID          DBID             `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name        string           `json:"name" bson:"name"` // As uploaded
+	Key         string           `json:"key" bson:"key"`
+	Kind        int              `json:"kind" bson:"kind"` // types.Resource code
+	Extension   string           `json:"extension" bson:"extension"`
+	ContentType string           `json:"content_type" bson:"content_type"`
+	Size        int64            `json:"size" bson:"size"`
+	Checksum    string           `json:"checksum" bson:"checksum"` // SHA-256, hex encoded
+	Links       []ResourceLinkDB `json:"links" bson:"links"`
+	UploadedBy  string           `json:"uploaded_by,omitempty" bson:"uploaded_by,omitempty"`
+	CreatedAt   DBDateTime       `json:"created_at,omitzero" bson:"created_at,omitempty"`
+	UpdatedAt   DBDateTime       `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
+	DeletedAt   DBDateTime       `json:"deleted_at" bson:"deleted_at"`
+}
+
+// ResourceLinkDB points a resource to a student, a companion or a session
+type ResourceLinkDB struct {
+	Kind string `json:"kind" bson:"kind"`
+	ID   DBID   `json:"id" bson:"id"`
+}
+
+// ResourceLink represents the request body for linking a resource, and a link on the responses
+type ResourceLink struct {
+	Kind string `json:"kind"` // student, companion or session
+	ID   string `json:"id"`
+}
+
+// ResourceResponse represents the response body for a resource
+type ResourceResponse struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Kind        string         `json:"kind"`
+	Extension   string         `json:"extension"`
+	ContentType string         `json:"content_type"`
+	Size        int64          `json:"size"`
+	Checksum    string         `json:"checksum"`
+	Links       []ResourceLink `json:"links"`
+	UploadedBy  string         `json:"uploaded_by,omitempty"`
+	CreatedAt   DBDateTime     `json:"created_at,omitzero"`
+	UpdatedAt   DBDateTime     `json:"updated_at,omitzero"`
+}
+
+// SignedURLResponse represents a download URL that works without a token until it expires
+type SignedURLResponse struct {
+	URL       string     `json:"url"`
+	ExpiresAt DBDateTime `json:"expires_at"`
+}
+
+// ToDB validates the link, false when the kind is unknown or the ID invalid
+func (l ResourceLink) ToDB() (ResourceLinkDB, bool) {
+	link := ResourceLinkDB{Kind: l.Kind}
+	if !slices.Contains(RESOURCE_LINK_KINDS, l.Kind) || !ID.Ensure(l.ID, &link.ID, "ID") {
+		return ResourceLinkDB{}, false
+	}
+	return link, true
+}
+
+// LinkedTo reports whether the resource is linked to the record
+func (u ResourceDBMongo) LinkedTo(link ResourceLinkDB) bool {
+	return slices.Contains(u.Links, link)
+}
+
+func (u ResourceDBMongo) ToResponse() ResourceResponse {
+	kind := types.Resource.FromCode(u.Kind, u.Extension)
+	links := make([]ResourceLink, 0, len(u.Links))
+	for _, link := range u.Links {
+		links = append(links, ResourceLink{Kind: link.Kind, ID: link.ID.Hex()})
+	}
+
+	return ResourceResponse{
+		ID:          u.ID.Hex(),
+		Name:        u.Name,
+		Kind:        kind.Name(),
+		Extension:   kind.Extension(),
+		ContentType: u.ContentType,
+		Size:        u.Size,
+		Checksum:    u.Checksum,
+		Links:       links,
+		UploadedBy:  u.UploadedBy,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+	}
+}
+func (u ResourceDBMongo) IsEmpty() bool {
+	return u.ID.IsZero() && u.Key == ""
+}
+
+func (ResourceDBMongo) TableName() string {
+	return "resources"
+}
+
+var _ DBModelInterface = (*ResourceDBMongo)(nil)
@@ -0,0 +1,10 @@
+package models
+
+// SearchResult represents a student, companion or university found by a search
+type SearchResult struct {
+	Kind   string `json:"kind"` // student, companion or university
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"` // Institution email of people, location of universities
+	Score  int    `json:"score"`            // Higher is a better match
+}
@@ -0,0 +1,57 @@
+package models
+
+// Types of the session lifecycle events, a status change is told with its own type instead of SESSION_UPDATED
+const (
+	SESSION_CREATED   = "session.created"
+	SESSION_UPDATED   = "session.updated"
+	SESSION_COMPLETED = "session.completed"
+	SESSION_CANCELLED = "session.cancelled"
+	SESSION_NO_SHOW   = "session.no_show"
+	SESSION_DELETED   = "session.deleted"
+)
+
+var SESSION_EVENTS = []string{SESSION_CREATED, SESSION_UPDATED, SESSION_COMPLETED, SESSION_CANCELLED, SESSION_NO_SHOW, SESSION_DELETED}
+
+// SessionEvent is a change made to a session, Previous is empty on creation
+type SessionEvent struct {
+	Type     string
+	Session  SessionDBMongo
+	Previous SessionDBMongo
+	At       DBDateTime
+}
+
+// SessionEventResponse is a session event as told outside the API
+type SessionEventResponse struct {
+	Type     string           `json:"type"`
+	Session  SessionResponse  `json:"session"`
+	Previous *SessionResponse `json:"previous,omitempty"`
+	At       DBDateTime       `json:"at"`
+}
+
+// SessionChange returns the type of the event that took the session from before to after
+func SessionChange(before SessionDBMongo, after SessionDBMongo) string {
+	if before.Status != after.Status {
+		switch after.Status {
+		case STATUS_COMPLETED:
+			return SESSION_COMPLETED
+		case STATUS_CANCELLED:
+			return SESSION_CANCELLED
+		case STATUS_UNATTENDED:
+			return SESSION_NO_SHOW
+		}
+	}
+	return SESSION_UPDATED
+}
+
+func (e SessionEvent) ToResponse() SessionEventResponse {
+	response := SessionEventResponse{
+		Type:    e.Type,
+		Session: e.Session.ToResponse(),
+		At:      e.At,
+	}
+	if !e.Previous.IsEmpty() {
+		previous := e.Previous.ToResponse()
+		response.Previous = &previous
+	}
+	return response
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"dainxor/atv/types"
 	"errors"
+	"time"
 )
 
 type SessionDBMongo struct {
@@ -18,6 +19,7 @@ type SessionDBMongo struct {
 	SessionNotes        string        `json:"session_notes,omitempty" bson:"session_notes,omitempty"`
 	Date                string        `json:"date,omitempty" bson:"date,omitempty"`
 	Status              sessionStatus `json:"status,omitempty" bson:"status,omitempty"`
+	IDUniversity        DBID          `json:"id_university,omitzero" bson:"id_university,omitempty"` // Tenant of the session, set by configs.DB
 	CreatedAt           DBDateTime    `json:"created_at,omitzero" bson:"created_at,omitempty"`
 	UpdatedAt           DBDateTime    `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
 	DeletedAt           DBDateTime    `json:"deleted_at" bson:"deleted_at"`
@@ -68,6 +70,34 @@ var STATUS = map[sessionStatus]string{
 	STATUS_UNATTENDED: "No asistió",
 }
 
+// Layouts accepted on the Date of a session, tried in order
+var SESSION_DATE_LAYOUTS = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseSessionDate reads a session date in any of SESSION_DATE_LAYOUTS, dates without an offset are local
+func ParseSessionDate(date string) (time.Time, bool) {
+	for _, layout := range SESSION_DATE_LAYOUTS {
+		if parsed, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// When returns the start of the session, false when Date can not be read
+func (u SessionDBMongo) When() (time.Time, bool) {
+	return ParseSessionDate(u.Date)
+}
+
+// Is reports whether the session has the given status
+func (u SessionDBMongo) Is(status sessionStatus) bool {
+	return u.Status == status
+}
+
 func statusName(code sessionStatus) string {
 	if name, exists := STATUS[code]; exists {
 		return name
@@ -115,9 +145,11 @@ func (u SessionCreate) ToUpdate(extra map[string]string) types.Result[SessionDBM
 		CompanionSpeciality: extra["CompanionSpeciality"],
 		SessionNotes:        u.SessionNotes,
 		Date:                u.Date,
-		Status:              statusCode(u.Status),
 		UpdatedAt:           Time.Now(),
 	}
+	if u.Status != "" { // Left out, the status is kept instead of becoming unknown
+		obj.Status = statusCode(u.Status)
+	}
 
 	if !ID.OmitEmpty(u.IDStudent, &obj.IDStudent, "IDStudent") ||
 		!ID.OmitEmpty(u.IDCompanion, &obj.IDCompanion, "IDCompanion") ||
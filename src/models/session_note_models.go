@@ -0,0 +1,172 @@
+package models
+
+import (
+	"dainxor/atv/types"
+	"path"
+	"slices"
+)
+
+// SessionNoteDBMongo is one version of the structured record of a session.
+// Notes are append-only: every edit inserts the next version, the previous ones are kept as history.
+type SessionNoteDBMongo struct {
+	ID            DBID             `json:"_id,omitempty" bson:"_id,omitempty"`
+	IDSession     DBID             `json:"id_session" bson:"id_session"`
+	Version       int              `json:"version" bson:"version"`
+	Objectives    []string         `json:"objectives" bson:"objectives"`
+	Observations  string           `json:"observations" bson:"observations"`
+	AgreedActions []string         `json:"agreed_actions" bson:"agreed_actions"`
+	FollowUpDate  DBDateTime       `json:"follow_up_date,omitzero" bson:"follow_up_date,omitempty"`
+	Attachments   []NoteAttachment `json:"attachments" bson:"attachments"`           // Carried over to every later version
+	Author        string           `json:"author,omitempty" bson:"author,omitempty"` // User that wrote the version, empty when anonymous
+	CreatedAt     DBDateTime       `json:"created_at,omitzero" bson:"created_at,omitempty"`
+	DeletedAt     DBDateTime       `json:"deleted_at" bson:"deleted_at"`
+}
+
+// NoteAttachment is a file attached to a session note, its content lives on the blob store under Key
+type NoteAttachment struct {
+	Name        string     `json:"name" bson:"name"` // As uploaded
+	Key         string     `json:"key" bson:"key"`
+	Kind        int        `json:"kind" bson:"kind"` // types.Resource code
+	Extension   string     `json:"extension" bson:"extension"`
+	ContentType string     `json:"content_type" bson:"content_type"`
+	Size        int64      `json:"size" bson:"size"`
+	AddedAt     DBDateTime `json:"added_at" bson:"added_at"`
+}
+
+// SessionNoteCreate represents the request body for writing a new version of the notes of a session
+type SessionNoteCreate struct {
+	Objectives    []string `json:"objectives,omitempty"`
+	Observations  string   `json:"observations,omitempty"`
+	AgreedActions []string `json:"agreed_actions,omitempty"`
+	FollowUpDate  string   `json:"follow_up_date,omitempty"`
+}
+
+// SessionNoteResponse represents the response body for a version of the notes of a session
+type SessionNoteResponse struct {
+	ID            string                   `json:"id"`
+	IDSession     string                   `json:"id_session"`
+	Version       int                      `json:"version"`
+	Objectives    []string                 `json:"objectives"`
+	Observations  string                   `json:"observations"`
+	AgreedActions []string                 `json:"agreed_actions"`
+	FollowUpDate  DBDateTime               `json:"follow_up_date,omitzero"`
+	Attachments   []NoteAttachmentResponse `json:"attachments"`
+	Author        string                   `json:"author,omitempty"`
+	CreatedAt     DBDateTime               `json:"created_at,omitzero"`
+}
+
+// NoteAttachmentResponse represents an attachment of a session note, Name on the path downloads it
+type NoteAttachmentResponse struct {
+	Name        string     `json:"name"`
+	File        string     `json:"file"`
+	Kind        string     `json:"kind"`
+	Extension   string     `json:"extension"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	AddedAt     DBDateTime `json:"added_at"`
+}
+
+// ToInsert builds the version following previous, keeping its attachments.
+// False when the follow-up date is invalid.
+func (u SessionNoteCreate) ToInsert(previous SessionNoteDBMongo, session DBID, author string) (SessionNoteDBMongo, bool) {
+	obj := SessionNoteDBMongo{
+		IDSession:     session,
+		Version:       previous.Version + 1,
+		Objectives:    nonNil(u.Objectives),
+		Observations:  u.Observations,
+		AgreedActions: nonNil(u.AgreedActions),
+		Attachments:   nonNil(previous.Attachments),
+		Author:        author,
+		CreatedAt:     Time.Now(),
+		DeletedAt:     Time.Zero(),
+	}
+
+	if u.FollowUpDate != "" {
+		followUp, ok := ParseSessionDate(u.FollowUpDate)
+		if !ok {
+			return SessionNoteDBMongo{}, false
+		}
+		obj.FollowUpDate = followUp
+	}
+	return obj, true
+}
+
+// WithAttachment builds the version following u, with the same content and one more attachment
+func (u SessionNoteDBMongo) WithAttachment(attachment NoteAttachment, session DBID, author string) SessionNoteDBMongo {
+	next := u
+	next.ID = DBID{}
+	next.IDSession = session
+	next.Version = u.Version + 1
+	next.Objectives = nonNil(u.Objectives)
+	next.AgreedActions = nonNil(u.AgreedActions)
+	next.Attachments = append(slices.Clone(u.Attachments), attachment)
+	next.Author = author
+	next.CreatedAt = Time.Now()
+	next.DeletedAt = Time.Zero()
+	return next
+}
+
+// Attachment returns the attachment stored under the file name, the last segment of its key
+func (u SessionNoteDBMongo) Attachment(file string) (NoteAttachment, bool) {
+	for _, attachment := range u.Attachments {
+		if attachment.File() == file {
+			return attachment, true
+		}
+	}
+	return NoteAttachment{}, false
+}
+
+// File returns the last segment of the key, unique between the attachments of a session
+func (a NoteAttachment) File() string {
+	return path.Base(a.Key)
+}
+
+func (a NoteAttachment) ToResponse() NoteAttachmentResponse {
+	kind := types.Resource.FromCode(a.Kind, a.Extension)
+	return NoteAttachmentResponse{
+		Name:        a.Name,
+		File:        a.File(),
+		Kind:        kind.Name(),
+		Extension:   kind.Extension(),
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		AddedAt:     a.AddedAt,
+	}
+}
+
+func (u SessionNoteDBMongo) ToResponse() SessionNoteResponse {
+	attachments := make([]NoteAttachmentResponse, 0, len(u.Attachments))
+	for _, attachment := range u.Attachments {
+		attachments = append(attachments, attachment.ToResponse())
+	}
+
+	return SessionNoteResponse{
+		ID:            u.ID.Hex(),
+		IDSession:     u.IDSession.Hex(),
+		Version:       u.Version,
+		Objectives:    nonNil(u.Objectives),
+		Observations:  u.Observations,
+		AgreedActions: nonNil(u.AgreedActions),
+		FollowUpDate:  u.FollowUpDate,
+		Attachments:   attachments,
+		Author:        u.Author,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+func (u SessionNoteDBMongo) IsEmpty() bool {
+	return u.ID.IsZero() && u.Version == 0
+}
+
+func (SessionNoteDBMongo) TableName() string {
+	return "session_notes"
+}
+
+// nonNil returns an empty slice for nil, so lists are stored and answered as [] instead of null
+func nonNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
+
+var _ DBModelInterface = (*SessionNoteDBMongo)(nil)
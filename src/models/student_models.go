@@ -18,6 +18,7 @@ type StudentDBMongo struct {
 	Semester         uint       `json:"semester,omitempty" bson:"semester,omitempty"`
 	IDUniversity     DBID       `json:"id_university,omitempty" bson:"id_university,omitempty"`
 	PhoneNumber      string     `json:"phone_number,omitempty" bson:"phone_number,omitempty"`
+	Photo            PhotoDB    `json:"photo,omitzero" bson:"photo,omitempty"`
 	CreatedAt        DBDateTime `json:"created_at,omitzero" bson:"created_at,omitempty"`
 	UpdatedAt        DBDateTime `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
 	DeletedAt        DBDateTime `json:"deleted_at" bson:"deleted_at"`
@@ -51,6 +52,7 @@ type StudentResponse struct {
 	Semester         uint      `json:"semester" gorm:"not null"`
 	IDUniversity     string    `json:"id_university" gorm:"not null"`
 	PhoneNumber      string    `json:"phone_number"`
+	PhotoURL         string    `json:"photo_url,omitempty"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 }
@@ -116,6 +118,7 @@ func (user StudentDBMongo) ToResponse() StudentResponse {
 		Semester:         user.Semester,
 		IDUniversity:     user.IDUniversity.Hex(),
 		PhoneNumber:      user.PhoneNumber,
+		PhotoURL:         user.Photo.URL("student", user.ID),
 		CreatedAt:        user.CreatedAt,
 		UpdatedAt:        user.UpdatedAt,
 	}
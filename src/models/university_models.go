@@ -54,6 +54,9 @@ func (u UniversityDBMongo) IsEmpty() bool {
 	return u == (UniversityDBMongo{})
 }
 
+// Shared marks the universities as seen by every tenant, they are the tenants
+func (UniversityDBMongo) Shared() {}
+
 func (UniversityDBMongo) TableName() string {
 	return "universities"
 }
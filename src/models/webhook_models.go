@@ -0,0 +1,176 @@
+package models
+
+import (
+	"net/url"
+	"slices"
+)
+
+// Statuses of a webhook delivery
+const (
+	DELIVERY_PENDING   = "pending" // Queued or waiting for a retry
+	DELIVERY_DELIVERED = "delivered"
+	DELIVERY_FAILED    = "failed" // Out of attempts
+)
+
+// WebhookDBMongo is a subscription of an outside system to the session events
+type WebhookDBMongo struct {
+	ID          DBID       `json:"_id,omitempty" bson:"_id,omitempty"`
+	URL         string     `json:"url" bson:"url"`
+	Events      []string   `json:"events" bson:"events"`
+	Secret      string     `json:"-" bson:"secret"` // Signs the deliveries, only shown when the subscription is created
+	Description string     `json:"description,omitempty" bson:"description,omitempty"`
+	CreatedAt   DBDateTime `json:"created_at,omitzero" bson:"created_at,omitempty"`
+	UpdatedAt   DBDateTime `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
+	DeletedAt   DBDateTime `json:"deleted_at" bson:"deleted_at"`
+}
+
+// WebhookCreate represents the request body for subscribing, a secret is generated when none is given
+type WebhookCreate struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	Secret      string   `json:"secret,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// WebhookResponse represents the response body for a subscription
+type WebhookResponse struct {
+	ID          string     `json:"id"`
+	URL         string     `json:"url"`
+	Events      []string   `json:"events"`
+	Secret      string     `json:"secret,omitempty"` // Only when created
+	Description string     `json:"description,omitempty"`
+	CreatedAt   DBDateTime `json:"created_at,omitzero"`
+	UpdatedAt   DBDateTime `json:"updated_at,omitzero"`
+}
+
+// WebhookDeliveryDBMongo is an event sent, or to be sent, to a subscription
+type WebhookDeliveryDBMongo struct {
+	ID             DBID       `json:"_id,omitempty" bson:"_id,omitempty"`
+	IDWebhook      DBID       `json:"id_webhook" bson:"id_webhook"`
+	Event          string     `json:"event" bson:"event"`
+	Payload        string     `json:"payload" bson:"payload"` // Body sent, the same on every attempt and replay
+	Status         string     `json:"status" bson:"status"`
+	Attempts       int        `json:"attempts" bson:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty" bson:"response_status,omitempty"` // Of the latest attempt
+	LastError      string     `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	ReplayOf       DBID       `json:"replay_of,omitzero" bson:"replay_of,omitempty"`
+	CreatedAt      DBDateTime `json:"created_at,omitzero" bson:"created_at,omitempty"`
+	UpdatedAt      DBDateTime `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
+	DeliveredAt    DBDateTime `json:"delivered_at,omitzero" bson:"delivered_at,omitempty"`
+}
+
+// WebhookDeliveryResponse represents the response body for a delivery
+type WebhookDeliveryResponse struct {
+	ID             string     `json:"id"`
+	IDWebhook      string     `json:"id_webhook"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	ReplayOf       string     `json:"replay_of,omitempty"`
+	CreatedAt      DBDateTime `json:"created_at,omitzero"`
+	UpdatedAt      DBDateTime `json:"updated_at,omitzero"`
+	DeliveredAt    DBDateTime `json:"delivered_at,omitzero"`
+}
+
+// ToInsert builds the subscription, false when the URL is not http(s) or an event is unknown
+func (w WebhookCreate) ToInsert(secret string) (WebhookDBMongo, bool) {
+	target, err := url.Parse(w.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return WebhookDBMongo{}, false
+	}
+	if len(w.Events) == 0 {
+		return WebhookDBMongo{}, false
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(SESSION_EVENTS, event) {
+			return WebhookDBMongo{}, false
+		}
+	}
+
+	return WebhookDBMongo{
+		URL:         w.URL,
+		Events:      slices.Compact(slices.Sorted(slices.Values(w.Events))),
+		Secret:      secret,
+		Description: w.Description,
+		CreatedAt:   Time.Now(),
+		UpdatedAt:   Time.Now(),
+		DeletedAt:   Time.Zero(),
+	}, true
+}
+
+// Wants reports whether the subscription listens to the event
+func (w WebhookDBMongo) Wants(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+func (w WebhookDBMongo) ToResponse() WebhookResponse {
+	return WebhookResponse{
+		ID:          w.ID.Hex(),
+		URL:         w.URL,
+		Events:      nonNil(w.Events),
+		Description: w.Description,
+		CreatedAt:   w.CreatedAt,
+		UpdatedAt:   w.UpdatedAt,
+	}
+}
+func (w WebhookDBMongo) IsEmpty() bool {
+	return w.ID.IsZero() && w.URL == ""
+}
+
+func (WebhookDBMongo) TableName() string {
+	return "webhooks"
+}
+
+// NewDelivery builds a pending delivery of the payload to the subscription
+func NewDelivery(webhook DBID, event string, payload string) WebhookDeliveryDBMongo {
+	return WebhookDeliveryDBMongo{
+		IDWebhook: webhook,
+		Event:     event,
+		Payload:   payload,
+		Status:    DELIVERY_PENDING,
+		CreatedAt: Time.Now(),
+		UpdatedAt: Time.Now(),
+	}
+}
+
+// Replay builds a new delivery of the same payload
+func (d WebhookDeliveryDBMongo) Replay() WebhookDeliveryDBMongo {
+	replay := NewDelivery(d.IDWebhook, d.Event, d.Payload)
+	replay.ReplayOf = d.ID
+	return replay
+}
+
+func (d WebhookDeliveryDBMongo) ToResponse() WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:             d.ID.Hex(),
+		IDWebhook:      d.IDWebhook.Hex(),
+		Event:          d.Event,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+	if !d.ReplayOf.IsZero() {
+		response.ReplayOf = d.ReplayOf.Hex()
+	}
+	return response
+}
+func (d WebhookDeliveryDBMongo) IsEmpty() bool {
+	return d == (WebhookDeliveryDBMongo{})
+}
+
+func (WebhookDeliveryDBMongo) TableName() string {
+	return "webhook_deliveries"
+}
+
+var (
+	_ DBModelInterface = (*WebhookDBMongo)(nil)
+	_ DBModelInterface = (*WebhookDeliveryDBMongo)(nil)
+)
@@ -0,0 +1,159 @@
+// Package notify emails students and companions about their sessions: a confirmation
+// when one is booked, a reminder before it starts and a notice when it is cancelled
+// or moved. Messages go through the sender chosen with NOTIFY_SENDER, SMTP or, for
+// local development, a log or file sender, and every one is recorded on the database.
+// Session events and reminders are sent from background jobs, so a failed send is retried.
+package notify
+
+import (
+	"context"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/jobs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"net/mail"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	SENDER_LOG  = "log"
+	SENDER_FILE = "file"
+	SENDER_SMTP = "smtp"
+
+	DEFAULT_LANGUAGE = "es"
+	DEFAULT_FILE_DIR = "outbox"
+
+	SESSION_JOB = "notify.session" // Kind of the jobs sending a session event
+)
+
+// Message is an email ready to send
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+	Date    time.Time
+}
+
+// Sender delivers messages, Name identifies it on the notification records
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+	Name() string
+}
+
+type notifyType struct {
+	sender   atomic.Pointer[Sender]
+	from     atomic.Value // string
+	language atomic.Value // string
+}
+
+var current notifyType
+
+func init() {
+	envInit()
+	jobs.Register(SESSION_JOB, runSessionJob)
+
+	settings.OnReload(func(previous *settings.Loaded, next *settings.Loaded) {
+		if !reflect.DeepEqual(previous.Config.Notify, next.Config.Notify) {
+			ReloadEnv()
+		}
+	})
+}
+func ReloadEnv() {
+	envInit()
+}
+func envInit() {
+	config := settings.Get().Notify
+
+	from := config.From
+	if _, err := mail.ParseAddress(from); err != nil {
+		logger.Warning("NOTIFY_FROM is not an email address, using the default:", err)
+		from = "ATV <no-reply@atv.local>"
+	}
+	current.from.Store(from)
+
+	language := config.Language
+	if language == "" {
+		language = DEFAULT_LANGUAGE
+	}
+	current.language.Store(language)
+
+	var sender Sender = Log{}
+	switch config.Sender {
+	case SENDER_SMTP:
+		sender = NewSMTP(SMTPConfig{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			Timeout:  config.SMTPTimeout,
+		})
+	case SENDER_FILE:
+		dir := config.FileDir
+		if dir == "" {
+			dir = DEFAULT_FILE_DIR
+		}
+		sender = NewFile(dir)
+	}
+	Use(sender)
+	logger.Info("Notification sender:", Current().Name())
+}
+
+// Current returns the sender in use
+func Current() Sender {
+	return *current.sender.Load()
+}
+
+// Use replaces the sender in use until the next reload, for tests and for custom senders
+func Use(sender Sender) {
+	current.sender.Store(&sender)
+}
+
+// DefaultLanguage returns the language of the recipients without a preference
+func DefaultLanguage() string {
+	return current.language.Load().(string)
+}
+
+// sessionJob is the payload of a SESSION_JOB, the session as it was when the event happened
+type sessionJob struct {
+	Event        string                `json:"event"`
+	Session      models.SessionDBMongo `json:"session"`
+	PreviousDate string                `json:"previous_date,omitempty"`
+}
+
+// enqueue sends the event about the session on a background job, retried when a send fails.
+// It returns the error of queueing the job, the event is not sent then.
+func enqueue(ctx context.Context, event string, session models.SessionDBMongo, previousDate string) error {
+	_, err := jobs.Enqueue(ctx, SESSION_JOB, sessionJob{Event: event, Session: session, PreviousDate: previousDate})
+	return err
+}
+
+// pendingReminders returns the sessions with a reminder job waiting or running, their reminder is not queued again
+func pendingReminders(ctx context.Context) map[models.DBID]bool {
+	pending := map[models.DBID]bool{}
+	for _, status := range []string{models.JOB_QUEUED, models.JOB_RUNNING} {
+		queued, err := jobs.Current().List(ctx, status, SESSION_JOB)
+		if err != nil {
+			logger.WithContext(ctx).Warning("Failed to read the", status, "reminder jobs:", err)
+			continue
+		}
+		for _, job := range queued {
+			var payload sessionJob
+			if jobs.Decode(job, &payload) == nil && payload.Event == models.NOTIFICATION_REMINDER {
+				pending[payload.Session.ID] = true
+			}
+		}
+	}
+	return pending
+}
+
+// runSessionJob sends the event of the job, skipping the recipients a previous attempt reached
+func runSessionJob(ctx context.Context, job models.JobDBMongo) error {
+	var payload sessionJob
+	if err := jobs.Decode(job, &payload); err != nil {
+		return err
+	}
+	return notifySession(ctx, payload.Event, payload.Session, payload.PreviousDate, job.Attempts > 1)
+}
@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"dainxor/atv/logger"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bytes renders the message as a plain text email, headers encoded for non ASCII text
+func (m Message) Bytes() []byte {
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	domain := "atv.local"
+	if from, err := mail.ParseAddress(m.From); err == nil {
+		if _, host, found := strings.Cut(from.Address, "@"); found {
+			domain = host
+		}
+	}
+
+	var email bytes.Buffer
+	fmt.Fprintf(&email, "From: %s\r\n", m.From)
+	fmt.Fprintf(&email, "To: %s\r\n", m.To)
+	fmt.Fprintf(&email, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&email, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&email, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(random), domain)
+	email.WriteString("MIME-Version: 1.0\r\n")
+	email.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	email.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	email.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return email.Bytes()
+}
+
+// Log writes the messages to the log instead of sending them
+type Log struct{}
+
+func (Log) Name() string {
+	return SENDER_LOG
+}
+
+func (Log) Send(ctx context.Context, message Message) error {
+	logger.WithContext(ctx).Info("Notification to", message.To, "-", message.Subject, "\n"+message.Body)
+	return nil
+}
+
+// File writes every message as an .eml file on a directory, to open them with a mail client
+type File struct {
+	dir string
+}
+
+func NewFile(dir string) *File {
+	return &File{dir: dir}
+}
+
+func (f *File) Name() string {
+	return SENDER_FILE + " (" + f.dir + ")"
+}
+
+func (f *File) Send(ctx context.Context, message Message) error {
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	random := make([]byte, 4)
+	rand.Read(random)
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + hex.EncodeToString(random) + ".eml"
+	return os.WriteFile(filepath.Join(f.dir, name), message.Bytes(), 0o644)
+}
+
+// SMTPConfig locates the mail server, the credentials are optional
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Timeout  time.Duration // Longest a message may take to send, 0 only stops when the context ends
+}
+
+// SMTP sends the messages through a mail server, with STARTTLS when the server offers it
+type SMTP struct {
+	config SMTPConfig
+}
+
+func NewSMTP(config SMTPConfig) *SMTP {
+	return &SMTP{config: config}
+}
+
+func (s *SMTP) Name() string {
+	return SENDER_SMTP + " (" + s.config.Host + ")"
+}
+
+func (s *SMTP) Send(ctx context.Context, message Message) error {
+	from, err := mail.ParseAddress(message.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+
+	// smtp.SendMail has no timeout, a stuck server would block the sender for good
+	dialer := net.Dialer{Timeout: s.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) }) // Unblocks the exchange on cancel
+	defer stop()
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := s.exchange(client, from.Address, to.Address, message.Bytes()); err != nil {
+		return errors.Join(err, ctx.Err())
+	}
+	return nil
+}
+
+// exchange sends the message over an open connection, as smtp.SendMail does
+func (s *SMTP) exchange(client *smtp.Client, from string, to string, body []byte) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return err
+		}
+	}
+	if s.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support AUTH")
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(body); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
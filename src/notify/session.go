@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"cmp"
+	"context"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tenant"
+	"dainxor/atv/tracing"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const DISABLED_RECHECK = time.Minute // How often a disabled reminder loop looks if it was enabled
+
+// recipient is a student or companion told about a session
+type recipient struct {
+	kind        string
+	id          models.DBID
+	firstName   string
+	personal    string
+	institution string
+}
+
+// address picks the email the recipient prefers, falling back on the other one
+func (r recipient) address(preference models.NotificationPreferenceDBMongo) string {
+	if preference.Address == models.NOTIFICATION_ADDRESS_INSTITUTION {
+		return cmp.Or(r.institution, r.personal)
+	}
+	return cmp.Or(r.personal, r.institution)
+}
+
+// recipientsOf returns the student and the companion of the session, skipping those that can not be read
+func recipientsOf(ctx context.Context, session models.SessionDBMongo) []recipient {
+	recipients := []recipient{}
+
+	if student := db.Student.GetByID(ctx, session.IDStudent.Hex()); student.IsOk() {
+		s := student.Value()
+		recipients = append(recipients, recipient{models.NOTIFICATION_STUDENT, s.ID, s.FirstName, s.PersonalEmail, s.InstitutionEmail})
+	} else {
+		logger.WithContext(ctx).Warning("Student of session", session.ID.Hex(), "not notified:", student.Error())
+	}
+
+	if companion := db.Companion.GetByID(ctx, session.IDCompanion.Hex()); companion.IsOk() {
+		c := companion.Value()
+		recipients = append(recipients, recipient{models.NOTIFICATION_COMPANION, c.ID, c.FirstName, c.Email, c.InstitutionEmail})
+	} else {
+		logger.WithContext(ctx).Warning("Companion of session", session.ID.Hex(), "not notified:", companion.Error())
+	}
+	return recipients
+}
+
+// notifySession sends the event about the session to its student and companion, following their
+// preferences, and records each message. Reminders already sent for the session date are skipped,
+// as is any event already sent when retrying. It returns the sends that failed.
+func notifySession(ctx context.Context, event string, session models.SessionDBMongo, previousDate string, retry bool) error {
+	ctx, span := tracing.Start(ctx, "notify."+event)
+	defer span.End()
+
+	var failed []error
+	for _, to := range recipientsOf(ctx, session) {
+		preference := db.Notification.PreferenceOf(ctx, to.kind, to.id)
+		if preference.Mutes(event) {
+			logger.WithContext(ctx).Debug("The", to.kind, to.id.Hex(), "muted", event, "notifications")
+			continue
+		}
+		if (retry || event == models.NOTIFICATION_REMINDER) && db.Notification.WasSent(ctx, session, event, to.id) {
+			continue
+		}
+		address := to.address(preference)
+		if address == "" {
+			logger.WithContext(ctx).Warning("The", to.kind, to.id.Hex(), "has no email, not notified")
+			continue
+		}
+
+		language := cmp.Or(preference.Language, DefaultLanguage())
+		subject, body, err := Render(event, language, TemplateData{
+			Name:         to.firstName,
+			ForStudent:   to.kind == models.NOTIFICATION_STUDENT,
+			Student:      strings.TrimSpace(session.StudentName + " " + session.StudentSurname),
+			Companion:    strings.TrimSpace(session.CompanionName + " " + session.CompanionSurname),
+			Speciality:   session.CompanionSpeciality,
+			Date:         FormatDate(session.Date, language),
+			PreviousDate: FormatDate(previousDate, language),
+		})
+
+		sender := Current()
+		record := models.NotificationDBMongo{
+			IDSession:   session.ID,
+			Event:       event,
+			Recipient:   to.kind,
+			IDRecipient: to.id,
+			To:          address,
+			Language:    language,
+			SessionDate: session.Date,
+			Sender:      sender.Name(),
+			SentAt:      models.Time.Now(),
+		}
+		if err == nil {
+			err = sender.Send(ctx, Message{
+				From:    current.from.Load().(string),
+				To:      address,
+				Subject: subject,
+				Body:    body,
+				Date:    record.SentAt,
+			})
+		}
+		if err != nil {
+			logger.WithContext(ctx).Error("Failed to send", event, "of session", session.ID.Hex(), "to", address, ":", err)
+			record.Error = err.Error()
+			failed = append(failed, fmt.Errorf("%s to %s: %w", event, address, err))
+		}
+		db.Notification.Record(ctx, record)
+	}
+	return errors.Join(failed...)
+}
+
+// SessionCreated confirms a pending session to its student and companion.
+// It returns the error of queueing the confirmation.
+func SessionCreated(ctx context.Context, session models.SessionDBMongo) error {
+	if !session.Is(models.STATUS_PENDING) {
+		return nil
+	}
+	return enqueue(ctx, models.NOTIFICATION_CONFIRMATION, session, "")
+}
+
+// SessionChanged tells the student and companion when an update cancelled or moved the session.
+// It returns the error of queueing the notice.
+func SessionChanged(ctx context.Context, before models.SessionDBMongo, after models.SessionDBMongo) error {
+	switch {
+	case after.Is(models.STATUS_CANCELLED) && !before.Is(models.STATUS_CANCELLED):
+		return enqueue(ctx, models.NOTIFICATION_CANCELLATION, after, "")
+	case after.Is(models.STATUS_PENDING) && before.Date != after.Date:
+		return enqueue(ctx, models.NOTIFICATION_RESCHEDULE, after, before.Date)
+	}
+	return nil
+}
+
+// SessionDeleted tells the student and companion a pending session will not happen.
+// It returns the error of queueing the notice.
+func SessionDeleted(ctx context.Context, session models.SessionDBMongo) error {
+	if !session.Is(models.STATUS_PENDING) {
+		return nil
+	}
+	return enqueue(ctx, models.NOTIFICATION_CANCELLATION, session, "")
+}
+
+// remindersDue reports whether a recipient of the session still wants a reminder and has not got one
+func remindersDue(ctx context.Context, session models.SessionDBMongo) bool {
+	recipients := []struct {
+		kind string
+		id   models.DBID
+	}{
+		{models.NOTIFICATION_STUDENT, session.IDStudent},
+		{models.NOTIFICATION_COMPANION, session.IDCompanion},
+	}
+	for _, to := range recipients {
+		if !db.Notification.PreferenceOf(ctx, to.kind, to.id).Mutes(models.NOTIFICATION_REMINDER) &&
+			!db.Notification.WasSent(ctx, session, models.NOTIFICATION_REMINDER, to.id) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueReminders queues a reminder job for each pending session starting within NOTIFY_REMINDER_BEFORE
+// from now, unless one is already waiting or every recipient was reminded for the session date.
+// It returns how many reminders were queued.
+func QueueReminders(ctx context.Context, now time.Time) int {
+	ctx, span := tracing.Start(ctx, "notify.QueueReminders")
+	defer span.End()
+
+	sessions := db.Session.GetAll(ctx)
+	if sessions.IsErr() {
+		logger.WithContext(ctx).Error("Failed to read the sessions to remind:", sessions.Error())
+		return 0
+	}
+
+	window := settings.Get().Notify.ReminderBefore
+	pending := pendingReminders(ctx)
+	queued := 0
+	for _, session := range sessions.Value() {
+		when, ok := session.When()
+		if !ok || !session.Is(models.STATUS_PENDING) || !when.After(now) || when.Sub(now) > window || pending[session.ID] {
+			continue
+		}
+		scoped := tenant.With(ctx, session.IDUniversity)
+		if !remindersDue(scoped, session) {
+			continue
+		}
+		if enqueue(scoped, models.NOTIFICATION_REMINDER, session, "") == nil {
+			queued++
+		}
+	}
+	return queued
+}
+
+// Run queues the due reminders every NOTIFY_REMINDER_CHECK until ctx ends, the job workers send them
+func Run(ctx context.Context) {
+	for {
+		interval := settings.Get().Notify.ReminderCheck
+		if interval > 0 {
+			QueueReminders(ctx, time.Now())
+		} else {
+			interval = DISABLED_RECHECK
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
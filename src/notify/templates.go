@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"dainxor/atv/models"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Every event has a template per language on templates/<language>/<event>.tmpl,
+// defining a "subject" and a "body"
+//
+//go:embed templates
+var templateFiles embed.FS
+
+var templates = parseTemplates()
+
+// TemplateData is what the templates can show
+type TemplateData struct {
+	Name         string // First name of the recipient
+	ForStudent   bool   // The recipient is the student, otherwise the companion
+	Student      string
+	Companion    string
+	Speciality   string
+	Date         string // Start of the session, written in the language of the message
+	PreviousDate string // Start before a reschedule
+}
+
+func parseTemplates() map[string]*template.Template {
+	parsed := map[string]*template.Template{}
+	for _, language := range models.NOTIFICATION_LANGUAGES {
+		for _, event := range models.NOTIFICATION_EVENTS {
+			name := "templates/" + language + "/" + event + ".tmpl"
+			parsed[language+"/"+event] = template.Must(template.ParseFS(templateFiles, name))
+		}
+	}
+	return parsed
+}
+
+// Render writes the subject and body of the event in the language,
+// the default language is used for languages without templates
+func Render(event string, language string, data TemplateData) (subject string, body string, err error) {
+	tmpl, exists := templates[language+"/"+event]
+	if !exists {
+		tmpl, exists = templates[DefaultLanguage()+"/"+event]
+	}
+	if !exists {
+		return "", "", fmt.Errorf("no template for the %s event", event)
+	}
+
+	var subjectText, bodyText strings.Builder
+	if err := tmpl.ExecuteTemplate(&subjectText, "subject", data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.ExecuteTemplate(&bodyText, "body", data); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(subjectText.String()), strings.TrimSpace(bodyText.String()) + "\n", nil
+}
+
+var (
+	spanishDays   = []string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"}
+	spanishMonths = []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}
+)
+
+// FormatDate writes a session date for people, as it was given when it can not be read
+func FormatDate(date string, language string) string {
+	when, ok := models.ParseSessionDate(date)
+	if !ok {
+		return date
+	}
+	dateOnly := !strings.ContainsAny(date, "T ")
+
+	if language == "en" {
+		if dateOnly {
+			return when.Format("Monday, January 2, 2006")
+		}
+		return when.Format("Monday, January 2, 2006 at 15:04")
+	}
+	written := fmt.Sprintf("%s %d de %s de %d", spanishDays[when.Weekday()], when.Day(), spanishMonths[when.Month()-1], when.Year())
+	if dateOnly {
+		return written
+	}
+	return written + " a las " + when.Format("15:04")
+}
@@ -0,0 +1,234 @@
+// Package openapi builds an OpenAPI 3 document from the registered routes
+// and the JSON tags of the request and response models.
+package openapi
+
+import (
+	"cmp"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	OPENAPI_VERSION        = "3.0.3"
+	JSON_CONTENT_TYPE      = "application/json"
+	MULTIPART_CONTENT_TYPE = "multipart/form-data"
+	ANY_CONTENT_TYPE       = "*/*" // Responses sent with the content type of what they carry, like downloads
+
+	EVENT_STREAM_CONTENT_TYPE = "text/event-stream" // Server-sent events, the response stays open until the client leaves
+)
+
+// Operation describes a single method and path of the API
+type Operation struct {
+	Method      string
+	Path        string // As registered on gin, "/api/v1/student/:id"
+	Tag         string // Groups the operations on the UI, usually the resource
+	Summary     string
+	Description string
+	Deprecated  bool
+	Body        reflect.Type // Request body, nil when there is none
+	Response    reflect.Type // Type of the "data" field of the response, nil when unknown
+	List        bool         // The response data is a list of Response
+	Status      int          // Success status, 200 when 0
+	Envelope    bool         // The response is wrapped on data, message and extra
+	ContentType string       // Of the success response, application/json when empty
+	Upload      string       // Multipart field carrying a file, the body is a multipart form when set
+	Query       []QueryParameter
+}
+
+// QueryParameter is a query string parameter of an operation, always a string
+type QueryParameter struct {
+	Name        string
+	Required    bool
+	Description string
+}
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components components                      `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type operation struct {
+	OperationID string              `json:"operationId"`
+	Tags        []string            `json:"tags,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+	Parameters  []parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]response `json:"responses"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Methods OpenAPI can describe, gin also registers CONNECT for Any routes
+var methods = []string{
+	http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+	http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+}
+
+var paramRegex = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Build creates the document of the given operations
+func Build(info Info, operations []Operation) Document {
+	components := schemas{
+		"Error": envelope(&Schema{Type: "object"}),
+	}
+	document := Document{
+		OpenAPI: OPENAPI_VERSION,
+		Info:    info,
+		Paths:   map[string]map[string]operation{},
+	}
+
+	for _, op := range operations {
+		if !slices.Contains(methods, op.Method) {
+			continue
+		}
+
+		path := paramRegex.ReplaceAllString(op.Path, "{$1}")
+		if document.Paths[path] == nil {
+			document.Paths[path] = map[string]operation{}
+		}
+		document.Paths[path][strings.ToLower(op.Method)] = components.operation(op, path)
+	}
+
+	document.Components.Schemas = components
+	return document
+}
+
+func (s schemas) operation(op Operation, path string) operation {
+	result := operation{
+		OperationID: operationID(op.Method, path),
+		Summary:     op.Summary,
+		Description: op.Description,
+		Deprecated:  op.Deprecated,
+		Responses: map[string]response{
+			"default": {
+				Description: "Error",
+				Content:     jsonContent(&Schema{Ref: "#/components/schemas/Error"}),
+			},
+		},
+	}
+	if op.Tag != "" {
+		result.Tags = []string{op.Tag}
+	}
+
+	for _, match := range paramRegex.FindAllStringSubmatch(op.Path, -1) {
+		result.Parameters = append(result.Parameters, parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	for _, query := range op.Query {
+		result.Parameters = append(result.Parameters, parameter{
+			Name:        query.Name,
+			In:          "query",
+			Description: query.Description,
+			Required:    query.Required,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+
+	if op.Body != nil {
+		result.RequestBody = &requestBody{
+			Required: true,
+			Content:  jsonContent(s.schemaOf(op.Body)),
+		}
+	}
+	if op.Upload != "" {
+		form := &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{op.Upload: {Type: "string", Format: "binary"}},
+			Required:   []string{op.Upload},
+		}
+		result.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]mediaType{MULTIPART_CONTENT_TYPE: {Schema: form}},
+		}
+	}
+
+	body := &Schema{} // Any JSON, the route did not declare what it answers
+	if op.Response != nil || op.Envelope {
+		data := &Schema{}
+		if op.Response != nil {
+			data = s.schemaOf(op.Response)
+		}
+		if op.List {
+			data = &Schema{Type: "array", Items: data}
+		}
+		body = envelope(data)
+	}
+
+	content := jsonContent(body)
+	if op.ContentType != "" && op.ContentType != JSON_CONTENT_TYPE {
+		content = map[string]mediaType{op.ContentType: {Schema: &Schema{Type: "string"}}}
+	}
+
+	status := cmp.Or(op.Status, http.StatusOK)
+	result.Responses[strconv.Itoa(status)] = response{
+		Description: http.StatusText(status),
+		Content:     content,
+	}
+	return result
+}
+
+// envelope is the schema of types.JSONResponse with the given data
+func envelope(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"data":    data,
+			"message": {Type: "string"},
+			"extra":   {Type: "array", Items: &Schema{}},
+		},
+		Required: []string{"data", "message"},
+	}
+}
+
+func jsonContent(schema *Schema) map[string]mediaType {
+	return map[string]mediaType{JSON_CONTENT_TYPE: {Schema: schema}}
+}
+
+// operationID turns "GET /api/v1/student/{id}" into "get_api_v1_student_id"
+func operationID(method string, path string) string {
+	replacer := strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_")
+	return strings.ToLower(method) + strings.TrimRight(replacer.Replace(path), "_")
+}
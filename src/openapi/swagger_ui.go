@@ -0,0 +1,37 @@
+package openapi
+
+import (
+	"embed"
+	"strings"
+)
+
+// The page and the Swagger UI 5.18.2 scripts and styles, from swagger-ui-dist, are embedded in the binary
+// so the docs work without reaching a CDN. swagger-ui/LICENSE is the Swagger UI license.
+var (
+	//go:embed swagger_ui.html
+	swaggerUI string
+
+	//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js
+	swaggerAssets embed.FS
+)
+
+// SWAGGER_ASSETS are the Swagger UI files the page loads, with their content type
+var SWAGGER_ASSETS = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+}
+
+// SwaggerUI returns the Swagger UI page showing the spec served on specURL,
+// loading the SWAGGER_ASSETS from assetsURL
+func SwaggerUI(specURL string, assetsURL string) []byte {
+	return []byte(strings.NewReplacer("{{SPEC_URL}}", specURL, "{{ASSETS_URL}}", assetsURL).Replace(swaggerUI))
+}
+
+// SwaggerAsset returns the content of one of the SWAGGER_ASSETS
+func SwaggerAsset(name string) ([]byte, bool) {
+	if _, exist := SWAGGER_ASSETS[name]; !exist {
+		return nil, false
+	}
+	content, err := swaggerAssets.ReadFile("swagger-ui/" + name)
+	return content, err == nil
+}
@@ -0,0 +1,210 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// find returns the operation of a method and a path as registered on gin
+func (d Document) find(method string, path string) (operation, bool) {
+	operations, exist := d.Paths[paramRegex.ReplaceAllString(path, "{$1}")]
+	if !exist {
+		return operation{}, false
+	}
+	found, exist := operations[strings.ToLower(method)]
+	return found, exist
+}
+
+// Has reports whether the document describes the method and path, as registered on gin
+func (d Document) Has(method string, path string) bool {
+	_, exist := d.find(method, path)
+	return exist
+}
+
+// QueryParameters returns the names of the query string parameters of the operation
+func (d Document) QueryParameters(method string, path string) []string {
+	found, _ := d.find(method, path)
+	names := []string{}
+	for _, parameter := range found.Parameters {
+		if parameter.In == "query" {
+			names = append(names, parameter.Name)
+		}
+	}
+	return names
+}
+
+// RequestSchema returns the schema of the request body, nil when the operation takes none
+func (d Document) RequestSchema(method string, path string) *Schema {
+	found, exist := d.find(method, path)
+	if !exist || found.RequestBody == nil {
+		return nil
+	}
+	return found.RequestBody.Content[JSON_CONTENT_TYPE].Schema
+}
+
+// UploadField returns the multipart field carrying the file of the operation, "" when it takes no upload
+func (d Document) UploadField(method string, path string) string {
+	found, exist := d.find(method, path)
+	if !exist || found.RequestBody == nil {
+		return ""
+	}
+	form, exist := found.RequestBody.Content[MULTIPART_CONTENT_TYPE]
+	if !exist || form.Schema == nil || len(form.Schema.Required) == 0 {
+		return ""
+	}
+	return form.Schema.Required[0]
+}
+
+// ResponseSchema returns the content type and schema documented for a status.
+// Statuses without a response of their own use the default one, which only
+// describes errors, so documented is false for any other status below 400.
+func (d Document) ResponseSchema(method string, path string, status int) (contentType string, schema *Schema, documented bool) {
+	found, exist := d.find(method, path)
+	if !exist {
+		return "", nil, false
+	}
+
+	documented = true
+	response, exist := found.Responses[strconv.Itoa(status)]
+	if !exist {
+		response = found.Responses["default"]
+		documented = status >= http.StatusBadRequest
+	}
+	for contentType, media := range response.Content {
+		return contentType, media.Schema, documented
+	}
+	return "", nil, documented
+}
+
+// SuccessStatus returns the status documented for a successful call
+func (d Document) SuccessStatus(method string, path string) int {
+	found, _ := d.find(method, path)
+	for key := range found.Responses {
+		if status, err := strconv.Atoi(key); err == nil && status < http.StatusBadRequest {
+			return status
+		}
+	}
+	return 0
+}
+
+// Validate checks a value decoded by encoding/json against a schema of the document.
+// Object schemas with properties are closed: a property the model does not declare
+// is reported as well as a missing required one, so a renamed or leaked field fails.
+func (d Document) Validate(schema *Schema, value any) error {
+	return errors.Join(d.validate("$", schema, value)...)
+}
+
+func (d Document) validate(path string, schema *Schema, value any) []error {
+	if schema == nil {
+		return nil
+	}
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		referenced, exist := d.Components.Schemas[name]
+		if !exist {
+			return []error{fmt.Errorf("%s: unknown schema %s", path, schema.Ref)}
+		}
+		return d.validate(path, referenced, value)
+	}
+
+	if value == nil {
+		if schema.Type == "" || schema.Nullable {
+			return nil
+		}
+		return []error{fmt.Errorf("%s: is null, expected %s", path, schema.Type)}
+	}
+
+	switch schema.Type {
+	case "":
+		return nil // Anything goes
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []error{mismatch(path, schema, value)}
+		}
+		return d.validateObject(path, schema, object)
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return []error{mismatch(path, schema, value)}
+		}
+		errs := []error{}
+		for i, item := range array {
+			errs = append(errs, d.validate(path+"["+strconv.Itoa(i)+"]", schema.Items, item)...)
+		}
+		return errs
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return []error{mismatch(path, schema, value)}
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+				return []error{fmt.Errorf("%s: %q is not a date-time", path, text)}
+			}
+		}
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok || schema.Type == "integer" && number != math.Trunc(number) {
+			return []error{mismatch(path, schema, value)}
+		}
+		if schema.Minimum != nil && number < *schema.Minimum {
+			return []error{fmt.Errorf("%s: %v is below the minimum %v", path, number, *schema.Minimum)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []error{mismatch(path, schema, value)}
+		}
+	default:
+		return []error{fmt.Errorf("%s: unknown schema type %s", path, schema.Type)}
+	}
+	return nil
+}
+
+func (d Document) validateObject(path string, schema *Schema, object map[string]any) []error {
+	errs := []error{}
+	for _, name := range schema.Required {
+		if _, exist := object[name]; !exist {
+			errs = append(errs, fmt.Errorf("%s: missing required property %q", path, name))
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, exist := schema.Properties[name]
+		switch {
+		case exist:
+			errs = append(errs, d.validate(path+"."+name, property, object[name])...)
+		case schema.AdditionalProperties != nil:
+			errs = append(errs, d.validate(path+"."+name, schema.AdditionalProperties, object[name])...)
+		case len(schema.Properties) > 0:
+			errs = append(errs, fmt.Errorf("%s: undocumented property %q, expected one of %v", path, name, sortedKeys(schema.Properties)))
+		}
+	}
+	return errs
+}
+
+func mismatch(path string, schema *Schema, value any) error {
+	return fmt.Errorf("%s: expected %s, got %T %v", path, schema.Type, value, value)
+}
+
+func sortedKeys(properties map[string]*Schema) []string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,115 @@
+package photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+const exifOrientationTag = 0x0112
+
+// exifOrientation returns the EXIF orientation of a jpeg, 1 (upright) when it has none.
+// Only the orientation is read, the rest of the metadata is dropped when encoding.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for offset := 2; offset+4 <= len(data); {
+		if data[offset] != 0xFF {
+			return 1
+		}
+		marker := data[offset+1]
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if marker == 0xDA || length < 2 || offset+2+length > len(data) {
+			return 1 // Image data starts, no EXIF before it
+		}
+
+		segment := data[offset+4 : offset+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		offset += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag of the first IFD of a TIFF header
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := range entries {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient turns the picture upright following its EXIF orientation
+func orient(picture image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return picture
+	}
+
+	bounds := picture.Bounds()
+	source := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(source, source.Bounds(), picture, bounds.Min, draw.Src)
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Orientations 5 to 8 swap the sides
+	targetWidth, targetHeight := width, height
+	if orientation >= 5 {
+		targetWidth, targetHeight = height, width
+	}
+	target := image.NewNRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+
+	for y := range height {
+		for x := range width {
+			var tx, ty int
+			switch orientation {
+			case 2: // Mirrored
+				tx, ty = width-1-x, y
+			case 3: // Upside down
+				tx, ty = width-1-x, height-1-y
+			case 4: // Mirrored upside down
+				tx, ty = x, height-1-y
+			case 5: // Mirrored, turned left
+				tx, ty = y, x
+			case 6: // Turned left, rotate clockwise
+				tx, ty = height-1-y, x
+			case 7: // Mirrored, turned right
+				tx, ty = height-1-y, width-1-x
+			case 8: // Turned right, rotate counterclockwise
+				tx, ty = y, width-1-x
+			}
+			target.SetNRGBA(tx, ty, source.NRGBAAt(x, y))
+		}
+	}
+	return target
+}
@@ -0,0 +1,109 @@
+// Package photo turns an uploaded profile picture into the images served for it:
+// the format is checked, the picture is turned upright, its metadata (EXIF included)
+// is dropped by encoding it again, and square thumbnails are cut, all in pure Go.
+package photo
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // Registers the gif decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+)
+
+const (
+	SIZE_SMALL  = "small"  // Square, for lists
+	SIZE_MEDIUM = "medium" // Square, for profiles
+	SIZE_LARGE  = "large"  // The whole picture, bounded
+
+	MAX_PIXELS   = 40_000_000 // Larger pictures are rejected before decoding them
+	JPEG_QUALITY = 85
+)
+
+// Sizes are the images made from every photo, by name and length of the longest side
+var Sizes = map[string]int{
+	SIZE_SMALL:  64,
+	SIZE_MEDIUM: 256,
+	SIZE_LARGE:  1024,
+}
+
+// Formats accepted, as reported by image.DecodeConfig
+var Formats = []string{"jpeg", "png", "gif"}
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format, expected jpeg, png or gif")
+	ErrTooManyPixels     = errors.New("image too large")
+)
+
+// Image is one encoded size of a photo
+type Image struct {
+	Size    string
+	Content []byte
+}
+
+// Processed is a photo ready to store, every size encoded the same way
+type Processed struct {
+	Extension   string // jpg or png
+	ContentType string
+	Images      []Image
+}
+
+// Process validates and decodes the picture, then encodes every size of it.
+// Pictures with transparency (png and gif) are kept as png, the rest become jpeg.
+func Process(content io.Reader) (Processed, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return Processed{}, err
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || !slices.Contains(Formats, format) {
+		return Processed{}, ErrUnsupportedFormat
+	}
+	if config.Width*config.Height > MAX_PIXELS {
+		return Processed{}, ErrTooManyPixels
+	}
+
+	picture, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedFormat
+	}
+	if format == "jpeg" {
+		picture = orient(picture, exifOrientation(data))
+	}
+
+	processed := Processed{Extension: "jpg", ContentType: "image/jpeg"}
+	if format != "jpeg" {
+		processed.Extension, processed.ContentType = "png", "image/png"
+	}
+
+	names := make([]string, 0, len(Sizes))
+	for name := range Sizes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		var resized image.Image
+		if name == SIZE_LARGE {
+			resized = fit(picture, Sizes[name])
+		} else {
+			resized = thumbnail(picture, Sizes[name])
+		}
+
+		var encoded bytes.Buffer
+		if processed.Extension == "jpg" {
+			err = jpeg.Encode(&encoded, resized, &jpeg.Options{Quality: JPEG_QUALITY})
+		} else {
+			err = png.Encode(&encoded, resized)
+		}
+		if err != nil {
+			return Processed{}, err
+		}
+		processed.Images = append(processed.Images, Image{Size: name, Content: encoded.Bytes()})
+	}
+	return processed, nil
+}
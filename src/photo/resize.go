@@ -0,0 +1,67 @@
+package photo
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// thumbnail crops the centered square of the picture and scales it to size
+func thumbnail(picture image.Image, size int) image.Image {
+	bounds := picture.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	square := image.Rect(0, 0, side, side).Add(bounds.Min).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+	return scale(picture, square, min(size, side), min(size, side))
+}
+
+// fit scales the picture down so its longest side is at most size, smaller pictures are kept
+func fit(picture image.Image, size int) image.Image {
+	bounds := picture.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/bounds.Dx())
+		} else {
+			width, height = max(1, width*size/bounds.Dy()), size
+		}
+	}
+	return scale(picture, bounds, width, height)
+}
+
+// scale resamples the area of the picture to width by height, averaging the source pixels
+// each target pixel covers. It is a box filter, good enough for scaling photos down.
+func scale(picture image.Image, area image.Rectangle, width int, height int) *image.NRGBA {
+	source := image.NewNRGBA(image.Rect(0, 0, area.Dx(), area.Dy()))
+	draw.Draw(source, source.Bounds(), picture, area.Min, draw.Src)
+
+	target := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0, y1 := y*area.Dy()/height, max((y+1)*area.Dy()/height, y*area.Dy()/height+1)
+		for x := range width {
+			x0, x1 := x*area.Dx()/width, max((x+1)*area.Dx()/width, x*area.Dx()/width+1)
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pixel := source.NRGBAAt(sx, sy)
+					// Weighted by alpha so transparent pixels do not darken the edges
+					r += uint64(pixel.R) * uint64(pixel.A)
+					g += uint64(pixel.G) * uint64(pixel.A)
+					b += uint64(pixel.B) * uint64(pixel.A)
+					a += uint64(pixel.A)
+					count++
+				}
+			}
+			if a == 0 {
+				continue // Fully transparent
+			}
+			target.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / a),
+				G: uint8(g / a),
+				B: uint8(b / a),
+				A: uint8(a / count),
+			})
+		}
+	}
+	return target
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"cmp"
+	"context"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	DEFAULT_LIMIT      = "120/m" // Default limit of anonymous callers, per IP
+	DEFAULT_USER_LIMIT = "600/m" // Default limit of authenticated callers, per user
+)
+
+// limitsType is the configuration in use, replaced as a whole on reload
+type limitsType struct {
+	enabled   bool
+	ipLimit   Limit
+	userLimit Limit
+	groups    map[string]Limit // Keyed like the settings, "student" or "student_user"
+}
+
+type limiterType struct {
+	store  atomic.Value // Store
+	limits atomic.Pointer[limitsType]
+}
+
+var limiter limiterType
+
+func init() {
+	limiter.store.Store(Store(NewMemoryStore()))
+	envInit()
+
+	settings.OnReload(func(previous *settings.Loaded, current *settings.Loaded) {
+		if !reflect.DeepEqual(previous.Config.RateLimit, current.Config.RateLimit) {
+			ReloadEnv()
+		}
+	})
+}
+func ReloadEnv() {
+	envInit()
+}
+func envInit() {
+	config := settings.Get().RateLimit
+
+	defaultIP, _ := ParseLimit(DEFAULT_LIMIT)
+	defaultUser, _ := ParseLimit(DEFAULT_USER_LIMIT)
+	limits := &limitsType{
+		enabled:   config.Enabled,
+		ipLimit:   limitOf(config.Default, defaultIP),
+		userLimit: limitOf(config.DefaultUser, defaultUser),
+		groups:    map[string]Limit{},
+	}
+	for key, value := range config.Groups {
+		if limit, err := ParseLimit(value); err == nil {
+			limits.groups[key] = limit
+		}
+	}
+	limiter.limits.Store(limits)
+
+	logger.Info("Rate limiting enabled:", limits.enabled, "default limits:", limits.ipLimit, "per IP,", limits.userLimit, "per user")
+}
+
+// limitOf parses a configured limit, or returns fallback if missing or invalid
+func limitOf(value string, fallback Limit) Limit {
+	if value == "" {
+		return fallback
+	}
+
+	limit, err := ParseLimit(value)
+	if err != nil {
+		logger.Warning("Invalid rate limit", value, ":", err, "using:", fallback)
+		return fallback
+	}
+	return limit
+}
+
+// groupKey turns a route group into its settings key, e.g. "session-type" into "session_type"
+func groupKey(group string) string {
+	return strings.ToLower(strings.NewReplacer("-", "_", " ", "_", "/", "_").Replace(group))
+}
+
+// LimitsFor returns the per IP and per user limits of a route group,
+// read from RATE_LIMIT_<GROUP> and RATE_LIMIT_<GROUP>_USER, falling back to the defaults
+func LimitsFor(group string) (ipLimit Limit, userLimit Limit) {
+	limits := limiter.limits.Load()
+	key := groupKey(cmp.Or(group, "default"))
+
+	ipLimit, exist := limits.groups[key]
+	if !exist {
+		ipLimit = limits.ipLimit
+	}
+	userLimit, exist = limits.groups[key+"_user"]
+	if !exist {
+		userLimit = limits.userLimit
+	}
+	return ipLimit, userLimit
+}
+
+func Enabled() bool {
+	return limiter.limits.Load().enabled
+}
+
+// SetStore replaces the in-memory store, e.g. with a RedisStore when running several instances
+func SetStore(store Store) {
+	limiter.store.Store(store)
+}
+
+// Take takes a token from the bucket of key
+func Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	return limiter.store.Load().(Store).Take(ctx, key, limit)
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests can be made at once,
+// and the bucket refills at Rate requests per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited disables the limiter for the groups using it
+var Unlimited = Limit{Rate: math.Inf(1)}
+
+func (l Limit) IsUnlimited() bool {
+	return math.IsInf(l.Rate, 1)
+}
+func (l Limit) String() string {
+	if l.IsUnlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Burst, time.Duration(float64(l.Burst)/l.Rate*float64(time.Second)))
+}
+
+// ParseLimit reads limits written as "<requests>/<period>", e.g. "60/m", "10/s" or "1000/h".
+// The period can also be any time.Duration, as in "100/30s". "off" disables the limit.
+func ParseLimit(value string) (Limit, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "off" || value == "none" {
+		return Unlimited, nil
+	}
+
+	countTxt, periodTxt, found := strings.Cut(value, "/")
+	if !found {
+		return Limit{}, errors.New("expected <requests>/<period>")
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(countTxt))
+	if err != nil || count < 1 {
+		return Limit{}, errors.New("requests must be a positive integer")
+	}
+
+	var period time.Duration
+	switch periodTxt = strings.TrimSpace(periodTxt); periodTxt {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		period, err = time.ParseDuration(periodTxt)
+		if err != nil || period <= 0 {
+			return Limit{}, errors.New("invalid period")
+		}
+	}
+
+	return Limit{Rate: float64(count) / period.Seconds(), Burst: count}, nil
+}
+
+// Decision is the outcome of taking a token from a bucket
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Time until a token is available, 0 when allowed
+	Reset      time.Duration // Time until the bucket is full again
+}
+
+// Store keeps the buckets, the in-memory store works for a single instance
+// and a Redis store shares the buckets between instances.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// decide applies the token bucket algorithm to a bucket with the given tokens,
+// shared by every store so they behave the same
+func decide(tokens float64, limit Limit) (Decision, float64) {
+	decision := Decision{Limit: limit.Burst}
+
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+
+	decision.Remaining = int(tokens)
+	decision.Reset = secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate)
+	return decision, tokens
+}
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RedisScripter is the only thing the Redis store needs from a client,
+// any client with EVAL support can be adapted to it, e.g. with go-redis:
+//
+//	func (a adapter) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return a.client.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisScripter interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// The bucket is refilled and taken from atomically on the server,
+// it returns the tokens left after the call and whether the request is allowed
+const redisTakeScript = `
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(bucket[1]) or burst
+local last = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "last", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`
+
+// RedisStore keeps the buckets in Redis so every instance shares them
+type RedisStore struct {
+	client RedisScripter
+	prefix string
+}
+
+func NewRedisStore(client RedisScripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	now := float64(time.Now().UnixMicro()) / 1e6
+	reply, err := s.client.Eval(ctx, redisTakeScript, []string{s.prefix + key}, limit.Burst, limit.Rate, now)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return Decision{}, fmt.Errorf("unexpected reply from rate limit script: %v", reply)
+	}
+
+	var tokens float64
+	if _, err := fmt.Sscan(fmt.Sprint(values[1]), &tokens); err != nil {
+		return Decision{}, fmt.Errorf("unexpected tokens from rate limit script: %v", values[1])
+	}
+
+	// The script already took the token, so the decision is rebuilt from the tokens before it
+	allowed := fmt.Sprint(values[0]) == "1"
+	if allowed {
+		tokens++
+	}
+	decision, _ := decide(tokens, limit)
+	return decision, nil
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+	"dainxor/atv/openapi"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ADMIN_ROLE = "admin"
+
+func AdminRoutes(router *gin.Engine) {
+	adminRouter := router.Group("/admin")
+	adminRouter.Use(middleware.RoleMiddleware(ADMIN_ROLE), middleware.TenantMiddleware(false))
+	{
+		// The config and the job queue are shared by every tenant
+		acrossTenants := adminRouter.Group("", middleware.SuperAdminMiddleware())
+		acrossTenants.GET("/config", controller.Admin.Config)
+		acrossTenants.POST("/config/reload", controller.Admin.ReloadConfig)
+
+		acrossTenants.GET("/jobs", controller.Job.GetAll)
+		acrossTenants.GET("/jobs/:id", controller.Job.GetByID)
+		acrossTenants.POST("/jobs/:id/retry", controller.Job.Retry)
+
+		adminRouter.POST("/webhooks", controller.Webhook.Create)
+		adminRouter.GET("/webhooks", controller.Webhook.GetAll)
+		adminRouter.GET("/webhooks/:id", controller.Webhook.GetByID)
+		adminRouter.DELETE("/webhooks/:id", controller.Webhook.DeleteByID)
+		adminRouter.GET("/webhooks/:id/deliveries", controller.Webhook.GetDeliveries)
+		adminRouter.POST("/webhooks/deliveries/:id/replay", controller.Webhook.Replay)
+	}
+	catalogue.document(http.MethodGet, "/admin/config", openapi.Operation{Envelope: true})
+	catalogue.document(http.MethodPost, "/admin/config/reload", openapi.Operation{Envelope: true})
+
+	job := reflect.TypeFor[models.JobResponse]()
+	catalogue.document(http.MethodGet, "/admin/jobs", openapi.Operation{Envelope: true, Response: job, List: true, Query: []openapi.QueryParameter{
+		{Name: "status", Description: "Only the jobs with this status: " + strings.Join(models.JOB_STATUSES, ", ")},
+		{Name: "kind", Description: "Only the jobs of this kind"},
+	}})
+	catalogue.document(http.MethodGet, "/admin/jobs/:id", openapi.Operation{Envelope: true, Response: job})
+	catalogue.document(http.MethodPost, "/admin/jobs/:id/retry", openapi.Operation{Envelope: true, Response: job})
+
+	webhook := reflect.TypeFor[models.WebhookResponse]()
+	delivery := reflect.TypeFor[models.WebhookDeliveryResponse]()
+	catalogue.document(http.MethodPost, "/admin/webhooks", openapi.Operation{Envelope: true, Body: reflect.TypeFor[models.WebhookCreate](), Response: webhook, Status: http.StatusCreated})
+	catalogue.document(http.MethodGet, "/admin/webhooks", openapi.Operation{Envelope: true, Response: webhook, List: true})
+	catalogue.document(http.MethodGet, "/admin/webhooks/:id", openapi.Operation{Envelope: true, Response: webhook})
+	catalogue.document(http.MethodDelete, "/admin/webhooks/:id", openapi.Operation{Envelope: true, Response: webhook})
+	catalogue.document(http.MethodGet, "/admin/webhooks/:id/deliveries", openapi.Operation{Envelope: true, Response: delivery, List: true})
+	catalogue.document(http.MethodPost, "/admin/webhooks/deliveries/:id/replay", openapi.Operation{Envelope: true, Response: delivery, Status: http.StatusCreated})
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AssignmentRoutes(router *gin.Engine) {
+	// Grouping the assignment routes under "api/v#/assignment"
+	// An assignment links a student with their companion on a speciality
+	assignmentRoutes := NewVersionedGroup("assignment", middleware.RateLimitMiddleware("assignment")).
+		Models(models.AssignmentCreate{}, models.AssignmentResponse{})
+	{
+		assignmentRoutes.GET("/:id", controller.Assignment.GetByID)
+		assignmentRoutes.GET("/all", controller.Assignment.GetAll)
+		assignmentRoutes.GET("/student/:student_id", controller.Assignment.GetAllByStudentID).ReturnsList()
+
+		assignmentRoutes.POST("/", controller.Assignment.Create)
+		assignmentRoutes.POST("/:id/reassign", controller.Assignment.Reassign).
+			Body(models.AssignmentChange{}).Status(http.StatusCreated)
+		assignmentRoutes.POST("/:id/end", controller.Assignment.End).
+			Body(models.AssignmentChange{}).Status(http.StatusOK)
+	}
+	assignmentRoutes.Mount(router)
+}
@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"dainxor/atv/configs"
+	"dainxor/atv/openapi"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteEntry is a registered route as listed on /api/info/
+type RouteEntry struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Handler     string `json:"handler"`
+	Deprecated  bool   `json:"deprecated,omitempty"`
+	Sunset      string `json:"sunset,omitempty"`
+	Gone        bool   `json:"gone,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+type catalogueRoute struct {
+	group *VersionedGroup
+	route *VersionedRoute
+}
+
+// catalogueType keeps what the versioned routes declared, gin only knows methods, paths and handlers
+type catalogueType struct {
+	mutex  sync.RWMutex
+	routes map[string]catalogueRoute    // Keyed by "METHOD /path"
+	plain  map[string]openapi.Operation // Routes registered directly on gin, keyed the same way
+}
+
+var catalogue = catalogueType{routes: map[string]catalogueRoute{}, plain: map[string]openapi.Operation{}}
+
+func catalogueKey(method string, path string) string {
+	return method + " " + path
+}
+
+func (c *catalogueType) add(group *VersionedGroup, route *VersionedRoute, path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	methods := []string{route.method}
+	if route.method == METHOD_ANY {
+		methods = anyMethods
+	}
+	for _, method := range methods {
+		c.routes[catalogueKey(method, path)] = catalogueRoute{group: group, route: route}
+	}
+}
+
+// document describes a route registered directly on gin, only the body, response and query fields are used
+func (c *catalogueType) document(method string, path string, operation openapi.Operation) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.plain[catalogueKey(method, path)] = operation
+}
+
+func (c *catalogueType) findPlain(method string, path string) (openapi.Operation, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	operation, exist := c.plain[catalogueKey(method, path)]
+	return operation, exist
+}
+
+func (c *catalogueType) find(method string, path string) (catalogueRoute, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, exist := c.routes[catalogueKey(method, path)]
+	return entry, exist
+}
+
+// Methods gin registers for Any routes
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodHead,
+	http.MethodOptions, http.MethodDelete, http.MethodConnect, http.MethodTrace,
+}
+
+// handlerName turns "dainxor/atv/controller.studentType.GetByIDMongo-fm" into "studentType.GetByIDMongo"
+func handlerName(name string) string {
+	name = strings.TrimSuffix(path.Base(name), "-fm")
+	_, name, _ = strings.Cut(name, ".")
+	return name
+}
+
+// Catalogue lists every route registered on the router, sorted by path and method
+func Catalogue(router *gin.Engine) []RouteEntry {
+	entries := []RouteEntry{}
+	for _, info := range router.Routes() {
+		entry := RouteEntry{
+			Method:  info.Method,
+			Path:    info.Path,
+			Handler: handlerName(info.Handler),
+		}
+		if found, exist := catalogue.find(info.Method, info.Path); exist {
+			entry.Deprecated = found.route.isDeprecated()
+			entry.Sunset = found.route.sunset
+			entry.Gone = found.route.removed()
+			if entry.Gone {
+				entry.Handler = "gone" // The declared handlers are no longer mounted
+			}
+			entry.Replacement = found.route.replacement
+		}
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a RouteEntry, b RouteEntry) int {
+		return strings.Compare(a.Path+" "+a.Method, b.Path+" "+b.Method)
+	})
+	return entries
+}
+
+// Operations describes every registered route for the OpenAPI spec,
+// using the models declared by the versioned groups
+func Operations(router *gin.Engine) []openapi.Operation {
+	operations := []openapi.Operation{}
+	for _, entry := range Catalogue(router) {
+		operation := openapi.Operation{
+			Method:  entry.Method,
+			Path:    entry.Path,
+			Tag:     tagOf(entry.Path),
+			Summary: entry.Handler,
+		}
+
+		if plain, exist := catalogue.findPlain(entry.Method, entry.Path); exist {
+			operation.Response, operation.List, operation.Status = plain.Response, plain.List, plain.Status
+			operation.Envelope, operation.ContentType = plain.Envelope, plain.ContentType
+			operation.Body, operation.Query = plain.Body, plain.Query
+		}
+		if found, exist := catalogue.find(entry.Method, entry.Path); exist {
+			route := found.route
+			operation.Tag = found.group.name
+			operation.Envelope = true
+			operation.Deprecated = route.isDeprecated()
+			operation.Body, operation.Response, operation.List = route.body, route.response, route.list
+			operation.Status = route.status
+			operation.Query = route.query
+			if operation.Status == 0 && entry.Method == http.MethodPost {
+				operation.Status = http.StatusCreated
+			}
+
+			operation.Upload = route.upload
+			if operation.Body == nil && operation.Upload == "" && slices.Contains([]string{http.MethodPost, http.MethodPut, http.MethodPatch}, entry.Method) {
+				operation.Body = found.group.create
+			}
+			if operation.Response == nil {
+				operation.Response = found.group.response
+				operation.List = operation.List || strings.HasSuffix(route.path, "/all")
+			}
+			if route.produces != "" {
+				operation.ContentType = route.produces
+			}
+			if entry.Method == http.MethodPatch {
+				operation.Description = "Only the fields sent are updated."
+			}
+			if route.removed() {
+				operation.Description = "Gone since version " + route.sunset + ", use " + route.replacement + " instead."
+				operation.Body, operation.Response, operation.List, operation.Status = nil, nil, false, http.StatusGone
+				operation.Upload, operation.ContentType = "", ""
+			} else if operation.Deprecated && route.replacement != "" {
+				operation.Description = "Deprecated, use " + route.replacement + " instead."
+			}
+		}
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// tagOf groups the routes that are not versioned by their first path segment after /api
+func tagOf(routePath string) string {
+	segments := strings.Split(strings.Trim(routePath, "/"), "/")
+	if len(segments) > 1 && segments[0] == "api" {
+		return segments[1]
+	}
+	return segments[0]
+}
+
+// Spec builds the OpenAPI document of every registered route
+func Spec(router *gin.Engine) openapi.Document {
+	return openapi.Build(
+		openapi.Info{
+			Title:       "ATV API",
+			Version:     configs.App.ApiVersion(),
+			Description: "Generated from the registered routes and the request and response models.",
+		},
+		Operations(router),
+	)
+}
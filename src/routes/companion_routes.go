@@ -1,7 +1,12 @@
 package routes
 
 import (
+	"dainxor/atv/calendar"
 	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+	"dainxor/atv/openapi"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,19 +15,48 @@ func CompanionRoutes(router *gin.Engine) {
 	// Grouping the companion routes under "api/v#/companion"
 	// This allows for better organization and versioning of the API
 	// Grouping can also be done inside other groups
-	companionRouter := router.Group("api/v1/companion")
+	companionRoutes := NewVersionedGroup("companion", middleware.RateLimitMiddleware("companion")).
+		Models(models.CompanionCreate{}, models.CompanionResponse{})
 	{
-		companionRouter.GET("/:id", controller.Companion.GetByIDMongo)
-		companionRouter.GET("/all", controller.Companion.GetAllMongo)
-
-		companionRouter.POST("/", controller.Companion.CreateMongo)
-
-		companionRouter.PUT("/:id", controller.Companion.UpdateMongo)
-
-		companionRouter.PATCH("/:id", controller.Companion.PatchMongo)
-
-		companionRouter.DELETE("/:id", controller.Companion.DeleteByID)
-		//companionRouter.DELETE("/permanent-delete/:id/:confirm", controller.Student.ForceDeleteByID)
+		companionRoutes.GET("/:id", controller.Companion.GetByIDMongo)
+		companionRoutes.GET("/all", controller.Companion.GetAllMongo)
+		companionRoutes.GET("/by-number/:number_id", controller.Companion.GetByNumberID)
+		companionRoutes.GET("/by-email/:email", controller.Companion.GetByEmail)
+		companionRoutes.GET("/:id/caseload", controller.Companion.Caseload).
+			Returns(models.CaseloadResponse{})
+		companionRoutes.GET("/suggest", controller.Companion.Suggest).
+			Returns(models.CompanionSuggestion{}).ReturnsList().
+			Query("speciality", true, "ID of the speciality the companion must have").
+			Query("date", false, "Start of the session, now when empty").
+			Query("student", false, "ID of the student, companions who already saw them rank first")
+
+		companionRoutes.POST("/", controller.Companion.CreateMongo)
+
+		companionRoutes.PUT("/:id", controller.Companion.UpdateMongo)
+
+		companionRoutes.PATCH("/:id", controller.Companion.PatchMongo)
+
+		// Profile photo, served in every size of photo.Sizes
+		companionRoutes.PUT("/:id/photo", controller.Companion.PutPhoto).
+			Upload(controller.PHOTO_FIELD)
+		companionRoutes.GET("/:id/photo", controller.Companion.GetPhoto).
+			Produces(openapi.ANY_CONTENT_TYPE).
+			Query("size", false, "small, medium or large, medium when empty")
+
+		// Calendar feed, subscribed to with the token of its URL instead of signing in.
+		// Only the owner or an admin can see or revoke the URL.
+		companionRoutes.GET("/:id/calendar", middleware.OwnerMiddleware(ADMIN_ROLE), controller.Companion.GetCalendar).
+			Returns(models.CalendarFeedResponse{})
+		companionRoutes.DELETE("/:id/calendar", middleware.OwnerMiddleware(ADMIN_ROLE), controller.Companion.RevokeCalendar).
+			Returns(models.CalendarFeedResponse{})
+		companionRoutes.GET("/:id/calendar.ics", controller.Companion.Calendar).
+			Produces(calendar.CONTENT_TYPE).
+			Query("token", true, "Token of the feed URL, from GET /:id/calendar").
+			WithoutTenant()
+
+		companionRoutes.DELETE("/:id", controller.Companion.DeleteByID).Status(http.StatusAccepted)
+		//companionRoutes.DELETE("/permanent-delete/:id/:confirm", controller.Student.ForceDeleteByID)
 
 	}
+	companionRoutes.Mount(router)
 }
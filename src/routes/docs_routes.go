@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"dainxor/atv/openapi"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	OPENAPI_PATH = "/api/openapi.json"
+	DOCS_PATH    = "/api/docs"
+
+	DOCS_CONTENT_TYPE = "text/html; charset=utf-8"
+)
+
+// DocsRoutes serves the OpenAPI spec and the Swagger UI, with its embedded scripts and styles.
+// The spec is built on the first request, once every route is registered.
+func DocsRoutes(router *gin.Engine) {
+	spec := sync.OnceValue(func() openapi.Document {
+		return Spec(router)
+	})
+
+	router.GET(OPENAPI_PATH, func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec())
+	})
+	router.GET(DOCS_PATH, func(c *gin.Context) {
+		c.Data(http.StatusOK, DOCS_CONTENT_TYPE, openapi.SwaggerUI(OPENAPI_PATH, DOCS_PATH))
+	})
+	catalogue.document(http.MethodGet, DOCS_PATH, openapi.Operation{ContentType: DOCS_CONTENT_TYPE})
+
+	for name, contentType := range openapi.SWAGGER_ASSETS {
+		content, _ := openapi.SwaggerAsset(name)
+		router.GET(DOCS_PATH+"/"+name, func(c *gin.Context) {
+			c.Header("Cache-Control", "public, max-age=86400")
+			c.Data(http.StatusOK, contentType, content)
+		})
+		catalogue.document(http.MethodGet, DOCS_PATH+"/"+name, openapi.Operation{ContentType: contentType})
+	}
+}
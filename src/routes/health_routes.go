@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"dainxor/atv/controller"
+	"dainxor/atv/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func HealthRoutes(router *gin.Engine) {
+	router.GET("/healthz", controller.Health.Live)
+	router.GET("/readyz", controller.Health.Ready)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		catalogue.document(http.MethodGet, path, openapi.Operation{Envelope: true})
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"dainxor/atv/metrics"
+	"dainxor/atv/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func MetricsRoutes(router *gin.Engine) {
+	router.GET("/metrics", func(c *gin.Context) {
+		c.Header("Content-Type", metrics.CONTENT_TYPE)
+		c.Status(http.StatusOK)
+		metrics.Write(c.Writer)
+	})
+	catalogue.document(http.MethodGet, "/metrics", openapi.Operation{ContentType: metrics.CONTENT_TYPE})
+}
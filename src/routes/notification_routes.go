@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NotificationRoutes(router *gin.Engine) {
+	// Grouping the notification routes under "api/v#/notification"
+	// Students and companions get emails about their sessions, :kind is student or companion
+	notificationRoutes := NewVersionedGroup("notification", middleware.RateLimitMiddleware("notification")).
+		Models(models.NotificationPreferenceCreate{}, models.NotificationPreferenceResponse{})
+	{
+		notificationRoutes.GET("/preferences/:kind/:target_id", controller.Notification.GetPreference)
+		notificationRoutes.PUT("/preferences/:kind/:target_id", controller.Notification.SetPreference)
+
+		notificationRoutes.GET("/session/:session_id", controller.Notification.GetAllBySessionID).
+			Returns(models.NotificationResponse{}).ReturnsList()
+	}
+	notificationRoutes.Mount(router)
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"dainxor/atv/configs/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustProxies makes the router believe the client IP of X-Forwarded-For only from the
+// SERVER_TRUSTED_PROXIES peers, from anyone else the client IP is the peer address.
+// gin trusts every proxy otherwise, so a caller could pick its own IP for the rate limits.
+func TrustProxies(router *gin.Engine) error {
+	return router.SetTrustedProxies(settings.Get().Server.TrustedProxies)
+}
@@ -0,0 +1,29 @@
+package routes
+
+import "github.com/gin-gonic/gin"
+
+// Register adds every route of the API to the router, the server and the contract tests share it
+func Register(router *gin.Engine) {
+	// Root level routes
+	MainRoutes(router)
+	HealthRoutes(router)  // Liveness and readiness checks
+	MetricsRoutes(router) // Prometheus metrics
+	AdminRoutes(router)   // Effective configuration and reloads, admin role only
+
+	// Api routes
+	InfoRoutes(router) // Routes for information about the API
+	DocsRoutes(router) // OpenAPI spec and Swagger UI
+	TestRoutes(router) // Routes for testing purposes
+
+	// Versioned API routes
+	StudentRoutes(router)
+	UniversityRoutes(router)
+	SpecialityRoutes(router)
+	CompanionRoutes(router)
+	SessionTypeRoutes(router)
+	SessionRoutes(router)
+	AssignmentRoutes(router)
+	ResourceRoutes(router)
+	NotificationRoutes(router)
+	SearchRoutes(router)
+}
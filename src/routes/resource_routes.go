@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+	"dainxor/atv/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ResourceRoutes(router *gin.Engine) {
+	// Grouping the uploaded files under "api/v#/resource"
+	// The files live on the blob store, the routes handle their metadata, links and downloads
+	resourceRoutes := NewVersionedGroup("resource", middleware.RateLimitMiddleware("resource")).
+		Models(models.ResourceLink{}, models.ResourceResponse{})
+	{
+		resourceRoutes.POST("/", controller.Resource.Upload).
+			Upload(controller.RESOURCE_FIELD)
+
+		resourceRoutes.GET("/:id", controller.Resource.GetByID)
+		resourceRoutes.GET("/:id/url", controller.Resource.SignedURL).
+			Returns(models.SignedURLResponse{})
+		resourceRoutes.GET("/:id/download", controller.Resource.Download).
+			Produces(openapi.ANY_CONTENT_TYPE).
+			Query("expires", true, "Expiry of the URL, as given by /:id/url").
+			Query("signature", true, "Signature of the URL, as given by /:id/url").
+			WithoutTenant()
+		resourceRoutes.GET("/linked/:kind/:target_id", controller.Resource.GetAllLinkedTo).ReturnsList()
+
+		resourceRoutes.POST("/:id/link", controller.Resource.Link).Status(http.StatusOK)
+
+		resourceRoutes.DELETE("/:id", controller.Resource.DeleteByID)
+	}
+	resourceRoutes.Mount(router)
+}
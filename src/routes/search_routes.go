@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SearchRoutes(router *gin.Engine) {
+	// Search across the resources under "api/v#/search"
+	searchRoutes := NewVersionedGroup("search", middleware.RateLimitMiddleware("search")).
+		Models(nil, models.SearchResult{})
+	{
+		searchRoutes.GET("", controller.Search.Find).
+			ReturnsList().
+			Query("q", true, "Name, document number or email, accents and case are ignored").
+			Query("kind", false, "student, companion or university, every kind when empty").
+			Query("limit", false, "Most results answered, 20 when empty and at most 100")
+	}
+	searchRoutes.Mount(router)
+}
@@ -2,24 +2,47 @@ package routes
 
 import (
 	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+	"dainxor/atv/openapi"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SessionRoutes(router *gin.Engine) {
-	// Grouping the speciality routes under "api/v1/speciality"
-	sessionRouter := router.Group("api/v1/session")
+	// Grouping the speciality routes under "api/v#/speciality"
+	sessionRoutes := NewVersionedGroup("session", middleware.RateLimitMiddleware("session")).
+		Models(models.SessionCreate{}, models.SessionResponse{})
 	{
-		sessionRouter.POST("/", controller.Session.Create)
+		sessionRoutes.POST("/", controller.Session.Create)
 
-		sessionRouter.GET("/:id", controller.Session.GetByID)
-		sessionRouter.GET("/all", controller.Session.GetAll)
-		sessionRouter.GET("/student/:student_id", controller.Session.GetAllByStudentID)
+		sessionRoutes.GET("/:id", controller.Session.GetByID)
+		sessionRoutes.GET("/all", controller.Session.GetAll)
+		sessionRoutes.GET("/student/:student_id", controller.Session.GetAllByStudentID).ReturnsList()
 
-		sessionRouter.PUT("/:id", controller.Session.UpdateByID)
+		// Live changes as server-sent events, for the dashboards that used to poll /all
+		sessionRoutes.GET("/stream", controller.Session.Stream).
+			Produces(openapi.EVENT_STREAM_CONTENT_TYPE).
+			Query("id_student", false, "Only the sessions of this student").
+			Query("id_companion", false, "Only the sessions of this companion")
 
-		sessionRouter.PATCH("/:id", controller.Session.PatchByID)
+		sessionRoutes.PUT("/:id", controller.Session.UpdateByID)
 
-		sessionRouter.DELETE("/:id", controller.Session.DeleteByID)
+		sessionRoutes.PATCH("/:id", controller.Session.PatchByID)
+
+		sessionRoutes.DELETE("/:id", controller.Session.DeleteByID)
+
+		// Structured notes, every write adds a version
+		sessionRoutes.GET("/:id/notes", controller.SessionNote.GetLatest).
+			Returns(models.SessionNoteResponse{})
+		sessionRoutes.GET("/:id/notes/history", controller.SessionNote.GetHistory).
+			Returns(models.SessionNoteResponse{}).ReturnsList()
+		sessionRoutes.POST("/:id/notes", controller.SessionNote.Append).
+			Body(models.SessionNoteCreate{}).Returns(models.SessionNoteResponse{})
+		sessionRoutes.POST("/:id/notes/attachments", controller.SessionNote.Attach).
+			Upload(controller.NOTE_ATTACHMENT_FIELD).Returns(models.SessionNoteResponse{})
+		sessionRoutes.GET("/:id/notes/attachments/:file", controller.SessionNote.Download).
+			Produces(openapi.ANY_CONTENT_TYPE)
 	}
+	sessionRoutes.Mount(router)
 }
@@ -2,17 +2,21 @@ package routes
 
 import (
 	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SessionTypeRoutes(router *gin.Engine) {
-	// Grouping the session type routes under "api/v1/session-type"
-	sessionTypeRouter := router.Group("api/v1/session-type")
+	// Grouping the session type routes under "api/v#/session-type"
+	sessionTypeRoutes := NewVersionedGroup("session-type", middleware.RateLimitMiddleware("session-type")).
+		Models(models.SessionTypeCreate{}, models.SessionTypeResponse{})
 	{
-		sessionTypeRouter.POST("/", controller.SessionType.Create)
+		sessionTypeRoutes.POST("/", controller.SessionType.Create)
 
-		sessionTypeRouter.GET("/:id", controller.SessionType.GetByID)
-		sessionTypeRouter.GET("/all", controller.SessionType.GetAll)
+		sessionTypeRoutes.GET("/:id", controller.SessionType.GetByID)
+		sessionTypeRoutes.GET("/all", controller.SessionType.GetAll)
 	}
+	sessionTypeRoutes.Mount(router)
 }
@@ -2,17 +2,21 @@ package routes
 
 import (
 	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SpecialityRoutes(router *gin.Engine) {
-	// Grouping the speciality routes under "api/v1/speciality"
-	specialityRouter := router.Group("api/v1/speciality")
+	// Grouping the speciality routes under "api/v#/speciality"
+	specialityRoutes := NewVersionedGroup("speciality", middleware.RateLimitMiddleware("speciality")).
+		Models(models.SpecialityCreate{}, models.SpecialityResponse{})
 	{
-		specialityRouter.POST("/", controller.Speciality.Create)
+		specialityRoutes.POST("/", controller.Speciality.Create)
 
-		specialityRouter.GET("/:id", controller.Speciality.GetByID)
-		specialityRouter.GET("/all", controller.Speciality.GetAll)
+		specialityRoutes.GET("/:id", controller.Speciality.GetByID)
+		specialityRoutes.GET("/all", controller.Speciality.GetAll)
 	}
+	specialityRoutes.Mount(router)
 }
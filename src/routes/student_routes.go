@@ -1,45 +1,74 @@
 package routes
 
 import (
+	"dainxor/atv/calendar"
 	"dainxor/atv/controller"
-	"dainxor/atv/logger"
-	"dainxor/atv/types"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+	"dainxor/atv/openapi"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 func StudentRoutes(router *gin.Engine) {
-	// Grouping the student routes under "api/v#/student"
-	// This allows for better organization and versioning of the API
-	// Grouping can also be done inside other groups
-	router.Group("api/v0/user").Any("", func(ctx *gin.Context) {
-		ctx.JSON(types.Http.C300().MovedPermanently(),
-			types.EmptyResponse(
-				logger.DeprecateMsg("0.0.3", "0.1.0", "Use /api/v1/student/ instead"),
-			),
-		)
-	})
-
-	studentRouter := router.Group("api/v0/student")
-	{
-		studentRouter.GET("/:id", controller.Student.GetByIDGorm)
-		studentRouter.GET("/all", controller.Student.GetAllGorm)
+	// The v0 user routes were renamed to student and are gone since 0.1.0
+	userRoutes := NewVersionedGroup("user")
+	userRoutes.Any("").
+		Since(0).Until(0).
+		Deprecated("0.0.3").Sunset("0.1.0").
+		Replacement("/api/v1/student/")
+	userRoutes.Mount(router)
 
-		studentRouter.POST("/", controller.Student.CreateGorm)
-	}
-	studentRouter = router.Group("api/v1/student")
+	// Routes under "api/v#/student", each one declares the route versions it is served on
+	studentRoutes := NewVersionedGroup("student", middleware.RateLimitMiddleware("student")).
+		Models(models.StudentCreate{}, models.StudentResponse{})
 	{
-		studentRouter.GET("/:id", controller.Student.GetByIDMongo)
-		studentRouter.GET("/all", controller.Student.GetAllMongo)
+		// v0 used the SQL database, removed in 0.1.1
+		studentRoutes.GET("/:id", controller.Student.GetByIDGorm).
+			Since(0).Until(0).
+			Deprecated("0.0.3").Sunset("0.1.1").
+			Replacement("/api/v1/student/:id")
+		studentRoutes.GET("/all", controller.Student.GetAllGorm).
+			Since(0).Until(0).
+			Deprecated("0.0.3").Sunset("0.1.1").
+			Replacement("/api/v1/student/all")
+		studentRoutes.POST("/", controller.Student.CreateGorm).
+			Since(0).Until(0).
+			Deprecated("0.0.3").Sunset("0.1.1").
+			Replacement("/api/v1/student/")
+
+		studentRoutes.GET("/:id", controller.Student.GetByIDMongo)
+		studentRoutes.GET("/all", controller.Student.GetAllMongo)
+		studentRoutes.GET("/by-number/:number_id", controller.Student.GetByNumberID)
+		studentRoutes.GET("/by-email/:email", controller.Student.GetByEmail)
+
+		studentRoutes.POST("/", controller.Student.CreateMongo)
 
-		studentRouter.POST("/", controller.Student.CreateMongo)
+		studentRoutes.PUT("/:id", controller.Student.UpdateMongo)
 
-		studentRouter.PUT("/:id", controller.Student.UpdateMongo)
+		studentRoutes.PATCH("/:id", controller.Student.PatchMongo)
 
-		studentRouter.PATCH("/:id", controller.Student.PatchMongo)
+		// Profile photo, served in every size of photo.Sizes
+		studentRoutes.PUT("/:id/photo", controller.Student.PutPhoto).
+			Upload(controller.PHOTO_FIELD)
+		studentRoutes.GET("/:id/photo", controller.Student.GetPhoto).
+			Produces(openapi.ANY_CONTENT_TYPE).
+			Query("size", false, "small, medium or large, medium when empty")
 
-		studentRouter.DELETE("/:id", controller.Student.DeleteByID)
-		//studentRouter.DELETE("/permanent-delete/:id/:confirm", controller.Student.ForceDeleteByID)
+		// Calendar feed, subscribed to with the token of its URL instead of signing in.
+		// Only the owner or an admin can see or revoke the URL.
+		studentRoutes.GET("/:id/calendar", middleware.OwnerMiddleware(ADMIN_ROLE), controller.Student.GetCalendar).
+			Returns(models.CalendarFeedResponse{})
+		studentRoutes.DELETE("/:id/calendar", middleware.OwnerMiddleware(ADMIN_ROLE), controller.Student.RevokeCalendar).
+			Returns(models.CalendarFeedResponse{})
+		studentRoutes.GET("/:id/calendar.ics", controller.Student.Calendar).
+			Produces(calendar.CONTENT_TYPE).
+			Query("token", true, "Token of the feed URL, from GET /:id/calendar").
+			WithoutTenant()
 
+		studentRoutes.DELETE("/:id", controller.Student.DeleteByID).Status(http.StatusAccepted)
+		//studentRoutes.DELETE("/permanent-delete/:id/:confirm", controller.Student.ForceDeleteByID)
 	}
+	studentRoutes.Mount(router)
 }
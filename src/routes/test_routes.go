@@ -2,12 +2,13 @@ package routes
 
 import (
 	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func TestRoutes(router *gin.Engine) {
-	testRouter := router.Group("api/test")
+	testRouter := router.Group("api/test", middleware.RateLimitMiddleware("test"))
 	{
 		testRouter.GET("/get", controller.Test.Get)
 		testRouter.POST("/post", controller.Test.Post)
@@ -2,17 +2,22 @@ package routes
 
 import (
 	"dainxor/atv/controller"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 func UniversityRoutes(router *gin.Engine) {
-	// Grouping the university routes under "api/v1/university"
-	universityRouter := router.Group("api/v1/university")
+	// Grouping the university routes under "api/v#/university"
+	universityRoutes := NewVersionedGroup("university", middleware.RateLimitMiddleware("university")).
+		Models(models.UniversityCreate{}, models.UniversityResponse{}).
+		WithoutTenant() // The tenants themselves
 	{
-		universityRouter.POST("/", controller.University.Create)
+		universityRoutes.POST("/", controller.University.Create)
 
-		universityRouter.GET("/:id", controller.University.GetByID)
-		universityRouter.GET("/all", controller.University.GetAll)
+		universityRoutes.GET("/:id", controller.University.GetByID)
+		universityRoutes.GET("/all", controller.University.GetAll)
 	}
+	universityRoutes.Mount(router)
 }
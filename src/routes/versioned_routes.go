@@ -0,0 +1,280 @@
+package routes
+
+import (
+	"dainxor/atv/configs"
+	"dainxor/atv/logger"
+	"dainxor/atv/middleware"
+	"dainxor/atv/openapi"
+	"dainxor/atv/types"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DEFAULT_SINCE_VERSION = 1     // Route version new routes are served from
+	METHOD_ANY            = "ANY" // Serves the route on every method
+)
+
+// VersionedRoute is a handler together with the route versions it is served on
+// and, once it is deprecated, when it goes away and what replaces it
+type VersionedRoute struct {
+	method   string
+	path     string
+	handlers []gin.HandlerFunc
+
+	since   uint64 // First route version serving it
+	until   uint64 // Last route version serving it, only when bounded
+	bounded bool   // False while the route is still served on the current version
+
+	deprecated  string    // App version it was deprecated in
+	sunset      string    // App version from which it answers 410 Gone
+	sunsetDate  time.Time // Optional, sent on the Sunset header
+	replacement string    // Path of the route replacing it, sent on the Link header
+
+	body     reflect.Type // Request body documented on the OpenAPI spec, the group default when nil
+	response reflect.Type // Response data documented on the OpenAPI spec, the group default when nil
+	list     bool         // The response data is a list, by default the routes ending in /all
+	status   int          // Success status documented, 201 for POST and 200 for the rest when 0
+	query    []openapi.QueryParameter
+	upload   string // Multipart field carrying a file, the route takes a form instead of JSON
+	produces string // Content type of a success response that is not the JSON envelope
+
+	withoutTenant bool // Also answers without a tenant, acting across tenants
+}
+
+// Since sets the first route version serving the route
+func (r *VersionedRoute) Since(version uint64) *VersionedRoute {
+	r.since = version
+	return r
+}
+
+// Until sets the last route version serving the route
+func (r *VersionedRoute) Until(version uint64) *VersionedRoute {
+	r.until, r.bounded = version, true
+	return r
+}
+
+// Deprecated marks the route as deprecated from the given app version
+func (r *VersionedRoute) Deprecated(appVersion string) *VersionedRoute {
+	r.deprecated = appVersion
+	return r
+}
+
+// Sunset sets the app version from which the route answers 410 Gone,
+// date is optional and only used for the Sunset header
+func (r *VersionedRoute) Sunset(appVersion string, date ...time.Time) *VersionedRoute {
+	r.sunset = appVersion
+	if len(date) > 0 {
+		r.sunsetDate = date[0]
+	}
+	return r
+}
+
+// Replacement sets the path callers should move to
+func (r *VersionedRoute) Replacement(path string) *VersionedRoute {
+	r.replacement = path
+	return r
+}
+
+// Body sets the request body model documented for the route
+func (r *VersionedRoute) Body(model any) *VersionedRoute {
+	r.body = reflect.TypeOf(model)
+	return r
+}
+
+// Returns sets the response model documented for the route
+func (r *VersionedRoute) Returns(model any) *VersionedRoute {
+	r.response = reflect.TypeOf(model)
+	return r
+}
+
+// ReturnsList documents the response as a list of the response model
+func (r *VersionedRoute) ReturnsList() *VersionedRoute {
+	r.list = true
+	return r
+}
+
+// Query documents a query string parameter of the route
+func (r *VersionedRoute) Query(name string, required bool, description string) *VersionedRoute {
+	r.query = append(r.query, openapi.QueryParameter{Name: name, Required: required, Description: description})
+	return r
+}
+
+// Upload documents the request as a multipart form carrying a file on field
+func (r *VersionedRoute) Upload(field string) *VersionedRoute {
+	r.upload = field
+	return r
+}
+
+// Produces documents a success response sent as is, like a file download, instead of the JSON envelope
+func (r *VersionedRoute) Produces(contentType string) *VersionedRoute {
+	r.produces = contentType
+	return r
+}
+
+// Status sets the success status documented for the route
+func (r *VersionedRoute) Status(code int) *VersionedRoute {
+	r.status = code
+	return r
+}
+
+// WithoutTenant lets the route answer requests without a tenant, acting across tenants.
+// Meant for links authorized by their own signature or token, opened without headers.
+func (r *VersionedRoute) WithoutTenant() *VersionedRoute {
+	r.withoutTenant = true
+	return r
+}
+
+func (r *VersionedRoute) servedOn(version uint64) bool {
+	return version >= r.since && (!r.bounded || version <= r.until)
+}
+
+// removed reports whether the app reached the sunset version
+func (r *VersionedRoute) removed() bool {
+	return r.sunset != "" && logger.VersionReached(r.sunset)
+}
+
+// isDeprecated reports whether the app reached the deprecation version, a sunset implies a deprecation
+func (r *VersionedRoute) isDeprecated() bool {
+	return r.sunset != "" || (r.deprecated != "" && logger.VersionReached(r.deprecated))
+}
+
+// lifecycleHeaders sets the Deprecation, Sunset and Link headers of the route
+func (r *VersionedRoute) lifecycleHeaders(c *gin.Context) {
+	c.Header("Deprecation", "true")
+	if !r.sunsetDate.IsZero() {
+		c.Header("Sunset", r.sunsetDate.UTC().Format(http.TimeFormat))
+	}
+	if r.replacement != "" {
+		c.Header("Link", "<"+r.replacement+">; rel=\"successor-version\"")
+	}
+}
+
+// deprecationMiddleware logs every call to a deprecated route and tells the caller about it
+func (r *VersionedRoute) deprecationMiddleware(path string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r.lifecycleHeaders(c)
+		if r.sunset != "" {
+			logger.Deprecate(r.deprecated, r.sunset, "Called", r.method, path, "use", r.replacement, "instead")
+		} else {
+			logger.Warning("Called deprecated route", r.method, path, "use", r.replacement, "instead")
+		}
+		c.Next()
+	}
+}
+
+// goneHandler answers every call to a removed route
+func (r *VersionedRoute) goneHandler() gin.HandlerFunc {
+	reason := fmt.Sprintf("Removed in version %s", r.sunset)
+	if r.replacement != "" {
+		reason += ", use " + r.replacement + " instead"
+	}
+
+	return func(c *gin.Context) {
+		r.lifecycleHeaders(c)
+		c.JSON(types.Http.C400().Gone(),
+			types.EmptyResponse(
+				"Gone",
+				reason,
+			),
+		)
+	}
+}
+
+// VersionedGroup collects the routes of a resource and mounts them under /api/v<N>/<name>
+// for every route version up to configs.App.RoutesVersion
+type VersionedGroup struct {
+	name       string
+	middleware []gin.HandlerFunc
+	routes     []*VersionedRoute
+
+	create   reflect.Type // Default request body of POST, PUT and PATCH routes
+	response reflect.Type // Default response data of every route
+
+	withoutTenant bool // Every route answers without a tenant, for the resources tenants share
+}
+
+func NewVersionedGroup(name string, middleware ...gin.HandlerFunc) *VersionedGroup {
+	return &VersionedGroup{name: name, middleware: middleware}
+}
+
+// Models sets the request and response models documented by default for the routes of the group,
+// usually the *Create and *Response structs of the resource
+func (g *VersionedGroup) Models(create any, response any) *VersionedGroup {
+	g.create, g.response = reflect.TypeOf(create), reflect.TypeOf(response)
+	return g
+}
+
+// WithoutTenant lets every route of the group answer without a tenant, see VersionedRoute.WithoutTenant
+func (g *VersionedGroup) WithoutTenant() *VersionedGroup {
+	g.withoutTenant = true
+	return g
+}
+
+// Handle adds a route served from DEFAULT_SINCE_VERSION on
+func (g *VersionedGroup) Handle(method string, path string, handlers ...gin.HandlerFunc) *VersionedRoute {
+	route := &VersionedRoute{
+		method:   method,
+		path:     path,
+		handlers: handlers,
+		since:    DEFAULT_SINCE_VERSION,
+	}
+	g.routes = append(g.routes, route)
+	return route
+}
+func (g *VersionedGroup) GET(path string, handlers ...gin.HandlerFunc) *VersionedRoute {
+	return g.Handle(http.MethodGet, path, handlers...)
+}
+func (g *VersionedGroup) POST(path string, handlers ...gin.HandlerFunc) *VersionedRoute {
+	return g.Handle(http.MethodPost, path, handlers...)
+}
+func (g *VersionedGroup) PUT(path string, handlers ...gin.HandlerFunc) *VersionedRoute {
+	return g.Handle(http.MethodPut, path, handlers...)
+}
+func (g *VersionedGroup) PATCH(path string, handlers ...gin.HandlerFunc) *VersionedRoute {
+	return g.Handle(http.MethodPatch, path, handlers...)
+}
+func (g *VersionedGroup) DELETE(path string, handlers ...gin.HandlerFunc) *VersionedRoute {
+	return g.Handle(http.MethodDelete, path, handlers...)
+}
+func (g *VersionedGroup) Any(path string, handlers ...gin.HandlerFunc) *VersionedRoute {
+	return g.Handle(METHOD_ANY, path, handlers...)
+}
+
+// Mount registers every route on each route version it is served on, up to the current one.
+// Removed routes answer 410 Gone and deprecated ones add the Deprecation, Sunset and Link headers.
+func (g *VersionedGroup) Mount(router *gin.Engine) {
+	current := configs.App.RoutesVersion()
+
+	for version := uint64(0); version <= current; version++ {
+		var group *gin.RouterGroup
+
+		for _, route := range g.routes {
+			if !route.servedOn(version) {
+				continue
+			}
+			if group == nil {
+				group = router.Group(fmt.Sprintf("api/v%d/%s", version, g.name), g.middleware...)
+			}
+
+			handlers := append([]gin.HandlerFunc{middleware.TenantMiddleware(g.withoutTenant || route.withoutTenant)}, route.handlers...)
+			path := group.BasePath() + route.path
+			if route.removed() {
+				handlers = []gin.HandlerFunc{route.goneHandler()}
+			} else if route.isDeprecated() {
+				handlers = append([]gin.HandlerFunc{route.deprecationMiddleware(path)}, handlers...)
+			}
+
+			if route.method == METHOD_ANY {
+				group.Any(route.path, handlers...)
+			} else {
+				group.Handle(route.method, route.path, handlers...)
+			}
+			catalogue.add(g, route, path)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/models"
+	"dainxor/atv/tenant"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// cacheKey identifies the records of one kind a tenant sees
+type cacheKey struct {
+	tenant models.DBID
+	kind   string
+}
+
+type cacheEntry struct {
+	documents []document
+	built     time.Time
+}
+
+// cacheType keeps the documents of each kind between searches. Writes through configs.DB drop the
+// kind they touched, and every entry is rebuilt after SEARCH_INDEX_TTL to catch other instances.
+type cacheType struct {
+	mutex      sync.Mutex
+	entries    map[cacheKey]cacheEntry
+	generation uint64 // Bumped on every drop, a build that raced one is not kept
+}
+
+var cache = cacheType{entries: map[cacheKey]cacheEntry{}}
+
+// kindOf maps the collections the search reads to their kind
+var kindOf = map[string]string{
+	models.StudentDBMongo{}.TableName():    KIND_STUDENT,
+	models.CompanionDBMongo{}.TableName():  KIND_COMPANION,
+	models.UniversityDBMongo{}.TableName(): KIND_UNIVERSITY,
+}
+
+func init() {
+	configs.DB.OnWrite(func(ctx context.Context, collection string) {
+		if kind, exist := kindOf[collection]; exist {
+			Invalidate(kind)
+		}
+	})
+	settings.OnReload(func(previous *settings.Loaded, next *settings.Loaded) {
+		if !reflect.DeepEqual(previous.Config.DB, next.Config.DB) || previous.Config.Tenancy != next.Config.Tenancy {
+			Invalidate(KINDS...)
+		}
+	})
+}
+
+// Invalidate drops the cached records of the kinds for every tenant, the next search reads them again
+func Invalidate(kinds ...string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for key := range cache.entries {
+		for _, kind := range kinds {
+			if key.kind == kind {
+				delete(cache.entries, key)
+			}
+		}
+	}
+	cache.generation++
+}
+
+// AddCached adds the records of the kind the tenant of ctx sees, from the cache while it is fresh.
+// Otherwise build adds them to an empty index, which is cached unless a write happened meanwhile.
+func (i *Index) AddCached(ctx context.Context, kind string, build func(ctx context.Context, fresh *Index) error) error {
+	id, _ := tenant.From(ctx)
+	key := cacheKey{tenant: id, kind: kind}
+
+	cache.mutex.Lock()
+	entry, exist := cache.entries[key]
+	generation := cache.generation
+	cache.mutex.Unlock()
+
+	if !exist || time.Since(entry.built) >= settings.Get().Search.IndexTTL {
+		var fresh Index
+		if err := build(ctx, &fresh); err != nil {
+			return err
+		}
+		entry = cacheEntry{documents: fresh.documents, built: time.Now()}
+
+		cache.mutex.Lock()
+		if cache.generation == generation {
+			cache.entries[key] = entry
+		}
+		cache.mutex.Unlock()
+	}
+	i.documents = append(i.documents, entry.documents...)
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package search finds students, companions and universities by partial name, document number
+// or email. Matching ignores case and accents, so "garcia" finds "García", and tolerates a typo
+// on longer words. Results are ranked by how well every word of the query matched.
+package search
+
+import (
+	"cmp"
+	"dainxor/atv/models"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Kinds of result
+const (
+	KIND_STUDENT    = "student"
+	KIND_COMPANION  = "companion"
+	KIND_UNIVERSITY = "university"
+)
+
+var KINDS = []string{KIND_STUDENT, KIND_COMPANION, KIND_UNIVERSITY}
+
+const (
+	MIN_QUERY     = 2   // Shorter queries would match nearly everything
+	DEFAULT_LIMIT = 20  // Results answered when the limit is not given
+	MAX_LIMIT     = 100 // Most results answered
+)
+
+// Points of a query word, by how it matched a field
+const (
+	SCORE_FIELD  = 10 // The whole field, like a document number or an email
+	SCORE_WORD   = 8  // A word of the field
+	SCORE_PREFIX = 6  // The start of a word of the field
+	SCORE_INSIDE = 3  // Somewhere in the field
+	SCORE_TYPO   = 2  // A word of the field with one typo, two on long words
+)
+
+const SCORE_FULL_NAME = 5 // Extra points when the query is the whole name
+
+// Normalize lowers the text and strips its accents, the form both the query and the fields are compared in
+func Normalize(text string) string {
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+	if err != nil {
+		stripped = text
+	}
+	return strings.Join(strings.Fields(strings.ToLower(stripped)), " ")
+}
+
+// document is a record as the search sees it
+type document struct {
+	result models.SearchResult
+	fields []string // Normalized
+}
+
+func newDocument(kind string, id models.DBID, name string, detail string, fields ...string) document {
+	doc := document{result: models.SearchResult{Kind: kind, ID: id.Hex(), Name: name, Detail: detail}}
+	for _, field := range append([]string{name}, fields...) {
+		if field = Normalize(field); field != "" {
+			doc.fields = append(doc.fields, field)
+		}
+	}
+	return doc
+}
+
+// Index holds the records to search, build it with the Add methods
+type Index struct {
+	documents []document
+}
+
+func (i *Index) AddStudents(students []models.StudentDBMongo) {
+	for _, student := range students {
+		i.documents = append(i.documents, newDocument(KIND_STUDENT, student.ID,
+			strings.TrimSpace(student.FirstName+" "+student.LastName), student.InstitutionEmail,
+			student.NumberID, student.PersonalEmail, student.InstitutionEmail,
+		))
+	}
+}
+
+func (i *Index) AddCompanions(companions []models.CompanionDBMongo) {
+	for _, companion := range companions {
+		i.documents = append(i.documents, newDocument(KIND_COMPANION, companion.ID,
+			strings.TrimSpace(companion.FirstName+" "+companion.LastName), companion.InstitutionEmail,
+			companion.NumberID, companion.Email, companion.InstitutionEmail,
+		))
+	}
+}
+
+func (i *Index) AddUniversities(universities []models.UniversityDBMongo) {
+	for _, university := range universities {
+		i.documents = append(i.documents, newDocument(KIND_UNIVERSITY, university.ID,
+			university.Name, university.Location,
+			university.Location,
+		))
+	}
+}
+
+// Find returns up to limit records matching every word of the query, best first
+func (i *Index) Find(query string, limit int) []models.SearchResult {
+	query = Normalize(query)
+	words := strings.Fields(query)
+	results := []models.SearchResult{}
+	if len(words) == 0 {
+		return results
+	}
+
+	for _, doc := range i.documents {
+		total := 0
+		for _, word := range words {
+			best := 0
+			for _, field := range doc.fields {
+				best = max(best, score(word, field))
+			}
+			if best == 0 {
+				total = 0
+				break // Every word has to match
+			}
+			total += best
+		}
+		if total == 0 {
+			continue
+		}
+		if len(doc.fields) > 0 && doc.fields[0] == query {
+			total += SCORE_FULL_NAME
+		}
+		result := doc.result
+		result.Score = total
+		results = append(results, result)
+	}
+
+	slices.SortStableFunc(results, func(a models.SearchResult, b models.SearchResult) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(Normalize(a.Name), Normalize(b.Name)))
+	})
+	return results[:min(len(results), limit)]
+}
+
+// score returns the points of a query word on a field, 0 when it does not match
+func score(word string, field string) int {
+	if word == field {
+		return SCORE_FIELD
+	}
+
+	best := 0
+	for _, part := range strings.FieldsFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		switch {
+		case part == word:
+			return SCORE_WORD
+		case strings.HasPrefix(part, word):
+			best = max(best, SCORE_PREFIX)
+		case typos(word, part) <= allowedTypos(word):
+			best = max(best, SCORE_TYPO)
+		}
+	}
+	if best == 0 && strings.Contains(field, word) {
+		best = SCORE_INSIDE
+	}
+	return best
+}
+
+// allowedTypos is how many edits a query word may be away from a word of a field,
+// none on numbers since a document number a digit away belongs to someone else
+func allowedTypos(word string) int {
+	if strings.ContainsFunc(word, unicode.IsDigit) {
+		return 0
+	}
+	switch length := len([]rune(word)); {
+	case length >= 8:
+		return 2
+	case length >= 4:
+		return 1
+	}
+	return 0
+}
+
+// typos returns the edit distance between a and b, inserting, deleting or replacing a letter
+func typos(a string, b string) int {
+	x, y := []rune(a), []rune(b)
+	previous := make([]int, len(y)+1)
+	current := make([]int, len(y)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		current[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(y)]
+}
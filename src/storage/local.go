@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps the blobs as files under a directory
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+func (l *Local) Name() string {
+	return BACKEND_LOCAL + " (" + l.root + ")"
+}
+
+func (l *Local) path(key string) (string, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(cleaned)), nil
+}
+
+// Put writes the blob to a temporary file first, so a failed upload leaves nothing behind
+func (l *Local) Put(ctx context.Context, key string, content io.Reader) (Blob, error) {
+	target, err := l.path(key)
+	if err != nil {
+		return Blob{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return Blob{}, err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return Blob{}, err
+	}
+	defer os.Remove(file.Name()) // No-op once renamed
+
+	size, err := io.Copy(file, Limited(content))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return Blob{}, err
+	}
+
+	if err := os.Rename(file.Name(), target); err != nil {
+		return Blob{}, err
+	}
+	return Blob{Key: key, Size: size}, nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, Blob, error) {
+	target, err := l.path(key)
+	if err != nil {
+		return nil, Blob{}, err
+	}
+
+	file, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Blob{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Blob{}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, Blob{}, err
+	}
+	return file, Blob{Key: key, Size: info.Size()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+var _ Store = (*Local)(nil)
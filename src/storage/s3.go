@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	BACKEND_S3 = "s3"
+
+	s3Algorithm = "AWS4-HMAC-SHA256"
+	s3Service   = "s3"
+	s3TimeFmt   = "20060102T150405Z"
+	s3DateFmt   = "20060102"
+)
+
+// S3Config addresses a bucket on an S3-compatible service
+type S3Config struct {
+	Endpoint  string // Like https://s3.amazonaws.com or http://localhost:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3 keeps the blobs as objects of a bucket, addressed path-style so MinIO and similar services work.
+// Requests are signed with AWS Signature Version 4, without the AWS SDK.
+type S3 struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3(config S3Config) *S3 {
+	return &S3{
+		config: config,
+		client: &http.Client{Timeout: time.Minute},
+		now:    time.Now,
+	}
+}
+
+func (s *S3) Name() string {
+	return BACKEND_S3 + " (" + s.config.Endpoint + "/" + s.config.Bucket + ")"
+}
+
+// Put reads the whole blob first, S3 needs its length and checksum up front.
+// MaxSize keeps the buffer bounded.
+func (s *S3) Put(ctx context.Context, key string, content io.Reader) (Blob, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return Blob{}, err
+	}
+	body, err := io.ReadAll(Limited(content))
+	if err != nil {
+		return Blob{}, err
+	}
+
+	response, err := s.do(ctx, http.MethodPut, cleaned, body)
+	if err != nil {
+		return Blob{}, err
+	}
+	response.Body.Close()
+	return Blob{Key: key, Size: int64(len(body))}, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Blob, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return nil, Blob{}, err
+	}
+	response, err := s.do(ctx, http.MethodGet, cleaned, nil)
+	if err != nil {
+		return nil, Blob{}, err
+	}
+	return response.Body, Blob{Key: key, Size: response.ContentLength}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	response, err := s.do(ctx, http.MethodDelete, cleaned, nil)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// do sends a signed request for the object, non 2xx answers are errors and 404 is ErrNotFound
+func (s *S3) do(ctx context.Context, method string, key string, body []byte) (*http.Response, error) {
+	target, err := url.Parse(strings.TrimRight(s.config.Endpoint, "/") + "/" + s.config.Bucket + "/" + key)
+	if err != nil {
+		return nil, err
+	}
+	target.RawPath = s3Escape("/"+s.config.Bucket+"/"+key, false) // Sent as signed
+
+	request, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.ContentLength = int64(len(body))
+	s.sign(request, body)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrNotFound
+	}
+	if response.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		response.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s %s", method, key, response.Status, detail)
+	}
+	return response, nil
+}
+
+// sign adds the Signature Version 4 headers to the request
+func (s *S3) sign(request *http.Request, body []byte) {
+	now := s.now().UTC()
+	payloadHash := sha256Hex(body)
+	request.Header.Set("X-Amz-Date", now.Format(s3TimeFmt))
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		"host:" + request.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + now.Format(s3TimeFmt),
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := now.Format(s3DateFmt) + "/" + s.config.Region + "/" + s3Service + "/aws4_request"
+	stringToSign := strings.Join([]string{s3Algorithm, now.Format(s3TimeFmt), scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format(s3DateFmt))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", s3Algorithm+
+		" Credential="+s.config.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+// s3Escape encodes a path as Signature Version 4 expects, every byte but the unreserved ones
+func s3Escape(path string, encodeSlash bool) string {
+	var escaped strings.Builder
+	for _, b := range []byte(path) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			escaped.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+var _ Store = (*S3)(nil)
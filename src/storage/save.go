@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"dainxor/atv/types"
+	"encoding/hex"
+	"io"
+)
+
+// Stored describes a file saved with Save
+type Stored struct {
+	Blob
+	Name        string // As uploaded
+	ContentType string // Sniffed from the content, the declared one is not trusted
+	Kind        types.ResourceInfo
+	Checksum    string // SHA-256 of the content, hex encoded
+}
+
+// Save sniffs the content type, hashes and stores the content under a new key below prefix.
+// Content over MaxSize fails with ErrTooLarge.
+func Save(ctx context.Context, store Store, prefix string, name string, content io.Reader) (Stored, error) {
+	contentType, content, err := Sniff(content)
+	if err != nil {
+		return Stored{}, err
+	}
+
+	hash := sha256.New()
+	blob, err := store.Put(ctx, NewKey(prefix, name), io.TeeReader(content, hash))
+	if err != nil {
+		return Stored{}, err
+	}
+
+	return Stored{
+		Blob:        blob,
+		Name:        name,
+		ContentType: contentType,
+		Kind:        types.Resource.FromContentType(contentType, name),
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
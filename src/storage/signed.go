@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DEFAULT_URL_EXPIRY = 15 * time.Minute
+
+var (
+	ErrSignatureInvalid = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signature expired")
+)
+
+// signerType signs download URLs, so a file can be fetched without a token until the URL expires
+type signerType struct {
+	mutex  sync.RWMutex
+	secret []byte
+	expiry time.Duration
+	random []byte // Used while no secret is configured, URLs stop working on restart
+}
+
+var signer signerType
+
+func (s *signerType) configure(secret string, expiry time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.random == nil {
+		s.random = make([]byte, 32)
+		rand.Read(s.random)
+	}
+	s.secret = []byte(secret)
+	if secret == "" {
+		s.secret = s.random
+	}
+	s.expiry = expiry
+	if expiry <= 0 {
+		s.expiry = DEFAULT_URL_EXPIRY
+	}
+}
+
+func (s *signerType) signature(id string, expires int64) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the expiry, as unix seconds, and the signature of a download URL for the id
+func Sign(id string, now time.Time) (expires int64, signature string) {
+	signer.mutex.RLock()
+	expiry := signer.expiry
+	signer.mutex.RUnlock()
+
+	expires = now.Add(expiry).Unix()
+	return expires, signer.signature(id, expires)
+}
+
+// Verify checks the expiry and signature sent on a download URL for the id
+func Verify(id string, expires string, signature string, now time.Time) error {
+	at, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if !hmac.Equal([]byte(signer.signature(id, at)), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+	if now.Unix() > at {
+		return ErrSignatureExpired
+	}
+	return nil
+}
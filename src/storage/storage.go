@@ -0,0 +1,158 @@
+// Package storage keeps the files of the application, like uploads and session note attachments,
+// on a blob store chosen with STORAGE_BACKEND, local disk or S3-compatible.
+// The records pointing to a file only keep its key.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	BACKEND_LOCAL = "local"
+
+	DEFAULT_LOCAL_DIR = "uploads"
+	DEFAULT_MAX_SIZE  = 10 << 20 // 10 MiB
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+	ErrTooLarge   = errors.New("blob too large")
+)
+
+// Blob describes a stored file
+type Blob struct {
+	Key  string
+	Size int64
+}
+
+// Store is a blob store backend. Keys are slash separated paths like "session-notes/<id>/<name>".
+type Store interface {
+	Put(ctx context.Context, key string, content io.Reader) (Blob, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, Blob, error)
+	Delete(ctx context.Context, key string) error
+	Name() string
+}
+
+type storageType struct {
+	store   atomic.Pointer[Store] // atomic.Value panics when the backend type changes on reload
+	maxSize atomic.Int64
+}
+
+var current storageType
+
+func init() {
+	envInit()
+
+	settings.OnReload(func(previous *settings.Loaded, next *settings.Loaded) {
+		if !reflect.DeepEqual(previous.Config.Storage, next.Config.Storage) {
+			ReloadEnv()
+		}
+	})
+}
+func ReloadEnv() {
+	envInit()
+}
+func envInit() {
+	config := settings.Get().Storage
+
+	maxSize := int64(config.MaxSize)
+	if maxSize <= 0 {
+		maxSize = DEFAULT_MAX_SIZE
+	}
+	current.maxSize.Store(maxSize)
+	signer.configure(config.SigningSecret, config.URLExpiry)
+
+	switch config.Backend {
+	case BACKEND_S3:
+		Use(NewS3(S3Config{
+			Endpoint:  config.S3Endpoint,
+			Bucket:    config.S3Bucket,
+			Region:    config.S3Region,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+		}))
+	default:
+		dir := config.LocalDir
+		if dir == "" {
+			dir = DEFAULT_LOCAL_DIR
+		}
+		Use(NewLocal(dir))
+	}
+	logger.Info("Storage backend:", Current().Name())
+}
+
+// Current returns the store in use
+func Current() Store {
+	return *current.store.Load()
+}
+
+// Use replaces the store in use until the next reload, for tests and for custom backends
+func Use(store Store) {
+	current.store.Store(&store)
+}
+
+// MaxSize returns the largest file accepted, in bytes
+func MaxSize() int64 {
+	return current.maxSize.Load()
+}
+
+// NewKey returns a unique key under prefix keeping the extension of name
+func NewKey(prefix string, name string) string {
+	random := make([]byte, 12)
+	rand.Read(random)
+	return path.Join(prefix, hex.EncodeToString(random)+strings.ToLower(path.Ext(name)))
+}
+
+// cleanKey rejects keys leaving the root of the store
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != key || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// limitReader fails with ErrTooLarge once more than limit bytes are read
+type limitReader struct {
+	reader io.Reader
+	left   int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.reader.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// Limited caps content to MaxSize, reading past it fails with ErrTooLarge
+func Limited(content io.Reader) io.Reader {
+	return &limitReader{reader: content, left: MaxSize()}
+}
+
+// Sniff detects the MIME type of content from its first bytes,
+// the returned reader still yields the whole content
+func Sniff(content io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", nil, err
+	}
+	head = head[:n]
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), content), nil
+}
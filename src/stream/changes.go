@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const RESTART_DELAY = 5 * time.Second // Wait before watching again after the change stream fails
+
+// change is the part of a change stream event the broker needs
+type change struct {
+	OperationType     string                `bson:"operationType"`
+	FullDocument      models.SessionDBMongo `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.M `bson:"updatedFields"`
+	} `bson:"updateDescription"`
+}
+
+// event turns the change into the session event db.Session would have told
+func (c change) event() models.SessionEvent {
+	kind := models.SESSION_UPDATED
+	switch {
+	case c.OperationType == "insert":
+		kind = models.SESSION_CREATED
+	case c.UpdateDescription.UpdatedFields["deleted_at"] != nil && !c.FullDocument.DeletedAt.IsZero():
+		kind = models.SESSION_DELETED
+	case c.UpdateDescription.UpdatedFields["status"] != nil:
+		kind = models.SessionChange(models.SessionDBMongo{}, c.FullDocument) // The previous status is not on the change
+	}
+	return models.SessionEvent{Type: kind, Session: c.FullDocument, At: models.Time.Now()}
+}
+
+// Run feeds the broker from the MongoDB change stream when STREAM_SOURCE is change_stream,
+// until ctx ends. While the change stream is down the local changes are published instead.
+func Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if settings.Get().Stream.Source == SOURCE_CHANGE_STREAM && configs.DB.Type() == configs.DB.Types().MongoDB() {
+			err := watch(ctx)
+			broker.watching.Store(false)
+			if err != nil && ctx.Err() == nil {
+				logger.Warning("Session change stream stopped, publishing the local changes:", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(RESTART_DELAY):
+		}
+	}
+}
+
+// watch publishes the session changes until the change stream fails or ctx ends
+func watch(ctx context.Context) error {
+	if err := configs.DB.Connect(ctx); err != nil {
+		return err
+	}
+
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: bson.D{
+		{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"insert", "update", "replace"}}}},
+	}}}}
+	changes, err := configs.DB.From(models.SessionDBMongo{}).Watch(ctx, pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		return err // Standalone servers have no change streams
+	}
+	defer changes.Close(context.WithoutCancel(ctx))
+
+	broker.watching.Store(true)
+	logger.Info("Publishing the session changes from the MongoDB change stream")
+	for changes.Next(ctx) {
+		var next change
+		if err := changes.Decode(&next); err != nil {
+			logger.Warning("Failed to read a session change:", err)
+			continue
+		}
+		Publish(next.event())
+	}
+	return changes.Err()
+}
@@ -0,0 +1,119 @@
+// Package stream publishes the session changes to the clients following them live. Changes made
+// through db.Session are published in process. With STREAM_SOURCE=change_stream they come from
+// the MongoDB change stream instead, so every instance sees the changes made by the others; the
+// in-process source is used again whenever the change stream is not available.
+package stream
+
+import (
+	"context"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/db"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	SOURCE_LOCAL         = "local"
+	SOURCE_CHANGE_STREAM = "change_stream"
+)
+
+// Filter picks the events of a subscription, empty fields match any session
+type Filter struct {
+	IDStudent   models.DBID
+	IDCompanion models.DBID
+	Tenant      models.DBID // University of the sessions, any when zero
+}
+
+// Matches reports whether the event concerns the student, companion and tenant of the filter
+func (f Filter) Matches(event models.SessionEvent) bool {
+	return (f.IDStudent.IsZero() || event.Session.IDStudent == f.IDStudent) &&
+		(f.IDCompanion.IsZero() || event.Session.IDCompanion == f.IDCompanion) &&
+		(f.Tenant.IsZero() || event.Session.IDUniversity == f.Tenant)
+}
+
+// Subscription receives the events matching its filter until it is closed.
+// Events is closed when the subscriber falls STREAM_BUFFER events behind.
+type Subscription struct {
+	Events <-chan models.SessionEvent
+	events chan models.SessionEvent
+	filter Filter
+	once   sync.Once
+}
+
+type brokerType struct {
+	mutex         sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+	watching      atomic.Bool // The change stream feeds the broker, the local changes are not published
+}
+
+var broker = brokerType{subscriptions: map[*Subscription]struct{}{}}
+
+func init() {
+	db.Session.Listen(func(ctx context.Context, event models.SessionEvent) {
+		if !broker.watching.Load() {
+			Publish(event)
+		}
+	})
+}
+
+// Subscribe starts receiving the events matching the filter
+func Subscribe(filter Filter) *Subscription {
+	events := make(chan models.SessionEvent, settings.Get().Stream.Buffer)
+	subscription := &Subscription{Events: events, events: events, filter: filter}
+
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	broker.subscriptions[subscription] = struct{}{}
+	return subscription
+}
+
+// Close stops the subscription, it is safe to call more than once
+func (s *Subscription) Close() {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	s.close()
+}
+
+// close ends the subscription, with the broker locked
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		delete(broker.subscriptions, s)
+		close(s.events)
+	})
+}
+
+// CloseAll ends every subscription, so the streams finish when the server shuts down
+func CloseAll() {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	for subscription := range broker.subscriptions {
+		subscription.close()
+	}
+}
+
+// Publish sends the event to every matching subscription, dropping those too far behind
+func Publish(event models.SessionEvent) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	for subscription := range broker.subscriptions {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			logger.Warning("Session stream subscriber fell behind, dropping it")
+			subscription.close()
+		}
+	}
+}
+
+// Subscribers returns how many subscriptions are open
+func Subscribers() int {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+	return len(broker.subscriptions)
+}
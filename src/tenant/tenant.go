@@ -0,0 +1,31 @@
+// Package tenant carries the university a request acts for. configs.DB keeps the records of every
+// model not marked models.SharedModel to the tenant of the context: queries only see that tenant's
+// records and new ones are stamped with it. Contexts without a tenant see every record, as the
+// background work and super-admins do.
+package tenant
+
+import (
+	"context"
+	"dainxor/atv/models"
+)
+
+const (
+	FIELD            = "id_university" // Where the records kept per tenant store it
+	SUPER_ADMIN_ROLE = "super_admin"   // Token role acting across tenants, or for the one of the header
+)
+
+type key struct{}
+
+// With returns a context acting for the university id, ctx as is when id is zero
+func With(ctx context.Context, id models.DBID) context.Context {
+	if id.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, key{}, id)
+}
+
+// From returns the tenant of the context, false when it acts across tenants
+func From(ctx context.Context) (models.DBID, bool) {
+	id, ok := ctx.Value(key{}).(models.DBID)
+	return id, ok
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"dainxor/atv/models"
+	"dainxor/atv/routes"
+	"maps"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAssignmentDefaultsSessionCompanion(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+
+	path := "/api/v1/session/"
+	body := models.SessionCreate{IDStudent: ids["student"], IDSessionType: ids["session-type"], Date: "2026-02-02T10:00"}
+	result := send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, body: body})
+	if result.status != http.StatusCreated || result.data["id_companion"] != ids["companion"] {
+		t.Fatalf("Session without companion should default to the assigned one %s, got %d %v", ids["companion"], result.status, result.data)
+	}
+}
+
+func TestAssignmentReassignKeepsHistory(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+	other := createFixture(t, router, spec, "companion", ids, 1)
+
+	reassign := "/api/v1/assignment/:id/reassign"
+	result := send(t, router, spec, contractCall{
+		method: http.MethodPost, path: reassign, target: "/api/v1/assignment/" + ids["assignment"] + "/reassign",
+		body: models.AssignmentChange{IDCompanion: other, Reason: "Schedule change"},
+	})
+	if result.status != http.StatusCreated || result.data["id_previous"] != ids["assignment"] || result.data["id_companion"] != other {
+		t.Fatalf("Reassign should start an assignment with %s replacing %s, got %d %v", other, ids["assignment"], result.status, result.data)
+	}
+
+	end := "/api/v1/assignment/:id/end"
+	result = send(t, router, spec, contractCall{
+		method: http.MethodPost, path: end, target: "/api/v1/assignment/" + ids["assignment"] + "/end",
+		body: models.AssignmentChange{Reason: "Again"},
+	})
+	if result.status != http.StatusConflict {
+		t.Errorf("Ending a reassigned assignment should conflict, got %d", result.status)
+	}
+
+	// The fixture companion is no longer assigned, booking with them warns
+	path := "/api/v1/session/"
+	booking := fixtureBody("session", ids, 1)
+	request := contractCall{method: http.MethodPost, path: path, target: path, body: booking}
+	if result := send(t, router, spec, request); result.status != http.StatusCreated || !strings.HasPrefix(result.message, "Warning") {
+		t.Fatalf("Booking with a non assigned companion should succeed with a warning, got %d %q", result.status, result.message)
+	}
+}
+
+func TestAssignmentReassignToABadCompanionKeepsTheAssignment(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+
+	elsewhere := maps.Clone(ids)
+	elsewhere["speciality"] = createFixture(t, router, spec, "speciality", nil, 1)
+	otherSpeciality := createFixture(t, router, spec, "companion", elsewhere, 1)
+
+	path := "/api/v1/assignment/:id"
+	before := send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: "/api/v1/assignment/" + ids["assignment"]})
+
+	reassign := "/api/v1/assignment/:id/reassign"
+	for name, companion := range map[string]string{"missing companion": models.DBID{1}.Hex(), "companion of another speciality": otherSpeciality} {
+		result := send(t, router, spec, contractCall{
+			method: http.MethodPost, path: reassign, target: "/api/v1/assignment/" + ids["assignment"] + "/reassign",
+			body: models.AssignmentChange{IDCompanion: companion, Reason: "Schedule change"},
+		})
+		if result.status < http.StatusBadRequest {
+			t.Errorf("Reassigning to a %s answered %d, expected an error", name, result.status)
+		}
+
+		current := send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: "/api/v1/assignment/" + ids["assignment"]})
+		if current.status != http.StatusOK || !maps.Equal(current.data, before.data) {
+			t.Errorf("After reassigning to a %s the assignment is %v, expected it still active with %s", name, current.data, ids["companion"])
+		}
+	}
+}
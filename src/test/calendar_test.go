@@ -0,0 +1,136 @@
+package main
+
+import (
+	"dainxor/atv/auth"
+	"dainxor/atv/calendar"
+	"dainxor/atv/models"
+	"dainxor/atv/routes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getFeed fetches the calendar feed at url, returning the status and the body
+func getFeed(t *testing.T, router *gin.Engine, url string) (int, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestCalendarFeedListsSessionsByToken(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+
+	path := "/api/v1/companion/:id/calendar"
+	token := signToken(t, auth.Claims{Subject: ids["companion"], Role: "companion", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	result := send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: "/api/v1/companion/" + ids["companion"] + "/calendar", token: token})
+	url, _ := result.data["url"].(string)
+	if !strings.Contains(url, "token="+ids["companion_calendar"]) {
+		t.Fatalf("The feed URL should carry the token, got %q", url)
+	}
+
+	status, body := getFeed(t, router, url)
+	if status != http.StatusOK {
+		t.Fatalf("Expected the feed, got %d: %s", status, body)
+	}
+	uid := "UID:" + ids["session"] + "@" + calendar.UID_DOMAIN + "\r\n"
+	for _, expected := range []string{"BEGIN:VCALENDAR\r\n", "VERSION:2.0\r\n", uid, "SUMMARY:Tutoring 0\r\n", "DTSTART;VALUE=DATE:20260115\r\n", "STATUS:CONFIRMED\r\n"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("The feed should contain %q:\n%s", expected, body)
+		}
+	}
+
+	send(t, router, spec, contractCall{method: http.MethodPatch, path: "/api/v1/session/:id", target: "/api/v1/session/" + ids["session"], body: models.SessionCreate{Status: models.STATUS[models.STATUS_CANCELLED]}})
+	if _, body = getFeed(t, router, url); !strings.Contains(body, uid) || !strings.Contains(body, "STATUS:CANCELLED\r\n") {
+		t.Errorf("The cancelled session should keep its UID and be marked cancelled:\n%s", body)
+	}
+
+	// Tokens only open the feed they belong to
+	for _, target := range []string{
+		"/api/v1/companion/" + ids["companion"] + "/calendar.ics",
+		"/api/v1/companion/" + ids["companion"] + "/calendar.ics?token=wrong",
+		"/api/v1/companion/" + ids["companion"] + "/calendar.ics?token=" + ids["student_calendar"],
+		"/api/v1/student/" + ids["student"] + "/calendar.ics?token=" + ids["companion_calendar"],
+	} {
+		if status, _ := getFeed(t, router, target); status != http.StatusUnauthorized {
+			t.Errorf("GET %s answered %d, expected 401", target, status)
+		}
+	}
+
+	send(t, router, spec, contractCall{method: http.MethodDelete, path: path, target: "/api/v1/companion/" + ids["companion"] + "/calendar", token: token})
+	if status, _ := getFeed(t, router, url); status != http.StatusUnauthorized {
+		t.Errorf("A revoked feed URL answered %d, expected 401", status)
+	}
+	result = send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: "/api/v1/companion/" + ids["companion"] + "/calendar", token: token})
+	if result.data["url"] == url {
+		t.Error("A new token should be made after revoking the feed")
+	}
+}
+
+func TestCalendarFeedURLIsOnlyForItsOwner(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+
+	expires := time.Now().Add(time.Hour).Unix()
+	student := signToken(t, auth.Claims{Subject: ids["student"], Role: "student", ExpiresAt: expires})
+	for _, check := range []struct {
+		name  string
+		token string
+		owner string
+		code  int
+	}{
+		{"anonymous", "", "student", http.StatusUnauthorized},
+		{"anonymous", "", "companion", http.StatusUnauthorized},
+		{"another user", student, "companion", http.StatusForbidden},
+		{"the owner", student, "student", http.StatusOK},
+		{"an admin", adminToken(t), "companion", http.StatusOK},
+	} {
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			path := "/api/v1/" + check.owner + "/:id/calendar"
+			result := send(t, router, spec, contractCall{method: method, path: path, target: strings.Replace(path, ":id", ids[check.owner], 1), token: check.token})
+			if result.status != check.code {
+				t.Errorf("%s %s by %s answered %d, expected %d", method, path, check.name, result.status, check.code)
+			}
+			if result.status != http.StatusOK && result.data["token"] != nil {
+				t.Errorf("%s %s by %s answered the feed token", method, path, check.name)
+			}
+		}
+	}
+}
+
+func TestCalendarLinesAreEscapedAndFolded(t *testing.T) {
+	start := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	feed := calendar.Feed{Events: []calendar.Event{{
+		UID:         "1@test",
+		Summary:     "Tutoría; grupal, 1\\2",
+		Description: strings.Repeat("Acompañante: Luis Pérez\n", 10),
+		Start:       start,
+		End:         start.Add(time.Hour),
+	}}}
+
+	var out strings.Builder
+	if err := feed.Write(&out, start); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	if !strings.Contains(text, `SUMMARY:Tutoría\; grupal\, 1\\2`+"\r\n") || !strings.Contains(text, "DTSTART:20260115T100000Z\r\n") {
+		t.Errorf("Unexpected feed:\n%s", text)
+	}
+	for line := range strings.SplitSeq(strings.TrimSuffix(text, "\r\n"), "\r\n") {
+		if len(line) > calendar.MAX_LINE {
+			t.Errorf("Line of %d octets: %q", len(line), line)
+		}
+	}
+	if unfolded := strings.ReplaceAll(text, "\r\n ", ""); !strings.Contains(unfolded, "DESCRIPTION:"+strings.Repeat(`Acompañante: Luis Pérez\n`, 10)) {
+		t.Errorf("The description should unfold back whole:\n%s", unfolded)
+	}
+}
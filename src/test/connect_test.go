@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"errors"
+	"testing"
+	"time"
+)
+
+// useUnreachableDB points the database at a MongoDB nobody listens on
+func useUnreachableDB(t *testing.T) {
+	t.Cleanup(func() {
+		start := time.Now()
+		configs.Reload("unreachable database test finished")
+		if waited := time.Since(start); waited > time.Second {
+			t.Errorf("Reloading waited %v on the background reconnect, expected it to be stopped", waited)
+		}
+	})
+	t.Setenv("DB_TYPE", configs.DB.Types().MongoDB())
+	t.Setenv("CONECTION_STRING", "mongodb://127.0.0.1:1")
+	t.Setenv("DB_NAME", "atv_unreachable")
+	t.Setenv("DB_TIMEOUT_CONNECT", "200ms")
+	t.Setenv("DB_CONNECT_RETRIES", "5")
+	t.Setenv("DB_CONNECT_BACKOFF", "2s")
+	configs.Reload("unreachable database test")
+}
+
+func TestConnectFailsFastWhileReconnecting(t *testing.T) {
+	useUnreachableDB(t)
+	ctx := context.Background()
+
+	if err := configs.DB.Connect(ctx); !errors.Is(err, configs.ErrDBUnavailable) {
+		t.Fatal("First connection answered", err, "expected", configs.ErrDBUnavailable)
+	}
+	time.Sleep(50 * time.Millisecond) // The background reconnect takes over
+
+	for range 3 {
+		start := time.Now()
+		err := configs.DB.Connect(ctx)
+		if !errors.Is(err, configs.ErrDBUnavailable) {
+			t.Error("Connection while reconnecting answered", err, "expected", configs.ErrDBUnavailable)
+		}
+		if waited := time.Since(start); waited > 50*time.Millisecond {
+			t.Errorf("Connection while reconnecting waited %v, expected it to fail fast", waited)
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"dainxor/atv/auth"
+	"dainxor/atv/configs"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/jobs"
+	"dainxor/atv/middleware"
+	"dainxor/atv/models"
+	"dainxor/atv/openapi"
+	"dainxor/atv/photo"
+	"dainxor/atv/routes"
+	"dainxor/atv/storage"
+	"encoding/json"
+	"image"
+	"image/png"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The contract test serves every registered route against the in-process
+// memory database and checks each request it sends and each response it gets
+// against the OpenAPI document, so a model and its documentation can not drift apart.
+
+var versionedPath = regexp.MustCompile(`^/api/v\d+/([^/]+)`)
+
+// useMemoryDB switches the database to the in-process store and the blob store
+// to a temporary directory for the test, the previous settings are restored when it ends
+func useMemoryDB(t *testing.T) {
+	t.Cleanup(func() { configs.Reload("contract test finished") })
+	t.Setenv("DB_TYPE", configs.DB.Types().Memory())
+	t.Setenv("STORAGE_LOCAL_DIR", t.TempDir())
+	configs.Reload("contract test")
+	configs.DB.Memory().Reset()
+	jobs.Use(jobs.NewMemory())
+
+	if configs.DB.Type() != configs.DB.Types().Memory() {
+		t.Fatal("Could not switch to the memory database, DB_TYPE is", configs.DB.Type())
+	}
+}
+
+func contractRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := routes.TrustProxies(router); err != nil {
+		panic(err)
+	}
+	router.Use(middleware.RecoverMiddleware())
+	router.Use(middleware.TokenMiddleware())
+	routes.Register(router)
+	return router
+}
+
+type contractCall struct {
+	method string
+	path   string // As registered on gin
+	target string // With the path parameters filled in
+	body   any
+	upload string // Content of the file sent on upload routes, a fixed text when empty
+	token  string // Sent as bearer token, none when empty
+	tenant string // Sent on the TENANCY_HEADER header, none when empty
+
+	invalidBody bool // Sent on purpose, not checked against the schema
+}
+
+type contractResult struct {
+	status  int
+	data    map[string]any
+	list    []any // The data when it is a list
+	message string
+}
+
+// send validates the request body, serves the call and validates the response
+func send(t *testing.T, router *gin.Engine, spec openapi.Document, call contractCall) contractResult {
+	t.Helper()
+
+	var payload []byte
+	contentType := openapi.JSON_CONTENT_TYPE
+	if field := spec.UploadField(call.method, call.path); field != "" {
+		payload, contentType = uploadForm(field, cmp.Or(call.upload, "Contract test attachment"))
+	} else if call.body != nil {
+		payload, _ = json.Marshal(call.body)
+		if schema := spec.RequestSchema(call.method, call.path); schema != nil && !call.invalidBody {
+			var decoded any
+			json.Unmarshal(payload, &decoded)
+			if err := spec.Validate(schema, decoded); err != nil {
+				t.Errorf("%s %s request body does not match the schema:\n%v", call.method, call.path, err)
+			}
+		}
+	}
+
+	request := httptest.NewRequest(call.method, call.target, bytes.NewReader(payload))
+	if payload != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if call.token != "" {
+		request.Header.Set("Authorization", "Bearer "+call.token)
+	}
+	if call.tenant != "" {
+		request.Header.Set(settings.Get().Tenancy.Header, call.tenant)
+	}
+	if contentType, _, _ := spec.ResponseSchema(call.method, call.path, http.StatusOK); contentType == openapi.EVENT_STREAM_CONTENT_TYPE {
+		ctx, cancel := context.WithTimeout(request.Context(), 50*time.Millisecond) // Streams stay open until the client leaves
+		defer cancel()
+		request = request.WithContext(ctx)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	result := contractResult{status: recorder.Code}
+	contentType, schema, documented := spec.ResponseSchema(call.method, call.path, recorder.Code)
+	if !documented {
+		t.Errorf("%s %s answered %d, which is not documented:\n%s", call.method, call.target, recorder.Code, recorder.Body)
+		return result
+	}
+	if call.method == http.MethodHead {
+		return result // No body to check
+	}
+
+	got, _, _ := mime.ParseMediaType(recorder.Header().Get("Content-Type"))
+	wanted, _, _ := mime.ParseMediaType(contentType)
+	if got != wanted && wanted != openapi.ANY_CONTENT_TYPE {
+		t.Errorf("%s %s answered %s, documented as %s", call.method, call.target, got, wanted)
+		return result
+	}
+	if got != openapi.JSON_CONTENT_TYPE {
+		return result
+	}
+
+	var body any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Errorf("%s %s answered invalid JSON: %v", call.method, call.target, err)
+		return result
+	}
+	if err := spec.Validate(schema, body); err != nil {
+		t.Errorf("%s %s response %d does not match the schema:\n%v\n%s", call.method, call.target, recorder.Code, err, recorder.Body)
+	}
+
+	if envelope, ok := body.(map[string]any); ok {
+		result.data, _ = envelope["data"].(map[string]any)
+		result.list, _ = envelope["data"].([]any)
+		result.message, _ = envelope["message"].(string)
+	}
+	return result
+}
+
+// uploadForm returns a multipart form carrying a small text file on field, with its content type
+func uploadForm(field string, content string) ([]byte, string) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	file, _ := writer.CreateFormFile(field, "contract.txt")
+	file.Write([]byte(content))
+	writer.Close()
+	return form.Bytes(), writer.FormDataContentType()
+}
+
+// contractPhoto returns a small png, for the photo routes that reject anything else
+func contractPhoto() string {
+	var encoded bytes.Buffer
+	png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 4, 3)))
+	return encoded.String()
+}
+
+// fixtureBody returns the body to create or update a resource, n makes the unique fields differ between calls
+func fixtureBody(resource string, ids map[string]string, n int) any {
+	suffix := strconv.Itoa(n)
+	switch resource {
+	case "university":
+		return models.UniversityCreate{Name: "Contract University " + suffix, Location: "Bogotá"}
+	case "speciality":
+		return models.SpecialityCreate{Name: "Psychology " + suffix}
+	case "session-type":
+		return models.SessionTypeCreate{Name: "Tutoring " + suffix}
+	case "student":
+		return models.StudentCreate{
+			NumberID:         "100000000" + suffix,
+			FirstName:        "Ana",
+			LastName:         "García " + suffix,
+			PersonalEmail:    "ana" + suffix + "@example.com",
+			InstitutionEmail: "ana" + suffix + "@university.edu",
+			ResidenceAddress: "Street " + suffix,
+			Semester:         3,
+			IDUniversity:     ids["university"],
+			PhoneNumber:      "300000000" + suffix,
+		}
+	case "companion":
+		return models.CompanionCreate{
+			NumberID:         "200000000" + suffix,
+			FirstName:        "Luis",
+			LastName:         "Pérez " + suffix,
+			Email:            "luis" + suffix + "@example.com",
+			InstitutionEmail: "luis" + suffix + "@university.edu",
+			PhoneNumber:      "310000000" + suffix,
+			IDSpeciality:     ids["speciality"],
+		}
+	case "session":
+		return models.SessionCreate{
+			IDStudent:     ids["student"],
+			IDCompanion:   ids["companion"],
+			IDSessionType: ids["session-type"],
+			SessionNotes:  "Session notes " + suffix,
+			Status:        "Pendiente",
+			Date:          "2026-01-15",
+		}
+	case "resource":
+		return models.ResourceLink{Kind: models.RESOURCE_LINK_STUDENT, ID: ids["student"]}
+	case "assignment":
+		return models.AssignmentCreate{
+			IDStudent:   ids["student"],
+			IDCompanion: ids["companion"],
+			Reason:      "First assignment " + suffix,
+		}
+	}
+	return map[string]any{}
+}
+
+// changeBody returns the body of a call acting on an existing resource, nil when it uses the fixture body
+func changeBody(path string, ids map[string]string, n int) any {
+	switch {
+	case strings.HasSuffix(path, "/reassign"):
+		return models.AssignmentChange{IDCompanion: ids["companion"], Reason: "Reassigned " + strconv.Itoa(n)}
+	case strings.HasSuffix(path, "/end"):
+		return models.AssignmentChange{Reason: "Ended " + strconv.Itoa(n)}
+	case strings.HasSuffix(path, "/notes"):
+		return models.SessionNoteCreate{
+			Objectives:    []string{"Objective " + strconv.Itoa(n)},
+			Observations:  "Observations " + strconv.Itoa(n),
+			AgreedActions: []string{"Action " + strconv.Itoa(n)},
+			FollowUpDate:  "2026-01-22",
+		}
+	case strings.HasSuffix(path, "/webhooks"):
+		return models.WebhookCreate{URL: "https://university.edu/hooks/" + strconv.Itoa(n), Events: []string{models.SESSION_CREATED}}
+	}
+	return nil
+}
+
+// createFixture creates a resource through the API and returns its ID
+func createFixture(t *testing.T, router *gin.Engine, spec openapi.Document, resource string, ids map[string]string, n int) string {
+	path := "/api/v1/" + resource + "/"
+	call := contractCall{method: http.MethodPost, path: path, target: path, body: fixtureBody(resource, ids, n)}
+	result := send(t, router, spec, call)
+
+	id, _ := result.data["id"].(string)
+	if result.status != http.StatusCreated || id == "" {
+		t.Fatalf("Could not create the %s fixture, status %d data %v", resource, result.status, result.data)
+	}
+	return id
+}
+
+// createFixtures creates one of each resource through the API, in dependency order
+func createFixtures(t *testing.T, router *gin.Engine, spec openapi.Document) map[string]string {
+	ids := map[string]string{}
+	for _, resource := range []string{"university", "speciality", "session-type", "student", "companion", "assignment", "session"} {
+		ids[resource] = createFixture(t, router, spec, resource, ids, 0)
+	}
+
+	// An attachment on the session notes, for the routes reading it
+	path := "/api/v1/session/:id/notes/attachments"
+	result := send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: strings.Replace(path, ":id", ids["session"], 1)})
+	attachments, _ := result.data["attachments"].([]any)
+	if result.status != http.StatusCreated || len(attachments) != 1 {
+		t.Fatalf("Could not attach a file to the session notes, status %d data %v", result.status, result.data)
+	}
+	ids["file"], _ = attachments[0].(map[string]any)["file"].(string)
+
+	// A photo for the student and the companion, for the routes serving it
+	for _, owner := range []string{"student", "companion"} {
+		path = "/api/v1/" + owner + "/:id/photo"
+		result = send(t, router, spec, contractCall{method: http.MethodPut, path: path, target: strings.Replace(path, ":id", ids[owner], 1), upload: contractPhoto()})
+		if result.status != http.StatusOK || result.data["photo_url"] == nil {
+			t.Fatalf("Could not upload the %s photo, status %d data %v", owner, result.status, result.data)
+		}
+	}
+
+	// A calendar feed for the student and the companion, opened by each of them, its token opens the feed routes
+	for _, owner := range []string{"student", "companion"} {
+		path = "/api/v1/" + owner + "/:id/calendar"
+		token := signToken(t, auth.Claims{Subject: ids[owner], Role: owner, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		result = send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: strings.Replace(path, ":id", ids[owner], 1), token: token})
+		ids[owner+"_calendar"], _ = result.data["token"].(string)
+		if result.status != http.StatusOK || ids[owner+"_calendar"] == "" {
+			t.Fatalf("Could not open the %s calendar feed, status %d data %v", owner, result.status, result.data)
+		}
+	}
+
+	// An upload linked to the student, for the resource routes
+	path = "/api/v1/resource/"
+	result = send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, upload: "Contract resource"})
+	ids["resource"], _ = result.data["id"].(string)
+	if result.status != http.StatusCreated || ids["resource"] == "" {
+		t.Fatalf("Could not upload the resource fixture, status %d data %v", result.status, result.data)
+	}
+	path = "/api/v1/resource/:id/link"
+	result = send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: "/api/v1/resource/" + ids["resource"] + "/link", body: fixtureBody("resource", ids, 0)})
+	if result.status != http.StatusOK {
+		t.Fatalf("Could not link the resource fixture, status %d data %v", result.status, result.data)
+	}
+
+	jobs.RunDue(context.Background(), time.Now()) // The session confirmations, for the notification routes
+	return ids
+}
+
+// freshAssignment creates a student of its own for a call that starts or ends an assignment,
+// with an active assignment when the call acts on one and another companion to reassign it to
+func freshAssignment(t *testing.T, router *gin.Engine, spec openapi.Document, ids map[string]string, path string, n int) map[string]string {
+	fresh := maps.Clone(ids)
+	fresh["student"] = createFixture(t, router, spec, "student", fresh, n)
+	if strings.Contains(path, ":id") {
+		fresh["assignment"] = createFixture(t, router, spec, "assignment", fresh, n)
+		fresh["companion"] = createFixture(t, router, spec, "companion", fresh, n)
+	}
+	return fresh
+}
+
+// contractCalls builds a call for every route, deletions go last so the rest find their fixture
+func contractCalls(t *testing.T, router *gin.Engine, spec openapi.Document, fixtures map[string]string) []contractCall {
+	calls := []contractCall{}
+	for _, entry := range routes.Catalogue(router) {
+		if entry.Method == http.MethodConnect {
+			continue // Registered by gin for Any routes, OpenAPI can not describe it
+		}
+
+		resource := ""
+		if match := versionedPath.FindStringSubmatch(entry.Path); match != nil {
+			resource = match[1]
+		}
+
+		// Assignments change state, so each call that creates or ends one gets a student of its own
+		ids := fixtures
+		if resource == "assignment" && entry.Method == http.MethodPost {
+			ids = freshAssignment(t, router, spec, fixtures, entry.Path, 1000+len(calls))
+		}
+		// Lookups get a record of their own, the other calls change the fixtures
+		var lookup struct{ number, email string }
+		if strings.Contains(entry.Path, ":number_id") || strings.Contains(entry.Path, ":email") {
+			n := 1000 + len(calls)
+			createFixture(t, router, spec, resource, ids, n)
+			switch body := fixtureBody(resource, ids, n).(type) {
+			case models.StudentCreate:
+				lookup.number, lookup.email = body.NumberID, body.PersonalEmail
+			case models.CompanionCreate:
+				lookup.number, lookup.email = body.NumberID, body.InstitutionEmail // Either email finds them
+			}
+		}
+		target := strings.NewReplacer(
+			":id", cmp.Or(ids[resource], "000000000000000000000000"), // Routes without fixtures, like the admin ones, get a missing one
+			":student_id", ids["student"],
+			":session_id", ids["session"],
+			":file", ids["file"],
+			":kind", models.RESOURCE_LINK_STUDENT,
+			":target_id", ids["student"],
+			":confirm", "delete-permanently",
+			":number_id", lookup.number,
+			":email", lookup.email,
+		).Replace(entry.Path)
+
+		query := url.Values{}
+		for _, name := range spec.QueryParameters(entry.Method, entry.Path) {
+			query.Set(name, queryValue(name, ids))
+		}
+		if query.Has("token") {
+			query.Set("token", ids[resource+"_calendar"]) // Each calendar feed has a token of its own
+		}
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+
+		call := contractCall{method: entry.Method, path: entry.Path, target: target, upload: "Contract upload " + strconv.Itoa(len(calls))}
+		if strings.HasSuffix(entry.Path, "/photo") {
+			call.upload = contractPhoto()
+		}
+		if strings.HasSuffix(entry.Path, "/calendar") {
+			call.token = adminToken(t) // Only the owner or an admin handles the feed URL
+		}
+		if slices.Contains([]string{http.MethodPost, http.MethodPut, http.MethodPatch}, entry.Method) {
+			call.body = changeBody(entry.Path, ids, len(calls)+1)
+			if call.body == nil {
+				call.body = fixtureBody(resource, ids, len(calls)+1)
+			}
+		}
+		calls = append(calls, call)
+	}
+
+	slices.SortStableFunc(calls, func(a contractCall, b contractCall) int {
+		return boolOrder(a.method == http.MethodDelete) - boolOrder(b.method == http.MethodDelete)
+	})
+	return calls
+}
+
+// queryValue returns a value for a documented query parameter, one matching the fixtures
+func queryValue(name string, ids map[string]string) string {
+	if id, exist := ids[name]; exist {
+		return id
+	}
+	switch name {
+	case "id_student", "id_companion":
+		return ids[strings.TrimPrefix(name, "id_")]
+	case "date":
+		return "2026-01-16T10:00"
+	case "q":
+		return "garcia" // The student fixture, found without its accent
+	case "size":
+		return photo.SIZE_SMALL
+	case "expires", "signature":
+		expires, signature := storage.Sign(ids["resource"], time.Now())
+		if name == "expires" {
+			return strconv.FormatInt(expires, 10)
+		}
+		return signature
+	}
+	return ""
+}
+
+func boolOrder(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func TestContractEveryRouteIsDocumented(t *testing.T) {
+	router := contractRouter()
+	spec := routes.Spec(router)
+
+	for _, entry := range routes.Catalogue(router) {
+		if entry.Method != http.MethodConnect && !spec.Has(entry.Method, entry.Path) {
+			t.Errorf("%s %s is registered but not on the OpenAPI document", entry.Method, entry.Path)
+		}
+	}
+}
+
+func TestContractResponsesMatchSchema(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+
+	ids := createFixtures(t, router, spec)
+	removed := map[string]bool{}
+	for _, entry := range routes.Catalogue(router) {
+		removed[entry.Method+" "+entry.Path] = entry.Gone
+	}
+
+	for _, call := range contractCalls(t, router, spec, ids) {
+		t.Run(call.method+" "+call.path, func(t *testing.T) {
+			result := send(t, router, spec, call)
+
+			// The fixtures exist, so every live versioned route must reach its documented success
+			switch {
+			case removed[call.method+" "+call.path]:
+				if result.status != http.StatusGone {
+					t.Errorf("%s %s was removed but answered %d", call.method, call.target, result.status)
+				}
+			case versionedPath.MatchString(call.path):
+				if wanted := spec.SuccessStatus(call.method, call.path); result.status != wanted {
+					t.Errorf("%s %s answered %d, expected %d", call.method, call.target, result.status, wanted)
+				}
+			}
+		})
+	}
+}
+
+func TestContractErrorsMatchSchema(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+
+	missing := "000000000000000000000000"
+	calls := []contractCall{
+		{method: http.MethodGet, path: "/api/v1/student/:id", target: "/api/v1/student/" + missing},
+		{method: http.MethodGet, path: "/api/v1/student/:id", target: "/api/v1/student/not-an-id"},
+		{method: http.MethodGet, path: "/api/v1/student/all", target: "/api/v1/student/all"},
+		{method: http.MethodPut, path: "/api/v1/student/:id", target: "/api/v1/student/" + missing, body: models.StudentCreate{FirstName: "Nobody"}},
+		{method: http.MethodPatch, path: "/api/v1/companion/:id", target: "/api/v1/companion/" + missing, body: models.CompanionCreate{FirstName: "Nobody"}},
+		{method: http.MethodPut, path: "/api/v1/session/:id", target: "/api/v1/session/" + missing, body: models.SessionCreate{SessionNotes: "None"}},
+		{method: http.MethodDelete, path: "/api/v1/companion/:id", target: "/api/v1/companion/" + missing},
+		{method: http.MethodPost, path: "/api/v1/university/", target: "/api/v1/university/", body: "not an object", invalidBody: true},
+		{method: http.MethodGet, path: "/admin/config", target: "/admin/config"},
+		{method: http.MethodPost, path: "/admin/jobs/:id/retry", target: "/admin/jobs/" + missing + "/retry"},
+	}
+
+	for _, call := range calls {
+		result := send(t, router, spec, call)
+		if result.status < http.StatusBadRequest {
+			t.Errorf("%s %s should fail, answered %d", call.method, call.target, result.status)
+		}
+	}
+}
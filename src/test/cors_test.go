@@ -0,0 +1,174 @@
+package main
+
+import (
+	"dainxor/atv/configs"
+	"dainxor/atv/middleware"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func corsRouter(config configs.CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.CORSMiddlewareWith(config))
+	router.GET("/api/v1/student/all", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func preflight(router *gin.Engine, origin string, method string, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodOptions, "/api/v1/student/all", nil)
+	request.Header.Set("Origin", origin)
+	request.Header.Set("Access-Control-Request-Method", method)
+	request.Header.Set("Access-Control-Request-Headers", strings.Join(append([]string{"Content-Type"}, headers...), ","))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func testCORSConfig() configs.CORSConfig {
+	config := configs.DefaultCORSConfig()
+	config.AllowOrigins = []string{"https://atv.example.com", "https://*.preview.example.com"}
+	config.AllowMethods = []string{"GET", "POST"}
+	config.AllowCredentials = true
+	config.MaxAge = time.Hour
+	return config
+}
+
+func TestCORSPreflightAllowedOrigin(t *testing.T) {
+	recorder := preflight(corsRouter(testCORSConfig()), "https://atv.example.com", "POST")
+
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":      "https://atv.example.com",
+		"Access-Control-Allow-Methods":     "GET,POST",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "3600",
+	}
+	for header, value := range expected {
+		if got := recorder.Header().Get(header); got != value {
+			t.Errorf("Expected %s to be %q, got %q", header, value, got)
+		}
+	}
+	if recorder.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Errorf("Expected Access-Control-Allow-Headers to be set")
+	}
+}
+
+func TestCORSPreflightRejectedOrigin(t *testing.T) {
+	router := corsRouter(testCORSConfig())
+
+	origins := []string{
+		"https://evil.example.com",
+		"http://atv.example.com",               // Different scheme
+		"https://preview.example.com",          // The wildcard needs a subdomain
+		"https://app.preview.example.com:8443", // Different port
+		"https://preview.example.com.evil.com",
+	}
+	for _, origin := range origins {
+		recorder := preflight(router, origin, "GET")
+
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusForbidden, origin, recorder.Code)
+		}
+		if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Expected no Access-Control-Allow-Origin for %s, got %q", origin, got)
+		}
+	}
+}
+
+func TestCORSPreflightWildcardSubdomain(t *testing.T) {
+	router := corsRouter(testCORSConfig())
+
+	for _, origin := range []string{"https://pr-12.preview.example.com", "https://a.b.preview.example.com"} {
+		recorder := preflight(router, origin, "GET")
+
+		if recorder.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusNoContent, origin, recorder.Code)
+		}
+		if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", origin, got)
+		}
+	}
+}
+
+func TestCORSAllowAllDropsCredentials(t *testing.T) {
+	config := testCORSConfig()
+	config.AllowOrigins = []string{"*"}
+	if !config.AllowsAll() || !config.AllowsOrigin("https://anything.example.org") {
+		t.Fatalf("Expected \"*\" to allow every origin")
+	}
+
+	recorder := preflight(corsRouter(config), "https://anything.example.org", "GET")
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected Access-Control-Allow-Origin \"*\", got %q", got)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Credentials with every origin allowed, got %q", got)
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	router := corsRouter(testCORSConfig())
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/student/all", nil)
+	request.Header.Set("Origin", "https://atv.example.com")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "https://atv.example.com" {
+		t.Errorf("Expected Access-Control-Allow-Origin to echo the origin, got %q", got)
+	}
+	if got := recorder.Header().Get("Access-Control-Expose-Headers"); got == "" {
+		t.Errorf("Expected Access-Control-Expose-Headers to be set")
+	}
+}
+
+func TestCORSRequestWithoutOrigin(t *testing.T) {
+	router := corsRouter(testCORSConfig())
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/student/all", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d for a same origin request, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestCORSPreflightAllowsTheTenantHeader(t *testing.T) {
+	useReload(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.CORSMiddleware())
+	router.GET("/api/v1/student/all", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	origin := "https://admin.example.com"
+	t.Setenv("CORS_ALLOW_ORIGINS", origin)
+	for _, header := range []string{"X-ATV-Tenant", "X-University"} {
+		t.Setenv("TENANCY_HEADER", header)
+		configs.Reload("tenant header test")
+
+		recorder := preflight(router, origin, "GET", header)
+		allowed := strings.Split(strings.ToLower(recorder.Header().Get("Access-Control-Allow-Headers")), ",")
+		if recorder.Code != http.StatusNoContent || !slices.Contains(allowed, strings.ToLower(header)) {
+			t.Errorf("The preflight should allow %s, got %d with %v", header, recorder.Code, allowed)
+		}
+	}
+}
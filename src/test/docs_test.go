@@ -0,0 +1,34 @@
+package main
+
+import (
+	"dainxor/atv/openapi"
+	"dainxor/atv/routes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSwaggerUIIsServedWithoutACDN(t *testing.T) {
+	router := contractRouter()
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, routes.DOCS_PATH, nil))
+	page := recorder.Body.String()
+	if recorder.Code != http.StatusOK || strings.Contains(page, "https://") || !strings.Contains(page, `"`+routes.OPENAPI_PATH+`"`) {
+		t.Fatalf("Docs page answered %d, expected 200 loading only local files:\n%s", recorder.Code, page)
+	}
+
+	for name, contentType := range openapi.SWAGGER_ASSETS {
+		path := routes.DOCS_PATH + "/" + name
+		if !strings.Contains(page, path) {
+			t.Errorf("Docs page does not load %s", path)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != contentType || recorder.Body.Len() < 100000 {
+			t.Errorf("%s answered %d with %q and %d bytes, expected the embedded %s", path, recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body.Len(), contentType)
+		}
+	}
+}
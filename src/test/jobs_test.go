@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"dainxor/atv/jobs"
+	"dainxor/atv/models"
+	"dainxor/atv/routes"
+	"dainxor/atv/types"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobBackoffDoublesUpToTheLimit(t *testing.T) {
+	t.Setenv("JOBS_BACKOFF", "10s")
+	t.Setenv("JOBS_MAX_BACKOFF", "1m")
+	useMemoryDB(t)
+
+	for attempt, expected := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 30: time.Minute} {
+		if wait := jobs.Backoff(attempt); wait != expected {
+			t.Errorf("Attempt %d should wait %v, got %v", attempt, expected, wait)
+		}
+	}
+}
+
+func TestFailingJobsRetryUntilDead(t *testing.T) {
+	for _, queue := range []jobs.Queue{jobs.NewMemory(), jobs.Database{}} {
+		t.Run(queue.Name(), func(t *testing.T) {
+			t.Setenv("JOBS_MAX_ATTEMPTS", "3")
+			t.Setenv("JOBS_BACKOFF", "1m")
+			useMemoryDB(t)
+			jobs.Use(queue)
+			testRetriesUntilDead(t)
+		})
+	}
+}
+
+func testRetriesUntilDead(t *testing.T) {
+
+	var runs atomic.Int32
+	jobs.Register("test.flaky", func(ctx context.Context, job models.JobDBMongo) error {
+		var payload struct{ Fail int }
+		if err := jobs.Decode(job, &payload); err != nil {
+			return err
+		}
+		if int(runs.Add(1)) <= payload.Fail {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	job, err := jobs.Enqueue(ctx, "test.flaky", map[string]int{"Fail": 5})
+	if err != nil {
+		t.Fatal("Could not queue the job:", err)
+	}
+
+	// Each retry waits for its backoff, 1m then 2m
+	next := time.Now()
+	for attempt, wait := range []time.Duration{time.Minute, 2 * time.Minute, 0} {
+		if ran := jobs.RunDue(ctx, next); ran != 1 {
+			t.Fatalf("Attempt %d should run once due, %d ran", attempt+1, ran)
+		}
+		job, _ = jobs.Current().Get(ctx, job.ID.Hex())
+		if wait == 0 {
+			break
+		}
+		if job.Status != models.JOB_QUEUED || job.RunAt.Sub(job.UpdatedAt) != wait {
+			t.Fatalf("Attempt %d should be retried after %v, got %+v", attempt+1, wait, job)
+		}
+		if ran := jobs.RunDue(ctx, job.RunAt.Add(-time.Second)); ran != 0 {
+			t.Fatalf("Attempt %d ran before its backoff", attempt+2)
+		}
+		next = job.RunAt
+	}
+
+	job, _ = jobs.Current().Get(ctx, job.ID.Hex())
+	if job.Status != models.JOB_DEAD || job.Attempts != 3 || job.LastError != "not yet" {
+		t.Fatalf("The job should be dead after 3 attempts, got %+v", job)
+	}
+	if dead, _ := jobs.Current().List(ctx, models.JOB_DEAD, ""); len(dead) != 1 {
+		t.Errorf("Expected the job on the dead letters, got %+v", dead)
+	}
+
+	// An admin retry gets it done
+	if _, err := jobs.Retry(ctx, job.ID.Hex()); err != nil {
+		t.Fatal("Could not retry the dead job:", err)
+	}
+	runs.Store(5)
+	if ran := jobs.RunDue(ctx, time.Now().Add(time.Second)); ran != 1 {
+		t.Fatalf("The retried job should run right away, %d ran", ran)
+	}
+	job, _ = jobs.Current().Get(ctx, job.ID.Hex())
+	if job.Status != models.JOB_DONE || job.Attempts != 1 {
+		t.Fatalf("The retried job should be done on its first attempt, got %+v", job)
+	}
+
+	if _, err := jobs.Retry(ctx, job.ID.Hex()); err == nil || err.(*types.HttpError).Code != http.StatusConflict {
+		t.Errorf("Only dead jobs should be retried, got %v", err)
+	}
+}
+
+func TestJobsWithoutHandlerAreDead(t *testing.T) {
+	useMemoryDB(t)
+	ctx := context.Background()
+
+	job, _ := jobs.Enqueue(ctx, "test.unknown", nil)
+	jobs.RunDue(ctx, time.Now().Add(time.Second))
+
+	job, _ = jobs.Current().Get(ctx, job.ID.Hex())
+	if job.Status != models.JOB_DEAD || job.Attempts != 1 {
+		t.Fatalf("A job without handler should be dead at once, got %+v", job)
+	}
+
+	router := contractRouter()
+	spec := routes.Spec(router)
+	result := send(t, router, spec, contractCall{method: http.MethodGet, path: "/admin/jobs", target: "/admin/jobs?status=dead"})
+	if result.status != http.StatusUnauthorized {
+		t.Errorf("The job list is for admins only, got %d", result.status)
+	}
+}
+
+func TestFinishedJobsArePrunedAfterRetention(t *testing.T) {
+	for _, queue := range []jobs.Queue{jobs.NewMemory(), jobs.Database{}} {
+		t.Run(queue.Name(), func(t *testing.T) {
+			t.Setenv("JOBS_RETENTION", "1h")
+			useMemoryDB(t)
+			jobs.Use(queue)
+			jobs.Register("test.prune", func(ctx context.Context, job models.JobDBMongo) error { return nil })
+
+			ctx := context.Background()
+			done, _ := jobs.Enqueue(ctx, "test.prune", nil)
+			jobs.RunDue(ctx, time.Now().Add(time.Second))
+			waiting, _ := jobs.Enqueue(ctx, "test.prune", nil)
+
+			if pruned := jobs.Prune(ctx, time.Now().Add(30*time.Minute)); pruned != 0 {
+				t.Fatalf("Pruned %d jobs within their retention", pruned)
+			}
+			if pruned := jobs.Prune(ctx, time.Now().Add(2*time.Hour)); pruned != 1 {
+				t.Fatalf("Pruned %d jobs, expected the done one", pruned)
+			}
+			if _, err := jobs.Current().Get(ctx, done.ID.Hex()); err == nil {
+				t.Errorf("The done job should be gone")
+			}
+			if job, err := jobs.Current().Get(ctx, waiting.ID.Hex()); err != nil || job.Status != models.JOB_QUEUED {
+				t.Errorf("The queued job should be kept, got %+v %v", job, err)
+			}
+		})
+	}
+}
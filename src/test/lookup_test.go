@@ -0,0 +1,56 @@
+package main
+
+import (
+	"dainxor/atv/openapi"
+	"dainxor/atv/routes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lookup calls a lookup route with the value, prefix is the route up to its parameter
+func lookup(t *testing.T, router *gin.Engine, spec openapi.Document, prefix string, value string) contractResult {
+	t.Helper()
+	path := prefix + ":number_id"
+	if strings.HasSuffix(prefix, "/by-email/") {
+		path = prefix + ":email"
+	}
+	return send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: prefix + url.PathEscape(value)})
+}
+
+func TestLookupsByNumberAndEitherEmail(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+
+	for _, check := range []struct {
+		prefix, value, id string
+	}{
+		{"/api/v1/student/by-number/", "1000000000", ids["student"]},
+		{"/api/v1/student/by-email/", "ana0@example.com", ids["student"]},
+		{"/api/v1/student/by-email/", "ana0@university.edu", ids["student"]},
+		{"/api/v1/companion/by-number/", "2000000000", ids["companion"]},
+		{"/api/v1/companion/by-email/", "luis0@example.com", ids["companion"]},
+		{"/api/v1/companion/by-email/", "luis0@university.edu", ids["companion"]},
+	} {
+		result := lookup(t, router, spec, check.prefix, check.value)
+		if result.status != http.StatusOK || result.data["id"] != check.id {
+			t.Errorf("GET %s%s answered %d %v, expected %s", check.prefix, check.value, result.status, result.data, check.id)
+		}
+	}
+
+	send(t, router, spec, contractCall{method: http.MethodDelete, path: "/api/v1/student/:id", target: "/api/v1/student/" + ids["student"]})
+	for _, missing := range [][2]string{
+		{"/api/v1/student/by-number/", "1000000000"}, // Deleted
+		{"/api/v1/student/by-email/", "ana0@example.com"},
+		{"/api/v1/companion/by-number/", "9999999999"},
+	} {
+		if result := lookup(t, router, spec, missing[0], missing[1]); result.status != http.StatusNotFound {
+			t.Errorf("GET %s%s answered %d, expected 404", missing[0], missing[1], result.status)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"dainxor/atv/metrics"
+	"dainxor/atv/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics of the exposition tests, registered once like the application ones
+var (
+	testCounter   = metrics.NewCounter("atv_test_calls_total", "Calls made by the test,\nby \"path\".", "path")
+	testGauge     = metrics.NewGauge("atv_test_level", "Level set by the test.")
+	testHistogram = metrics.NewHistogram("atv_test_wait_seconds", "Waits seen by the test.", []float64{1, 0.1}, "kind")
+)
+
+// exposition returns the lines of the metric named, HELP and TYPE included
+func exposition(name string) []string {
+	var output bytes.Buffer
+	metrics.Write(&output)
+
+	lines := []string{}
+	for _, line := range strings.Split(output.String(), "\n") {
+		if strings.HasPrefix(line, name) || strings.HasPrefix(line, "# HELP "+name+" ") || strings.HasPrefix(line, "# TYPE "+name+" ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func expectLines(t *testing.T, name string, expected []string) {
+	t.Helper()
+	got := exposition(name)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Exposition of %s:\n%s\nexpected:\n%s", name, strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestMetricsCounterExposition(t *testing.T) {
+	testCounter.Inc("/b")
+	testCounter.Add(2, `/a "quoted" \ back`+"\nslash")
+	testCounter.Add(-5, "/b") // Ignored, counters only go up
+
+	expectLines(t, "atv_test_calls_total", []string{
+		`# HELP atv_test_calls_total Calls made by the test,\nby "path".`,
+		`# TYPE atv_test_calls_total counter`,
+		`atv_test_calls_total{path="/a \"quoted\" \\ back\nslash"} 2`,
+		`atv_test_calls_total{path="/b"} 1`,
+	})
+}
+
+func TestMetricsGaugeExposition(t *testing.T) {
+	testGauge.Set(0)
+	expectLines(t, "atv_test_level", []string{
+		`# HELP atv_test_level Level set by the test.`,
+		`# TYPE atv_test_level gauge`,
+		`atv_test_level 0`,
+	})
+
+	testGauge.Set(2.5)
+	testGauge.Dec()
+	if lines := exposition("atv_test_level"); lines[len(lines)-1] != "atv_test_level 1.5" {
+		t.Errorf("Gauge written as %q, expected atv_test_level 1.5", lines[len(lines)-1])
+	}
+}
+
+func TestMetricsHistogramExposition(t *testing.T) {
+	for _, wait := range []float64{0.05, 0.1, 0.5, 3} {
+		testHistogram.Observe(wait, "db")
+	}
+
+	expectLines(t, "atv_test_wait_seconds", []string{
+		`# HELP atv_test_wait_seconds Waits seen by the test.`,
+		`# TYPE atv_test_wait_seconds histogram`,
+		`atv_test_wait_seconds_bucket{kind="db",le="0.1"} 2`,
+		`atv_test_wait_seconds_bucket{kind="db",le="1"} 3`,
+		`atv_test_wait_seconds_bucket{kind="db",le="+Inf"} 4`,
+		`atv_test_wait_seconds_sum{kind="db"} 3.65`,
+		`atv_test_wait_seconds_count{kind="db"} 4`,
+	})
+}
+
+func TestMetricsPanicOnUnmatchedRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RecoverMiddleware())
+	router.NoRoute(func(c *gin.Context) { panic("no route") })
+
+	before := metrics.PanicsRecovered.Value(middleware.UNMATCHED_ROUTE)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Panic answered %d, expected 500", recorder.Code)
+	}
+	if after := metrics.PanicsRecovered.Value(middleware.UNMATCHED_ROUTE); after != before+1 {
+		t.Errorf("Panics recovered on unmatched routes went from %v to %v, expected one more", before, after)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"dainxor/atv/jobs"
+	"dainxor/atv/models"
+	"dainxor/atv/notify"
+	"dainxor/atv/routes"
+	"errors"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// recordingSender keeps the messages instead of sending them
+type recordingSender struct {
+	mutex    sync.Mutex
+	messages []notify.Message
+	failing  string // Address whose next message fails
+}
+
+func (r *recordingSender) Name() string {
+	return "recording"
+}
+
+func (r *recordingSender) Send(ctx context.Context, message notify.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if message.To == r.failing {
+		r.failing = ""
+		return errors.New("mailbox unavailable")
+	}
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+// take returns the messages sent so far, once every queued notification is done, and forgets them
+func (r *recordingSender) take() []notify.Message {
+	jobs.RunDue(context.Background(), time.Now())
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	messages := r.messages
+	r.messages = nil
+	return messages
+}
+
+func TestNotificationTemplatesInBothLanguages(t *testing.T) {
+	for _, language := range models.NOTIFICATION_LANGUAGES {
+		for _, event := range models.NOTIFICATION_EVENTS {
+			date := notify.FormatDate("2026-01-15T10:00", language)
+			subject, body, err := notify.Render(event, language, notify.TemplateData{
+				Name: "Ana", ForStudent: true, Companion: "Luis Pérez", Date: date, PreviousDate: date,
+			})
+			if err != nil {
+				t.Fatalf("Failed to render %s in %s: %v", event, language, err)
+			}
+			if !strings.Contains(subject, date) || !strings.Contains(body, "Ana") || !strings.Contains(body, "Luis Pérez") {
+				t.Errorf("The %s %s message misses its data: %q %q", language, event, subject, body)
+			}
+		}
+	}
+
+	if date := notify.FormatDate("2026-01-15T10:00", "es"); date != "jueves 15 de enero de 2026 a las 10:00" {
+		t.Errorf("Unexpected Spanish date %q", date)
+	}
+	if date := notify.FormatDate("2026-01-15", "en"); date != "Thursday, January 15, 2026" {
+		t.Errorf("Unexpected English date %q", date)
+	}
+}
+
+func TestSessionNotificationsFollowPreferences(t *testing.T) {
+	useMemoryDB(t)
+	sender := &recordingSender{}
+	notify.Use(sender)
+	t.Cleanup(notify.ReloadEnv)
+
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+	sender.take()
+
+	// The student wants English on the institution email, the companion no confirmations
+	preferences := "/api/v1/notification/preferences/:kind/:target_id"
+	for kind, body := range map[string]models.NotificationPreferenceCreate{
+		models.NOTIFICATION_STUDENT:   {Address: models.NOTIFICATION_ADDRESS_INSTITUTION, Language: "en"},
+		models.NOTIFICATION_COMPANION: {Muted: []string{models.NOTIFICATION_CONFIRMATION}},
+	} {
+		target := "/api/v1/notification/preferences/" + kind + "/" + ids[kind]
+		if result := send(t, router, spec, contractCall{method: http.MethodPut, path: preferences, target: target, body: body}); result.status != http.StatusOK {
+			t.Fatalf("Could not set the %s preferences, got %d %v", kind, result.status, result.data)
+		}
+	}
+
+	path := "/api/v1/session/"
+	result := send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, body: fixtureBody("session", ids, 1)})
+	if result.status != http.StatusCreated {
+		t.Fatalf("Could not book the session, got %d %v", result.status, result.data)
+	}
+	session, _ := result.data["id"].(string)
+
+	messages := sender.take()
+	if len(messages) != 1 || messages[0].To != "ana0@university.edu" || !strings.HasPrefix(messages[0].Subject, "Session confirmed") {
+		t.Fatalf("Only the student should get an English confirmation on the institution email, got %+v", messages)
+	}
+
+	// Moving the session tells both
+	patch := "/api/v1/session/:id"
+	result = send(t, router, spec, contractCall{method: http.MethodPatch, path: patch, target: "/api/v1/session/" + session, body: models.SessionCreate{Date: "2026-01-20T09:00"}})
+	if result.status != http.StatusOK {
+		t.Fatalf("Could not move the session, got %d %v", result.status, result.data)
+	}
+	messages = sender.take()
+	if len(messages) != 2 || !strings.Contains(messages[1].Body, "jueves 15 de enero de 2026") || messages[1].To != "luis0@example.com" {
+		t.Fatalf("Both should get a reschedule notice, the companion in Spanish, got %+v", messages)
+	}
+
+	// Reminders go once per session date
+	now := time.Date(2026, 1, 19, 12, 0, 0, 0, time.Local)
+	if queued := notify.QueueReminders(context.Background(), now); queued != 1 {
+		t.Fatalf("Queued %d reminders, expected the one of the session", queued)
+	}
+	if queued := notify.QueueReminders(context.Background(), now); queued != 0 {
+		t.Errorf("Queued %d reminders while one was waiting, expected none", queued)
+	}
+	if messages = sender.take(); len(messages) != 2 {
+		t.Fatalf("Expected a reminder for the student and the companion, got %+v", messages)
+	}
+	if queued := notify.QueueReminders(context.Background(), now.Add(time.Hour)); queued != 0 {
+		t.Errorf("Queued %d reminders after both were sent, expected none", queued)
+	}
+	if messages = sender.take(); len(messages) != 0 {
+		t.Fatalf("Reminders should not be sent twice, got %+v", messages)
+	}
+
+	history := "/api/v1/notification/session/:session_id"
+	result = send(t, router, spec, contractCall{method: http.MethodGet, path: history, target: "/api/v1/notification/session/" + session})
+	if result.status != http.StatusOK {
+		t.Errorf("Expected the notification history, got %d", result.status)
+	}
+}
+
+func TestFailedSessionNotificationsAreRetried(t *testing.T) {
+	useMemoryDB(t)
+	sender := &recordingSender{}
+	notify.Use(sender)
+	t.Cleanup(notify.ReloadEnv)
+
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+	sender.take()
+
+	sender.failing = "luis0@example.com"
+	path := "/api/v1/session/"
+	result := send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, body: fixtureBody("session", ids, 1)})
+	if result.status != http.StatusCreated {
+		t.Fatalf("Could not book the session, got %d %v", result.status, result.data)
+	}
+	if messages := sender.take(); len(messages) != 1 || messages[0].To != "ana0@example.com" {
+		t.Fatalf("Only the student should get the confirmation at first, got %+v", messages)
+	}
+
+	// The retry only goes to the companion, once its backoff passed
+	jobs.RunDue(context.Background(), time.Now().Add(jobs.Backoff(1)+time.Second))
+	if messages := sender.take(); len(messages) != 1 || messages[0].To != "luis0@example.com" {
+		t.Fatalf("The retry should only reach the companion, got %+v", messages)
+	}
+}
+
+// fullQueue refuses every job
+type fullQueue struct {
+	*jobs.Memory
+}
+
+func (fullQueue) Add(ctx context.Context, job models.JobDBMongo) (models.JobDBMongo, error) {
+	return job, errors.New("queue is full")
+}
+
+func TestSessionNotificationQueueErrorsAreReturned(t *testing.T) {
+	useMemoryDB(t)
+	jobs.Use(fullQueue{jobs.NewMemory()})
+
+	session := models.SessionDBMongo{ID: bson.NewObjectID(), Date: "2026-01-15T10:00", Status: models.STATUS_PENDING}
+	if err := notify.SessionCreated(context.Background(), session); err == nil {
+		t.Error("Queueing the confirmation on a full queue succeeded, expected its error")
+	}
+	cancelled := session
+	cancelled.Status = models.STATUS_CANCELLED
+	if err := notify.SessionChanged(context.Background(), session, cancelled); err == nil {
+		t.Error("Queueing the cancellation on a full queue succeeded, expected its error")
+	}
+	if err := notify.SessionChanged(context.Background(), session, session); err != nil {
+		t.Errorf("An update without a notice returned %v, expected nothing to queue", err)
+	}
+}
+
+// smtpStandIn accepts SMTP connections on a local port, answering the commands
+// unless stuck, when it only keeps the connections open
+func smtpStandIn(t *testing.T, stuck bool) (notify.SMTPConfig, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Could not listen:", err)
+	}
+	var mutex sync.Mutex
+	conns := []net.Conn{}
+	t.Cleanup(func() {
+		listener.Close()
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			conns = append(conns, conn)
+			mutex.Unlock()
+			if !stuck {
+				go answerSMTP(conn, received)
+			}
+		}
+	}()
+
+	address := listener.Addr().(*net.TCPAddr)
+	return notify.SMTPConfig{Host: address.IP.String(), Port: address.Port}, received
+}
+
+func answerSMTP(conn net.Conn, received chan string) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 stand-in ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.Fields(line + " ")[0]); command {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 stand-in")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			body, _ := text.ReadDotBytes()
+			received <- string(body)
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func smtpMessage() notify.Message {
+	return notify.Message{From: "ATV <no-reply@atv.local>", To: "ana@example.com", Subject: "Sesión", Body: "Hola"}
+}
+
+func TestSMTPSendsTheMessage(t *testing.T) {
+	config, received := smtpStandIn(t, false)
+	config.Timeout = time.Second
+
+	if err := notify.NewSMTP(config).Send(context.Background(), smtpMessage()); err != nil {
+		t.Fatal("Send failed:", err)
+	}
+	if body := <-received; !strings.Contains(body, "To: ana@example.com") || !strings.Contains(body, "Hola") {
+		t.Errorf("The server received:\n%s", body)
+	}
+}
+
+func TestSMTPGivesUpOnAStuckServer(t *testing.T) {
+	config, _ := smtpStandIn(t, true)
+
+	for name, check := range map[string]struct {
+		timeout time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+	}{
+		"timeout": {200 * time.Millisecond, func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) }},
+		"context deadline": {0, func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 200*time.Millisecond)
+		}},
+	} {
+		config.Timeout = check.timeout
+		ctx, cancel := check.ctx()
+		start := time.Now()
+		err := notify.NewSMTP(config).Send(ctx, smtpMessage())
+		cancel()
+
+		if waited := time.Since(start); err == nil || waited > time.Second {
+			t.Errorf("With a %s, sending to a stuck server answered %v after %v, expected an error within a second", name, err, waited)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"dainxor/atv/configs"
+	"dainxor/atv/models"
+	"dainxor/atv/photo"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exifJPEG encodes a jpeg of the given size carrying an EXIF orientation
+func exifJPEG(width int, height int, orientation uint16) []byte {
+	picture := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			picture.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, picture, nil)
+
+	// Little endian TIFF header with a single IFD entry: the orientation
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+func TestPhotoSizes(t *testing.T) {
+	var source bytes.Buffer
+	png.Encode(&source, image.NewNRGBA(image.Rect(0, 0, 300, 100)))
+
+	processed, err := photo.Process(&source)
+	if err != nil {
+		t.Fatalf("Failed to process png: %v", err)
+	}
+	if processed.Extension != "png" || processed.ContentType != "image/png" {
+		t.Errorf("A png should stay png, got %s %s", processed.Extension, processed.ContentType)
+	}
+
+	expected := map[string]image.Point{
+		photo.SIZE_SMALL:  {64, 64},
+		photo.SIZE_MEDIUM: {100, 100}, // Cropped square, not enlarged
+		photo.SIZE_LARGE:  {300, 100}, // Already within bounds, not enlarged
+	}
+	if len(processed.Images) != len(expected) {
+		t.Fatalf("Expected %d sizes, got %d", len(expected), len(processed.Images))
+	}
+	for _, encoded := range processed.Images {
+		config, err := png.DecodeConfig(bytes.NewReader(encoded.Content))
+		if err != nil {
+			t.Fatalf("Size %s is not a png: %v", encoded.Size, err)
+		}
+		if got := (image.Point{config.Width, config.Height}); got != expected[encoded.Size] {
+			t.Errorf("Size %s should be %v, got %v", encoded.Size, expected[encoded.Size], got)
+		}
+	}
+}
+
+func TestPhotoIsTurnedUprightAndStripped(t *testing.T) {
+	processed, err := photo.Process(bytes.NewReader(exifJPEG(120, 40, 6))) // Rotated 90° clockwise
+	if err != nil {
+		t.Fatalf("Failed to process jpeg: %v", err)
+	}
+	if processed.Extension != "jpg" {
+		t.Errorf("A jpeg should stay jpeg, got %s", processed.Extension)
+	}
+
+	for _, encoded := range processed.Images {
+		if bytes.Contains(encoded.Content, []byte("Exif")) {
+			t.Errorf("Size %s still carries EXIF metadata", encoded.Size)
+		}
+		if encoded.Size != photo.SIZE_LARGE {
+			continue
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(encoded.Content))
+		if err != nil {
+			t.Fatalf("Large size is not a jpeg: %v", err)
+		}
+		if config.Width != 40 || config.Height != 120 {
+			t.Errorf("Orientation 6 should turn 120x40 into 40x120, got %dx%d", config.Width, config.Height)
+		}
+	}
+}
+
+func TestPhotoRejectsOtherContent(t *testing.T) {
+	_, err := photo.Process(strings.NewReader("<svg xmlns=\"http://www.w3.org/2000/svg\"/>"))
+	if !errors.Is(err, photo.ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestPhotoURLFollowsTheRoutesVersion(t *testing.T) {
+	t.Cleanup(func() {
+		configs.Reload("photo URL test finished")
+		configs.ReloadAppEnv()
+	})
+	t.Setenv("ATV_ROUTE_VERSION", "3")
+	configs.Reload("photo URL test")
+	configs.ReloadAppEnv()
+
+	id := models.DBID{1}
+	photo := models.PhotoDB{Key: "photos/student", Extension: "jpg", UpdatedAt: time.Unix(1700000000, 0)}
+	if url := photo.URL("student", id); url != "/api/v3/student/"+id.Hex()+"/photo?v=1700000000" {
+		t.Errorf("Photo URL is %q, expected it on the v3 routes", url)
+	}
+	if url := (models.PhotoDB{}).URL("student", id); url != "" {
+		t.Errorf("URL without a photo is %q, expected none", url)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"dainxor/atv/auth"
+	"dainxor/atv/configs"
+	"dainxor/atv/middleware"
+	"dainxor/atv/ratelimit"
+	"dainxor/atv/routes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseLimit(t *testing.T) {
+	for _, check := range []struct {
+		value string
+		limit ratelimit.Limit
+		valid bool
+	}{
+		{"60/m", ratelimit.Limit{Rate: 1, Burst: 60}, true},
+		{" 10 / s ", ratelimit.Limit{Rate: 10, Burst: 10}, true},
+		{"3600/h", ratelimit.Limit{Rate: 1, Burst: 3600}, true},
+		{"100/30s", ratelimit.Limit{Rate: 100.0 / 30, Burst: 100}, true},
+		{"OFF", ratelimit.Unlimited, true},
+		{"60", ratelimit.Limit{}, false},
+		{"0/m", ratelimit.Limit{}, false},
+		{"-1/m", ratelimit.Limit{}, false},
+		{"ten/m", ratelimit.Limit{}, false},
+		{"10/fortnight", ratelimit.Limit{}, false},
+		{"10/-1s", ratelimit.Limit{}, false},
+	} {
+		limit, err := ratelimit.ParseLimit(check.value)
+		if (err == nil) != check.valid || limit != check.limit {
+			t.Errorf("ParseLimit(%q) = %+v, %v; expected %+v, valid %v", check.value, limit, err, check.limit, check.valid)
+		}
+	}
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit, _ := ratelimit.ParseLimit("2/100ms") // A token every 50ms
+	ctx := context.Background()
+
+	for i, remaining := range []int{1, 0} {
+		decision, _ := store.Take(ctx, "bucket", limit)
+		if !decision.Allowed || decision.Limit != 2 || decision.Remaining != remaining {
+			t.Errorf("Take %d got %+v, expected allowed with %d remaining", i+1, decision, remaining)
+		}
+	}
+
+	denied, _ := store.Take(ctx, "bucket", limit)
+	if denied.Allowed || denied.RetryAfter <= 0 || denied.RetryAfter > 50*time.Millisecond {
+		t.Errorf("Take over the burst got %+v, expected denied with a retry within 50ms", denied)
+	}
+	if other, _ := store.Take(ctx, "other", limit); !other.Allowed {
+		t.Errorf("Another key shared the bucket, got %+v", other)
+	}
+
+	time.Sleep(denied.RetryAfter + 10*time.Millisecond)
+	if refilled, _ := store.Take(ctx, "bucket", limit); !refilled.Allowed {
+		t.Errorf("Take after the retry got %+v, expected a refilled token", refilled)
+	}
+}
+
+// limitedRouter serves a route of the ratetest group, limited as set on the environment
+func limitedRouter(t *testing.T, ipLimit string, userLimit string) *gin.Engine {
+	t.Cleanup(func() { configs.Reload("rate limit test finished") })
+	t.Setenv("RATE_LIMIT_ENABLED", "true")
+	t.Setenv("RATE_LIMIT_RATETEST", ipLimit)
+	t.Setenv("RATE_LIMIT_RATETEST_USER", userLimit)
+	configs.Reload("rate limit test")
+	ratelimit.SetStore(ratelimit.NewMemoryStore())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := routes.TrustProxies(router); err != nil {
+		t.Fatal("Could not set the trusted proxies:", err)
+	}
+	router.Use(middleware.TokenMiddleware())
+	router.GET("/limited", middleware.RateLimitMiddleware("ratetest"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func limitedCall(router *gin.Engine, ip string, token string) *httptest.ResponseRecorder {
+	return forwardedCall(router, ip, "", token)
+}
+
+// forwardedCall is limitedCall through a peer at ip, forwarding for the client IP when given
+func forwardedCall(router *gin.Engine, ip string, client string, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	request.RemoteAddr = ip + ":40000"
+	if client != "" {
+		request.Header.Set("X-Forwarded-For", client)
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRateLimitPerIPHeaders(t *testing.T) {
+	router := limitedRouter(t, "2/m", "3/m")
+
+	for i := range 2 {
+		response := limitedCall(router, "10.0.0.1", "")
+		remaining := strconv.Itoa(1 - i)
+		if response.Code != http.StatusOK || response.Header().Get("X-RateLimit-Limit") != "2" || response.Header().Get("X-RateLimit-Remaining") != remaining {
+			t.Errorf("Call %d answered %d with limit %q remaining %q, expected 200 with 2 and %s", i+1, response.Code,
+				response.Header().Get("X-RateLimit-Limit"), response.Header().Get("X-RateLimit-Remaining"), remaining)
+		}
+		if reset, _ := strconv.Atoi(response.Header().Get("X-RateLimit-Reset")); reset < 1 || reset > 60 {
+			t.Errorf("Call %d has X-RateLimit-Reset %q, expected within the minute", i+1, response.Header().Get("X-RateLimit-Reset"))
+		}
+	}
+
+	limited := limitedCall(router, "10.0.0.1", "")
+	if limited.Code != http.StatusTooManyRequests || limited.Header().Get("Retry-After") != "30" || limited.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Call over the limit answered %d with Retry-After %q remaining %q, expected 429 with 30 and 0",
+			limited.Code, limited.Header().Get("Retry-After"), limited.Header().Get("X-RateLimit-Remaining"))
+	}
+	if other := limitedCall(router, "10.0.0.2", ""); other.Code != http.StatusOK {
+		t.Errorf("Another IP answered %d, expected its own bucket", other.Code)
+	}
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	router := limitedRouter(t, "1/m", "3/m")
+	expires := time.Now().Add(time.Hour).Unix()
+	ana := signToken(t, auth.Claims{Subject: "ana", ExpiresAt: expires})
+	luis := signToken(t, auth.Claims{Subject: "luis", ExpiresAt: expires})
+
+	for i, ip := range []string{"10.0.1.1", "10.0.1.2", "10.0.1.3"} { // A user keeps the bucket across IPs
+		if response := limitedCall(router, ip, ana); response.Code != http.StatusOK || response.Header().Get("X-RateLimit-Limit") != "3" {
+			t.Errorf("User call %d answered %d with limit %q, expected 200 with the user limit", i+1, response.Code, response.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+	if response := limitedCall(router, "10.0.1.4", ana); response.Code != http.StatusTooManyRequests {
+		t.Errorf("User call over the limit answered %d, expected 429", response.Code)
+	}
+	if response := limitedCall(router, "10.0.1.1", luis); response.Code != http.StatusOK {
+		t.Errorf("Another user answered %d, expected its own bucket", response.Code)
+	}
+	if response := limitedCall(router, "10.0.1.1", ""); response.Code != http.StatusOK {
+		t.Errorf("An anonymous call from a user's IP answered %d, expected the IP bucket", response.Code)
+	}
+	if response := limitedCall(router, "10.0.1.1", "not-a-token"); response.Code != http.StatusTooManyRequests {
+		t.Errorf("An invalid token answered %d, expected it to count as anonymous on the IP bucket", response.Code)
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	t.Setenv("SERVER_TRUSTED_PROXIES", "10.9.0.0/16")
+	router := limitedRouter(t, "1/m", "1/m")
+
+	// An untrusted peer can not pick its own IP, every spoofed one counts on the peer's bucket
+	if response := forwardedCall(router, "10.0.2.1", "192.0.2.1", ""); response.Code != http.StatusOK {
+		t.Fatalf("First call answered %d, expected 200", response.Code)
+	}
+	if response := forwardedCall(router, "10.0.2.1", "192.0.2.2", ""); response.Code != http.StatusTooManyRequests {
+		t.Errorf("A spoofed X-Forwarded-For answered %d, expected 429 on the peer's bucket", response.Code)
+	}
+
+	// A trusted proxy forwards for each client, who get their own buckets
+	for _, client := range []string{"192.0.2.1", "192.0.2.2"} {
+		if response := forwardedCall(router, "10.9.0.1", client, ""); response.Code != http.StatusOK {
+			t.Errorf("Client %s through the trusted proxy answered %d, expected its own bucket", client, response.Code)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"dainxor/atv/configs"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"dainxor/atv/middleware"
+	"dainxor/atv/ratelimit"
+	"dainxor/atv/routes"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useReload restores the configuration of the environment once the test is done
+func useReload(t *testing.T) {
+	t.Cleanup(func() { configs.Reload("reload test finished") })
+}
+
+// eventually waits up to two seconds for done to hold
+func eventually(done func() bool) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if done() {
+			return true
+		}
+	}
+	return done()
+}
+
+func TestReloadSwapsLogCORSAndRateLimits(t *testing.T) {
+	useReload(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.CORSMiddleware())
+	router.GET("/api/v1/student/all", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	origin := "https://reload.example.com"
+	if allowed := preflight(router, origin, "GET").Header().Get("Access-Control-Allow-Origin"); allowed != "" {
+		t.Fatalf("Origin %s allowed as %q before the reload", origin, allowed)
+	}
+	generation := configs.CORS.Generation()
+
+	t.Setenv("DNX_LOG_MIN_LEVEL", "ERROR")
+	t.Setenv("CORS_ALLOW_ORIGINS", origin)
+	t.Setenv("RATE_LIMIT_DEFAULT", "7/m")
+	t.Setenv("RATE_LIMIT_RELOADED", "3/m")
+	configs.Reload("reload test")
+
+	if logger.LogLevelsHas(logger.LEVEL_INFO) || !logger.LogLevelsHas(logger.LEVEL_ERROR) {
+		t.Errorf("Log levels are %05b after the reload, expected from ERROR up", logger.LogLevels())
+	}
+	if configs.CORS.Generation() != generation+1 || !slices.Contains(configs.CORS.Config().AllowOrigins, origin) {
+		t.Errorf("CORS generation %d allows %v, expected generation %d with %s", configs.CORS.Generation(), configs.CORS.Config().AllowOrigins, generation+1, origin)
+	}
+	if allowed := preflight(router, origin, "GET").Header().Get("Access-Control-Allow-Origin"); allowed != origin {
+		t.Errorf("The running middleware allowed the origin as %q, expected %s", allowed, origin)
+	}
+	if reloaded, _ := ratelimit.LimitsFor("reloaded"); reloaded.Burst != 3 {
+		t.Errorf("The reloaded group allows %v, expected 3/m", reloaded)
+	}
+	if other, _ := ratelimit.LimitsFor("other"); other.Burst != 7 {
+		t.Errorf("Other groups allow %v, expected the new default 7/m", other)
+	}
+}
+
+func TestReloadIgnoresRestartSections(t *testing.T) {
+	useReload(t)
+	routesVersion, apiVersion := configs.App.RoutesVersion(), configs.App.ApiVersion()
+
+	t.Setenv("ATV_ROUTE_VERSION", "7")
+	t.Setenv("ATV_API_VERSION", "9.9.9")
+	t.Setenv("OTEL_TRACES_EXPORTER", "stdout")
+	t.Setenv("JOBS_WORKERS", "9")
+	previous := settings.Current()
+	loaded := configs.Reload("restart test")
+
+	if configs.App.RoutesVersion() != routesVersion || configs.App.ApiVersion() != apiVersion {
+		t.Errorf("The reload applied routes version %d and API version %s, expected %d and %s until a restart",
+			configs.App.RoutesVersion(), configs.App.ApiVersion(), routesVersion, apiVersion)
+	}
+
+	restart := map[string]bool{}
+	for _, field := range settings.Changes(previous, loaded) {
+		restart[field.Env] = field.Restart
+	}
+	for env, expected := range map[string]bool{"ATV_ROUTE_VERSION": true, "ATV_API_VERSION": true, "OTEL_TRACES_EXPORTER": true, "JOBS_WORKERS": false} {
+		if needs, changed := restart[env]; !changed || needs != expected {
+			t.Errorf("%s changed %v needing a restart %v, expected a change needing a restart %v", env, changed, needs, expected)
+		}
+	}
+}
+
+var (
+	reloadHookOnce  sync.Once
+	reloadHookMutex sync.Mutex
+	reloadHookSeen  [][2]string // The reload_hook flag before and after each reload
+)
+
+func TestReloadHooksGetThePreviousSettings(t *testing.T) {
+	useReload(t)
+	reloadHookOnce.Do(func() {
+		settings.OnReload(func(previous *settings.Loaded, current *settings.Loaded) {
+			reloadHookMutex.Lock()
+			defer reloadHookMutex.Unlock()
+			reloadHookSeen = append(reloadHookSeen, [2]string{previous.Config.Features["reload_hook"], current.Config.Features["reload_hook"]})
+		})
+	})
+	reloadHookMutex.Lock()
+	reloadHookSeen = nil
+	reloadHookMutex.Unlock()
+
+	t.Setenv("FEATURE_RELOAD_HOOK", "true")
+	configs.Reload("hook test")
+	t.Setenv("FEATURE_RELOAD_HOOK", "false")
+	configs.Reload("hook test")
+
+	reloadHookMutex.Lock()
+	defer reloadHookMutex.Unlock()
+	if expected := [][2]string{{"", "true"}, {"true", "false"}}; !slices.Equal(reloadHookSeen, expected) {
+		t.Errorf("The hook saw %v, expected %v", reloadHookSeen, expected)
+	}
+}
+
+func TestReloadOnHangup(t *testing.T) {
+	useReload(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	configs.ReloadOnHangup(ctx)
+
+	t.Setenv("RATE_LIMIT_HANGUP", "4/m")
+	before := configs.Reloads().Reloads
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal("Could not send SIGHUP:", err)
+	}
+
+	reloaded := eventually(func() bool {
+		status := configs.Reloads()
+		return status.Reloads > before && status.LastReason == "SIGHUP"
+	})
+	if limit, _ := ratelimit.LimitsFor("hangup"); !reloaded || limit.Burst != 4 {
+		t.Errorf("SIGHUP reloaded %v with the group allowing %v, expected a reload to 4/m", reloaded, limit)
+	}
+}
+
+func TestReloadOnConfigFileChange(t *testing.T) {
+	useReload(t)
+	t.Chdir(t.TempDir())
+	t.Setenv("ATV_CONFIG_WATCH", "20ms")
+	configs.Reload("watch test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go configs.WatchConfig(ctx)
+
+	time.Sleep(50 * time.Millisecond) // The first stamps see no .env
+	if err := os.WriteFile(settings.DEFAULT_ENV_FILE, []byte("RATE_LIMIT_WATCHED=6/m\n"), 0o600); err != nil {
+		t.Fatal("Could not write the .env file:", err)
+	}
+
+	if !eventually(func() bool { limit, _ := ratelimit.LimitsFor("watched"); return limit.Burst == 6 }) {
+		limit, _ := ratelimit.LimitsFor("watched")
+		t.Errorf("The watched group allows %v after writing .env, expected 6/m", limit)
+	}
+	if status := configs.Reloads(); status.LastReason != "config file changed" {
+		t.Errorf("Last reload was for %q, expected the config file change", status.LastReason)
+	}
+}
+
+func TestAdminConfigRoutes(t *testing.T) {
+	useReload(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	token := adminToken(t)
+
+	if result := send(t, router, spec, contractCall{method: http.MethodGet, path: "/admin/config", target: "/admin/config"}); result.status != http.StatusUnauthorized {
+		t.Errorf("Anonymous config request answered %d, expected 401", result.status)
+	}
+
+	result := send(t, router, spec, contractCall{method: http.MethodGet, path: "/admin/config", target: "/admin/config", token: token})
+	config, _ := result.data["config"].(map[string]any)
+	authConfig, _ := config["auth"].(map[string]any)
+	if result.status != http.StatusOK || authConfig["jwt_secret"] != settings.REDACTED {
+		t.Errorf("Config answered %d with the auth settings %v, expected 200 with the secret redacted", result.status, authConfig)
+	}
+
+	before := configs.Reloads().Reloads
+	t.Setenv("RATE_LIMIT_ADMIN_RELOAD", "2/m")
+	result = send(t, router, spec, contractCall{method: http.MethodPost, path: "/admin/config/reload", target: "/admin/config/reload", token: token})
+	status, _ := result.data["reload"].(map[string]any)
+	if reloads, _ := status["reloads"].(float64); result.status != http.StatusOK || int(reloads) != before+1 || status["last_reason"] != "admin request" {
+		t.Errorf("Reload answered %d with the status %v, expected 200 after reload %d", result.status, status, before+1)
+	}
+	if limit, _ := ratelimit.LimitsFor("admin_reload"); limit.Burst != 2 {
+		t.Errorf("The reloaded group allows %v, expected 2/m", limit)
+	}
+
+	t.Setenv("DB_TIMEOUT", "soon")
+	if result := send(t, router, spec, contractCall{method: http.MethodPost, path: "/admin/config/reload", target: "/admin/config/reload", token: token}); len(result.data["problems"].([]any)) != 1 {
+		t.Errorf("Reload with an invalid value reported the problems %v, expected DB_TIMEOUT", result.data["problems"])
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"dainxor/atv/auth"
+	"dainxor/atv/configs"
+	"dainxor/atv/routes"
+	"dainxor/atv/storage"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// s3StandIn is a minimal S3-compatible server keeping the objects in memory,
+// it checks the requests carry a Signature Version 4 header and the payload checksum
+type s3StandIn struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "payload checksum mismatch", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		object, exist := s.objects[r.URL.Path]
+		if !exist {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3StorageRoundTrip(t *testing.T) {
+	standIn := &s3StandIn{objects: map[string][]byte{}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	store := storage.NewS3(storage.S3Config{Endpoint: server.URL, Bucket: "atv", Region: "us-east-1", AccessKey: "access", SecretKey: "secret"})
+	ctx := context.Background()
+
+	blob, err := store.Put(ctx, "resources/report.txt", strings.NewReader("S3 content"))
+	if err != nil || blob.Size != int64(len("S3 content")) {
+		t.Fatalf("Put failed: %v %+v", err, blob)
+	}
+	if _, exist := standIn.objects["/atv/resources/report.txt"]; !exist {
+		t.Fatalf("Object should be stored path-style under the bucket, got %v", standIn.objects)
+	}
+
+	content, _, err := store.Get(ctx, "resources/report.txt")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	read, _ := io.ReadAll(content)
+	content.Close()
+	if string(read) != "S3 content" {
+		t.Errorf("Get returned %q", read)
+	}
+
+	if err := store.Delete(ctx, "resources/report.txt"); err != nil {
+		t.Fatal("Delete failed:", err)
+	}
+	if _, _, err := store.Get(ctx, "resources/report.txt"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Deleted object should not be found, got %v", err)
+	}
+}
+
+func uploadResource(router *gin.Engine, content string) (int, map[string]any) {
+	return uploadResourceAs(router, content, "")
+}
+
+// uploadResourceAs uploads the content with the token, anonymously when it is empty
+func uploadResourceAs(router *gin.Engine, content string, token string) (int, map[string]any) {
+	form, contentType := uploadForm("file", content)
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/resource/", bytes.NewReader(form))
+	request.Header.Set("Content-Type", contentType)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	return recorder.Code, body.Data
+}
+
+func TestResourceUploadDeduplicates(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	ana := signToken(t, auth.Claims{Subject: "ana", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	status, first := uploadResourceAs(router, "Same content", ana)
+	if status != http.StatusCreated || first["kind"] != "text" || first["uploaded_by"] != "ana" {
+		t.Fatalf("First upload should create a text resource of ana, got %d %v", status, first)
+	}
+	status, second := uploadResourceAs(router, "Same content", ana)
+	if status != http.StatusOK || second["id"] != first["id"] {
+		t.Errorf("Second upload should answer the first resource %v, got %d %v", first["id"], status, second)
+	}
+}
+
+func TestResourceUploadDoesNotRevealOtherUploads(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	ana := signToken(t, auth.Claims{Subject: "ana", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	_, first := uploadResourceAs(router, "Private content", ana)
+	luis := signToken(t, auth.Claims{Subject: "luis", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	status, other := uploadResourceAs(router, "Private content", luis)
+	if status != http.StatusCreated || other["id"] == first["id"] || other["uploaded_by"] != "luis" {
+		t.Errorf("Upload of the same content by another user answered %d with %v, expected a resource of their own", status, other)
+	}
+
+	ids := map[any]bool{first["id"]: true, other["id"]: true}
+	for range 2 {
+		status, anonymous := uploadResourceAs(router, "Private content", "")
+		if status != http.StatusCreated || ids[anonymous["id"]] || anonymous["uploaded_by"] != nil {
+			t.Errorf("Anonymous upload of the same content answered %d with %v, expected a new resource", status, anonymous)
+		}
+		ids[anonymous["id"]] = true
+	}
+}
+
+func TestResourceDownloadNeedsValidSignature(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+
+	_, resource := uploadResource(router, "Signed content")
+	id, _ := resource["id"].(string)
+	path := "/api/v1/resource/:id/url"
+	signed := send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: "/api/v1/resource/" + id + "/url"})
+	url, _ := signed.data["url"].(string)
+
+	download := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		return recorder
+	}
+	if recorder := download(url); recorder.Code != http.StatusOK || recorder.Body.String() != "Signed content" {
+		t.Errorf("Signed URL should download the file, got %d %q", recorder.Code, recorder.Body)
+	}
+	if recorder := download(url + "x"); recorder.Code != http.StatusForbidden {
+		t.Errorf("Tampered signature should be forbidden, got %d", recorder.Code)
+	}
+
+	expires, signature := storage.Sign(id, time.Now().Add(-time.Hour))
+	if err := storage.Verify(id, strconv.FormatInt(expires, 10), signature, time.Now()); !errors.Is(err, storage.ErrSignatureExpired) {
+		t.Errorf("Expired signature should be rejected as expired, got %v", err)
+	}
+}
+
+func TestStorageBackendCanBeSwitched(t *testing.T) {
+	t.Cleanup(func() { configs.Reload("storage switch test finished") })
+	t.Setenv("STORAGE_BACKEND", "s3")
+	t.Setenv("STORAGE_S3_ENDPOINT", "http://127.0.0.1:1")
+	t.Setenv("STORAGE_S3_BUCKET", "atv")
+	configs.Reload("storage switch test") // From the local backend
+
+	if name := storage.Current().Name(); !strings.HasPrefix(name, "s3") {
+		t.Errorf("Storage backend is %q after switching, expected s3", name)
+	}
+}
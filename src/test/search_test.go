@@ -0,0 +1,111 @@
+package main
+
+import (
+	"dainxor/atv/configs"
+	"dainxor/atv/models"
+	"dainxor/atv/routes"
+	"dainxor/atv/search"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestSearchIgnoresAccentsAndRanksMatches(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+
+	path := "/api/v1/student/"
+	for n, name := range [][2]string{{"José", "Núñez"}, {"Josefina", "Nuñez Ortega"}} {
+		body := fixtureBody("student", ids, 10+n).(models.StudentCreate)
+		body.FirstName, body.LastName = name[0], name[1]
+		result := send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, body: body})
+		ids[name[0]], _ = result.data["id"].(string)
+	}
+
+	find := func(query url.Values) []map[string]any {
+		t.Helper()
+		result := send(t, router, spec, contractCall{method: http.MethodGet, path: "/api/v1/search", target: "/api/v1/search?" + query.Encode()})
+		if result.status != http.StatusOK {
+			t.Fatalf("Search %v answered %d", query, result.status)
+		}
+		found := []map[string]any{}
+		for _, item := range result.list {
+			found = append(found, item.(map[string]any))
+		}
+		return found
+	}
+
+	found := find(url.Values{"q": {"jose nunez"}})
+	if len(found) != 2 || found[0]["id"] != ids["José"] || found[1]["id"] != ids["Josefina"] {
+		t.Errorf("The whole name should rank before the prefix match, got %v", found)
+	}
+	if found := find(url.Values{"q": {"garsia"}}); len(found) != 1 || found[0]["id"] != ids["student"] {
+		t.Errorf("A typo should still find García, got %v", found)
+	}
+	if found := find(url.Values{"q": {"1000000000"}}); len(found) != 1 || found[0]["id"] != ids["student"] {
+		t.Errorf("The document number should find the student, got %v", found)
+	}
+	if found := find(url.Values{"q": {"luis0@university.edu"}}); len(found) != 1 || found[0]["kind"] != search.KIND_COMPANION {
+		t.Errorf("The institution email should find the companion, got %v", found)
+	}
+	if found := find(url.Values{"q": {"BOGOTA"}, "kind": {search.KIND_UNIVERSITY}}); len(found) != 1 || found[0]["id"] != ids["university"] {
+		t.Errorf("The location should find the university, got %v", found)
+	}
+	if found := find(url.Values{"q": {"jose"}, "limit": {"1"}}); len(found) != 1 {
+		t.Errorf("The limit should cap the results, got %v", found)
+	}
+
+	for _, query := range []string{"q=a", "q=ana&kind=session", "q=ana&limit=0"} {
+		result := send(t, router, spec, contractCall{method: http.MethodGet, path: "/api/v1/search", target: "/api/v1/search?" + query})
+		if result.status != http.StatusBadRequest {
+			t.Errorf("Search %s answered %d, expected 400", query, result.status)
+		}
+	}
+}
+
+func TestSearchIndexFollowsWritesAndExpires(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+
+	find := func(query string) int {
+		t.Helper()
+		result := send(t, router, spec, contractCall{method: http.MethodGet, path: "/api/v1/search", target: "/api/v1/search?q=" + query})
+		if result.status != http.StatusOK {
+			t.Fatalf("Search %s answered %d", query, result.status)
+		}
+		return len(result.list)
+	}
+
+	if found := find("mariela"); found != 0 {
+		t.Fatalf("Found %d before the student was created", found)
+	}
+	path := "/api/v1/student/"
+	body := fixtureBody("student", ids, 10).(models.StudentCreate)
+	body.FirstName = "Mariela"
+	send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, body: body})
+	if found := find("mariela"); found != 1 {
+		t.Errorf("Found %d after creating the student, expected the write to refresh the index", found)
+	}
+
+	// Writes of other instances do not go through this one, they show once the index expires
+	outside := models.StudentDBMongo{ID: bson.NewObjectID(), FirstName: "Rosalba", LastName: "Quintero"}
+	if _, err := configs.DB.Memory().InsertOne(outside.TableName(), outside); err != nil {
+		t.Fatal("Could not insert the student:", err)
+	}
+	if found := find("rosalba"); found != 0 {
+		t.Errorf("Found %d before the index expired, expected the cached records", found)
+	}
+	t.Setenv("SEARCH_INDEX_TTL", "1ms")
+	configs.Reload("search test")
+	time.Sleep(5 * time.Millisecond)
+	if found := find("rosalba"); found != 1 {
+		t.Errorf("Found %d once the index expired, expected the new student", found)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"dainxor/atv/db"
+	"dainxor/atv/models"
+	"dainxor/atv/routes"
+	"dainxor/atv/storage"
+	"dainxor/atv/types"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSessionNotesKeepEveryVersion(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec) // Version 1 carries the fixture attachment
+
+	path := "/api/v1/session/:id/notes"
+	target := "/api/v1/session/" + ids["session"] + "/notes"
+	for _, observation := range []string{"First visit", "Second visit"} {
+		body := models.SessionNoteCreate{Observations: observation, AgreedActions: []string{"Read chapter 2"}}
+		if result := send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: target, body: body}); result.status != http.StatusCreated {
+			t.Fatalf("Writing the notes answered %d", result.status)
+		}
+	}
+
+	latest := send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: target})
+	attachments, _ := latest.data["attachments"].([]any)
+	if latest.data["version"] != 3.0 || latest.data["observations"] != "Second visit" || len(attachments) != 1 {
+		t.Errorf("Latest notes should be version 3 keeping the attachment, got %v", latest.data)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, target+"/history", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "First visit") {
+		t.Errorf("History should keep the previous versions, got %d %s", recorder.Code, recorder.Body)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, target+"/attachments/"+ids["file"], nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "Contract test attachment" {
+		t.Errorf("Attachment download answered %d %q", recorder.Code, recorder.Body)
+	}
+}
+
+func TestConcurrentSessionNoteEditsGetTheirOwnVersion(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec) // Version 1 carries the fixture attachment
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4)) // The edits have to overlap on a single CPU too
+	ctx := context.Background()
+	edits := 8
+	results := make([]types.Result[models.SessionNoteDBMongo], edits)
+	start := make(chan struct{})
+	var wait sync.WaitGroup
+	for i := range edits {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			<-start
+			results[i] = db.SessionNote.Append(ctx, ids["session"], models.SessionNoteCreate{Observations: fmt.Sprint("Edit ", i)}, "companion")
+		}()
+	}
+	close(start)
+	wait.Wait()
+
+	written := 1
+	for _, result := range results {
+		switch {
+		case result.IsOk():
+			written++
+		case result.Error().(*types.HttpError).Code != http.StatusConflict:
+			t.Errorf("A concurrent edit failed with %v, expected a 409 at worst", result.Error())
+		}
+	}
+	history := db.SessionNote.GetHistory(ctx, ids["session"]).Value()
+	if len(history) != written {
+		t.Fatalf("Expected %d versions, the history has %d", written, len(history))
+	}
+	for i, note := range history {
+		if note.Version != i+1 {
+			t.Errorf("Version %d of the history is numbered %d, expected one note per version", i+1, note.Version)
+		}
+	}
+}
+
+func TestLocalStorageRejectsKeysOutsideTheRoot(t *testing.T) {
+	store := storage.NewLocal(t.TempDir())
+	for _, key := range []string{"../escape.txt", "/absolute.txt", "a/../../b.txt", ""} {
+		if _, err := store.Put(context.Background(), key, strings.NewReader("x")); !errors.Is(err, storage.ErrInvalidKey) {
+			t.Errorf("Key %q should be rejected, got %v", key, err)
+		}
+	}
+}
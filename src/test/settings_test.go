@@ -0,0 +1,201 @@
+package main
+
+import (
+	"dainxor/atv/configs/settings"
+	"maps"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// useSettingsFiles runs the test on a directory holding only the config.yaml and .env given, empty ones are not written
+func useSettingsFiles(t *testing.T, yaml string, dotenv string) {
+	t.Chdir(t.TempDir())
+	for name, content := range map[string]string{settings.DEFAULT_CONFIG_FILE: yaml, settings.DEFAULT_ENV_FILE: dotenv} {
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal("Could not write", name, err)
+		}
+	}
+}
+
+func fieldOf(loaded *settings.Loaded, env string) (settings.Field, bool) {
+	for _, field := range loaded.Fields {
+		if field.Env == env {
+			return field, true
+		}
+	}
+	return settings.Field{}, false
+}
+
+func problemEnvs(loaded *settings.Loaded) []string {
+	envs := []string{}
+	for _, problem := range loaded.Problems {
+		envs = append(envs, problem.Env)
+	}
+	slices.Sort(envs)
+	return envs
+}
+
+func TestSettingsSourcePrecedence(t *testing.T) {
+	useSettingsFiles(t, `
+db:
+  type: MEMORY
+  timeout: 3s
+  connect_retries: 2
+log:
+  min_level: INFO
+jobs:
+  workers: 7
+rate_limit:
+  groups:
+    student: 30/m
+    session: 1/s
+`, "DB_TIMEOUT=4s\nJOBS_WORKERS=8\nRATE_LIMIT_SESSION=2/s\n")
+	t.Setenv("DB_TIMEOUT", "5s")
+	t.Setenv("RATE_LIMIT_SESSION", "3/s")
+
+	loaded := settings.Load()
+	if problems := problemEnvs(loaded); len(problems) != 0 {
+		t.Fatal("Loading found problems with", problems)
+	}
+
+	config := loaded.Config
+	if config.DB.Timeout != 5*time.Second || config.Jobs.Workers != 8 || config.DB.ConnectRetries != 2 || config.Log.MinLevel != "INFO" || config.Server.Address != ":8080" {
+		t.Errorf("Loaded timeout %v, workers %d, retries %d, level %q, address %q, expected 5s, 8, 2, INFO and :8080",
+			config.DB.Timeout, config.Jobs.Workers, config.DB.ConnectRetries, config.Log.MinLevel, config.Server.Address)
+	}
+	if groups := map[string]string{"student": "30/m", "session": "3/s"}; !maps.Equal(config.RateLimit.Groups, groups) {
+		t.Errorf("Loaded the rate limit groups %v, expected %v", config.RateLimit.Groups, groups)
+	}
+
+	for env, source := range map[string]string{
+		"DB_TIMEOUT":              settings.SOURCE_ENV,
+		"RATE_LIMIT_SESSION":      settings.SOURCE_ENV,
+		"JOBS_WORKERS":            settings.SOURCE_DOTENV,
+		"DB_CONNECT_RETRIES":      settings.SOURCE_FILE,
+		"DNX_LOG_MIN_LEVEL":       settings.SOURCE_FILE,
+		"RATE_LIMIT_STUDENT":      settings.SOURCE_FILE,
+		"SERVER_ADDRESS":          settings.SOURCE_DEFAULT,
+		"STORAGE_URL_EXPIRY":      settings.SOURCE_DEFAULT,
+		"NOTIFY_SMTP_HOST":        settings.SOURCE_DEFAULT,
+		"TENANCY_HEADER":          settings.SOURCE_DEFAULT,
+		"ATV_ROUTE_VERSION":       settings.SOURCE_DEFAULT,
+		"JOBS_MAX_BACKOFF":        settings.SOURCE_DEFAULT,
+		"JOBS_RETENTION":          settings.SOURCE_DEFAULT,
+		"WEBHOOK_TIMEOUT":         settings.SOURCE_DEFAULT,
+		"CALENDAR_SESSION_LENGTH": settings.SOURCE_DEFAULT,
+		"SEARCH_INDEX_TTL":        settings.SOURCE_DEFAULT,
+	} {
+		field, exist := fieldOf(loaded, env)
+		if !exist || field.Source != source || loaded.IsSet(env) != (source != settings.SOURCE_DEFAULT) {
+			t.Errorf("%s came from %q, set %v, expected %q", env, field.Source, loaded.IsSet(env), source)
+		}
+	}
+}
+
+func TestSettingsDefaults(t *testing.T) {
+	useSettingsFiles(t, "", "")
+
+	config := settings.Load().Config
+	if config.DB.Type != "SQLITE" || config.DB.Timeout != 10*time.Second || config.App.RoutesVersion != 1 || config.Tenancy.Enabled || config.Tenancy.Header != "X-ATV-Tenant" {
+		t.Errorf("Defaults loaded as db %q timeout %v, routes version %d, tenancy %v on %q",
+			config.DB.Type, config.DB.Timeout, config.App.RoutesVersion, config.Tenancy.Enabled, config.Tenancy.Header)
+	}
+	if !config.RateLimit.Enabled || config.RateLimit.Default != "120/m" || config.Storage.MaxSize != 10485760 || config.Notify.ReminderBefore != 24*time.Hour {
+		t.Errorf("Defaults loaded as rate limit %v %q, max size %d, reminder %v",
+			config.RateLimit.Enabled, config.RateLimit.Default, config.Storage.MaxSize, config.Notify.ReminderBefore)
+	}
+}
+
+func TestSettingsInvalidValuesFallBackToDefaults(t *testing.T) {
+	for _, check := range []struct {
+		env      string
+		value    string
+		expected string // Effective value, the default unless the setting is a map entry, which is dropped
+	}{
+		{"DB_TYPE", "ORACLE", "SQLITE"},
+		{"DB_TESTING", "yes", "false"},
+		{"DB_TIMEOUT", "soon", "10s"},
+		{"DB_TIMEOUT", "-1s", "10s"},
+		{"DB_CONNECT_RETRIES", "-1", "5"},
+		{"JOBS_WORKERS", "four", "4"},
+		{"ATV_API_VERSION", "1.2", "0.1.4"},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4318", "http://localhost:4318"},
+		{"AUTH_JWT_SECRET", "short", ""},
+		{"DNX_LOG_MIN_LEVEL", "LOUD", ""},
+		{"DNX_LOG_DISABLE_LEVELS", "DEBUG|LOUD", ""},
+		{"CORS_ALLOW_ORIGINS", "https://atv.example.com/app", ""},
+		{"SERVER_TRUSTED_PROXIES", "10.0.0.0/8,proxy.local", ""},
+		{"RATE_LIMIT_DEFAULT", "lots", "120/m"},
+		{"RATE_LIMIT_STUDENT", "fast", ""},
+		{"FEATURE_NEW_SEARCH", "maybe", ""},
+	} {
+		t.Run(check.env+"="+check.value, func(t *testing.T) {
+			useSettingsFiles(t, "", "")
+			t.Setenv(check.env, check.value)
+
+			loaded := settings.Load()
+			if problems := problemEnvs(loaded); !slices.Equal(problems, []string{check.env}) {
+				t.Errorf("Loading found problems with %v, expected only %s", problems, check.env)
+			}
+			if loaded.IsSet(check.env) {
+				t.Errorf("%s is set, expected the invalid value to be discarded", check.env)
+			}
+
+			field, exist := fieldOf(loaded, check.env)
+			if exist && (field.Source != settings.SOURCE_DEFAULT || field.Value != check.expected) {
+				t.Errorf("%s is %q from %q, expected the default %q", check.env, field.Value, field.Source, check.expected)
+			}
+			if !exist && (len(loaded.Config.RateLimit.Groups) != 0 || len(loaded.Config.Features) != 0) {
+				t.Errorf("Map entry %s was kept, groups %v features %v", check.env, loaded.Config.RateLimit.Groups, loaded.Config.Features)
+			}
+		})
+	}
+}
+
+func TestSettingsCrossFieldProblems(t *testing.T) {
+	for _, check := range []struct {
+		name     string
+		env      map[string]string
+		problems []string
+	}{
+		{"valid", map[string]string{}, []string{}},
+		{"mongo", map[string]string{"DB_TYPE": "MONGO", "CONECTION_STRING": "", "DB_NAME": ""}, []string{"CONECTION_STRING", "DB_NAME"}},
+		{"mongo testing", map[string]string{"DB_TYPE": "MONGO", "DB_TESTING": "true", "CONECTION_STRING": "mongodb://db", "DB_NAME": "atv"}, []string{"CONECTION_STRING_TEST", "DB_NAME_TEST"}},
+		{"postgres", map[string]string{"DB_TYPE": "POSTGRES", "CONECTION_STRING": ""}, []string{"CONECTION_STRING"}},
+		{"s3", map[string]string{"STORAGE_BACKEND": "s3"}, []string{"STORAGE_S3_BUCKET", "STORAGE_S3_ENDPOINT"}},
+		{"smtp", map[string]string{"NOTIFY_SENDER": "smtp"}, []string{"NOTIFY_SMTP_HOST"}},
+		{"change stream", map[string]string{"STREAM_SOURCE": "change_stream"}, []string{"STREAM_SOURCE"}},
+		{"tenancy", map[string]string{"TENANCY_ENABLED": "true", "TENANCY_HEADER": ""}, []string{"TENANCY_HEADER"}},
+		{"job backoff", map[string]string{"JOBS_BACKOFF": "2h"}, []string{"JOBS_MAX_BACKOFF"}},
+		{"cors", map[string]string{"CORS_ALLOW_CREDENTIALS": "true", "CORS_ALLOW_ORIGINS": "*"}, []string{"CORS_ALLOW_CREDENTIALS"}},
+	} {
+		t.Run(check.name, func(t *testing.T) {
+			useSettingsFiles(t, "", "DB_TYPE=MEMORY\n")
+			for env, value := range check.env {
+				t.Setenv(env, value)
+			}
+
+			if problems := problemEnvs(settings.Load()); !slices.Equal(problems, check.problems) {
+				t.Errorf("Loading found problems with %v, expected %v", problems, check.problems)
+			}
+		})
+	}
+}
+
+func TestSettingsRedactSecrets(t *testing.T) {
+	useSettingsFiles(t, "", "")
+	t.Setenv("AUTH_JWT_SECRET", "a-secret-long-enough")
+
+	loaded := settings.Load()
+	if loaded.Config.Auth.JWTSecret != "a-secret-long-enough" || loaded.Redacted().Auth.JWTSecret != settings.REDACTED {
+		t.Errorf("Secret loaded as %q and redacted as %q", loaded.Config.Auth.JWTSecret, loaded.Redacted().Auth.JWTSecret)
+	}
+	if field, _ := fieldOf(loaded, "AUTH_JWT_SECRET"); field.Value != settings.REDACTED || !field.Secret {
+		t.Errorf("Secret field shows %q, secret %v", field.Value, field.Secret)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"dainxor/atv/models"
+	"dainxor/atv/routes"
+	"dainxor/atv/stream"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// nextEvent reads the stream up to the next event, returning its name and data
+func nextEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("The stream ended before the event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		case line == "" && name != "":
+			return name, data
+		}
+	}
+}
+
+func TestSessionStreamPushesFilteredChanges(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	other := stream.Subscribe(stream.Filter{IDCompanion: bson.NewObjectID()})
+	defer other.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/session/stream?id_companion="+ids["companion"], nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal("Could not open the stream:", err)
+	}
+	defer response.Body.Close()
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("Expected an event stream, got %s", response.Header.Get("Content-Type"))
+	}
+
+	path := "/api/v1/session/"
+	result := send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, body: fixtureBody("session", ids, 1)})
+	session, _ := result.data["id"].(string)
+	target := "/api/v1/session/" + session
+	send(t, router, spec, contractCall{method: http.MethodPatch, path: "/api/v1/session/:id", target: target, body: models.SessionCreate{Status: models.STATUS[models.STATUS_CANCELLED]}})
+	send(t, router, spec, contractCall{method: http.MethodDelete, path: "/api/v1/session/:id", target: target})
+
+	reader := bufio.NewReader(response.Body)
+	for _, expected := range []string{models.SESSION_CREATED, models.SESSION_CANCELLED, models.SESSION_DELETED} {
+		name, data := nextEvent(t, reader)
+		if name != expected || !strings.Contains(data, session) {
+			t.Fatalf("Expected %s of session %s, got %s %s", expected, session, name, data)
+		}
+	}
+
+	if len(other.Events) != 0 {
+		t.Errorf("The sessions of another companion should not be streamed, got %d events", len(other.Events))
+	}
+}
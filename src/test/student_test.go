@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"dainxor/atv/configs"
 	"dainxor/atv/db"
 	"dainxor/atv/logger"
 	"dainxor/atv/models"
@@ -27,6 +29,13 @@ func init() {
 }
 
 func TestStudentOperations(t *testing.T) {
+	if configs.DB.Type() != configs.DB.Types().MongoDB() {
+		t.Skip("Student operations need a MongoDB database, DB_TYPE is", configs.DB.Type())
+	}
+	if err := configs.DB.Ping(context.Background()); err != nil {
+		t.Skip("Database unavailable:", err)
+	}
+
 	createObj := models.StudentCreate{
 		NumberID:         "123456789",
 		FirstName:        "John",
@@ -39,14 +48,14 @@ func TestStudentOperations(t *testing.T) {
 		PhoneNumber:      "123-456-7890",
 	}
 
-	resultObj := db.Student.Create(createObj)
+	resultObj := db.Student.Create(context.Background(), createObj)
 
 	if resultObj.IsErr() {
 		t.Errorf("Failed to create student: %v", resultObj.Error())
 		return
 	}
 
-	getResult := db.Student.GetByID(resultObj.Value().ID.Hex())
+	getResult := db.Student.GetByID(context.Background(), resultObj.Value().ID.Hex())
 
 	patchObg := models.StudentCreate{
 		NumberID:    "1234567890",
@@ -55,7 +64,7 @@ func TestStudentOperations(t *testing.T) {
 		PhoneNumber: "1234567891",
 	}
 
-	patchResult := db.Student.PatchByID(getResult.Value().ID.Hex(), patchObg)
+	patchResult := db.Student.PatchByID(context.Background(), getResult.Value().ID.Hex(), patchObg)
 	if patchResult.IsErr() {
 		t.Errorf("Failed to patch student: %v", patchResult.Error())
 		return
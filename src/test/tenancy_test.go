@@ -0,0 +1,189 @@
+package main
+
+import (
+	"dainxor/atv/auth"
+	"dainxor/atv/configs"
+	"dainxor/atv/models"
+	"dainxor/atv/openapi"
+	"dainxor/atv/routes"
+	"dainxor/atv/tenant"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTenancy turns tenant scoping on for the test
+func useTenancy(t *testing.T) {
+	t.Cleanup(func() { configs.Reload("tenancy test finished") })
+	t.Setenv("TENANCY_ENABLED", "true")
+	configs.Reload("tenancy test")
+}
+
+// superAdminToken signs a token acting across tenants, or for the one of its header
+func superAdminToken(t *testing.T) string {
+	return signToken(t, auth.Claims{Subject: "root", Role: tenant.SUPER_ADMIN_ROLE, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+}
+
+// createTenantFixtures creates a student, a companion and a session kept to the tenant
+func createTenantFixtures(t *testing.T, router *gin.Engine, spec openapi.Document, university string, n int) map[string]string {
+	ids := map[string]string{"university": university}
+	token := superAdminToken(t)
+	for _, resource := range []string{"speciality", "session-type", "student", "companion", "session"} {
+		path := "/api/v1/" + resource + "/"
+		result := send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, body: fixtureBody(resource, ids, n), token: token, tenant: university})
+		ids[resource], _ = result.data["id"].(string)
+		if result.status != http.StatusCreated || ids[resource] == "" {
+			t.Fatalf("Could not create the %s of tenant %s, status %d data %v", resource, university, result.status, result.data)
+		}
+	}
+	return ids
+}
+
+// listIDs returns the ids listed by the route
+func listIDs(t *testing.T, router *gin.Engine, spec openapi.Document, path string, call contractCall) (int, []string) {
+	t.Helper()
+	call.method, call.path, call.target = http.MethodGet, path, path
+	result := send(t, router, spec, call)
+
+	ids := []string{}
+	for _, item := range result.list {
+		if item, ok := item.(map[string]any); ok {
+			id, _ := item["id"].(string)
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return result.status, ids
+}
+
+func TestTenantsOnlySeeTheirOwnRecords(t *testing.T) {
+	useMemoryDB(t)
+	useTenancy(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+
+	first := createFixture(t, router, spec, "university", nil, 1)
+	second := createFixture(t, router, spec, "university", nil, 2)
+	a := createTenantFixtures(t, router, spec, first, 1)
+	b := createTenantFixtures(t, router, spec, second, 2)
+	token := superAdminToken(t)
+
+	for _, resource := range []string{"student", "companion", "session", "speciality", "session-type"} {
+		status, ids := listIDs(t, router, spec, "/api/v1/"+resource+"/all", contractCall{token: token, tenant: first})
+		if status != http.StatusOK || !slices.Equal(ids, []string{a[resource]}) {
+			t.Errorf("The first tenant listed the %ss %v with status %d, expected only %s", resource, ids, status, a[resource])
+		}
+
+		path := "/api/v1/" + resource + "/:id"
+		result := send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: "/api/v1/" + resource + "/" + b[resource], token: token, tenant: first})
+		if result.status != http.StatusNotFound {
+			t.Errorf("The first tenant read the %s of the second with status %d, expected 404", resource, result.status)
+		}
+	}
+
+	path := "/api/v1/student/:id"
+	result := send(t, router, spec, contractCall{method: http.MethodDelete, path: path, target: "/api/v1/student/" + b["student"], token: token, tenant: first})
+	if result.status < http.StatusBadRequest {
+		t.Errorf("The first tenant deleted the student of the second with status %d, expected an error", result.status)
+	}
+	if status, ids := listIDs(t, router, spec, "/api/v1/student/all", contractCall{token: token, tenant: second}); status != http.StatusOK || !slices.Equal(ids, []string{b["student"]}) {
+		t.Errorf("The second tenant listed the students %v with status %d, expected %s to be kept", ids, status, b["student"])
+	}
+}
+
+func TestTenantIsResolvedFromTheTokenOrTheHeader(t *testing.T) {
+	useMemoryDB(t)
+	useTenancy(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+
+	first := createFixture(t, router, spec, "university", nil, 1)
+	second := createFixture(t, router, spec, "university", nil, 2)
+	a := createTenantFixtures(t, router, spec, first, 1)
+	b := createTenantFixtures(t, router, spec, second, 2)
+	expires := time.Now().Add(time.Hour).Unix()
+	coordinator := signToken(t, auth.Claims{Subject: "coordinator", Tenant: first, ExpiresAt: expires})
+	untenanted := signToken(t, auth.Claims{Subject: "ana", ExpiresAt: expires})
+	superAdmin := superAdminToken(t)
+
+	both := []string{a["student"], b["student"]}
+	slices.Sort(both)
+	for _, check := range []struct {
+		name   string
+		call   contractCall
+		status int
+		ids    []string
+	}{
+		{"no tenant", contractCall{}, http.StatusBadRequest, []string{}},
+		{"unknown tenant", contractCall{token: superAdmin, tenant: models.DBID{1}.Hex()}, http.StatusForbidden, []string{}},
+		{"invalid tenant", contractCall{token: superAdmin, tenant: "not-an-id"}, http.StatusForbidden, []string{}},
+		{"anonymous header", contractCall{tenant: second}, http.StatusUnauthorized, []string{}},
+		{"token without a tenant and header", contractCall{token: untenanted, tenant: second}, http.StatusForbidden, []string{}},
+		{"token tenant", contractCall{token: coordinator}, http.StatusOK, []string{a["student"]}},
+		{"token and same header", contractCall{token: coordinator, tenant: first}, http.StatusOK, []string{a["student"]}},
+		{"token and other header", contractCall{token: coordinator, tenant: second}, http.StatusForbidden, []string{}},
+		{"super-admin", contractCall{token: superAdmin}, http.StatusOK, both},
+		{"super-admin and header", contractCall{token: superAdmin, tenant: second}, http.StatusOK, []string{b["student"]}},
+	} {
+		status, ids := listIDs(t, router, spec, "/api/v1/student/all", check.call)
+		if status != check.status || !slices.Equal(ids, check.ids) {
+			t.Errorf("With %s the students listed were %v with status %d, expected %v with %d", check.name, ids, status, check.ids, check.status)
+		}
+	}
+
+	// The universities are the tenants, anyone lists them to pick one
+	if status, _ := listIDs(t, router, spec, "/api/v1/university/all", contractCall{}); status != http.StatusOK {
+		t.Errorf("The universities were listed with status %d without a tenant, expected 200", status)
+	}
+
+	// Behind a gateway setting the header, it is taken from anyone
+	t.Setenv("TENANCY_TRUST_HEADER", "true")
+	configs.Reload("trusted header test")
+	if status, ids := listIDs(t, router, spec, "/api/v1/student/all", contractCall{tenant: second}); status != http.StatusOK || !slices.Equal(ids, []string{b["student"]}) {
+		t.Errorf("With a trusted header the students listed were %v with status %d, expected %s", ids, status, b["student"])
+	}
+}
+
+func TestAdminRoutesKeepToTheTenant(t *testing.T) {
+	useMemoryDB(t)
+	useTenancy(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+
+	first := createFixture(t, router, spec, "university", nil, 1)
+	expires := time.Now().Add(time.Hour).Unix()
+	admin := signToken(t, auth.Claims{Subject: "coordinator", Role: routes.ADMIN_ROLE, Tenant: first, ExpiresAt: expires})
+	untenanted := signToken(t, auth.Claims{Subject: "admin", Role: routes.ADMIN_ROLE, ExpiresAt: expires})
+	superAdmin := signToken(t, auth.Claims{Subject: "root", Role: tenant.SUPER_ADMIN_ROLE, ExpiresAt: expires})
+
+	for _, check := range []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"tenant admin", "/admin/webhooks", admin, http.StatusOK},
+		{"tenant admin", "/admin/config", admin, http.StatusForbidden},
+		{"tenant admin", "/admin/jobs", admin, http.StatusForbidden},
+		{"admin without a tenant", "/admin/webhooks", untenanted, http.StatusBadRequest},
+		{"super-admin", "/admin/config", superAdmin, http.StatusOK},
+		{"super-admin", "/admin/jobs", superAdmin, http.StatusOK},
+	} {
+		if result := send(t, router, spec, contractCall{method: http.MethodGet, path: check.path, target: check.path, token: check.token}); result.status != check.status {
+			t.Errorf("GET %s as %s answered %d, expected %d", check.path, check.name, result.status, check.status)
+		}
+	}
+}
+
+func TestIndexesAreTenantPrefixed(t *testing.T) {
+	for _, index := range models.INDEXES {
+		keys := configs.IndexKeys(index)
+		_, shared := index.Model.(models.SharedModel)
+		if prefixed := keys[0] == tenant.FIELD; prefixed == shared {
+			t.Errorf("Index %v of %s has the keys %v, tenant prefix expected %v", index.Keys, index.Model.TableName(), keys, !shared)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"dainxor/atv/tracing"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	remoteTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	remoteSpan  = "00f067aa0ba902b7"
+)
+
+func TestTraceparentContinuesTheCallerTrace(t *testing.T) {
+	ctx := tracing.Extract(context.Background(), " 00-"+remoteTrace+"-"+remoteSpan+"-01 ")
+	if id := tracing.TraceIDFrom(ctx); id != remoteTrace {
+		t.Errorf("Trace ID of the extracted context is %q, expected %s", id, remoteTrace)
+	}
+
+	ctx, span := tracing.StartKind(ctx, "request", tracing.KIND_SERVER)
+	if span.TraceID().String() != remoteTrace || span.ParentSpanID().String() != remoteSpan {
+		t.Errorf("Span continued trace %s parent %s, expected %s %s", span.TraceID(), span.ParentSpanID(), remoteTrace, remoteSpan)
+	}
+
+	_, child := tracing.Start(ctx, "db")
+	if child.TraceID() != span.TraceID() || child.ParentSpanID() != span.SpanID() {
+		t.Errorf("Child span has trace %s parent %s, expected %s %s", child.TraceID(), child.ParentSpanID(), span.TraceID(), span.SpanID())
+	}
+
+	expected := "00-" + remoteTrace + "-" + span.SpanID().String() + "-01"
+	if header := tracing.Traceparent(span); header != expected {
+		t.Errorf("Traceparent is %q, expected %q", header, expected)
+	}
+	if header := tracing.Traceparent(nil); header != "" {
+		t.Errorf("Traceparent of a nil span is %q, expected none", header)
+	}
+}
+
+func TestTraceparentIgnoresInvalidValues(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"00-" + remoteTrace + "-" + remoteSpan, // Missing flags
+		"ff-" + remoteTrace + "-" + remoteSpan + "-01",     // Forbidden version
+		"0-" + remoteTrace + "-" + remoteSpan + "-01",      // Short version
+		"00-" + remoteTrace[2:] + "-" + remoteSpan + "-01", // Short trace ID
+		"00-" + remoteTrace + "-" + remoteSpan + "zz-01",   // Not hex
+		"00-00000000000000000000000000000000-" + remoteSpan + "-01",
+		"00-" + remoteTrace + "-0000000000000000-01",
+	} {
+		ctx := tracing.Extract(context.Background(), value)
+		if id := tracing.TraceIDFrom(ctx); id != "" {
+			t.Errorf("Traceparent %q was accepted with trace %s", value, id)
+		}
+		if _, span := tracing.Start(ctx, "request"); span.TraceID().String() == remoteTrace || span.ParentSpanID().IsValid() {
+			t.Errorf("Traceparent %q became the parent of a new span", value)
+		}
+	}
+}
+
+// otlpPayload is the part of an OTLP/JSON export request the test reads
+type otlpPayload struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID           string          `json:"traceId"`
+				SpanID            string          `json:"spanId"`
+				ParentSpanID      string          `json:"parentSpanId"`
+				Name              string          `json:"name"`
+				Kind              int             `json:"kind"`
+				StartTimeUnixNano string          `json:"startTimeUnixNano"`
+				EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+				Attributes        []otlpAttribute `json:"attributes"`
+				Events            []struct {
+					Name string `json:"name"`
+				} `json:"events"`
+				Status struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func attributeValue(attributes []otlpAttribute, key string) map[string]any {
+	for _, attribute := range attributes {
+		if attribute.Key == key {
+			return attribute.Value
+		}
+	}
+	return nil
+}
+
+func TestOTLPExporterPayload(t *testing.T) {
+	received := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Collector got %s %s %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer collector.Close()
+
+	start := time.Unix(1700000000, 5)
+	var trace tracing.TraceID
+	var parent, id tracing.SpanID
+	copy(trace[:], []byte("0123456789abcdef"))
+	copy(parent[:], []byte("parent!!"))
+	copy(id[:], []byte("span-id!"))
+
+	exporter := tracing.NewOTLPExporter("atv-test", collector.URL+"/v1/traces")
+	err := exporter.Export(context.Background(), []tracing.SpanData{{
+		TraceID:       trace,
+		SpanID:        id,
+		ParentSpanID:  parent,
+		Name:          "GET /api/v1/student/:id",
+		Kind:          tracing.KIND_SERVER,
+		Start:         start,
+		End:           start.Add(time.Second),
+		Attributes:    map[string]any{"http.method": "GET", "http.status_code": 500, "retried": true, "ratio": 0.5},
+		Events:        []tracing.SpanEvent{{Name: "exception", Time: start}},
+		StatusCode:    tracing.STATUS_ERROR,
+		StatusMessage: "boom",
+	}})
+	if err != nil {
+		t.Fatal("Export failed:", err)
+	}
+
+	var payload otlpPayload
+	if err := json.Unmarshal(<-received, &payload); err != nil {
+		t.Fatal("Payload is not JSON:", err)
+	}
+	if len(payload.ResourceSpans) != 1 || len(payload.ResourceSpans[0].ScopeSpans) != 1 || len(payload.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("Payload does not hold a single span: %+v", payload)
+	}
+	if service := attributeValue(payload.ResourceSpans[0].Resource.Attributes, "service.name"); service["stringValue"] != "atv-test" {
+		t.Errorf("Resource service.name is %v, expected atv-test", service)
+	}
+
+	span := payload.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if span.TraceID != trace.String() || span.SpanID != id.String() || span.ParentSpanID != parent.String() {
+		t.Errorf("Span IDs are %s %s %s, expected %s %s %s", span.TraceID, span.SpanID, span.ParentSpanID, trace, id, parent)
+	}
+	if span.Name != "GET /api/v1/student/:id" || span.Kind != int(tracing.KIND_SERVER) {
+		t.Errorf("Span is %q kind %d", span.Name, span.Kind)
+	}
+	if span.StartTimeUnixNano != "1700000000000000005" || span.EndTimeUnixNano != "1700000001000000005" {
+		t.Errorf("Span times are %s to %s", span.StartTimeUnixNano, span.EndTimeUnixNano)
+	}
+	if span.Status.Code != int(tracing.STATUS_ERROR) || span.Status.Message != "boom" || len(span.Events) != 1 || span.Events[0].Name != "exception" {
+		t.Errorf("Span status %+v events %+v", span.Status, span.Events)
+	}
+	for key, expected := range map[string]map[string]any{
+		"http.method":      {"stringValue": "GET"},
+		"http.status_code": {"intValue": "500"}, // OTLP/JSON sends 64 bit integers as strings
+		"retried":          {"boolValue": true},
+		"ratio":            {"doubleValue": 0.5},
+	} {
+		value := attributeValue(span.Attributes, key)
+		for kind, want := range expected {
+			if len(value) != 1 || value[kind] != want {
+				t.Errorf("Attribute %s is %v, expected %s %v", key, value, kind, want)
+			}
+		}
+	}
+}
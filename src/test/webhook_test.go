@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"dainxor/atv/auth"
+	"dainxor/atv/configs"
+	"dainxor/atv/jobs"
+	"dainxor/atv/models"
+	"dainxor/atv/routes"
+	"dainxor/atv/webhooks"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// adminToken signs a token with the admin role under a test secret
+func adminToken(t *testing.T) string {
+	return signToken(t, auth.Claims{Subject: "admin", Role: routes.ADMIN_ROLE, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+}
+
+// signToken signs the claims under a test secret
+func signToken(t *testing.T, claims auth.Claims) string {
+	t.Cleanup(func() {
+		configs.Reload("test token finished")
+		auth.ReloadEnv()
+	})
+	t.Setenv("AUTH_JWT_SECRET", "contract-test-secret-0123456789")
+	configs.Reload("test token")
+	auth.ReloadEnv()
+
+	token, err := auth.Sign(claims)
+	if err != nil {
+		t.Fatal("Could not sign the", claims.Role, "token:", err)
+	}
+	return token
+}
+
+// useLocalSubscribers lets deliveries go to the test servers on loopback
+func useLocalSubscribers(t *testing.T) {
+	t.Cleanup(func() { configs.Reload("local subscribers finished") })
+	t.Setenv("WEBHOOK_ALLOW_NETWORKS", "127.0.0.1")
+	configs.Reload("local subscribers")
+}
+
+// subscriber keeps the deliveries it gets, failing the first ones
+type subscriber struct {
+	mutex    sync.Mutex
+	failures int
+	received []*http.Request
+	bodies   []string
+}
+
+func (s *subscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	s.received = append(s.received, r)
+	s.bodies = append(s.bodies, string(body))
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWebhooksDeliverSignedSessionEvents(t *testing.T) {
+	useMemoryDB(t)
+	useLocalSubscribers(t)
+	token := adminToken(t)
+	receiver := &subscriber{failures: 1}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec)
+
+	result := send(t, router, spec, contractCall{method: http.MethodPost, path: "/admin/webhooks", target: "/admin/webhooks", token: token, body: models.WebhookCreate{
+		URL:    server.URL,
+		Events: []string{models.SESSION_CREATED, models.SESSION_CANCELLED},
+	}})
+	secret, _ := result.data["secret"].(string)
+	webhook, _ := result.data["id"].(string)
+	if result.status != http.StatusCreated || secret == "" {
+		t.Fatalf("Could not subscribe, got %d %v", result.status, result.data)
+	}
+
+	path := "/api/v1/session/"
+	result = send(t, router, spec, contractCall{method: http.MethodPost, path: path, target: path, body: fixtureBody("session", ids, 1)})
+	session, _ := result.data["id"].(string)
+	if result.status != http.StatusCreated {
+		t.Fatalf("Could not book the session, got %d %v", result.status, result.data)
+	}
+
+	// The first attempt fails, the retry after the backoff gets through
+	ctx := context.Background()
+	jobs.RunDue(ctx, time.Now())
+	jobs.RunDue(ctx, time.Now().Add(jobs.Backoff(1)+time.Second))
+	if len(receiver.received) != 2 || receiver.bodies[0] != receiver.bodies[1] {
+		t.Fatalf("Expected the same delivery twice, got %d", len(receiver.received))
+	}
+	request := receiver.received[1]
+	signature := webhooks.Sign(secret, request.Header.Get(webhooks.HEADER_TIMESTAMP), []byte(receiver.bodies[1]))
+	if request.Header.Get(webhooks.HEADER_EVENT) != models.SESSION_CREATED || request.Header.Get(webhooks.HEADER_SIGNATURE) != signature {
+		t.Errorf("The delivery should be a signed %s, got headers %v", models.SESSION_CREATED, request.Header)
+	}
+
+	deliveries := "/admin/webhooks/:id/deliveries"
+	result = send(t, router, spec, contractCall{method: http.MethodGet, path: deliveries, target: "/admin/webhooks/" + webhook + "/deliveries", token: token})
+	if result.status != http.StatusOK || len(result.list) != 1 {
+		t.Fatalf("Expected one delivery on the log, got %d %v", result.status, result.list)
+	}
+	delivery, _ := result.list[0].(map[string]any)
+	if delivery["status"] != models.DELIVERY_DELIVERED || delivery["attempts"] != float64(2) {
+		t.Errorf("The delivery should be delivered on its second attempt, got %v", delivery)
+	}
+
+	// Updates without a status change are not subscribed, a cancellation is
+	patch := "/api/v1/session/:id"
+	send(t, router, spec, contractCall{method: http.MethodPatch, path: patch, target: "/api/v1/session/" + session, body: models.SessionCreate{SessionNotes: "Moved room"}})
+	send(t, router, spec, contractCall{method: http.MethodPatch, path: patch, target: "/api/v1/session/" + session, body: models.SessionCreate{Status: models.STATUS[models.STATUS_CANCELLED]}})
+	jobs.RunDue(ctx, time.Now())
+	if len(receiver.received) != 3 || receiver.received[2].Header.Get(webhooks.HEADER_EVENT) != models.SESSION_CANCELLED {
+		t.Fatalf("Only the cancellation should be delivered, got %d deliveries", len(receiver.received))
+	}
+
+	replay := "/admin/webhooks/deliveries/:id/replay"
+	result = send(t, router, spec, contractCall{method: http.MethodPost, path: replay, target: "/admin/webhooks/deliveries/" + delivery["id"].(string) + "/replay", token: token})
+	if result.status != http.StatusCreated || result.data["replay_of"] != delivery["id"] {
+		t.Fatalf("Could not replay the delivery, got %d %v", result.status, result.data)
+	}
+	jobs.RunDue(ctx, time.Now())
+	if len(receiver.received) != 4 || receiver.bodies[3] != receiver.bodies[0] {
+		t.Errorf("The replay should send the same payload again, got %d deliveries", len(receiver.received))
+	}
+}
+
+func TestWebhooksOnlyHearTheirTenant(t *testing.T) {
+	useMemoryDB(t)
+	useTenancy(t)
+	useLocalSubscribers(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+
+	expires := time.Now().Add(time.Hour).Unix()
+	universities, receivers, tokens, subscriptions := [2]string{}, [2]*subscriber{}, [2]string{}, [2]string{}
+	for i := range 2 {
+		universities[i] = createFixture(t, router, spec, "university", nil, i+1)
+		receivers[i] = &subscriber{}
+		server := httptest.NewServer(receivers[i])
+		defer server.Close()
+		tokens[i] = signToken(t, auth.Claims{Subject: "coordinator", Role: routes.ADMIN_ROLE, Tenant: universities[i], ExpiresAt: expires})
+
+		result := send(t, router, spec, contractCall{method: http.MethodPost, path: "/admin/webhooks", target: "/admin/webhooks", token: tokens[i], body: models.WebhookCreate{
+			URL:    server.URL,
+			Events: []string{models.SESSION_CREATED},
+		}})
+		subscriptions[i], _ = result.data["id"].(string)
+		if result.status != http.StatusCreated {
+			t.Fatalf("Could not subscribe for tenant %d, got %d %v", i+1, result.status, result.data)
+		}
+	}
+
+	if status, ids := listIDs(t, router, spec, "/admin/webhooks", contractCall{token: tokens[0]}); status != http.StatusOK || len(ids) != 1 || ids[0] != subscriptions[0] {
+		t.Errorf("The first tenant listed the webhooks %v with status %d, expected only %s", ids, status, subscriptions[0])
+	}
+	path := "/admin/webhooks/:id"
+	if result := send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: "/admin/webhooks/" + subscriptions[1], token: tokens[0]}); result.status != http.StatusNotFound {
+		t.Errorf("The first tenant read the webhook of the second with status %d, expected 404", result.status)
+	}
+
+	createTenantFixtures(t, router, spec, universities[0], 1)
+	jobs.RunDue(context.Background(), time.Now())
+	if len(receivers[0].received) != 1 || len(receivers[1].received) != 0 {
+		t.Errorf("A session of the first tenant was delivered %d and %d times, expected only to the first tenant's webhook",
+			len(receivers[0].received), len(receivers[1].received))
+	}
+}
+
+func TestWebhooksOnlyGoToPublicAddresses(t *testing.T) {
+	useMemoryDB(t)
+	token := adminToken(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	subscribe := func(url string) contractResult {
+		return send(t, router, spec, contractCall{method: http.MethodPost, path: "/admin/webhooks", target: "/admin/webhooks", token: token, body: models.WebhookCreate{
+			URL:    url,
+			Events: []string{models.SESSION_CREATED},
+		}})
+	}
+
+	for _, url := range []string{"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://169.254.169.254/latest/meta-data", "http://10.0.0.5/hook", "http://[::1]/hook", "http://[::ffff:192.168.1.1]/hook"} {
+		if result := subscribe(url); result.status != http.StatusUnprocessableEntity {
+			t.Errorf("Subscribing %s answered %d, expected 422", url, result.status)
+		}
+	}
+
+	// A subscriber allowed when it subscribed is checked again when dialled, and its redirects are not followed
+	useLocalSubscribers(t)
+	target := &subscriber{}
+	targetServer := httptest.NewServer(target)
+	defer targetServer.Close()
+	redirectServer := httptest.NewServer(http.RedirectHandler(targetServer.URL, http.StatusTemporaryRedirect))
+	defer redirectServer.Close()
+	receiver := &subscriber{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhooks := [2]string{}
+	for i, url := range []string{redirectServer.URL, server.URL} {
+		result := subscribe(url)
+		if webhooks[i], _ = result.data["id"].(string); result.status != http.StatusCreated {
+			t.Fatalf("Could not subscribe %s, got %d %v", url, result.status, result.data)
+		}
+	}
+	t.Setenv("WEBHOOK_ALLOW_NETWORKS", "")
+	configs.Reload("local subscribers refused")
+
+	createFixtures(t, router, spec) // Books a session
+	ctx := context.Background()
+	jobs.RunDue(ctx, time.Now())
+	deliveries := "/admin/webhooks/:id/deliveries"
+	result := send(t, router, spec, contractCall{method: http.MethodGet, path: deliveries, target: "/admin/webhooks/" + webhooks[1] + "/deliveries", token: token})
+	delivery, _ := result.list[0].(map[string]any)
+	if len(receiver.received) != 0 || !strings.Contains(delivery["last_error"].(string), "can not go to 127.0.0.1") {
+		t.Fatalf("The delivery should not dial loopback, got %d requests and %v", len(receiver.received), delivery)
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_NETWORKS", "127.0.0.1")
+	configs.Reload("local subscribers allowed")
+	jobs.RunDue(ctx, time.Now().Add(jobs.Backoff(1)+time.Second))
+	result = send(t, router, spec, contractCall{method: http.MethodGet, path: deliveries, target: "/admin/webhooks/" + webhooks[0] + "/deliveries", token: token})
+	delivery, _ = result.list[0].(map[string]any)
+	if len(receiver.received) != 1 || len(target.received) != 0 || delivery["response_status"] != float64(http.StatusTemporaryRedirect) {
+		t.Errorf("Only the direct subscriber should get the retry and the redirect be answered as is, got %d and %d requests and %v",
+			len(receiver.received), len(target.received), delivery)
+	}
+}
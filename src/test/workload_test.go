@@ -0,0 +1,110 @@
+package main
+
+import (
+	"dainxor/atv/models"
+	"dainxor/atv/routes"
+	"dainxor/atv/workload"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func workloadSession(student models.DBID, date string, status string) models.SessionDBMongo {
+	return models.SessionCreate{
+		IDStudent:     student.Hex(),
+		IDCompanion:   bson.NewObjectID().Hex(),
+		IDSessionType: bson.NewObjectID().Hex(),
+		Status:        status,
+		Date:          date,
+	}.ToInsert(map[string]string{}).Get()
+}
+
+func TestWeekStartIsMonday(t *testing.T) {
+	sunday := time.Date(2026, 3, 15, 18, 30, 0, 0, time.UTC)
+	if start := workload.WeekStart(sunday); !start.Equal(time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Week of %v should start on Monday 9, got %v", sunday, start)
+	}
+}
+
+func TestCaseload(t *testing.T) {
+	now := time.Date(2026, 3, 11, 12, 0, 0, 0, time.Local) // Wednesday
+	active, former, cancelled := bson.NewObjectID(), bson.NewObjectID(), bson.NewObjectID()
+	sessions := []models.SessionDBMongo{
+		workloadSession(active, "2026-03-09T10:00", "Completado"),
+		workloadSession(active, "2026-03-13T10:00", "Pendiente"),
+		workloadSession(active, "2026-03-20T10:00", "Pendiente"),
+		workloadSession(former, "2025-12-01T10:00", "Completado"),
+		workloadSession(cancelled, "2026-03-12T10:00", "Cancelado"),
+	}
+
+	caseload := workload.Caseload(models.CompanionDBMongo{}, sessions, now)
+	if caseload.HoursThisWeek != 2 || caseload.SessionsThisWeek != 2 {
+		t.Errorf("Expected 2 sessions and hours this week, got %d and %v", caseload.SessionsThisWeek, caseload.HoursThisWeek)
+	}
+	if len(caseload.ActiveStudents) != 1 || caseload.ActiveStudents[0].IDStudent != active.Hex() {
+		t.Fatalf("Only the student with recent sessions should be active, got %+v", caseload.ActiveStudents)
+	}
+	if next := caseload.ActiveStudents[0].NextSession; next != "2026-03-13T10:00" {
+		t.Errorf("Next session should be the closest pending one, got %q", next)
+	}
+	if len(caseload.UpcomingSessions) != 2 || caseload.UpcomingSessions[0].Date != "2026-03-13T10:00" {
+		t.Errorf("Expected the 2 pending sessions in date order, got %+v", caseload.UpcomingSessions)
+	}
+}
+
+func TestSuggestRanksContinuityThenLoad(t *testing.T) {
+	now := time.Date(2026, 3, 11, 12, 0, 0, 0, time.Local)
+	date := time.Date(2026, 3, 12, 15, 0, 0, 0, time.Local)
+	student := bson.NewObjectID()
+
+	idle := workload.Candidate{Companion: models.CompanionDBMongo{ID: bson.NewObjectID(), LastName: "Idle"}}
+	busy := workload.Candidate{Companion: models.CompanionDBMongo{ID: bson.NewObjectID(), LastName: "Busy"}, Sessions: []models.SessionDBMongo{
+		workloadSession(bson.NewObjectID(), "2026-03-10T09:00", "Completado"),
+		workloadSession(bson.NewObjectID(), "2026-03-11T09:00", "Completado"),
+	}}
+	known := workload.Candidate{Companion: models.CompanionDBMongo{ID: bson.NewObjectID(), LastName: "Known"}, Sessions: []models.SessionDBMongo{
+		workloadSession(student, "2026-02-20T09:00", "Completado"),
+		workloadSession(bson.NewObjectID(), "2026-03-10T09:00", "Completado"),
+		workloadSession(bson.NewObjectID(), "2026-03-11T09:00", "Completado"),
+		workloadSession(bson.NewObjectID(), "2026-03-11T11:00", "Completado"),
+	}}
+	taken := workload.Candidate{Companion: models.CompanionDBMongo{ID: bson.NewObjectID(), LastName: "Taken"}, Sessions: []models.SessionDBMongo{
+		workloadSession(student, "2026-03-12T14:30", "Pendiente"),
+	}}
+
+	suggestions := workload.Suggest([]workload.Candidate{idle, busy, known, taken}, student, date, now)
+	names := []string{}
+	for _, suggestion := range suggestions {
+		names = append(names, suggestion.Companion.LastName)
+	}
+
+	expected := []string{"Known", "Idle", "Busy"} // Taken has a session overlapping the date
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] || suggestions[i].Rank != i+1 {
+			t.Fatalf("Expected %v, got %v", expected, names)
+		}
+	}
+}
+
+func TestSuggestWithoutFreeCompanionsIsEmpty(t *testing.T) {
+	useMemoryDB(t)
+	router := contractRouter()
+	spec := routes.Spec(router)
+	ids := createFixtures(t, router, spec) // The only companion has the fixture session booked
+
+	path := "/api/v1/companion/suggest"
+	result := send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: path + "?speciality=" + ids["speciality"] + "&date=2026-01-15"})
+	if result.status != http.StatusOK || result.list == nil || len(result.list) != 0 {
+		t.Errorf("Expected an empty list when nobody is free, got %d %v", result.status, result.list)
+	}
+
+	result = send(t, router, spec, contractCall{method: http.MethodGet, path: path, target: path + "?speciality=" + ids["speciality"] + "&date=2026-01-16"})
+	if result.status != http.StatusOK || len(result.list) != 1 {
+		t.Errorf("Expected the companion free the next day, got %d %v", result.status, result.list)
+	}
+}
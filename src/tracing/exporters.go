@@ -0,0 +1,299 @@
+package tracing
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_OTLP_ENDPOINT = "http://localhost:4318" // Default OTLP/HTTP collector address
+	OTLP_TRACES_PATH      = "/v1/traces"
+
+	BATCH_SIZE     = 256             // Maximum spans sent on a single export
+	BATCH_INTERVAL = 5 * time.Second // Maximum time a span waits before being exported
+	QUEUE_SIZE     = 2048            // Spans beyond this are dropped instead of blocking requests
+)
+
+// Exporter sends finished spans somewhere
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// noopExporter discards every span, trace IDs are still created so logs can be correlated
+type noopExporter struct{}
+
+func (noopExporter) Export(context.Context, []SpanData) error {
+	return nil
+}
+func (noopExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// stdoutExporter writes one JSON object per span, useful to look at traces without a collector
+type stdoutExporter struct {
+	mutex sync.Mutex
+	out   io.Writer
+}
+
+func newStdoutExporter(out io.Writer) *stdoutExporter {
+	return &stdoutExporter{out: out}
+}
+func (e *stdoutExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	encoder := json.NewEncoder(e.out)
+	for _, span := range spans {
+		err := encoder.Encode(map[string]any{
+			"trace_id":       span.TraceID.String(),
+			"span_id":        span.SpanID.String(),
+			"parent_span_id": parentID(span.ParentSpanID),
+			"name":           span.Name,
+			"kind":           span.Kind,
+			"start":          span.Start,
+			"duration_ms":    float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+			"attributes":     span.Attributes,
+			"events":         span.Events,
+			"status":         span.StatusCode,
+			"status_message": span.StatusMessage,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (e *stdoutExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// otlpExporter sends spans to an OpenTelemetry collector using OTLP/HTTP with JSON encoding
+type otlpExporter struct {
+	serviceName string
+	url         string
+	client      *http.Client
+}
+
+func otlpEndpoint() string {
+	config := settings.Get().Tracing
+	if config.TracesEndpoint != "" {
+		return config.TracesEndpoint // Signal specific endpoints are used as is
+	}
+	endpoint := cmp.Or(config.Endpoint, DEFAULT_OTLP_ENDPOINT)
+	return strings.TrimSuffix(endpoint, "/") + OTLP_TRACES_PATH
+}
+
+// NewOTLPExporter returns an exporter posting spans to the OTLP/HTTP traces url as JSON
+func NewOTLPExporter(serviceName string, url string) Exporter {
+	logger.Info("Exporting traces to", url)
+	return &otlpExporter{
+		serviceName: serviceName,
+		url:         url,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+func (e *otlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("collector answered %s", response.Status)
+	}
+	return nil
+}
+func (e *otlpExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+type otlpMap = map[string]any
+
+func (e *otlpExporter) payload(spans []SpanData) otlpMap {
+	otlpSpans := make([]otlpMap, 0, len(spans))
+
+	for _, span := range spans {
+		events := make([]otlpMap, 0, len(span.Events))
+		for _, event := range span.Events {
+			events = append(events, otlpMap{
+				"name":         event.Name,
+				"timeUnixNano": strconv.FormatInt(event.Time.UnixNano(), 10),
+				"attributes":   otlpAttributes(event.Attributes),
+			})
+		}
+
+		otlpSpan := otlpMap{
+			"traceId":           span.TraceID.String(),
+			"spanId":            span.SpanID.String(),
+			"name":              span.Name,
+			"kind":              span.Kind,
+			"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.Attributes),
+			"events":            events,
+			"status":            otlpMap{"code": span.StatusCode, "message": span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			otlpSpan["parentSpanId"] = span.ParentSpanID.String()
+		}
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+
+	return otlpMap{
+		"resourceSpans": []otlpMap{{
+			"resource": otlpMap{
+				"attributes": otlpAttributes(map[string]any{"service.name": e.serviceName}),
+			},
+			"scopeSpans": []otlpMap{{
+				"scope": otlpMap{"name": "dainxor/atv/tracing"},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+}
+func otlpAttributes(attributes map[string]any) []otlpMap {
+	result := make([]otlpMap, 0, len(attributes))
+
+	for key, value := range attributes {
+		var otlpValue otlpMap
+		switch v := value.(type) {
+		case string:
+			otlpValue = otlpMap{"stringValue": v}
+		case bool:
+			otlpValue = otlpMap{"boolValue": v}
+		case int:
+			otlpValue = otlpMap{"intValue": strconv.Itoa(v)}
+		case int64:
+			otlpValue = otlpMap{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			otlpValue = otlpMap{"doubleValue": v}
+		default:
+			otlpValue = otlpMap{"stringValue": fmt.Sprint(v)}
+		}
+		result = append(result, otlpMap{"key": key, "value": otlpValue})
+	}
+	return result
+}
+
+func parentID(id SpanID) string {
+	if !id.IsValid() {
+		return ""
+	}
+	return id.String()
+}
+
+// processor batches finished spans and exports them out of the request path
+type processor struct {
+	exporter Exporter
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newProcessor(exporter Exporter) *processor {
+	p := &processor{
+		exporter: exporter,
+		queue:    make(chan SpanData, QUEUE_SIZE),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if _, isNoop := exporter.(noopExporter); !isNoop {
+		go p.run()
+	}
+	return p
+}
+func (p *processor) enqueue(span SpanData) {
+	if _, isNoop := p.exporter.(noopExporter); isNoop {
+		return
+	}
+
+	select {
+	case p.queue <- span:
+	default:
+		logger.Warning("Span queue is full, dropping span", span.Name)
+	}
+}
+func (p *processor) run() {
+	ticker := time.NewTicker(BATCH_INTERVAL)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, BATCH_SIZE)
+
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), BATCH_INTERVAL)
+		if err := p.exporter.Export(ctx, batch); err != nil {
+			logger.Warning("Failed to export", len(batch), "spans:", err)
+		}
+		cancel()
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= BATCH_SIZE {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-p.flush:
+			for len(p.queue) > 0 {
+				batch = append(batch, <-p.queue)
+			}
+			export()
+			close(flushed)
+		case <-p.done:
+			return
+		}
+	}
+}
+func (p *processor) shutdown(ctx context.Context) error {
+	var err error
+	p.once.Do(func() {
+		if _, isNoop := p.exporter.(noopExporter); !isNoop {
+			flushed := make(chan struct{})
+			select {
+			case p.flush <- flushed:
+				select {
+				case <-flushed:
+				case <-ctx.Done():
+				}
+			case <-ctx.Done():
+			}
+			close(p.done)
+		}
+		err = p.exporter.Shutdown(ctx)
+	})
+	return err
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+)
+
+// W3C Trace Context header, see https://www.w3.org/TR/trace-context/
+const TRACEPARENT_HEADER = "traceparent"
+
+type remoteContextKey struct{}
+type remoteParent struct {
+	traceID TraceID
+	spanID  SpanID
+}
+
+// Extract reads a traceparent header value and stores it in the context,
+// so the next span started from it continues the caller's trace.
+// Invalid or empty values are ignored.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ctx
+	}
+
+	var remote remoteParent
+	traceID, errTrace := hex.DecodeString(parts[1])
+	spanID, errSpan := hex.DecodeString(parts[2])
+	if errTrace != nil || errSpan != nil || len(traceID) != len(remote.traceID) || len(spanID) != len(remote.spanID) {
+		return ctx
+	}
+
+	copy(remote.traceID[:], traceID)
+	copy(remote.spanID[:], spanID)
+	if !remote.traceID.IsValid() || !remote.spanID.IsValid() {
+		return ctx
+	}
+
+	return context.WithValue(ctx, remoteContextKey{}, remote)
+}
+
+// Traceparent formats the span as a traceparent header value, or "" for a nil span
+func Traceparent(span *Span) string {
+	if span == nil {
+		return ""
+	}
+	return "00-" + span.TraceID().String() + "-" + span.SpanID().String() + "-01"
+}
@@ -0,0 +1,285 @@
+package tracing
+
+import (
+	"cmp"
+	"context"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/logger"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_SERVICE_NAME = "atv-backend"
+	DEFAULT_EXPORTER     = EXPORTER_NONE
+
+	EXPORTER_NONE   = "none"
+	EXPORTER_STDOUT = "stdout"
+	EXPORTER_OTLP   = "otlp"
+)
+
+type SpanKind int
+
+// Values follow the OTLP SpanKind enum
+const (
+	KIND_INTERNAL SpanKind = 1
+	KIND_SERVER   SpanKind = 2
+	KIND_CLIENT   SpanKind = 3
+)
+
+type StatusCode int
+
+// Values follow the OTLP StatusCode enum
+const (
+	STATUS_UNSET StatusCode = 0
+	STATUS_OK    StatusCode = 1
+	STATUS_ERROR StatusCode = 2
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+type SpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]any
+}
+
+// SpanData is the immutable copy of a finished span handed to the exporters
+type SpanData struct {
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	Name          string
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]any
+	Events        []SpanEvent
+	StatusCode    StatusCode
+	StatusMessage string
+}
+
+// Span is a single timed operation of a trace.
+// A nil span is valid and ignores every call, so callers never need to check.
+type Span struct {
+	mutex sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.data.TraceID
+}
+func (s *Span) SpanID() SpanID {
+	if s == nil {
+		return SpanID{}
+	}
+	return s.data.SpanID
+}
+func (s *Span) ParentSpanID() SpanID {
+	if s == nil {
+		return SpanID{}
+	}
+	return s.data.ParentSpanID
+}
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.Attributes[key] = value
+}
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.StatusCode = code
+	s.data.StatusMessage = message
+}
+
+// RecordError adds an exception event to the span and marks it as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.data.Events = append(s.data.Events, SpanEvent{
+		Name: "exception",
+		Time: time.Now(),
+		Attributes: map[string]any{
+			"exception.type":    fmt.Sprintf("%T", err),
+			"exception.message": err.Error(),
+		},
+	})
+	s.mutex.Unlock()
+	s.SetStatus(STATUS_ERROR, err.Error())
+}
+
+// End finishes the span and queues it for export, calling it more than once does nothing
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mutex.Unlock()
+
+	tracer.processor.enqueue(data)
+}
+
+type spanContextKey struct{}
+
+// FromContext returns the span stored in the context, or nil if there is none
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// TraceIDFrom returns the hex trace ID of the span in the context, or "" if there is none
+func TraceIDFrom(ctx context.Context) string {
+	span := FromContext(ctx)
+	if span == nil {
+		if remote, ok := ctx.Value(remoteContextKey{}).(remoteParent); ok {
+			return remote.traceID.String()
+		}
+		return ""
+	}
+	return span.TraceID().String()
+}
+
+// Start creates an internal span as child of the span in ctx
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return StartKind(ctx, name, KIND_INTERNAL)
+}
+
+// StartKind creates a span of the given kind as child of the span in ctx.
+// When ctx has no span but carries a remote parent (see Extract), that parent is used.
+func StartKind(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	span := &Span{
+		data: SpanData{
+			SpanID:     newSpanID(),
+			Name:       name,
+			Kind:       kind,
+			Start:      time.Now(),
+			Attributes: map[string]any{},
+		},
+	}
+
+	if parent := FromContext(ctx); parent != nil {
+		span.data.TraceID = parent.TraceID()
+		span.data.ParentSpanID = parent.SpanID()
+	} else if remote, ok := ctx.Value(remoteContextKey{}).(remoteParent); ok {
+		span.data.TraceID = remote.traceID
+		span.data.ParentSpanID = remote.spanID
+	} else {
+		span.data.TraceID = newTraceID()
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		putUint64(id[:8], rand.Uint64())
+		putUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		putUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+func putUint64(b []byte, v uint64) {
+	for i := range 8 {
+		b[i] = byte(v >> (56 - 8*i))
+	}
+}
+
+type tracerType struct {
+	serviceName  string
+	exporterName string
+	processor    *processor
+}
+
+var tracer tracerType
+
+func init() {
+	envInit()
+	logger.SetTraceIDExtractor(TraceIDFrom)
+}
+func envInit() {
+	config := settings.Get().Tracing
+	tracer.serviceName = cmp.Or(config.ServiceName, DEFAULT_SERVICE_NAME)
+	tracer.exporterName = strings.ToLower(cmp.Or(config.Exporter, DEFAULT_EXPORTER))
+
+	var exporter Exporter
+	switch tracer.exporterName {
+	case EXPORTER_NONE:
+		exporter = noopExporter{}
+	case EXPORTER_STDOUT:
+		exporter = newStdoutExporter(os.Stdout)
+	case EXPORTER_OTLP:
+		exporter = NewOTLPExporter(tracer.serviceName, otlpEndpoint())
+	default:
+		logger.Warning("Unknown OTEL_TRACES_EXPORTER", tracer.exporterName, "using default:", DEFAULT_EXPORTER)
+		tracer.exporterName = DEFAULT_EXPORTER
+		exporter = noopExporter{}
+	}
+
+	tracer.processor = newProcessor(exporter)
+	logger.Info("Tracing initialized with exporter:", tracer.exporterName)
+}
+
+func ServiceName() string {
+	return tracer.serviceName
+}
+func ExporterName() string {
+	return tracer.exporterName
+}
+
+// Shutdown flushes the pending spans and stops the exporter
+func Shutdown(ctx context.Context) error {
+	return tracer.processor.shutdown(ctx)
+}
@@ -49,3 +49,11 @@ func ErrorNotFound(information ...string) HttpError {
 func ErrorInternal(information ...string) HttpError {
 	return Error(Http.C500().InternalServerError(), information...)
 }
+
+func ErrorGatewayTimeout(information ...string) HttpError {
+	return Error(Http.C500().GatewayTimeout(), information...)
+}
+
+func ErrorServiceUnavailable(information ...string) HttpError {
+	return Error(Http.C500().ServiceUnavailable(), information...)
+}
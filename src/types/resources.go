@@ -1,6 +1,9 @@
 package types
 
-import "strings"
+import (
+	"path"
+	"strings"
+)
 
 type ResourceInfo struct {
 	code      int
@@ -39,3 +42,43 @@ func (ResourceTypes) Video(ext string) ResourceInfo {
 func (ResourceTypes) Audio(ext string) ResourceInfo {
 	return createResourceType(5, ext)
 }
+
+// Name returns the kind of the resource, "file" for unknown codes
+func (r *ResourceInfo) Name() string {
+	switch r.code {
+	case 1:
+		return "text"
+	case 2:
+		return "image"
+	case 4:
+		return "video"
+	case 5:
+		return "audio"
+	}
+	return "file"
+}
+
+// FromContentType returns the resource kind of a MIME type, keeping the extension of filename
+func (ResourceTypes) FromContentType(contentType string, filename string) ResourceInfo {
+	extension := strings.ToLower(strings.TrimPrefix(path.Ext(filename), "."))
+	kind, _, _ := strings.Cut(contentType, "/")
+	switch kind {
+	case "text":
+		if strings.HasPrefix(contentType, "text/plain") {
+			return Resource.Text()
+		}
+		return Resource.File(extension)
+	case "image":
+		return Resource.Image(extension)
+	case "video":
+		return Resource.Video(extension)
+	case "audio":
+		return Resource.Audio(extension)
+	}
+	return Resource.File(extension)
+}
+
+// FromCode rebuilds a stored resource kind
+func (ResourceTypes) FromCode(code int, extension string) ResourceInfo {
+	return createResourceType(code, extension)
+}
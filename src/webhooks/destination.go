@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"context"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/types"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Networks that are global unicast but still not on the internet
+var reservedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// client sends the deliveries. It only dials public addresses, checked after the name is resolved so
+// a DNS answer can not swap one in, and answers redirects as they are instead of following them.
+var client = newClient()
+
+func newClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // A proxy would dial the subscriber past the check
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, Control: dialControl}).DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Allowed reports whether deliveries may go to the address: public ones, or any on WEBHOOK_ALLOW_NETWORKS.
+// Loopback, private, link-local (cloud metadata among them), multicast and reserved addresses are refused.
+func Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, network := range settings.Get().Webhooks.AllowNetworks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			single, _ := netip.ParseAddr(network)
+			prefix = netip.PrefixFrom(single, single.BitLen())
+		}
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, reserved := range reservedNetworks {
+		if reserved.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckDestination refuses a subscription URL whose host is or resolves to an address deliveries may not go to.
+// URLs that do not parse are left to the subscription validation.
+func CheckDestination(ctx context.Context, raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil || target.Hostname() == "" {
+		return nil
+	}
+
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(target.Hostname()); err == nil {
+		addrs = append(addrs, addr)
+	} else if addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", target.Hostname()); err != nil {
+		httpErr := types.Error(types.Http.C400().UnprocessableEntity(), "Invalid value", "Invalid webhook URL", "The host "+target.Hostname()+" does not resolve")
+		return &httpErr
+	}
+
+	for _, addr := range addrs {
+		if !Allowed(addr) {
+			httpErr := types.Error(types.Http.C400().UnprocessableEntity(), "Invalid value", "Invalid webhook URL", "Deliveries can not go to "+addr.String()+", expected a public address")
+			return &httpErr
+		}
+	}
+	return nil
+}
+
+// dialControl stops a delivery connecting to an address that is not Allowed
+func dialControl(network string, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !Allowed(addr.Addr()) {
+		return fmt.Errorf("deliveries can not go to %s", addr.Addr())
+	}
+	return nil
+}
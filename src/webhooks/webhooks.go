@@ -0,0 +1,202 @@
+// Package webhooks tells outside systems, like the university ones, about session changes.
+// A subscription belongs to a tenant and picks the event types it wants of the tenant's sessions.
+// Each matching event becomes a delivery: a POST of the event as JSON to the subscription URL,
+// signed with its secret. Deliveries are sent on background jobs, so a subscriber that fails or
+// does not answer within WEBHOOK_TIMEOUT is retried with the job backoff. Every delivery is kept
+// as a log and can be replayed. Deliveries only go to public addresses, see Allowed, and
+// redirects are not followed.
+//
+// Receivers check the X-ATV-Signature header, "sha256=" followed by the hex HMAC-SHA256 of
+// the X-ATV-Timestamp header, a dot and the raw body, keyed with the subscription secret.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"dainxor/atv/configs/settings"
+	"dainxor/atv/db"
+	"dainxor/atv/jobs"
+	"dainxor/atv/logger"
+	"dainxor/atv/models"
+	"dainxor/atv/tenant"
+	"dainxor/atv/tracing"
+	"dainxor/atv/types"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DELIVERY_JOB = "webhooks.deliver" // Kind of the jobs sending a delivery
+
+	HEADER_EVENT     = "X-ATV-Event"
+	HEADER_DELIVERY  = "X-ATV-Delivery"
+	HEADER_TIMESTAMP = "X-ATV-Timestamp" // Unix seconds, part of the signed content
+	HEADER_SIGNATURE = "X-ATV-Signature"
+	SIGNATURE_PREFIX = "sha256="
+
+	MAX_RESPONSE_READ = 64 << 10 // Bytes of a subscriber answer read before closing it
+)
+
+func init() {
+	db.Session.Listen(emit)
+	jobs.Register(DELIVERY_JOB, deliver)
+}
+
+// deliveryJob is the payload of a DELIVERY_JOB
+type deliveryJob struct {
+	Delivery string `json:"delivery"`
+}
+
+// emit queues a delivery of the event to every subscription of the session's tenant listening to it
+func emit(ctx context.Context, event models.SessionEvent) {
+	ctx, span := tracing.Start(tenant.With(ctx, event.Session.IDUniversity), "webhooks.emit")
+	defer span.End()
+
+	webhooks := db.Webhook.GetAllByEvent(ctx, event.Type)
+	if webhooks.IsErr() {
+		logger.WithContext(ctx).Error("Failed to read the webhooks of", event.Type, ":", webhooks.Error())
+		return
+	}
+	if len(webhooks.Value()) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event.ToResponse())
+	if err != nil {
+		logger.WithContext(ctx).Error("Failed to encode the", event.Type, "event:", err)
+		return
+	}
+	for _, webhook := range webhooks.Value() {
+		if result := queue(ctx, models.NewDelivery(webhook.ID, event.Type, string(payload))); result.IsErr() {
+			logger.WithContext(ctx).Error("Failed to queue", event.Type, "for webhook", webhook.ID.Hex(), ":", result.Error())
+		}
+	}
+}
+
+// queue records the delivery and sends it on a job
+func queue(ctx context.Context, delivery models.WebhookDeliveryDBMongo) types.Result[models.WebhookDeliveryDBMongo] {
+	created := db.Webhook.CreateDelivery(ctx, delivery)
+	if created.IsErr() {
+		return created
+	}
+	if _, err := jobs.Enqueue(ctx, DELIVERY_JOB, deliveryJob{Delivery: created.Value().ID.Hex()}); err != nil {
+		httpErr := types.ErrorInternal("Failed to queue webhook delivery", err.Error())
+		return types.ResultErr[models.WebhookDeliveryDBMongo](&httpErr)
+	}
+	return created
+}
+
+// Replay sends a recorded delivery again, as a new delivery of the same payload
+func Replay(ctx context.Context, id string) types.Result[models.WebhookDeliveryDBMongo] {
+	ctx, span := tracing.Start(ctx, "webhooks.Replay")
+	defer span.End()
+
+	delivery := db.Webhook.GetDeliveryByID(ctx, id)
+	if delivery.IsErr() {
+		return delivery
+	}
+	return queue(ctx, delivery.Value().Replay())
+}
+
+// NewSecret returns a random secret for a subscription that did not bring one
+func NewSecret() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
+
+// Sign returns the X-ATV-Signature of the body sent at timestamp
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return SIGNATURE_PREFIX + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver sends the delivery of the job, failing while the subscriber does not take it so the job is retried
+func deliver(ctx context.Context, job models.JobDBMongo) error {
+	var payload deliveryJob
+	if err := jobs.Decode(job, &payload); err != nil {
+		return err
+	}
+	found := db.Webhook.GetDeliveryByID(ctx, payload.Delivery)
+	if found.IsErr() {
+		return found.Error()
+	}
+	delivery := found.Value()
+
+	webhook := db.Webhook.GetByID(ctx, delivery.IDWebhook.Hex())
+	var status int
+	var err error
+	switch {
+	case webhook.IsOk():
+		status, err = post(ctx, webhook.Value(), delivery)
+	case webhook.Error().(*types.HttpError).Code == http.StatusNotFound:
+		// Unsubscribed meanwhile, nothing left to retry
+		delivery.Status = models.DELIVERY_FAILED
+		delivery.LastError = "the webhook was deleted"
+		delivery.UpdatedAt = models.Time.Now()
+		db.Webhook.SaveDelivery(ctx, delivery)
+		return nil
+	default:
+		return webhook.Error()
+	}
+
+	now := models.Time.Now()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.UpdatedAt = now
+	if err == nil {
+		delivery.Status = models.DELIVERY_DELIVERED
+		delivery.LastError = ""
+		delivery.DeliveredAt = now
+	} else {
+		delivery.LastError = err.Error()
+		if job.Attempts >= job.MaxAttempts {
+			delivery.Status = models.DELIVERY_FAILED
+		}
+	}
+	if saved := db.Webhook.SaveDelivery(ctx, delivery); saved.IsErr() {
+		logger.WithContext(ctx).Error("Failed to log webhook delivery", delivery.ID.Hex(), ":", saved.Error())
+	}
+	return err
+}
+
+// post sends the delivery to the subscriber, returning the status it answered
+func post(ctx context.Context, webhook models.WebhookDBMongo, delivery models.WebhookDeliveryDBMongo) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, settings.Get().Webhooks.Timeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "ATV-Webhooks")
+	request.Header.Set(HEADER_EVENT, delivery.Event)
+	request.Header.Set(HEADER_DELIVERY, delivery.ID.Hex())
+	request.Header.Set(HEADER_TIMESTAMP, timestamp)
+	request.Header.Set(HEADER_SIGNATURE, Sign(webhook.Secret, timestamp, body))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, MAX_RESPONSE_READ))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("the subscriber answered %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
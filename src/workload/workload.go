@@ -0,0 +1,199 @@
+// Package workload measures how busy the companions are from their sessions,
+// to show their caseload and to suggest who should take a new session.
+package workload
+
+import (
+	"cmp"
+	"dainxor/atv/models"
+	"slices"
+	"time"
+)
+
+const (
+	DEFAULT_SESSION_DURATION = time.Hour           // Sessions carry no duration, each one counts as this long
+	DEFAULT_ACTIVE_WINDOW    = 30 * 24 * time.Hour // A student stays active this long after their last session
+)
+
+// Load is how busy a companion is on a given week
+type Load struct {
+	Hours          float64
+	Sessions       int
+	ActiveStudents int
+}
+
+// takesTime reports whether the session uses the companion's time, cancelled ones do not
+func takesTime(session models.SessionDBMongo) bool {
+	return !session.Is(models.STATUS_CANCELLED)
+}
+
+// WeekStart returns Monday at midnight of the week of t, in the location of t
+func WeekStart(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	year, month, day := t.Date()
+	return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// LoadOn measures the sessions of a companion on the week of date,
+// active students are counted at now
+func LoadOn(sessions []models.SessionDBMongo, date time.Time, now time.Time) Load {
+	return loadOn(sessions, date, len(activeStudents(sessions, now)))
+}
+
+// loadOn measures the sessions of a companion on the week of date, with the active students already counted
+func loadOn(sessions []models.SessionDBMongo, date time.Time, active int) Load {
+	start := WeekStart(date)
+	end := start.AddDate(0, 0, 7)
+
+	load := Load{ActiveStudents: active}
+	for _, session := range sessions {
+		when, ok := session.When()
+		if ok && takesTime(session) && !when.Before(start) && when.Before(end) {
+			load.Sessions++
+		}
+	}
+	load.Hours = float64(load.Sessions) * DEFAULT_SESSION_DURATION.Hours()
+	return load
+}
+
+// Available reports whether no session of the companion overlaps one starting at date
+func Available(sessions []models.SessionDBMongo, date time.Time) bool {
+	for _, session := range sessions {
+		when, ok := session.When()
+		if !ok || !takesTime(session) {
+			continue
+		}
+		if date.Before(when.Add(DEFAULT_SESSION_DURATION)) && when.Before(date.Add(DEFAULT_SESSION_DURATION)) {
+			return false
+		}
+	}
+	return true
+}
+
+// activeStudents groups the sessions by student, keeping the students with
+// a pending session ahead or a session within DEFAULT_ACTIVE_WINDOW
+func activeStudents(sessions []models.SessionDBMongo, now time.Time) []models.CaseloadStudent {
+	type studentLoad struct {
+		student models.CaseloadStudent
+		last    time.Time
+		next    time.Time
+	}
+	byStudent := map[models.DBID]*studentLoad{}
+	order := []models.DBID{}
+
+	for _, session := range sessions {
+		if !takesTime(session) {
+			continue
+		}
+		load, exist := byStudent[session.IDStudent]
+		if !exist {
+			load = &studentLoad{student: models.CaseloadStudent{
+				IDStudent: session.IDStudent.Hex(),
+				FirstName: session.StudentName,
+				LastName:  session.StudentSurname,
+			}}
+			byStudent[session.IDStudent] = load
+			order = append(order, session.IDStudent)
+		}
+		load.student.Sessions++
+
+		when, ok := session.When()
+		switch {
+		case !ok:
+		case when.Before(now) && when.After(load.last):
+			load.last = when
+			load.student.LastSession = session.Date
+		case !when.Before(now) && session.Is(models.STATUS_PENDING) && (load.next.IsZero() || when.Before(load.next)):
+			load.next = when
+			load.student.NextSession = session.Date
+		}
+	}
+
+	active := []models.CaseloadStudent{}
+	for _, id := range order {
+		load := byStudent[id]
+		if !load.next.IsZero() || !load.last.IsZero() && now.Sub(load.last) <= DEFAULT_ACTIVE_WINDOW {
+			active = append(active, load.student)
+		}
+	}
+	return active
+}
+
+// Caseload describes the current work of a companion from their sessions
+func Caseload(companion models.CompanionDBMongo, sessions []models.SessionDBMongo, now time.Time) models.CaseloadResponse {
+	students := activeStudents(sessions, now)
+	load := loadOn(sessions, now, len(students))
+
+	upcoming := []models.SessionDBMongo{}
+	for _, session := range sessions {
+		if when, ok := session.When(); ok && !when.Before(now) && session.Is(models.STATUS_PENDING) {
+			upcoming = append(upcoming, session)
+		}
+	}
+	slices.SortStableFunc(upcoming, func(a models.SessionDBMongo, b models.SessionDBMongo) int {
+		whenA, _ := a.When()
+		whenB, _ := b.When()
+		return whenA.Compare(whenB)
+	})
+
+	upcomingResponses := make([]models.SessionResponse, 0, len(upcoming))
+	for _, session := range upcoming {
+		upcomingResponses = append(upcomingResponses, session.ToResponse())
+	}
+
+	return models.CaseloadResponse{
+		IDCompanion:      companion.ID.Hex(),
+		FirstName:        companion.FirstName,
+		LastName:         companion.LastName,
+		WeekStart:        WeekStart(now),
+		HoursThisWeek:    load.Hours,
+		SessionsThisWeek: load.Sessions,
+		ActiveStudents:   students,
+		UpcomingSessions: upcomingResponses,
+	}
+}
+
+// Candidate is a companion that could take a session, with their sessions
+type Candidate struct {
+	Companion models.CompanionDBMongo
+	Sessions  []models.SessionDBMongo
+}
+
+// Suggest ranks the candidates free at date. Companions who already worked with
+// the student come first, to keep continuity, then the least loaded on that week.
+// student may be the zero ID when the session has no student yet.
+func Suggest(candidates []Candidate, student models.DBID, date time.Time, now time.Time) []models.CompanionSuggestion {
+	suggestions := []models.CompanionSuggestion{}
+	for _, candidate := range candidates {
+		if !Available(candidate.Sessions, date) {
+			continue
+		}
+
+		load := LoadOn(candidate.Sessions, date, now)
+		withStudent := 0
+		for _, session := range candidate.Sessions {
+			if !student.IsZero() && session.IDStudent == student && takesTime(session) {
+				withStudent++
+			}
+		}
+
+		suggestions = append(suggestions, models.CompanionSuggestion{
+			Companion:           candidate.Companion.ToResponse(),
+			HoursThatWeek:       load.Hours,
+			ActiveStudents:      load.ActiveStudents,
+			SessionsWithStudent: withStudent,
+		})
+	}
+
+	slices.SortStableFunc(suggestions, func(a models.CompanionSuggestion, b models.CompanionSuggestion) int {
+		return cmp.Or(
+			cmp.Compare(b.SessionsWithStudent, a.SessionsWithStudent),
+			cmp.Compare(a.HoursThatWeek, b.HoursThatWeek),
+			cmp.Compare(a.ActiveStudents, b.ActiveStudents),
+			cmp.Compare(a.Companion.LastName+" "+a.Companion.FirstName, b.Companion.LastName+" "+b.Companion.FirstName),
+		)
+	})
+	for i := range suggestions {
+		suggestions[i].Rank = i + 1
+	}
+	return suggestions
+}